@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"OrderUserProject/internal/address"
+	"OrderUserProject/internal/apps/order-api"
+	"OrderUserProject/internal/configs"
+	"OrderUserProject/internal/events"
+	"OrderUserProject/internal/models"
+	"OrderUserProject/internal/repository"
+	"OrderUserProject/pkg/kafka"
+	"github.com/sirupsen/logrus"
+	"os"
+	"time"
+)
+
+// StartAddressMigration => one time job, addresses of existing users and orders are normalized with the address
+// normalizer of user-api. Set 'dryRun=true' to only see the counts.
+func StartAddressMigration() {
+	// Logger instead of standard log we use 'logrus' package
+	logger := logrus.StandardLogger()
+	logger.SetOutput(os.Stdout)
+	logger.SetLevel(logrus.InfoLevel)
+	logger.SetFormatter(&logrus.JSONFormatter{TimestampFormat: time.RFC3339})
+	logger.Info("Logger enabled!!")
+
+	// Environment value
+	env := os.Getenv("environment")
+	dryRun := os.Getenv("dryRun") == "true"
+
+	// Get config
+	config := configs.GetConfig(env)
+
+	normalizer, err := address.NewNormalizer(config.Address.DatasetPath)
+	if err != nil {
+		logger.Fatalf("Address normalizer cannot create: %v", err)
+	}
+
+	// Connection with mongoDB and create collections
+	database := configs.
+		ConnectDB(config.Database.Connection).
+		Database(config.Database.DatabaseName)
+
+	// Updated orders are sent to order-elastic, so elasticsearch duplicate has the normalized address too
+	producer := kafka.NewProducerKafka(config.Kafka.Address)
+	orderIDSerializer, err := events.NewSerializer(config.Kafka.Serialization["OrderID"], events.OrderChangedType)
+	if err != nil {
+		logger.Fatalf("Kafka serializer cannot create: %v", err)
+	}
+	producer.SetTopicSerializer(config.Kafka.TopicName["OrderID"], orderIDSerializer)
+
+	migration := address.Migration{
+		Normalizer: normalizer,
+		Users:      repository.NewUserRepository(database.Collection(config.Database.UserCollectionName)),
+		Orders:     repository.NewOrderRepository(database.Collection(config.Database.OrderCollectionName)),
+		DryRun:     dryRun,
+		OrderUpdated: func(order models.Order) {
+			envelope, err := order_api.NewOrderChangedEnvelope(order.ID, "Updated", &order, config.Kafka.OrderEventMode)
+			if err != nil {
+				logger.Errorf("Something went wrong convert to event: %v", err)
+				return
+			}
+			if err := producer.SendToKafkaWithValue(envelope, config.Kafka.TopicName["OrderID"]); err != nil {
+				logger.Errorf("Order (%v) cannot pushed: %v", order.ID, err)
+			}
+		},
+	}
+
+	logger.Infof("Address migration is starting (dry run: %v)...", dryRun)
+	result, err := migration.Run()
+	for _, rejected := range result.Rejected {
+		logger.Warnf("Address is not normalized: %v", rejected)
+	}
+	if err != nil {
+		logger.Fatalf("Address migration failed: %v", err)
+	}
+
+	logger.Infof("Address migration is done. Users: %v, orders: %v, rejected addresses: %v", result.Users, result.Orders, len(result.Rejected))
+}
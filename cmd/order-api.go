@@ -3,11 +3,15 @@ package cmd
 import (
 	docs "OrderUserProject/docs/order"
 	"OrderUserProject/internal/apps/order-api"
+	"OrderUserProject/internal/apps/order-api/graphQL"
 	"OrderUserProject/internal/apps/order-api/handler"
+	"OrderUserProject/internal/apps/order-api/roots"
 	"OrderUserProject/internal/configs"
+	"OrderUserProject/internal/events"
 	"OrderUserProject/internal/repository"
 	"OrderUserProject/pkg"
 	"OrderUserProject/pkg/kafka"
+	"OrderUserProject/pkg/money"
 	"fmt"
 	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
@@ -41,6 +45,8 @@ func StartOrderAPI() {
 
 	// Validator instance
 	v := validator.New()
+	// Money fields of promotions are validated with their minor units (e.g. "gte=0" of amount)
+	money.RegisterValidation(v)
 
 	// Logger instead of echo.log we use 'logrus' package
 	log.Logger().SetOutput(os.Stdout)
@@ -58,24 +64,107 @@ func StartOrderAPI() {
 
 	// Create Kafka producer
 	producer := kafka.NewProducerKafka(config.Kafka.Address)
-
-	// Connection with mongoDB and create collection
-	mongoOrderCollection := configs.
+	orderIDSerializer, err := events.NewSerializer(config.Kafka.Serialization["OrderID"], events.OrderChangedType)
+	if err != nil {
+		log.Fatalf("Kafka serializer cannot create: %v", err)
+	}
+	producer.SetTopicSerializer(config.Kafka.TopicName["OrderID"], orderIDSerializer)
+	// 'OrderEvents' topic carries several event types, there is no avro schema for it
+	orderEventsSerializer, err := kafka.NewSerializer(config.Kafka.Serialization["OrderEvents"], "")
+	if err != nil {
+		log.Fatalf("Kafka serializer cannot create: %v", err)
+	}
+	producer.SetTopicSerializer(config.Kafka.TopicName["OrderEvents"], orderEventsSerializer)
+	// 'InventoryCommands' topic carries several command types like 'OrderEvents'
+	inventoryCommandsSerializer, err := kafka.NewSerializer(config.Kafka.Serialization["InventoryCommands"], "")
+	if err != nil {
+		log.Fatalf("Kafka serializer cannot create: %v", err)
+	}
+	producer.SetTopicSerializer(config.Kafka.TopicName["InventoryCommands"], inventoryCommandsSerializer)
+
+	// Connection with mongoDB and create collections
+	mongoDatabase := configs.
 		ConnectDB(config.Database.Connection).
-		Database(config.Database.DatabaseName).
-		Collection(config.Database.OrderCollectionName)
+		Database(config.Database.DatabaseName)
+	mongoOrderCollection := mongoDatabase.Collection(config.Database.OrderCollectionName)
+	mongoSagaCollection := mongoDatabase.Collection(config.Database.SagaCollectionName)
+	mongoPromotionCollection := mongoDatabase.Collection(config.Database.PromotionCollectionName)
+	mongoInvoiceCollection := mongoDatabase.Collection(config.Database.InvoiceCollectionName)
+	mongoCounterCollection := mongoDatabase.Collection(config.Database.CounterCollectionName)
 
 	// Create repo and services (Singleton)
 	OrderRepository := repository.NewOrderRepository(mongoOrderCollection)
-	OrderService := order_api.NewOrderService(OrderRepository)
+	PromotionRepository := repository.NewPromotionRepository(mongoPromotionCollection)
+	PromotionService := order_api.NewPromotionService(PromotionRepository)
+	OrderService := order_api.NewOrderService(OrderRepository, PromotionService)
 	ElasticService := order_api.NewElasticService(&config)
+	UserService := order_api.NewUserService(config.HttpClient.UserAPI)
+	// Changed orders of GraphQL subscriptions
+	OrderUpdates := graphQL.NewOrderUpdates()
+	// Last order changes of order stream
+	OrderChanges := order_api.NewOrderChangeLog(config.Stream.ChangeLogSize)
+	SagaRepository := repository.NewInventorySagaRepository(mongoSagaCollection)
+	SagaService := order_api.NewInventorySagaService(SagaRepository, OrderRepository, PromotionService, func(command events.DomainEvent) error {
+		// => SEND MESSAGE (InventoryCommands) => order id is the key, so product-api handles commands of an order in order
+		envelope, err := command.Envelope()
+		if err != nil {
+			return err
+		}
+		return producer.SendToKafkaWithKey(command.Key, envelope, config.Kafka.TopicName["InventoryCommands"])
+	})
+
+	// Rendered invoices are kept in GridFS or in a directory
+	InvoiceStorage := order_api.NewFileSystemInvoiceStorage(config.Invoice.Directory)
+	if config.Invoice.Storage == "gridfs" {
+		InvoiceStorage, err = order_api.NewGridFSInvoiceStorage(mongoDatabase, config.Invoice.Bucket)
+		if err != nil {
+			log.Fatalf("Invoice storage cannot create: %v", err)
+		}
+	}
+	InvoiceRepository := repository.NewInvoiceRepository(mongoInvoiceCollection, mongoCounterCollection)
+	InvoiceService := order_api.NewInvoiceService(InvoiceRepository, InvoiceStorage, config.Invoice.NumberPrefix, config.Invoice.BillableStatuses, order_api.InvoiceSeller{
+		Name:      config.Invoice.SellerName,
+		Address:   config.Invoice.SellerAddress,
+		TaxNumber: config.Invoice.SellerTaxNumber,
+	})
 
 	// Check ram address
 	fmt.Printf("%s%p\n", "Order Repository(order-api.go):", OrderRepository)
 	fmt.Printf("%s%p\n", "Order Service(order-api.go):", OrderService)
 
 	// Create handler
-	handler.NewOrderHandler(e, OrderService, producer, &config, v, ElasticService)
+	handler.NewOrderHandler(e, OrderService, UserService, OrderUpdates, OrderChanges, SagaService, InvoiceService, producer, &config, v, ElasticService)
+	handler.NewPromotionHandler(e, PromotionService, &config, v)
+
+	// Consume user events => address changes are applied to open orders
+	userEventConsumer := kafka.NewConsumerKafkaWithGroup(config.Kafka.Address, "order-api")
+	userEventRoot := roots.NewUserEventRoot(OrderService, SagaService, userEventConsumer, producer, &config, e.Logger)
+	go userEventRoot.StartConsumeUserEvents()
+
+	// GraphQL subscriptions and order stream => every instance consumes changed orders of 'OrderID' topic
+	orderChangeConsumer := kafka.NewBroadcastConsumerKafka(config.Kafka.Address, "order-api-subscriptions")
+	orderChangeConsumer.RegisterSerializer(orderIDSerializer)
+	orderChangeRoot := roots.NewOrderChangeRoot(OrderService, OrderUpdates, OrderChanges, orderChangeConsumer, &config, e.Logger)
+	go orderChangeRoot.StartConsumeOrderChanges()
+
+	// Inventory saga => consume replies of product-api and send commands of waiting sagas again
+	if config.Inventory.Enabled {
+		inventoryEventConsumer := kafka.NewConsumerKafkaWithGroup(config.Kafka.Address, "order-api-inventory")
+		inventoryEventRoot := roots.NewInventoryEventRoot(SagaService, inventoryEventConsumer, producer, &config, e.Logger)
+		go inventoryEventRoot.StartConsumeInventoryEvents()
+		go pkg.StartPeriodicJob("retry inventory sagas", config.Inventory.RetryInterval, inventoryEventRoot.RetryWaitingSagas)
+	}
+
+	// Purge soft deleted orders after retention period
+	if config.SoftDelete.Enabled {
+		go pkg.StartPeriodicJob("purge deleted orders", config.SoftDelete.PurgeInterval, func() error {
+			purged, err := OrderService.PurgeDeleted(config.SoftDelete.Retention)
+			if err == nil && purged > 0 {
+				e.Logger.Infof("%v soft deleted orders are purged.", purged)
+			}
+			return err
+		})
+	}
 
 	// If we don't use this swagger give an error
 	docs.SwaggerInfoorderAPI.Host = "localhost:30011"
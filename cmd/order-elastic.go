@@ -4,6 +4,8 @@ import (
 	"OrderUserProject/internal/apps/order-elastic"
 	"OrderUserProject/internal/apps/order-elastic/roots"
 	"OrderUserProject/internal/configs"
+	"OrderUserProject/internal/events"
+	"OrderUserProject/internal/repository"
 	"OrderUserProject/pkg/kafka"
 	"github.com/sirupsen/logrus"
 	"os"
@@ -24,16 +26,52 @@ func StartOrderElastic() {
 	// Get config
 	config := configs.GetConfig(env)
 
+	// Avro serializers => consumers read json by default, avro needs schema of topic
+	orderChangedAvro, err := events.NewAvroSerializer(events.OrderChangedType)
+	if err != nil {
+		logger.Fatalf("Avro serializer cannot create: %v", err)
+	}
+	orderSnapshotAvro, err := events.NewAvroSerializer(events.OrderSnapshotType)
+	if err != nil {
+		logger.Fatalf("Avro serializer cannot create: %v", err)
+	}
+	orderModelSerializer, err := events.NewSerializer(config.Kafka.Serialization["OrderModel"], events.OrderSnapshotType)
+	if err != nil {
+		logger.Fatalf("Kafka serializer cannot create: %v", err)
+	}
+
 	// Create OrderElasticRoot => Consume orderModel, save on elastic search
 	orderElasticService := order_elastic.NewOrderElasticService()
+	// Amounts of orders are mapped as scaled values before the first order is saved
+	if err := orderElasticService.EnsureOrderIndex(config); err != nil {
+		logger.Errorf("Order index cannot prepare: %v", err)
+	}
+
+	// Change stream source => orders collection is tailed instead of Kafka topics
+	if config.OrderSync.Source == order_elastic.SyncSourceChangeStream {
+		mongoDatabase := configs.
+			ConnectDB(config.Database.Connection).
+			Database(config.Database.DatabaseName)
+		changeStreamRepository := repository.NewOrderChangeStreamRepository(
+			mongoDatabase.Collection(config.Database.OrderCollectionName),
+			mongoDatabase.Collection(config.Database.ResumeTokenCollectionName))
+		orderChangeStreamRoot := roots.NewOrderChangeStreamRoot(orderElasticService, changeStreamRepository, &config, logger)
+
+		logger.Info("Order Elastic Service is starting with change stream source...")
+		roots.NewOrderChangeStreamSyncService(orderChangeStreamRoot).Start()
+		return
+	}
 	producerElastic := kafka.NewProducerKafka(config.Kafka.Address)
 	consumerElastic := kafka.NewConsumerKafka(config.Kafka.Address)
+	consumerElastic.RegisterSerializer(orderSnapshotAvro)
 	orderElasticRoot := roots.NewOrderElasticRoot(orderElasticService, consumerElastic, producerElastic, &config, logger)
 
 	// Create OrderEventRoot => Consume orderID, get order model, delete order from elastic and push order model
 	orderEventService := order_elastic.NewOrderEventService(logger)
 	producerEvent := kafka.NewProducerKafka(config.Kafka.Address)
+	producerEvent.SetTopicSerializer(config.Kafka.TopicName["OrderModel"], orderModelSerializer)
 	consumerEvent := kafka.NewConsumerKafka(config.Kafka.Address)
+	consumerEvent.RegisterSerializer(orderChangedAvro)
 	orderEventRoot := roots.NewOrderEventRoot(orderEventService, orderElasticService, consumerEvent, producerEvent, &config, logger)
 
 	// Create OrderSyncService
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	docs "OrderUserProject/docs/product"
+	"OrderUserProject/internal/apps/product-api"
+	"OrderUserProject/internal/apps/product-api/handler"
+	"OrderUserProject/internal/apps/product-api/roots"
+	"OrderUserProject/internal/configs"
+	"OrderUserProject/internal/repository"
+	"OrderUserProject/pkg"
+	"OrderUserProject/pkg/kafka"
+	"OrderUserProject/pkg/money"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	echoLog "github.com/labstack/gommon/log"
+	"github.com/neko-neko/echo-logrus/v2/log"
+	"github.com/sirupsen/logrus"
+	echoSwagger "github.com/swaggo/echo-swagger"
+	"net/http"
+	"os"
+	"time"
+)
+
+// @title           Product Microservice
+// @version         1.0
+// @description     This is a product catalog microservice project.
+// @termsOfService  http://swagger.io/terms/
+
+// @contact.name   API Support
+// @contact.url    http://www.swagger.io/support
+// @contact.email  support@swagger.io
+
+// @license.name  Apache 2.0
+// @license.url   http://www.apache.org/licenses/LICENSE-2.0.html
+
+// @host      localhost:30013
+// @BasePath  /api
+func StartProductAPI() {
+	// Echo instance
+	e := echo.New()
+
+	// Validator instance
+	v := validator.New()
+	// Money fields are validated with their minor units (e.g. "gt=0" of price)
+	money.RegisterValidation(v)
+
+	// Logger instead of echo.log we use 'logrus' package
+	log.Logger().SetOutput(os.Stdout)
+	log.Logger().SetLevel(echoLog.INFO)
+	log.Logger().SetFormatter(&logrus.JSONFormatter{TimestampFormat: time.RFC3339})
+	e.Logger = log.Logger()
+	e.Use(pkg.Logger())
+	log.Info("Logger enabled!!")
+
+	// Environment value
+	env := os.Getenv("environment")
+
+	// Get config
+	config := configs.GetConfig(env)
+
+	// Create Kafka producer => 'InventoryEvents' topic carries several event types, there is no avro schema for it
+	producer := kafka.NewProducerKafka(config.Kafka.Address)
+	inventoryEventsSerializer, err := kafka.NewSerializer(config.Kafka.Serialization["InventoryEvents"], "")
+	if err != nil {
+		log.Fatalf("Kafka serializer cannot create: %v", err)
+	}
+	producer.SetTopicSerializer(config.Kafka.TopicName["InventoryEvents"], inventoryEventsSerializer)
+
+	// Connection with mongoDB and create collections
+	mongoDatabase := configs.
+		ConnectDB(config.Database.Connection).
+		Database(config.Database.DatabaseName)
+	mongoProductCollection := mongoDatabase.Collection(config.Database.ProductCollectionName)
+	mongoReservationCollection := mongoDatabase.Collection(config.Database.ReservationCollectionName)
+
+	// Create repo and services (Singleton)
+	ProductRepository := repository.NewProductRepository(mongoProductCollection)
+	if err := ProductRepository.CreateIndexes(); err != nil {
+		log.Fatalf("Indexes of products cannot create: %v", err)
+	}
+	ProductService := product_api.NewProductService(ProductRepository)
+	InventoryRepository := repository.NewInventoryRepository(mongoProductCollection, mongoReservationCollection)
+	InventoryService := product_api.NewInventoryService(InventoryRepository)
+
+	// Create new app
+	handler.NewProductHandler(e, ProductService, InventoryService, &config, v)
+
+	// Consume inventory saga commands of order-api => reserve, release or commit stock
+	if config.Inventory.Enabled {
+		inventoryCommandConsumer := kafka.NewConsumerKafkaWithGroup(config.Kafka.Address, "product-api")
+		inventoryCommandRoot := roots.NewInventoryCommandRoot(InventoryService, inventoryCommandConsumer, producer, &config, e.Logger)
+		go inventoryCommandRoot.StartConsumeInventoryCommands()
+	}
+
+	// If we don't use this swagger give an error
+	docs.SwaggerInfoproductAPI.Host = "localhost:30013"
+	// Add swagger (InstanceName is important!)
+	e.GET("/swagger/*", echoSwagger.EchoWrapHandler(echoSwagger.InstanceName("productAPI")))
+
+	// Start server as asynchronous
+	go func() {
+		if err := e.Start(config.Server.Port["productAPI"]); err != nil && err != http.ErrServerClosed {
+			e.Logger.Fatal("Shutting down the server!")
+		}
+	}()
+
+	// Graceful Shutdown
+	pkg.GracefulShutdown(e, 10*time.Second)
+}
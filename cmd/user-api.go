@@ -2,11 +2,13 @@ package cmd
 
 import (
 	docs "OrderUserProject/docs/user"
+	"OrderUserProject/internal/address"
 	"OrderUserProject/internal/apps/user-api"
 	"OrderUserProject/internal/apps/user-api/handler"
 	"OrderUserProject/internal/configs"
 	"OrderUserProject/internal/repository"
 	"OrderUserProject/pkg"
+	"OrderUserProject/pkg/kafka"
 	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
 	echoLog "github.com/labstack/gommon/log"
@@ -54,6 +56,14 @@ func StartUserAPI() {
 	// Get config
 	config := configs.GetConfig(env)
 
+	// Create Kafka producer => 'UserEvents' topic carries several event types, there is no avro schema for it
+	producer := kafka.NewProducerKafka(config.Kafka.Address)
+	userEventsSerializer, err := kafka.NewSerializer(config.Kafka.Serialization["UserEvents"], "")
+	if err != nil {
+		log.Fatalf("Kafka serializer cannot create: %v", err)
+	}
+	producer.SetTopicSerializer(config.Kafka.TopicName["UserEvents"], userEventsSerializer)
+
 	// Connection with mongoDB and create collection
 	mongoUserCollection := configs.
 		ConnectDB(config.Database.Connection).
@@ -64,8 +74,25 @@ func StartUserAPI() {
 	UserRepository := repository.NewUserRepository(mongoUserCollection)
 	UserService := user_api.NewUserService(UserRepository)
 
+	// Address normalizer => canonical city and district names
+	addressNormalizer, err := address.NewNormalizer(config.Address.DatasetPath)
+	if err != nil {
+		log.Fatalf("Address normalizer cannot create: %v", err)
+	}
+
 	// Create new app
-	handler.NewUserHandler(e, UserService, v)
+	handler.NewUserHandler(e, UserService, v, producer, &config, addressNormalizer)
+
+	// Purge soft deleted users after retention period
+	if config.SoftDelete.Enabled {
+		go pkg.StartPeriodicJob("purge deleted users", config.SoftDelete.PurgeInterval, func() error {
+			purged, err := UserService.PurgeDeleted(config.SoftDelete.Retention)
+			if err == nil && purged > 0 {
+				e.Logger.Infof("%v soft deleted users are purged.", purged)
+			}
+			return err
+		})
+	}
 
 	// If we don't use this swagger give an error
 	docs.SwaggerInfouserAPI.Host = "localhost:30012"
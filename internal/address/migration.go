@@ -0,0 +1,106 @@
+package address
+
+import (
+	"OrderUserProject/internal/models"
+	"OrderUserProject/internal/repository"
+	"fmt"
+	"time"
+)
+
+// Migration => normalizes addresses of existing users and address snapshots of existing orders
+type Migration struct {
+	Normalizer Normalizer
+	Users      repository.IUserRepository
+	Orders     repository.IOrderRepository
+	// DryRun => only count the changes, nothing is written
+	DryRun bool
+	// OrderUpdated => called for every updated order, e.g. to send 'OrderChanged' event for elasticsearch duplicate
+	OrderUpdated func(order models.Order)
+}
+
+// MigrationResult => counts of migration, rejected addresses are left as they are and listed with the reason
+type MigrationResult struct {
+	Users    int
+	Orders   int
+	Rejected []string
+}
+
+// Run => normalize every address, users and orders are written only if an address is changed
+func (m Migration) Run() (MigrationResult, error) {
+	var result MigrationResult
+
+	users, err := m.Users.GetAll()
+	if err != nil {
+		return result, err
+	}
+
+	for _, user := range users {
+		changed := false
+		for i, userAddress := range user.Addresses {
+			normalizedAddress, ok := m.normalize(&result, "user "+user.ID, userAddress)
+			if ok && !equalAddress(userAddress, normalizedAddress) {
+				user.Addresses[i] = normalizedAddress
+				changed = true
+			}
+		}
+		if !changed {
+			continue
+		}
+
+		result.Users++
+		if m.DryRun {
+			continue
+		}
+		user.UpdatedAt = time.Now()
+		if _, err := m.Users.Update(user); err != nil {
+			return result, fmt.Errorf("user %v cannot update: %w", user.ID, err)
+		}
+	}
+
+	orders, err := m.Orders.GetAll()
+	if err != nil {
+		return result, err
+	}
+
+	for _, order := range orders {
+		changed := false
+		for _, orderAddress := range []*models.Address{&order.Address, &order.InvoiceAddress} {
+			normalizedAddress, ok := m.normalize(&result, "order "+order.ID, *orderAddress)
+			if ok && !equalAddress(*orderAddress, normalizedAddress) {
+				*orderAddress = normalizedAddress
+				changed = true
+			}
+		}
+		if !changed {
+			continue
+		}
+
+		result.Orders++
+		if m.DryRun {
+			continue
+		}
+		order.UpdatedAt = time.Now()
+		if _, err := m.Orders.Update(order); err != nil {
+			return result, fmt.Errorf("order %v cannot update: %w", order.ID, err)
+		}
+		if m.OrderUpdated != nil {
+			m.OrderUpdated(order)
+		}
+	}
+
+	return result, nil
+}
+
+// normalize => normalized address, rejected address is recorded on result
+func (m Migration) normalize(result *MigrationResult, owner string, address models.Address) (models.Address, bool) {
+	normalizedAddress, err := m.Normalizer.Normalize(address)
+	if err != nil {
+		result.Rejected = append(result.Rejected, fmt.Sprintf("%v address %v: %v", owner, address.ID, err))
+		return address, false
+	}
+	return normalizedAddress, true
+}
+
+func equalAddress(a models.Address, b models.Address) bool {
+	return a.Address == b.Address && a.City == b.City && a.District == b.District
+}
@@ -0,0 +1,135 @@
+package address
+
+import (
+	"OrderUserProject/internal/models"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// ErrUnknownCity => city is not in the dataset
+var ErrUnknownCity = errors.New("unknown city")
+
+// ErrUnknownDistrict => district is not a district of the city
+var ErrUnknownDistrict = errors.New("unknown district")
+
+// Normalizer => validates an address and returns it with canonical spellings. user-api uses it before saving addresses,
+// another implementation (e.g. an external address service) can be plugged in with the same interface.
+type Normalizer interface {
+	Normalize(address models.Address) (models.Address, error)
+}
+
+// NormalizerFunc => function as a Normalizer
+type NormalizerFunc func(address models.Address) (models.Address, error)
+
+func (f NormalizerFunc) Normalize(address models.Address) (models.Address, error) {
+	return f(address)
+}
+
+//go:embed data/tr.json
+var turkeyDataset []byte
+
+// Dataset => cities of a country and their districts
+type Dataset struct {
+	Country string `json:"country"`
+	Cities  []struct {
+		Plate     int      `json:"plate"`
+		Name      string   `json:"name"`
+		Districts []string `json:"districts"`
+	} `json:"cities"`
+}
+
+// DatasetNormalizer => normalizer with cities and districts of a dataset. City and district are matched with folded
+// keys, so "İstanbul", "Istanbul", "ISTANBUL" and "istanbul" are all "İstanbul".
+type DatasetNormalizer struct {
+	// cities => folded city name to canonical name
+	cities map[string]string
+	// districts => folded city name to folded district name to canonical name
+	districts map[string]map[string]string
+}
+
+// NewTurkeyNormalizer => normalizer with built-in dataset of Turkish cities and districts (data/tr.json)
+func NewTurkeyNormalizer() (*DatasetNormalizer, error) {
+	return newDatasetNormalizer(turkeyDataset)
+}
+
+// NewNormalizer => normalizer with dataset file on path, built-in Turkish dataset is used if path is empty
+func NewNormalizer(path string) (*DatasetNormalizer, error) {
+	if path == "" {
+		return NewTurkeyNormalizer()
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return newDatasetNormalizer(data)
+}
+
+func newDatasetNormalizer(data []byte) (*DatasetNormalizer, error) {
+	var dataset Dataset
+	if err := json.Unmarshal(data, &dataset); err != nil {
+		return nil, fmt.Errorf("address dataset cannot read: %w", err)
+	}
+
+	normalizer := &DatasetNormalizer{
+		cities:    map[string]string{},
+		districts: map[string]map[string]string{},
+	}
+	for _, city := range dataset.Cities {
+		cityKey := Fold(city.Name)
+		if _, ok := normalizer.cities[cityKey]; ok {
+			return nil, fmt.Errorf("address dataset has city %v twice", city.Name)
+		}
+		normalizer.cities[cityKey] = city.Name
+
+		normalizer.districts[cityKey] = map[string]string{}
+		for _, district := range city.Districts {
+			normalizer.districts[cityKey][Fold(district)] = district
+		}
+	}
+
+	return normalizer, nil
+}
+
+// Normalize => canonical city and district names and address line without extra spaces. Unknown city or a district
+// which is not in the city is rejected.
+func (n *DatasetNormalizer) Normalize(address models.Address) (models.Address, error) {
+	cityKey := Fold(address.City)
+	city, ok := n.cities[cityKey]
+	if !ok {
+		return address, fmt.Errorf("%w: %v", ErrUnknownCity, address.City)
+	}
+
+	district, ok := n.districts[cityKey][Fold(address.District)]
+	if !ok {
+		return address, fmt.Errorf("%w: %v is not a district of %v", ErrUnknownDistrict, address.District, city)
+	}
+
+	address.City = city
+	address.District = district
+	address.Address = strings.Join(strings.Fields(address.Address), " ")
+	return address, nil
+}
+
+// turkishLetters => letters which are written without their marks when Turkish keyboard is not used
+var turkishLetters = strings.NewReplacer("ı", "i", "ç", "c", "ğ", "g", "ö", "o", "ş", "s", "ü", "u", "â", "a", "î", "i", "û", "u")
+
+// Fold => key of a name for comparison. Lower case with Turkish rules (İ => i, I => ı), then letters without marks
+// (ı => i, ş => s ...), so both "Istanbul" and "İstanbul" are "istanbul". Spaces, dots, dashes and combining marks
+// (decomposed "İ" is "I" and a combining dot) are ignored.
+func Fold(name string) string {
+	folded := strings.ToLowerSpecial(unicode.TurkishCase, strings.TrimSpace(name))
+	folded = turkishLetters.Replace(folded)
+
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || unicode.IsPunct(r) || unicode.Is(unicode.Mn, r) {
+			return -1
+		}
+		return r
+	}, folded)
+}
@@ -0,0 +1,67 @@
+package address
+
+import (
+	"OrderUserProject/internal/models"
+	"errors"
+	"github.com/go-playground/assert/v2"
+	"testing"
+)
+
+func TestFold_TurkishSpellings(t *testing.T) {
+	for _, name := range []string{"İstanbul", "Istanbul", "ISTANBUL", "istanbul", "ıstanbul", " İstanbul ", "I\u0307stanbul"} {
+		assert.Equal(t, "istanbul", Fold(name))
+	}
+
+	assert.Equal(t, Fold("Kadıköy"), Fold("KADIKÖY"))
+	assert.Equal(t, Fold("Şişli"), Fold("sisli"))
+	assert.Equal(t, Fold("Eyüpsultan"), Fold("Eyüp Sultan"))
+}
+
+func TestDatasetNormalizer_Normalize(t *testing.T) {
+	normalizer, err := NewTurkeyNormalizer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := map[string]struct {
+		city     string
+		district string
+		err      error
+	}{
+		"canonical":        {"İstanbul", "Beşiktaş", nil},
+		"ascii":            {"Istanbul", "Besiktas", nil},
+		"upper-case":       {"ISTANBUL", "BEŞİKTAŞ", nil},
+		"unknown-city":     {"Gotham", "Beşiktaş", ErrUnknownCity},
+		"unknown-district": {"Ankara", "Beşiktaş", ErrUnknownDistrict},
+	}
+
+	for name, result := range results {
+		address := models.Address{Address: "  Levent   Mah.  ", City: result.city, District: result.district}
+
+		normalized, err := normalizer.Normalize(address)
+		if !errors.Is(err, result.err) {
+			t.Errorf("%v: expected error: %v, but got: %v", name, result.err, err)
+		}
+
+		if result.err == nil {
+			assert.Equal(t, "İstanbul", normalized.City)
+			assert.Equal(t, "Beşiktaş", normalized.District)
+			assert.Equal(t, "Levent Mah.", normalized.Address)
+		}
+	}
+}
+
+func TestNewTurkeyNormalizer_HasEveryCity(t *testing.T) {
+	normalizer, err := NewTurkeyNormalizer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 81, len(normalizer.cities))
+
+	districtCount := 0
+	for _, districts := range normalizer.districts {
+		districtCount += len(districts)
+	}
+	assert.Equal(t, 973, districtCount)
+}
@@ -0,0 +1,249 @@
+package order_api
+
+import (
+	"OrderUserProject/internal/models"
+	"OrderUserProject/internal/repository"
+	"OrderUserProject/pkg/money"
+	"fmt"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/mongo"
+	"time"
+)
+
+// Cancel => order is canceled completely (request without lines or every unit is canceled) or quantities of its lines
+// are canceled. Shipped, delivered, closed or canceled orders return ErrOrderNotCancelable.
+//
+// Canceled order keeps its lines and amounts, whole total (with shipping) is refunded and usages of its promotions are
+// released. After partial cancellation discount share of canceled units is removed from order discount, amounts are
+// calculated again and status is derived from shipments, difference of totals is refunded (shipping is not refunded). Refund with store credit is issued immediately, other methods are
+// pending until payment side reports the result.
+func (b *OrderService) Cancel(id string, request OrderCancelRequest) (OrderCancelResult, error) {
+	before, err := b.OrderRepository.GetOrderById(id)
+	if err != nil {
+		return OrderCancelResult{}, err
+	}
+
+	if isOneOf(before.Status, NotCancelableStatuses) {
+		return OrderCancelResult{}, fmt.Errorf("%w: order is %v", ErrOrderNotCancelable, before.Status)
+	}
+
+	now := time.Now()
+	after := before
+	after.UpdatedAt = now
+	cancellation := models.OrderCancellation{
+		ID:         uuid.New().String(),
+		Reason:     request.Reason,
+		Note:       request.Note,
+		CanceledAt: now,
+	}
+
+	if len(request.Lines) > 0 {
+		lines, canceledLines, discount, err := cancelLines(before.Product, UnshippedQuantities(before), request.Lines)
+		if err != nil {
+			return OrderCancelResult{}, err
+		}
+		cancellation.Lines = canceledLines
+
+		if len(lines) > 0 {
+			cancellation.Partial = true
+			after.Product = lines
+			after.Discount = after.Discount.Sub(discount)
+			if err := CalculateTotals(&after); err != nil {
+				return OrderCancelResult{}, err
+			}
+			// Remaining units may be shipped or delivered already
+			after.Status = DeriveOrderStatus(after)
+		}
+	} else {
+		if HasActiveShipments(before) {
+			return OrderCancelResult{}, fmt.Errorf("%w: order has shipments, only lines which are not shipped can be canceled", ErrOrderNotCancelable)
+		}
+		for _, line := range before.Product {
+			cancellation.Lines = append(cancellation.Lines, models.CanceledLine{Sku: line.Sku, Name: line.Name, Quantity: line.Quantity})
+		}
+	}
+
+	refundAmount := before.Total
+	if cancellation.Partial {
+		refundAmount = before.Total.Sub(after.Total)
+	} else {
+		after.Status = CanceledStatus
+	}
+
+	result, saved, err := saveCancellation(b.OrderRepository, b.PromotionService, before, after, cancellation, refundAmount,
+		request.RefundMethod, NotCancelableStatuses)
+	if err != nil {
+		return OrderCancelResult{}, err
+	}
+	if saved == false {
+		// Order is shipped or changed after it is read
+		return OrderCancelResult{}, fmt.Errorf("%w: order is changed, please try again", ErrOrderNotCancelable)
+	}
+
+	return result, nil
+}
+
+// cancelOpenOrder => order is canceled completely by system (e.g. compensation of inventory saga or deleted user) like
+// Cancel without lines: every line is canceled with reason, whole total is refunded with original payment and usages
+// of promotions are released. Order which is closed (ClosedOrderStatuses) is not changed and nil is returned. Order
+// which is changed after it is read is read again.
+func cancelOpenOrder(orderRepository repository.IOrderRepository, promotionService IPromotionService, order models.Order,
+	reason string, note string) (*OrderCancelResult, error) {
+	for attempt := 1; ; attempt++ {
+		if isOneOf(order.Status, ClosedOrderStatuses) {
+			return nil, nil
+		}
+
+		now := time.Now()
+		after := order
+		after.Status = CanceledStatus
+		after.UpdatedAt = now
+		cancellation := models.OrderCancellation{ID: uuid.New().String(), Reason: reason, Note: note, CanceledAt: now}
+		for _, line := range order.Product {
+			cancellation.Lines = append(cancellation.Lines, models.CanceledLine{Sku: line.Sku, Name: line.Name, Quantity: line.Quantity})
+		}
+
+		result, saved, err := saveCancellation(orderRepository, promotionService, order, after, cancellation, order.Total,
+			RefundMethodOriginalPayment, ClosedOrderStatuses)
+		if err != nil {
+			return nil, err
+		}
+		if saved {
+			return &result, nil
+		}
+		if attempt == maxCancelAttempts {
+			return nil, fmt.Errorf("%w: order is changed while it is canceled", ErrOrderNotCancelable)
+		}
+
+		order, err = orderRepository.GetOrderById(order.ID)
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// saveCancellation => cancellation and its refund (if refund amount is positive) are recorded on order and it is saved
+// unless it is changed after it is read or its status is one of notCancelableStatuses (saved is false). Usages of
+// promotions of fully canceled order are released, partially canceled order keeps them.
+func saveCancellation(orderRepository repository.IOrderRepository, promotionService IPromotionService, before models.Order,
+	after models.Order, cancellation models.OrderCancellation, refundAmount money.Amount, refundMethod string,
+	notCancelableStatuses []string) (OrderCancelResult, bool, error) {
+	var refund *models.Refund
+	if refundAmount.IsPositive() {
+		refund = &models.Refund{
+			ID:             uuid.New().String(),
+			CancellationID: cancellation.ID,
+			Amount:         refundAmount,
+			Currency:       before.Currency,
+			Method:         refundMethod,
+			Status:         RefundPending,
+			CreatedAt:      cancellation.CanceledAt,
+			UpdatedAt:      cancellation.CanceledAt,
+		}
+		if refund.Method == "" {
+			refund.Method = RefundMethodOriginalPayment
+		}
+		if refund.Method == RefundMethodStoreCredit {
+			refund.Status = RefundIssued
+		}
+		cancellation.RefundID = refund.ID
+		after.Refunds = append(append([]models.Refund(nil), before.Refunds...), *refund)
+	}
+	after.Cancellations = append(append([]models.OrderCancellation(nil), before.Cancellations...), cancellation)
+
+	saved, err := orderRepository.Cancel(after, before.UpdatedAt, notCancelableStatuses)
+	if err != nil || saved == false {
+		return OrderCancelResult{}, false, err
+	}
+
+	// Canceled order doesn't use its promotions anymore
+	if !cancellation.Partial {
+		promotionService.Release(before.UserId, usedPromotions(before.Promotions))
+	}
+
+	return OrderCancelResult{Before: before, After: after, Cancellation: cancellation, Refund: refund}, true, nil
+}
+
+// UpdateRefundStatus => pending refund of order is issued or failed. Returns order and refund after change.
+func (b *OrderService) UpdateRefundStatus(id string, refundId string, status string) (models.Order, models.Refund, error) {
+	order, err := b.OrderRepository.GetOrderById(id)
+	if err != nil {
+		return models.Order{}, models.Refund{}, err
+	}
+
+	index := -1
+	for i, refund := range order.Refunds {
+		if refund.ID == refundId {
+			index = i
+		}
+	}
+	if index < 0 {
+		return models.Order{}, models.Refund{}, fmt.Errorf("%w: %v", ErrRefundNotFound, refundId)
+	}
+	if order.Refunds[index].Status != RefundPending {
+		return models.Order{}, models.Refund{}, fmt.Errorf("%w: refund is %v", ErrRefundNotPending, order.Refunds[index].Status)
+	}
+
+	now := time.Now()
+	result, err := b.OrderRepository.UpdateRefundStatus(id, refundId, RefundPending, status, now)
+	if err != nil {
+		return models.Order{}, models.Refund{}, err
+	}
+	if result == false {
+		// Refund is updated by another request in the meantime
+		return models.Order{}, models.Refund{}, fmt.Errorf("%w: refund is already updated", ErrRefundNotPending)
+	}
+
+	order.Refunds = append([]models.Refund(nil), order.Refunds...)
+	order.Refunds[index].Status = status
+	order.Refunds[index].UpdatedAt = now
+	order.UpdatedAt = now
+
+	return order, order.Refunds[index], nil
+}
+
+// cancelLines => lines which are left after canceled quantities (lines without quantity are removed), canceled lines
+// and discount share of canceled units. Lines of the same sku in request are merged, only units which are not in a
+// shipment (unshipped) can be canceled.
+func cancelLines(lines []models.OrderProduct, unshipped map[string]int, requested []OrderCancelLineRequest) ([]models.OrderProduct, []models.CanceledLine, money.Amount, error) {
+	quantities := map[string]int{}
+	var skus []string
+	for _, line := range requested {
+		if _, ok := quantities[line.Sku]; !ok {
+			skus = append(skus, line.Sku)
+		}
+		quantities[line.Sku] += line.Quantity
+	}
+
+	for _, sku := range skus {
+		if quantities[sku] > unshipped[sku] {
+			return nil, nil, money.Amount{}, fmt.Errorf("%w: %v units of %v cannot be canceled, %v units are not shipped", ErrInvalidCancelLines, quantities[sku], sku, unshipped[sku])
+		}
+	}
+
+	var left []models.OrderProduct
+	var canceled []models.CanceledLine
+	var discount money.Amount
+	for _, line := range lines {
+		quantity := quantities[line.Sku]
+		if quantity > line.Quantity {
+			quantity = line.Quantity
+		}
+		quantities[line.Sku] -= quantity
+
+		if quantity > 0 {
+			shares := line.Discount.Allocate([]money.Amount{money.FromMinor(int64(line.Quantity - quantity)), money.FromMinor(int64(quantity))})
+			discount = discount.Add(shares[1])
+			canceled = append(canceled, models.CanceledLine{Sku: line.Sku, Name: line.Name, Quantity: quantity})
+		}
+		if line.Quantity > quantity {
+			line.Quantity -= quantity
+			left = append(left, line)
+		}
+	}
+
+	return left, canceled, discount, nil
+}
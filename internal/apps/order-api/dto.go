@@ -1,46 +1,107 @@
 package order_api
 
-import "time"
+import (
+	"OrderUserProject/internal/models"
+	"OrderUserProject/internal/query"
+	"OrderUserProject/pkg/money"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
 
 type OrderCreateRequest struct {
-	UserId         string `json:"userId" bson:"userId" validate:"required,uuid4"`
-	Status         string `json:"status" bson:"status" validate:"required,min=1,max=100"`
-	Address        string `json:"address" bson:"address" validate:"required,uuid4"`
-	InvoiceAddress string `json:"invoiceAddress" bson:"invoiceAddress" validate:"required,uuid4"`
-	Product        []struct {
-		Name     string  `json:"name" bson:"name" validate:"required,min=1,max=100"`
-		Quantity int     `json:"quantity" bson:"quantity" validate:"required"`
-		Price    float64 `json:"price" bson:"price" validate:"required"`
-	} `json:"product" bson:"product" validate:"required"`
+	UserId string `json:"userId" bson:"userId" validate:"required,uuid4"`
+	// Status => derived from shipments, request with status is rejected (pkg.CheckOrderStatus)
+	Status         string                `json:"status,omitempty" bson:"status" swaggerignore:"true"`
+	Address        string                `json:"address" bson:"address" validate:"required,uuid4"`
+	InvoiceAddress string                `json:"invoiceAddress" bson:"invoiceAddress" validate:"required,uuid4"`
+	Product        []OrderProductRequest `json:"product" bson:"product" validate:"required,min=1,dive"`
+	// CouponCode => optional coupon, it is not case sensitive
+	CouponCode string `json:"couponCode,omitempty" bson:"couponCode" validate:"omitempty,max=32"`
+	ComputedAmounts
 }
 
 type OrderUpdateRequest struct {
-	ID             string `json:"id" bson:"_id" validate:"required,uuid4"`
-	UserId         string `json:"userId" bson:"userId" validate:"required,uuid4"`
-	Status         string `json:"status" bson:"status" validate:"required,min=1,max=100"`
-	Address        string `json:"address" bson:"address" validate:"required,uuid4"`
-	InvoiceAddress string `json:"invoiceAddress" bson:"invoiceAddress" validate:"required,uuid4"`
-	Product        []struct {
-		Name     string  `json:"name" bson:"name" validate:"required,min=1,max=100"`
-		Quantity int     `json:"quantity" bson:"quantity" validate:"required"`
-		Price    float64 `json:"price" bson:"price" validate:"required"`
-	} `json:"product" bson:"product" validate:"required"`
+	ID     string `json:"id" bson:"_id" validate:"required,uuid4"`
+	UserId string `json:"userId" bson:"userId" validate:"required,uuid4"`
+	// Status => derived from shipments, request with status is rejected (pkg.CheckOrderStatus)
+	Status         string                `json:"status,omitempty" bson:"status" swaggerignore:"true"`
+	Address        string                `json:"address" bson:"address" validate:"required,uuid4"`
+	InvoiceAddress string                `json:"invoiceAddress" bson:"invoiceAddress" validate:"required,uuid4"`
+	Product        []OrderProductRequest `json:"product" bson:"product" validate:"required,min=1,dive"`
+	ComputedAmounts
+}
+
+// ClientTotals => json paths of amounts which are sent by client, e.g. "total" or "product[1].tax"
+func (r OrderCreateRequest) ClientTotals() []string {
+	return clientTotals(r.ComputedAmounts, r.Product)
+}
+
+// ClientTotals => json paths of amounts which are sent by client, e.g. "total" or "product[1].tax"
+func (r OrderUpdateRequest) ClientTotals() []string {
+	return clientTotals(r.ComputedAmounts, r.Product)
 }
 
 type OrderResponse struct {
-	ID             string          `json:"id" bson:"_id"`
-	UserId         string          `json:"userId" bson:"userId"`
-	Status         string          `json:"status" bson:"status"`
-	Address        AddressResponse `json:"address" bson:"address"`
-	InvoiceAddress AddressResponse `json:"invoiceAddress" bson:"invoiceAddress"`
-	Product        []struct {
-		Name     string  `json:"name" bson:"name"`
-		Quantity int     `json:"quantity" bson:"quantity"`
-		Price    float64 `json:"price" bson:"price"`
-	} `json:"product" bson:"product"`
-	Total     float64   `json:"total" bson:"total"`
-	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
-	UpdatedAt time.Time `json:"updatedAt" bson:"updatedAt"`
+	ID             string                     `json:"id" bson:"_id"`
+	UserId         string                     `json:"userId" bson:"userId"`
+	Status         string                     `json:"status" bson:"status"`
+	Address        AddressResponse            `json:"address" bson:"address"`
+	InvoiceAddress AddressResponse            `json:"invoiceAddress" bson:"invoiceAddress"`
+	Product        []models.OrderProduct      `json:"product" bson:"product"`
+	Currency       string                     `json:"currency" bson:"currency"`
+	Subtotal       money.Amount               `json:"subtotal" bson:"subtotal" swaggertype:"number"`
+	Discount       money.Amount               `json:"discount" bson:"discount" swaggertype:"number"`
+	Tax            money.Amount               `json:"tax" bson:"tax" swaggertype:"number"`
+	Shipping       money.Amount               `json:"shipping" bson:"shipping" swaggertype:"number"`
+	Total          money.Amount               `json:"total" bson:"total" swaggertype:"number"`
+	CouponCode     string                     `json:"couponCode,omitempty" bson:"couponCode"`
+	Promotions     []models.AppliedPromotion  `json:"promotions,omitempty" bson:"promotions"`
+	Cancellations  []models.OrderCancellation `json:"cancellations,omitempty" bson:"cancellations"`
+	Shipments      []models.Shipment          `json:"shipments,omitempty" bson:"shipments"`
+	Refunds        []models.Refund            `json:"refunds,omitempty" bson:"refunds"`
+	CreatedAt      time.Time                  `json:"createdAt" bson:"createdAt"`
+	UpdatedAt      time.Time                  `json:"updatedAt" bson:"updatedAt"`
+}
+
+// OrderProductRequest => order line, name and price come from product catalog (product-api)
+type OrderProductRequest struct {
+	Sku      string `json:"sku" bson:"sku" validate:"required,min=1,max=64"`
+	Quantity int    `json:"quantity" bson:"quantity" validate:"required,min=1"`
+	ComputedAmounts
+}
+
+// ComputedAmounts => amounts which are calculated by order-api from lines. They are bound only to reject requests
+// which send them, amounts of client are never saved.
+type ComputedAmounts struct {
+	Subtotal json.RawMessage `json:"subtotal,omitempty" bson:"-" swaggerignore:"true"`
+	Discount json.RawMessage `json:"discount,omitempty" bson:"-" swaggerignore:"true"`
+	Tax      json.RawMessage `json:"tax,omitempty" bson:"-" swaggerignore:"true"`
+	Total    json.RawMessage `json:"total,omitempty" bson:"-" swaggerignore:"true"`
+}
+
+// sent => json names of amounts which are in request (null is sent too)
+func (c ComputedAmounts) sent() []string {
+	var names []string
+	for name, value := range map[string]json.RawMessage{"subtotal": c.Subtotal, "discount": c.Discount, "tax": c.Tax, "total": c.Total} {
+		if len(value) > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func clientTotals(order ComputedAmounts, lines []OrderProductRequest) []string {
+	fields := order.sent()
+	for i, line := range lines {
+		for _, name := range line.sent() {
+			fields = append(fields, fmt.Sprintf("product[%d].%v", i, name))
+		}
+	}
+	return fields
 }
 
 type AddressResponse struct {
@@ -55,6 +116,82 @@ type AddressResponse struct {
 	} `json:"default" bson:"default"`
 }
 
+// ProductResponse => product of catalog from product-api, price is without VAT and VAT rate comes from tax class
+type ProductResponse struct {
+	ID       string       `json:"id"`
+	Sku      string       `json:"sku"`
+	Name     string       `json:"name"`
+	Price    money.Amount `json:"price"`
+	Currency string       `json:"currency"`
+	TaxRate  money.Rate   `json:"taxRate"`
+	Active   bool         `json:"active"`
+}
+
+// ErrProductNotFound => sku is not in product catalog or product is not active
+var ErrProductNotFound = errors.New("product not found")
+
+// ErrCurrencyMismatch => every line of an order has to be in the same currency
+var ErrCurrencyMismatch = errors.New("products have different currencies")
+
+// ErrInvalidDiscount => order discount cannot be negative or more than subtotal of order
+var ErrInvalidDiscount = errors.New("invalid discount")
+
+// Promotion types (models.Promotion.Type)
+const (
+	PromotionPercentage   = "Percentage"
+	PromotionFixedAmount  = "FixedAmount"
+	PromotionFreeShipping = "FreeShipping"
+	PromotionBuyXGetY     = "BuyXGetY"
+)
+
+// ErrCouponNotFound => there is no promotion with coupon code
+var ErrCouponNotFound = errors.New("coupon not found")
+
+// ErrCouponNotApplicable => coupon is not active, not valid now, not for this order or its limits are reached
+var ErrCouponNotApplicable = errors.New("coupon cannot be applied")
+
+// ErrPromotionLimitReached => usage limit of promotion is reached while order is saved
+var ErrPromotionLimitReached = errors.New("usage limit of promotion is reached")
+
+// ErrInvalidPromotion => rules of promotion don't fit its type
+var ErrInvalidPromotion = errors.New("invalid promotion")
+
+// ErrCouponCodeExists => coupon codes are unique
+var ErrCouponCodeExists = errors.New("coupon code already exists")
+
+// PromotionRules => rules of promotion which admin can change. Rates are percent, amounts are in Currency.
+type PromotionRules struct {
+	Name         string       `json:"name" validate:"required,min=1,max=100"`
+	Type         string       `json:"type" validate:"required,oneof=Percentage FixedAmount FreeShipping BuyXGetY"`
+	Percent      money.Rate   `json:"percent" swaggertype:"number" validate:"gte=0,lte=10000"`
+	Amount       money.Amount `json:"amount" swaggertype:"number" validate:"gte=0"`
+	MaxDiscount  money.Amount `json:"maxDiscount" swaggertype:"number" validate:"gte=0"`
+	Sku          string       `json:"sku" validate:"omitempty,max=64"`
+	BuyQuantity  int          `json:"buyQuantity" validate:"gte=0"`
+	GetQuantity  int          `json:"getQuantity" validate:"gte=0"`
+	Currency     string       `json:"currency" validate:"omitempty,iso4217"`
+	MinSubtotal  money.Amount `json:"minSubtotal" swaggertype:"number" validate:"gte=0"`
+	StartsAt     time.Time    `json:"startsAt"`
+	EndsAt       time.Time    `json:"endsAt"`
+	UsageLimit   int          `json:"usageLimit" validate:"gte=0"`
+	PerUserLimit int          `json:"perUserLimit" validate:"gte=0"`
+	Stackable    bool         `json:"stackable"`
+	Priority     int          `json:"priority"`
+	Active       bool         `json:"active"`
+}
+
+// PromotionCreateRequest => promotion without code is applied automatically to every eligible order
+type PromotionCreateRequest struct {
+	Code string `json:"code" validate:"omitempty,min=3,max=32,alphanum"`
+	PromotionRules
+}
+
+// PromotionUpdateRequest => code of promotion cannot change, usage counts are kept
+type PromotionUpdateRequest struct {
+	ID string `json:"id" validate:"required,uuid4"`
+	PromotionRules
+}
+
 type UserResponse struct {
 	ID        string            `json:"id"`
 	Name      string            `json:"name"`
@@ -62,11 +199,274 @@ type UserResponse struct {
 	Addresses []AddressResponse `json:"addresses"`
 }
 
-type OrderResponseForElastic struct {
-	OrderID string `json:"orderID" bson:"orderID"`
-	Status  string `json:"status" bson:"status"`
+// Types of order changes, they are the statuses of 'OrderChanged' events and event names of GET /api/orders/stream
+const (
+	OrderChangeCreated = "Created"
+	OrderChangeUpdated = "Updated"
+	OrderChangeDeleted = "Deleted"
+)
+
+// OrderStreamEvent => data of a Server-Sent Event of GET /api/orders/stream, order is empty for deleted orders
+type OrderStreamEvent struct {
+	Type    string         `json:"type"`
+	OrderID string         `json:"orderId"`
+	UserId  string         `json:"userId,omitempty"`
+	Status  string         `json:"status,omitempty"`
+	At      time.Time      `json:"at"`
+	Order   *OrderResponse `json:"order,omitempty"`
+}
+
+// Order event modes (configs.Config.Kafka.OrderEventMode)
+const (
+	OrderEventModeThin = "thin"
+	OrderEventModeFull = "full"
+)
+
+// Address change policies (configs.Config.Order.AddressChangePolicy)
+const (
+	AddressChangePolicyUpdate = "update"
+	AddressChangePolicyKeep   = "keep"
+)
+
+// Address change decisions which are recorded on order
+const (
+	AddressChangeUpdated = "Updated"
+	AddressChangeKept    = "Kept"
+)
+
+// ClosedOrderStatuses => orders with these statuses are not open anymore, address changes don't affect them
+var ClosedOrderStatuses = []string{DeliveredStatus, CanceledStatus, ClosedStatus}
+
+// OpenOrdersResponse => open orders of user (or of an address of user), user-api checks it before deleting a user
+// or an address
+type OpenOrdersResponse struct {
+	UserId    string   `json:"userId"`
+	AddressId string   `json:"addressId,omitempty"`
+	Count     int      `json:"count"`
+	OrderIds  []string `json:"orderIds"`
+}
+
+// CanceledStatus => status of canceled orders
+const CanceledStatus = "Canceled"
+
+// Statuses of orders which are derived from shipments (see DeriveOrderStatus), closed orders are not changed
+const (
+	NotShippedStatus       = "Not Shipped"
+	PartiallyShippedStatus = "Partially Shipped"
+	DeliveredStatus        = "Delivered"
+	NotDeliveredStatus     = "Not Delivered"
+	ClosedStatus           = "Closed"
+)
+
+// NotCancelableStatuses => orders with these statuses cannot be canceled, shipped orders are returned instead
+var NotCancelableStatuses = []string{ShippedStatus, PartiallyShippedStatus, DeliveredStatus, CanceledStatus, ClosedStatus}
+
+// maxCancelAttempts => order which is canceled by system is read again this many times if it changes in the meantime
+const maxCancelAttempts = 3
+
+// Reasons of cancellation
+const (
+	CancelReasonCustomerRequest = "CustomerRequest"
+	CancelReasonOutOfStock      = "OutOfStock"
+	CancelReasonPaymentFailed   = "PaymentFailed"
+	CancelReasonFraud           = "Fraud"
+	CancelReasonOther           = "Other"
+)
+
+// Refund methods and statuses. Store credit is given by us, so it is issued immediately.
+const (
+	RefundMethodOriginalPayment = "OriginalPayment"
+	RefundMethodStoreCredit     = "StoreCredit"
+	RefundMethodBankTransfer    = "BankTransfer"
+
+	RefundPending = "Pending"
+	RefundIssued  = "Issued"
+	RefundFailed  = "Failed"
+)
+
+// OrderCancelRequest => without lines the whole order is canceled, with lines only the quantities of lines are
+// canceled. Order is canceled completely when every unit is canceled.
+type OrderCancelRequest struct {
+	Reason       string                   `json:"reason" validate:"required,oneof=CustomerRequest OutOfStock PaymentFailed Fraud Other"`
+	Note         string                   `json:"note" validate:"required_if=Reason Other,max=500"`
+	Lines        []OrderCancelLineRequest `json:"lines" validate:"omitempty,dive"`
+	RefundMethod string                   `json:"refundMethod" validate:"omitempty,oneof=OriginalPayment StoreCredit BankTransfer"`
+}
+
+type OrderCancelLineRequest struct {
+	Sku      string `json:"sku" validate:"required,min=1,max=64"`
+	Quantity int    `json:"quantity" validate:"required,min=1"`
+}
+
+// OrderCancelResult => order before and after cancellation, refund is nil if nothing is paid back
+type OrderCancelResult struct {
+	Before       models.Order
+	After        models.Order
+	Cancellation models.OrderCancellation
+	Refund       *models.Refund
+}
+
+// OrderCancelResponse => cancellation and refund of order
+type OrderCancelResponse struct {
+	OrderID      string                   `json:"orderId"`
+	Status       string                   `json:"status"`
+	Cancellation models.OrderCancellation `json:"cancellation"`
+	Refund       *models.Refund           `json:"refund,omitempty"`
+}
+
+// RefundStatusRequest => payment side reports the result of a pending refund
+type RefundStatusRequest struct {
+	Status string `json:"status" validate:"required,oneof=Issued Failed"`
 }
 
+// ErrOrderNotCancelable => shipped, delivered, closed or already canceled orders cannot be canceled
+var ErrOrderNotCancelable = errors.New("order cannot be canceled")
+
+// ErrInvalidCancelLines => canceled sku is not on order or its quantity is more than quantity of line
+var ErrInvalidCancelLines = errors.New("invalid cancel lines")
+
+// ErrRefundNotFound => order has no refund with id
+var ErrRefundNotFound = errors.New("refund not found")
+
+// ErrRefundNotPending => only pending refunds can be issued or failed
+var ErrRefundNotPending = errors.New("refund is not pending")
+
+// ShippedStatus => status of shipped orders, reserved stock of order is committed
+const ShippedStatus = "Shipped"
+
+// Statuses of shipments, Delivered and Failed are final
+const (
+	ShipmentPreparing = "Preparing"
+	ShipmentShipped   = "Shipped"
+	ShipmentDelivered = "Delivered"
+	ShipmentFailed    = "Failed"
+)
+
+// ShipmentCreateRequest => without items every unit which is not in a shipment yet is shipped
+type ShipmentCreateRequest struct {
+	Carrier        string                `json:"carrier" validate:"required,min=1,max=64"`
+	TrackingNumber string                `json:"trackingNumber" validate:"omitempty,max=64"`
+	Items          []ShipmentItemRequest `json:"items" validate:"omitempty,dive"`
+}
+
+type ShipmentItemRequest struct {
+	Sku      string `json:"sku" validate:"required,min=1,max=64"`
+	Quantity int    `json:"quantity" validate:"required,min=1"`
+}
+
+// ShipmentUpdateRequest => status of shipment is changed by carrier, tracking number is required to ship
+type ShipmentUpdateRequest struct {
+	Status         string `json:"status" validate:"required,oneof=Shipped Delivered Failed"`
+	Carrier        string `json:"carrier" validate:"omitempty,max=64"`
+	TrackingNumber string `json:"trackingNumber" validate:"omitempty,max=64"`
+}
+
+// ShipmentChange => order before and after its shipment is created or changed
+type ShipmentChange struct {
+	Before   models.Order
+	After    models.Order
+	Shipment models.Shipment
+}
+
+// ErrOrderNotShippable => canceled or closed orders cannot ship, orders with shipments cannot be updated
+var ErrOrderNotShippable = errors.New("order cannot be shipped")
+
+// ErrOrderClosed => delivered, canceled or closed orders cannot be updated
+var ErrOrderClosed = errors.New("order is closed")
+
+// ErrShipmentNotFound => order has no shipment with id
+var ErrShipmentNotFound = errors.New("shipment not found")
+
+// ErrInvalidShipmentItems => sku is not on order or its quantity is more than units which are not shipped yet
+var ErrInvalidShipmentItems = errors.New("invalid shipment items")
+
+// ErrInvalidShipmentTransition => e.g. delivered shipment cannot ship again or shipment without tracking number
+var ErrInvalidShipmentTransition = errors.New("invalid shipment status")
+
+// Statuses of invoice, Generating invoice is claimed by a request which numbers and renders it
+const (
+	InvoiceGenerating = "Generating"
+	InvoiceIssued     = "Issued"
+)
+
+// Formats of rendered invoice documents
+const (
+	InvoicePDF  = "pdf"
+	InvoiceHTML = "html"
+)
+
+// ErrInvoiceNotBillable => order didn't reach a billable status (e.g. Shipped), so it has no invoice yet
+var ErrInvoiceNotBillable = errors.New("order is not billable")
+
+// ErrInvoiceInProgress => invoice of order is generated by another request
+var ErrInvoiceInProgress = errors.New("invoice is being generated")
+
+// InvoiceSeller => issuer of invoices
+type InvoiceSeller struct {
+	Name      string
+	Address   string
+	TaxNumber string
+}
+
+// States of inventory saga. Reserving, Releasing and Committing wait for reply of product-api.
+const (
+	SagaReserving  = "Reserving"
+	SagaReserved   = "Reserved"
+	SagaReleasing  = "Releasing"
+	SagaReleased   = "Released"
+	SagaCommitting = "Committing"
+	SagaCommitted  = "Committed"
+	SagaFailed     = "Failed"
+)
+
+// InFlightSagaStates => sagas which are not finished yet
+var InFlightSagaStates = []string{SagaReserving, SagaReserved, SagaReleasing, SagaCommitting}
+
+// AdjustableSagaStates => sagas whose stock is reserved or committed, canceled quantities of their orders are given back
+var AdjustableSagaStates = []string{SagaReserving, SagaReserved, SagaCommitting, SagaCommitted}
+
+// WaitingSagaStates => sagas which wait for a reply, their command is sent again after reply timeout
+var WaitingSagaStates = []string{SagaReserving, SagaReleasing, SagaCommitting}
+
+// ErrStockNotReserved => order cannot ship before its stock is reserved
+var ErrStockNotReserved = errors.New("stock of order is not reserved")
+
+// ErrStockLinesReserved => lines of order cannot change while its stock is reserved, lines can be canceled instead
+var ErrStockLinesReserved = errors.New("stock of order lines is reserved")
+
+// recalculateBatchSize => orders which are read at once while totals are calculated again
+const recalculateBatchSize = 500
+
+// RecalculationReport => result of recalculating totals of stored orders, diffs are orders whose amounts (or line
+// amounts) are different from the calculated ones or which cannot be calculated
+type RecalculationReport struct {
+	DryRun   bool         `json:"dryRun"`
+	Checked  int          `json:"checked"`
+	Changed  int          `json:"changed"`
+	Repaired int          `json:"repaired"`
+	Diffs    []TotalsDiff `json:"diffs"`
+}
+
+type TotalsDiff struct {
+	OrderID      string      `json:"orderId"`
+	Before       OrderTotals `json:"before"`
+	After        OrderTotals `json:"after"`
+	LinesChanged bool        `json:"linesChanged"`
+	Repaired     bool        `json:"repaired"`
+	Error        string      `json:"error,omitempty"`
+}
+
+type OrderTotals struct {
+	Currency string       `json:"currency"`
+	Subtotal money.Amount `json:"subtotal" swaggertype:"number"`
+	Discount money.Amount `json:"discount" swaggertype:"number"`
+	Tax      money.Amount `json:"tax" swaggertype:"number"`
+	Shipping money.Amount `json:"shipping" swaggertype:"number"`
+	Total    money.Amount `json:"total" swaggertype:"number"`
+}
+
+// OrderGetRequest => request of generic endpoints. Exact filters, match clauses and filter are and-ed, first field of
+// sort has the highest priority.
 type OrderGetRequest struct {
 	ExactFilters map[string][]interface{} `json:"exact_filters"`
 	Fields       []string                 `json:"fields"`
@@ -75,5 +475,63 @@ type OrderGetRequest struct {
 		Parameter  string      `json:"parameter"`
 		Value      interface{} `json:"value"`
 	} `json:"match"`
-	Sort map[string]int `json:"sort"`
+	Filter *query.Clause  `json:"filter"`
+	Sort   map[string]int `json:"sort"`
+	// sortFields => fields of sort in order of request body (OrderGetRequest.UnmarshalJSON)
+	sortFields []string
+}
+
+// ErrInvalidFilter => a clause of generic endpoint request is not valid, error names the clause (e.g. filter.or[1])
+var ErrInvalidFilter = query.ErrInvalidQuery
+
+// Page sizes of order lists, list without limit has DefaultOrderPageSize orders
+const (
+	DefaultOrderPageSize = 20
+	MaxOrderPageSize     = 100
+)
+
+// OrderFilter => filter and page of order list, empty fields don't filter. Orders are sorted by creation, newest first.
+type OrderFilter struct {
+	Status string
+	UserId string
+	Sku    string
+	Limit  int
+	Offset int
+}
+
+// OrderPage => a page of orders and count of every order which matches the filter
+type OrderPage struct {
+	TotalCount int64          `json:"totalCount"`
+	Limit      int            `json:"limit"`
+	Offset     int            `json:"offset"`
+	Orders     []models.Order `json:"orders"`
+}
+
+// OrderSearchResult => a page of orders of full-text search, most relevant first
+type OrderSearchResult struct {
+	TotalCount int64            `json:"totalCount"`
+	Limit      int              `json:"limit"`
+	Offset     int              `json:"offset"`
+	Hits       []OrderSearchHit `json:"hits"`
 }
+
+// OrderSearchHit => order (as it is on Elasticsearch) with its relevance score and highlighted parts of fields which
+// match the query, matched words are in <em> tags
+type OrderSearchHit struct {
+	Score     float64                `json:"score"`
+	Highlight map[string][]string    `json:"highlight,omitempty"`
+	Order     map[string]interface{} `json:"order"`
+}
+
+// OrderHistoryEntry => an event in timeline of order, e.g. a shipment is delivered or a refund is issued
+type OrderHistoryEntry struct {
+	At     time.Time `json:"at"`
+	Event  string    `json:"event"`
+	Detail string    `json:"detail,omitempty"`
+}
+
+// ErrUserNotFound => user of order is not found on user-api
+var ErrUserNotFound = errors.New("user not found")
+
+// ErrAddressNotFound => address or invoice address of order is not an address of its user
+var ErrAddressNotFound = errors.New("address not found")
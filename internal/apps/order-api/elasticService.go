@@ -2,10 +2,14 @@ package order_api
 
 import (
 	"OrderUserProject/internal/configs"
+	"OrderUserProject/internal/query"
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"github.com/elastic/go-elasticsearch/v7"
 	"github.com/labstack/gommon/log"
+	"io"
+	"strings"
 )
 
 type ElasticService struct {
@@ -24,163 +28,22 @@ func NewElasticService(config *configs.Config) *ElasticService {
 	elasticClient, err := elasticsearch.NewClient(cfg)
 
 	if err != nil {
-		log.Errorf("Error creating the client: ", err)
+		log.Errorf("Error creating the client: %v", err)
 	}
 
 	elasticService := &ElasticService{Config: config, ElasticClient: elasticClient}
 	return elasticService
 }
 
-func (e *ElasticService) FromModelConvertToElasticQuery(req OrderGetRequest) map[string]interface{} {
-	// Get config for generic endpoint
-	config := configs.GetGenericEndpointConfig("elasticsearch")
-
-	searchBody := make(map[string]interface{})
-	query := make(map[string]interface{})
-	boolQuery := make(map[string]interface{})
-	mustClauses := make([]map[string]interface{}, 0)
-	mustNotClauses := make([]map[string]interface{}, 0)
-
-	// Creating query for exact filters
-	if len(req.ExactFilters) > 0 {
-		for field, values := range req.ExactFilters {
-			if len(values) > 0 {
-				mustClause := make(map[string]interface{})
-				mustClause["terms"] = map[string]interface{}{
-					config.ExactFilterArea[field]: values,
-				}
-				mustClauses = append(mustClauses, mustClause)
-			}
-		}
-		boolQuery["must"] = mustClauses
-		query["bool"] = boolQuery
-	}
-
-	// Creating query for match
-	if len(req.Match) > 0 {
-		for _, model := range req.Match {
-			if config.MatchFilterParameter[model.Parameter] == "eq" {
-				// => "match":{"total":1800}
-				mustClause := make(map[string]interface{})
-				mustClause["match"] = map[string]interface{}{
-					config.ExactFilterArea[model.MatchField]: model.Value,
-				}
-				mustClauses = append(mustClauses, mustClause)
-				// => "must": [{"match": {"total": 1800}}]
-				boolQuery["must"] = mustClauses
-				// =>  "bool": {"must": [{"match": {"total": 1800}}]}
-				query["bool"] = boolQuery
-			} else if config.MatchFilterParameter[model.Parameter] == "ne" {
-				// => "match":{"total":1800}
-				mustNotClause := make(map[string]interface{})
-				mustNotClause["match"] = map[string]interface{}{
-					config.ExactFilterArea[model.MatchField]: model.Value,
-				}
-				mustNotClauses = append(mustNotClauses, mustNotClause)
-				// => "must_not": [{"match": {"total": 1800}}]
-				boolQuery["must_not"] = mustNotClauses
-				// =>  "bool": {"must_not": [{"match": {"total": 1800}}]}
-				query["bool"] = boolQuery
-			} else if config.MatchFilterParameter[model.Parameter] == "gt" || config.MatchFilterParameter[model.Parameter] == "gte" ||
-				config.MatchFilterParameter[model.Parameter] == "lt" || config.MatchFilterParameter[model.Parameter] == "lte" {
-				// => "lt":2000
-				parameterQuery := make(map[string]interface{})
-				parameterQuery[config.MatchFilterParameter[model.Parameter]] = model.Value
-				// => "total":{"lt":2000}
-				rangeQuery := make(map[string]interface{})
-				rangeQuery[model.MatchField] = parameterQuery
-				// => "range": {"total":{"lt": 2000}}
-				mustClause := make(map[string]interface{})
-				mustClause["range"] = rangeQuery
-				mustClauses = append(mustClauses, mustClause)
-				// => "must": [{"range": {"total":{"lt": 2000}}}]
-				boolQuery["must"] = mustClauses
-				// =>  "bool": {"must": [{"range": {"total":{"lt": 2000}}}]}
-				query["bool"] = boolQuery
-			} else if config.MatchFilterParameter[model.Parameter] == "in" {
-				// => "terms":{"total":[1800,2000,2200]}
-				mustClause := make(map[string]interface{})
-				mustClause["terms"] = map[string]interface{}{
-					config.ExactFilterArea[model.MatchField]: model.Value,
-				}
-				mustClauses = append(mustClauses, mustClause)
-				// => "must": ["terms":{"total":[1800,2000,2200]}]
-				boolQuery["must"] = mustClauses
-				// =>  "bool": {"must": ["terms":{"total":[1800,2000,2200]}]}
-				query["bool"] = boolQuery
-			} else if config.MatchFilterParameter[model.Parameter] == "nin" {
-				// => "terms":{"total":[1900,2000,2200]}
-				mustNotClause := make(map[string]interface{})
-				mustNotClause["terms"] = map[string]interface{}{
-					config.ExactFilterArea[model.MatchField]: model.Value,
-				}
-				mustNotClauses = append(mustNotClauses, mustNotClause)
-				// => "must_not": ["terms":{"total":[1900,2000,2200]}]
-				boolQuery["must_not"] = mustNotClauses
-				// =>  "bool": {"must_not": ["terms":{"total":[1900,2000,2200]}]}
-				query["bool"] = boolQuery
-			} else if config.MatchFilterParameter[model.Parameter] == "exists" {
-				// => "exists":{"field":"total"}
-				mustClause := make(map[string]interface{})
-				mustClause["exists"] = map[string]interface{}{
-					"field": model.Value,
-				}
-				mustClauses = append(mustClauses, mustClause)
-				// => "must": ["exists":{"field":"total"}]
-				boolQuery["must"] = mustClauses
-				// =>  "bool": {"must": ["exists":{"field":"total"}]}
-				query["bool"] = boolQuery
-			} else if config.MatchFilterParameter[model.Parameter] == "regex" {
-				// => "regexp":{"product.name": ".*a.*"}
-				mustClause := make(map[string]interface{})
-				mustClause["regexp"] = map[string]interface{}{
-					model.MatchField: model.Value,
-				}
-				mustClauses = append(mustClauses, mustClause)
-				// => "must": ["regexp":{"product.name": ".*a.*"}]
-				boolQuery["must"] = mustClauses
-				// =>  "bool": {"must": ["regexp":{"product.name": ".*a.*"}]}
-				query["bool"] = boolQuery
-			}
-		}
-	}
-
-	searchBody["query"] = query
-
-	// Creating sort area
-	if len(req.Sort) > 0 {
-		for field, value := range req.Sort {
-			if value == -1 {
-				searchBody["sort"] = map[string]interface{}{
-					field: "desc",
-				}
-			} else if value == 1 {
-				searchBody["sort"] = map[string]interface{}{
-					field: "asc",
-				}
-			}
-		}
-	}
-
-	// Creating fields area
-	if len(req.Fields) > 0 {
-		var idCheck bool
-		for _, value := range req.Fields {
-			if value == "id" {
-				idCheck = true
-				break
-			} else {
-				idCheck = false
-			}
-		}
-
-		if !idCheck {
-			req.Fields = append(req.Fields, "id")
-		}
-		searchBody["_source"] = req.Fields
+func (e *ElasticService) FromModelConvertToElasticQuery(req OrderGetRequest) (map[string]interface{}, error) {
+	// Create query of exact filters, matches, filter, fields and sort provided in the request
+	orderQuery, err := NewOrderQuery(req)
+	if err != nil {
+		return nil, err
 	}
 
-	return searchBody
+	// Create search body with config of generic endpoint
+	return query.Elastic(orderQuery, configs.GetGenericEndpointConfig("elasticsearch")), nil
 }
 
 func (e *ElasticService) GetFromElasticsearch(query map[string]interface{}) ([]interface{}, error) {
@@ -226,3 +89,108 @@ func (e *ElasticService) GetFromElasticsearch(query map[string]interface{}) ([]i
 
 	return orders, nil
 }
+
+// orderSearchFields => fields of full-text search with their boosts, product names are the most relevant
+var orderSearchFields = []string{
+	"product.name^3",
+	"userName^2",
+	"address.city^2",
+	"address.district^2",
+	"address.address",
+	"invoiceAddress.city",
+	"invoiceAddress.district",
+	"invoiceAddress.address",
+}
+
+// NewOrderSearchQuery => full-text search body of query text. Words may have typos (fuzziness depends on length of
+// word), texts are analyzed as Turkish by the index mapping of order-elastic.
+func NewOrderSearchQuery(text string, limit int, offset int) map[string]interface{} {
+	highlightFields := make(map[string]interface{}, len(orderSearchFields))
+	for _, field := range orderSearchFields {
+		name, _, _ := strings.Cut(field, "^")
+		highlightFields[name] = map[string]interface{}{}
+	}
+
+	return map[string]interface{}{
+		"query": map[string]interface{}{
+			"multi_match": map[string]interface{}{
+				"query":     text,
+				"fields":    orderSearchFields,
+				"fuzziness": "AUTO",
+				// First letter of a word is not a typo, so search of short words doesn't match every order
+				"prefix_length": 1,
+			},
+		},
+		"highlight": map[string]interface{}{
+			"pre_tags":  []string{"<em>"},
+			"post_tags": []string{"</em>"},
+			"fields":    highlightFields,
+		},
+		"from":             offset,
+		"size":             limit,
+		"track_total_hits": true,
+	}
+}
+
+// SearchOrders => a page of orders which match the text, most relevant first. Limit is DefaultOrderPageSize if it
+// isn't set and it is never more than MaxOrderPageSize.
+func (e *ElasticService) SearchOrders(text string, limit int, offset int) (OrderSearchResult, error) {
+	result := OrderSearchResult{Limit: limit, Offset: offset, Hits: []OrderSearchHit{}}
+	if result.Limit <= 0 {
+		result.Limit = DefaultOrderPageSize
+	}
+	if result.Limit > MaxOrderPageSize {
+		result.Limit = MaxOrderPageSize
+	}
+	if result.Offset < 0 {
+		result.Offset = 0
+	}
+
+	buf := new(bytes.Buffer)
+	if err := json.NewEncoder(buf).Encode(NewOrderSearchQuery(text, result.Limit, result.Offset)); err != nil {
+		return OrderSearchResult{}, err
+	}
+
+	res, err := e.ElasticClient.Search(
+		e.ElasticClient.Search.WithIndex(e.Config.Elasticsearch.IndexName["OrderSave"]),
+		e.ElasticClient.Search.WithBody(buf),
+	)
+	if err != nil {
+		return OrderSearchResult{}, err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return OrderSearchResult{}, fmt.Errorf("orders cannot search: %v", res.String())
+	}
+
+	if err := decodeOrderSearchHits(res.Body, &result); err != nil {
+		return OrderSearchResult{}, err
+	}
+	return result, nil
+}
+
+// decodeOrderSearchHits => total count and hits of search response
+func decodeOrderSearchHits(body io.Reader, result *OrderSearchResult) error {
+	var r struct {
+		Hits struct {
+			Total struct {
+				Value int64 `json:"value"`
+			} `json:"total"`
+			Hits []struct {
+				Score     float64                `json:"_score"`
+				Source    map[string]interface{} `json:"_source"`
+				Highlight map[string][]string    `json:"highlight"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.NewDecoder(body).Decode(&r); err != nil {
+		return err
+	}
+
+	result.TotalCount = r.Hits.Total.Value
+	for _, hit := range r.Hits.Hits {
+		result.Hits = append(result.Hits, OrderSearchHit{Score: hit.Score, Highlight: hit.Highlight, Order: hit.Source})
+	}
+	return nil
+}
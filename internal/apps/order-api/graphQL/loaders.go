@@ -0,0 +1,47 @@
+package graphQL
+
+import (
+	"OrderUserProject/pkg/dataloader"
+	"context"
+)
+
+// loadersKey => key of loaders of a request in context
+type loadersKey struct{}
+
+// Loaders => batch loaders of a GraphQL request, e.g. users of a list of orders are read with one user-api request
+type Loaders struct {
+	Users *dataloader.Loader
+}
+
+// WithLoaders => context with new loaders, loaders cache their values, so every request must have its own loaders
+func (r *Resolver) WithLoaders(ctx context.Context) context.Context {
+	loaders := &Loaders{
+		Users: dataloader.New(func(ids []string) (map[string]interface{}, error) {
+			users, err := r.Users.GetUsersByIds(ids)
+			if err != nil {
+				return nil, err
+			}
+			values := map[string]interface{}{}
+			for _, user := range users {
+				values[user.ID] = user
+			}
+			return values, nil
+		}),
+	}
+	return context.WithValue(ctx, loadersKey{}, loaders)
+}
+
+// loadUser => thunk of user of id, value is nil if user is not found. Without loaders in context (e.g. in jobs) user
+// is read directly.
+func (r *Resolver) loadUser(ctx context.Context, id string) func() (interface{}, error) {
+	if loaders, ok := ctx.Value(loadersKey{}).(*Loaders); ok {
+		return loaders.Users.Load(id)
+	}
+	return func() (interface{}, error) {
+		user, err := r.Users.GetUser(id)
+		if err != nil {
+			return nil, nil
+		}
+		return user, nil
+	}
+}
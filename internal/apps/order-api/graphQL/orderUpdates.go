@@ -0,0 +1,60 @@
+package graphQL
+
+import (
+	"OrderUserProject/internal/models"
+	"context"
+	"sync"
+)
+
+// subscriptionBufferSize => changed orders which wait for a slow subscriber, newer changes are dropped after it
+const subscriptionBufferSize = 16
+
+// OrderUpdates => changed orders of 'OrderChanged' events are sent to GraphQL subscriptions of this order-api instance
+type OrderUpdates struct {
+	mutex       sync.RWMutex
+	subscribers map[*orderSubscriber]struct{}
+}
+
+type orderSubscriber struct {
+	match   func(order models.Order) bool
+	updates chan interface{}
+}
+
+func NewOrderUpdates() *OrderUpdates {
+	return &OrderUpdates{subscribers: map[*orderSubscriber]struct{}{}}
+}
+
+// Publish => send changed order to subscriptions which match it, publisher never waits for a subscriber
+func (u *OrderUpdates) Publish(order models.Order) {
+	u.mutex.RLock()
+	defer u.mutex.RUnlock()
+
+	for subscriber := range u.subscribers {
+		if !subscriber.match(order) {
+			continue
+		}
+		select {
+		case subscriber.updates <- order:
+		default:
+		}
+	}
+}
+
+// Subscribe => channel of changed orders which match, channel is closed when context is done
+func (u *OrderUpdates) Subscribe(ctx context.Context, match func(order models.Order) bool) chan interface{} {
+	subscriber := &orderSubscriber{match: match, updates: make(chan interface{}, subscriptionBufferSize)}
+
+	u.mutex.Lock()
+	u.subscribers[subscriber] = struct{}{}
+	u.mutex.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		u.mutex.Lock()
+		delete(u.subscribers, subscriber)
+		close(subscriber.updates)
+		u.mutex.Unlock()
+	}()
+
+	return subscriber.updates
+}
@@ -1,48 +1,52 @@
 package graphQL
 
-import "fmt"
-
-func GenerateGraphQLQuery(status string) string {
+// GenerateGraphQLQuery => query of orders with status, status is a variable of query, so it is never parsed as query
+func GenerateGraphQLQuery(status string) (string, map[string]interface{}) {
 	// Create GraphQL query
-	query := fmt.Sprintf(`
-		query {
-			orders(status: "%s") {
-				id
-				userId
-				status
-				address {
+	query := `
+		query ($status: String) {
+			orders(status: $status) {
+				totalCount
+				orders {
 					id
-					address
-					city
-					district
-					type
-					default {
-						isDefaultInvoiceAddress
-						isDefaultRegularAddress
+					userId
+					status
+					address {
+						id
+						address
+						city
+						district
+						type
+						default {
+							isDefaultInvoiceAddress
+							isDefaultRegularAddress
+						}
 					}
-				}
-				invoiceAddress {
-					id
-					address
-					city
-					district
-					type
-					default {
-						isDefaultInvoiceAddress
-						isDefaultRegularAddress
+					invoiceAddress {
+						id
+						address
+						city
+						district
+						type
+						default {
+							isDefaultInvoiceAddress
+							isDefaultRegularAddress
+						}
 					}
+					product {
+						sku
+						name
+						quantity
+						price
+					}
+					currency
+					total
+					createdAt
+					updatedAt
 				}
-				product {
-					name
-					quantity
-					price
-				}
-				total
-				createdAt
-				updatedAt
 			}
 		}
-	`, status)
+	`
 
-	return query
+	return query, map[string]interface{}{"status": status}
 }
@@ -0,0 +1,264 @@
+package graphQL
+
+import (
+	"OrderUserProject/internal/apps/order-api"
+	"OrderUserProject/internal/configs"
+	"OrderUserProject/internal/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-playground/validator/v10"
+	"github.com/graphql-go/graphql"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrOrderNotFound => order with id is not found (or it is deleted)
+var ErrOrderNotFound = errors.New("order not found")
+
+// Hooks => side effects of REST API (kafka events, inventory saga) which mutations share, they are set by handler.
+// Context is the context of GraphQL request.
+type Hooks struct {
+	// Created => an error cancels the created order, it is returned to client
+	Created func(ctx context.Context, order models.Order) error
+	// Updating => order is checked before update, an error rejects the update and it is returned to client
+	Updating func(ctx context.Context, order models.Order) error
+	Updated  func(ctx context.Context, order models.Order)
+	// Deleted => deletedOrder is nil if order cannot be read before delete
+	Deleted func(ctx context.Context, id string, deletedOrder *models.Order)
+}
+
+// Resolver => resolvers of schema, orders are read and changed with order service like REST API, users are read
+// with user service in batches (see Loaders). Subscriptions get changed orders from Updates.
+type Resolver struct {
+	Service   order_api.IOrderService
+	Users     order_api.IUserService
+	Updates   *OrderUpdates
+	Config    *configs.Config
+	Validator *validator.Validate
+	Hooks     Hooks
+}
+
+func NewResolver(service order_api.IOrderService, users order_api.IUserService, updates *OrderUpdates, config *configs.Config, v *validator.Validate, hooks Hooks) *Resolver {
+	resolver := &Resolver{Service: service, Users: users, Updates: updates, Config: config, Validator: v, Hooks: hooks}
+	return resolver
+}
+
+// order => unknown order is null
+func (r *Resolver) order(p graphql.ResolveParams) (interface{}, error) {
+	order, err := r.Service.GetOrderById(p.Args["id"].(string))
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+func (r *Resolver) orders(p graphql.ResolveParams) (interface{}, error) {
+	filter := pageFilter(p.Args)
+	filter.UserId, _ = p.Args["userId"].(string)
+	return r.Service.FindOrders(filter)
+}
+
+func (r *Resolver) list(p graphql.ResolveParams) (interface{}, error) {
+	return r.Service.GetAll()
+}
+
+func (r *Resolver) user(p graphql.ResolveParams) (interface{}, error) {
+	id := p.Args["id"].(string)
+	load := r.loadUser(p.Context, id)
+	return func() (interface{}, error) {
+		user, err := load()
+		if err != nil || user == nil {
+			return nil, fmt.Errorf("%w: %v", order_api.ErrUserNotFound, id)
+		}
+		return user, nil
+	}, nil
+}
+
+// orderUser => user of order from user-api, deleted user is null. Users of orders in a list are read with one request.
+func (r *Resolver) orderUser(p graphql.ResolveParams) (interface{}, error) {
+	order, ok := p.Source.(models.Order)
+	if !ok {
+		return nil, nil
+	}
+	load := r.loadUser(p.Context, order.UserId)
+	return func() (interface{}, error) {
+		user, err := load()
+		if err != nil {
+			return nil, nil
+		}
+		return user, nil
+	}, nil
+}
+
+func (r *Resolver) orderHistory(p graphql.ResolveParams) (interface{}, error) {
+	order, ok := p.Source.(models.Order)
+	if !ok {
+		return nil, nil
+	}
+	return order_api.OrderHistory(order), nil
+}
+
+func (r *Resolver) userOrders(p graphql.ResolveParams) (interface{}, error) {
+	user, ok := p.Source.(order_api.UserResponse)
+	if !ok {
+		return nil, nil
+	}
+	filter := pageFilter(p.Args)
+	filter.UserId = user.ID
+	return r.Service.FindOrders(filter)
+}
+
+// createOrder => order is created like POST /api/orders, user, addresses and products are checked
+func (r *Resolver) createOrder(p graphql.ResolveParams) (interface{}, error) {
+	var request order_api.OrderCreateRequest
+	if err := decodeInput(p.Args["input"], &request); err != nil {
+		return nil, err
+	}
+	if err := r.Validator.Struct(request); err != nil {
+		return nil, fmt.Errorf("invalid order: %v", err)
+	}
+
+	order, err := r.Service.PrepareOrder(request.UserId, request.Address, request.InvoiceAddress, request.Product,
+		r.Config.HttpClient.UserAPI, r.Config.HttpClient.ProductAPI)
+	if err != nil {
+		return nil, err
+	}
+	// Status is derived from shipments, shipping fee is before promotions
+	order.Status = order_api.NotShippedStatus
+	order.Shipping = r.Config.Pricing.ShippingFee
+	order.CouponCode = request.CouponCode
+
+	result, err := r.Service.Insert(order)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.Hooks.Created != nil {
+		if err := r.Hooks.Created(p.Context, result); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// updateOrder => order is updated like PUT /api/orders, status is kept and promotions are evaluated again
+func (r *Resolver) updateOrder(p graphql.ResolveParams) (interface{}, error) {
+	var request order_api.OrderUpdateRequest
+	if err := decodeInput(p.Args["input"], &request); err != nil {
+		return nil, err
+	}
+	if err := r.Validator.Struct(request); err != nil {
+		return nil, fmt.Errorf("invalid order: %v", err)
+	}
+
+	oldOrder, err := r.Service.GetOrderById(request.ID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOrderNotFound, request.ID)
+	}
+	// Closed orders and orders whose shipment is prepared cannot change
+	if err := order_api.CheckUpdatable(oldOrder); err != nil {
+		return nil, err
+	}
+
+	order, err := r.Service.PrepareOrder(request.UserId, request.Address, request.InvoiceAddress, request.Product,
+		r.Config.HttpClient.UserAPI, r.Config.HttpClient.ProductAPI)
+	if err != nil {
+		return nil, err
+	}
+	order.ID = oldOrder.ID
+	order.Status = oldOrder.Status
+	order.CouponCode = oldOrder.CouponCode
+	order.Promotions = oldOrder.Promotions
+	order.Discount = oldOrder.Discount
+	order.Shipping = oldOrder.Shipping
+
+	if r.Hooks.Updating != nil {
+		if err := r.Hooks.Updating(p.Context, order); err != nil {
+			return nil, err
+		}
+	}
+
+	result, err := r.Service.Update(order)
+	if err != nil || result == false {
+		return nil, fmt.Errorf("order cannot be updated: %v", err)
+	}
+
+	// Total and createdAt are set in service, so stored order is returned
+	updatedOrder, err := r.Service.GetOrderById(order.ID)
+	if err != nil {
+		return nil, err
+	}
+	if r.Hooks.Updated != nil {
+		r.Hooks.Updated(p.Context, updatedOrder)
+	}
+	return updatedOrder, nil
+}
+
+// deleteOrder => order is deleted like DELETE /api/orders/{id}
+func (r *Resolver) deleteOrder(p graphql.ResolveParams) (interface{}, error) {
+	id := p.Args["id"].(string)
+
+	// Order before delete is used for domain event
+	var deletedOrder *models.Order
+	if order, err := r.Service.GetOrderById(id); err == nil {
+		deletedOrder = &order
+	}
+
+	var result bool
+	var err error
+	if r.Config.SoftDelete.Enabled {
+		result, err = r.Service.SoftDelete(id)
+	} else {
+		result, err = r.Service.Delete(id)
+	}
+	if err != nil || result == false {
+		return nil, fmt.Errorf("%w: %v", ErrOrderNotFound, id)
+	}
+
+	if r.Hooks.Deleted != nil {
+		r.Hooks.Deleted(p.Context, id, deletedOrder)
+	}
+	return true, nil
+}
+
+func (r *Resolver) subscribeOrderUpdated(p graphql.ResolveParams) (interface{}, error) {
+	id := p.Args["id"].(string)
+	return r.Updates.Subscribe(p.Context, func(order models.Order) bool {
+		return order.ID == id
+	}), nil
+}
+
+func (r *Resolver) subscribeOrdersForUser(p graphql.ResolveParams) (interface{}, error) {
+	userId := p.Args["userId"].(string)
+	return r.Updates.Subscribe(p.Context, func(order models.Order) bool {
+		return order.UserId == userId
+	}), nil
+}
+
+// orderUpdate => changed order of subscription is the source of its field
+func (r *Resolver) orderUpdate(p graphql.ResolveParams) (interface{}, error) {
+	return p.Source, nil
+}
+
+// pageFilter => filter of status, sku, limit and offset arguments
+func pageFilter(args map[string]interface{}) order_api.OrderFilter {
+	var filter order_api.OrderFilter
+	filter.Status, _ = args["status"].(string)
+	filter.Sku, _ = args["sku"].(string)
+	filter.Limit, _ = args["limit"].(int)
+	filter.Offset, _ = args["offset"].(int)
+	return filter
+}
+
+// decodeInput => input object argument to request of REST API, both have the same json names
+func decodeInput(input interface{}, request interface{}) error {
+	data, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, request)
+}
@@ -1,140 +1,393 @@
 package graphQL
 
 import (
-	"OrderUserProject/internal/configs"
-	"OrderUserProject/internal/models"
+	"OrderUserProject/pkg/money"
 	"context"
-	"errors"
 	"fmt"
 	"github.com/graphql-go/graphql"
-	"go.mongodb.org/mongo-driver/bson"
-	"os"
 )
 
-var orderType = graphql.NewObject(
-	graphql.ObjectConfig{
+// amountType => decimal amount (money.Amount) as number, e.g. 1433.9
+var amountType = graphql.NewScalar(graphql.ScalarConfig{
+	Name:        "Amount",
+	Description: "Decimal amount of money with 2 fraction digits in currency of order",
+	Serialize: func(value interface{}) interface{} {
+		switch amount := value.(type) {
+		case money.Amount:
+			return amount.Float64()
+		case *money.Amount:
+			if amount != nil {
+				return amount.Float64()
+			}
+		}
+		return nil
+	},
+})
+
+// rateType => percent rate (money.Rate) as number, e.g. VAT 20
+var rateType = graphql.NewScalar(graphql.ScalarConfig{
+	Name:        "Rate",
+	Description: "Percent rate with 2 fraction digits, e.g. 20 or 0.5",
+	Serialize: func(value interface{}) interface{} {
+		if rate, ok := value.(money.Rate); ok {
+			return float64(rate.BasisPoints()) / 100
+		}
+		return nil
+	},
+})
+
+var addressDefaultType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "AddressDefault",
+	Fields: graphql.Fields{
+		"isDefaultInvoiceAddress": &graphql.Field{Type: graphql.Boolean},
+		"isDefaultRegularAddress": &graphql.Field{Type: graphql.Boolean},
+	},
+})
+
+// addressType => address of user and address snapshots of order have the same fields
+var addressType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Address",
+	Fields: graphql.Fields{
+		"id":       &graphql.Field{Type: graphql.String},
+		"address":  &graphql.Field{Type: graphql.String},
+		"city":     &graphql.Field{Type: graphql.String},
+		"district": &graphql.Field{Type: graphql.String},
+		"type":     &graphql.Field{Type: graphql.NewList(graphql.String)},
+		"default":  &graphql.Field{Type: addressDefaultType},
+	},
+})
+
+var orderLineType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "OrderLine",
+	Description: "Line of order, name, price (without VAT) and VAT rate are snapshot of product catalog",
+	Fields: graphql.Fields{
+		"sku":      &graphql.Field{Type: graphql.String},
+		"name":     &graphql.Field{Type: graphql.String},
+		"quantity": &graphql.Field{Type: graphql.Int},
+		"price":    &graphql.Field{Type: amountType},
+		"taxRate":  &graphql.Field{Type: rateType},
+		"discount": &graphql.Field{Type: amountType},
+		"tax":      &graphql.Field{Type: amountType},
+		"total":    &graphql.Field{Type: amountType},
+	},
+})
+
+var appliedPromotionType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "AppliedPromotion",
+	Fields: graphql.Fields{
+		"promotionId": &graphql.Field{Type: graphql.String},
+		"code":        &graphql.Field{Type: graphql.String},
+		"name":        &graphql.Field{Type: graphql.String},
+		"type":        &graphql.Field{Type: graphql.String},
+		"sku":         &graphql.Field{Type: graphql.String},
+		"amount":      &graphql.Field{Type: amountType},
+	},
+})
+
+var shipmentItemType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "ShipmentItem",
+	Fields: graphql.Fields{
+		"sku":      &graphql.Field{Type: graphql.String},
+		"quantity": &graphql.Field{Type: graphql.Int},
+	},
+})
+
+var shipmentType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Shipment",
+	Fields: graphql.Fields{
+		"id":             &graphql.Field{Type: graphql.String},
+		"carrier":        &graphql.Field{Type: graphql.String},
+		"trackingNumber": &graphql.Field{Type: graphql.String},
+		"status":         &graphql.Field{Type: graphql.String},
+		"items":          &graphql.Field{Type: graphql.NewList(shipmentItemType)},
+		"shippedAt":      &graphql.Field{Type: graphql.DateTime},
+		"deliveredAt":    &graphql.Field{Type: graphql.DateTime},
+		"createdAt":      &graphql.Field{Type: graphql.DateTime},
+		"updatedAt":      &graphql.Field{Type: graphql.DateTime},
+	},
+})
+
+var canceledLineType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "CanceledLine",
+	Fields: graphql.Fields{
+		"sku":      &graphql.Field{Type: graphql.String},
+		"name":     &graphql.Field{Type: graphql.String},
+		"quantity": &graphql.Field{Type: graphql.Int},
+	},
+})
+
+var cancellationType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Cancellation",
+	Fields: graphql.Fields{
+		"id":         &graphql.Field{Type: graphql.String},
+		"reason":     &graphql.Field{Type: graphql.String},
+		"note":       &graphql.Field{Type: graphql.String},
+		"partial":    &graphql.Field{Type: graphql.Boolean},
+		"lines":      &graphql.Field{Type: graphql.NewList(canceledLineType)},
+		"refundId":   &graphql.Field{Type: graphql.String},
+		"canceledAt": &graphql.Field{Type: graphql.DateTime},
+	},
+})
+
+var refundType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Refund",
+	Fields: graphql.Fields{
+		"id":             &graphql.Field{Type: graphql.String},
+		"cancellationId": &graphql.Field{Type: graphql.String},
+		"amount":         &graphql.Field{Type: amountType},
+		"currency":       &graphql.Field{Type: graphql.String},
+		"method":         &graphql.Field{Type: graphql.String},
+		"status":         &graphql.Field{Type: graphql.String},
+		"createdAt":      &graphql.Field{Type: graphql.DateTime},
+		"updatedAt":      &graphql.Field{Type: graphql.DateTime},
+	},
+})
+
+var historyEntryType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "OrderHistoryEntry",
+	Description: "Event in timeline of order, e.g. ShipmentDelivered or RefundIssued",
+	Fields: graphql.Fields{
+		"at":     &graphql.Field{Type: graphql.DateTime},
+		"event":  &graphql.Field{Type: graphql.String},
+		"detail": &graphql.Field{Type: graphql.String},
+	},
+})
+
+var orderLineInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "OrderLineInput",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"sku":      &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"quantity": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.Int)},
+	},
+})
+
+var createOrderInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name:        "CreateOrderInput",
+	Description: "Order of user, address and invoiceAddress are ids of addresses of user",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"userId":         &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"address":        &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"invoiceAddress": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"product":        &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(orderLineInputType)))},
+		"couponCode":     &graphql.InputObjectFieldConfig{Type: graphql.String},
+	},
+})
+
+var updateOrderInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name:        "UpdateOrderInput",
+	Description: "Addresses and lines of order, orders with shipments or closed orders cannot be updated",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"id":             &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"userId":         &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"address":        &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"invoiceAddress": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"product":        &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(orderLineInputType)))},
+	},
+})
+
+// pageArgs => filter and page arguments of order lists
+func pageArgs(withUser bool) graphql.FieldConfigArgument {
+	args := graphql.FieldConfigArgument{
+		"status": &graphql.ArgumentConfig{Type: graphql.String, Description: "e.g. Not Shipped, Shipped, Delivered"},
+		"sku":    &graphql.ArgumentConfig{Type: graphql.String, Description: "orders which have a line of product"},
+		"limit":  &graphql.ArgumentConfig{Type: graphql.Int, Description: "page size, 20 by default and 100 at most"},
+		"offset": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 0},
+	}
+	if withUser {
+		args["userId"] = &graphql.ArgumentConfig{Type: graphql.String}
+	}
+	return args
+}
+
+// NewSchema => schema of orders and users. Queries and mutations are resolved with order service, users and their
+// addresses come from user-api.
+//
+//	{ orders(status: "Shipped", limit: 10) { totalCount orders { id total user { name } product { sku quantity } } } }
+//	mutation { deleteOrder(id: "e9caaa02-5c6a-4d2f-b795-11680de70401") }
+//	subscription { orderUpdated(id: "e9caaa02-5c6a-4d2f-b795-11680de70401") { status shipments { status } } }
+func NewSchema(resolver *Resolver) (graphql.Schema, error) {
+	// Order and user refer to each other, so their fields are thunks
+	var orderType, userType, orderPageType *graphql.Object
+
+	orderType = graphql.NewObject(graphql.ObjectConfig{
 		Name: "Order",
+		Fields: (graphql.FieldsThunk)(func() graphql.Fields {
+			return graphql.Fields{
+				"id":             &graphql.Field{Type: graphql.String},
+				"userId":         &graphql.Field{Type: graphql.String},
+				"status":         &graphql.Field{Type: graphql.String},
+				"address":        &graphql.Field{Type: addressType},
+				"invoiceAddress": &graphql.Field{Type: addressType},
+				"product":        &graphql.Field{Type: graphql.NewList(orderLineType)},
+				"currency":       &graphql.Field{Type: graphql.String},
+				"subtotal":       &graphql.Field{Type: amountType},
+				"discount":       &graphql.Field{Type: amountType},
+				"tax":            &graphql.Field{Type: amountType},
+				"shipping":       &graphql.Field{Type: amountType},
+				"total":          &graphql.Field{Type: amountType},
+				"couponCode":     &graphql.Field{Type: graphql.String},
+				"promotions":     &graphql.Field{Type: graphql.NewList(appliedPromotionType)},
+				"shipments":      &graphql.Field{Type: graphql.NewList(shipmentType)},
+				"cancellations":  &graphql.Field{Type: graphql.NewList(cancellationType)},
+				"refunds":        &graphql.Field{Type: graphql.NewList(refundType)},
+				"createdAt":      &graphql.Field{Type: graphql.DateTime},
+				"updatedAt":      &graphql.Field{Type: graphql.DateTime},
+				"history": &graphql.Field{
+					Type:        graphql.NewList(historyEntryType),
+					Description: "Timeline of order, oldest first",
+					Resolve:     resolver.orderHistory,
+				},
+				"user": &graphql.Field{
+					Type:        userType,
+					Description: "User of order, null if user is deleted",
+					Resolve:     resolver.orderUser,
+				},
+			}
+		}),
+	})
+
+	orderPageType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "OrderPage",
 		Fields: graphql.Fields{
-			"id": &graphql.Field{
-				Type: graphql.String,
-			},
-			"userId": &graphql.Field{
-				Type: graphql.String,
-			},
-			"status": &graphql.Field{
-				Type: graphql.String,
-			},
-			"total": &graphql.Field{
-				Type: graphql.Float,
+			"totalCount": &graphql.Field{Type: graphql.Int},
+			"limit":      &graphql.Field{Type: graphql.Int},
+			"offset":     &graphql.Field{Type: graphql.Int},
+			"orders":     &graphql.Field{Type: graphql.NewList(orderType)},
+		},
+	})
+
+	userType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "User",
+		Fields: graphql.Fields{
+			"id":        &graphql.Field{Type: graphql.String},
+			"name":      &graphql.Field{Type: graphql.String},
+			"email":     &graphql.Field{Type: graphql.String},
+			"addresses": &graphql.Field{Type: graphql.NewList(addressType)},
+			"orders": &graphql.Field{
+				Type:        orderPageType,
+				Description: "Orders of user, newest first",
+				Args:        pageArgs(false),
+				Resolve:     resolver.userOrders,
 			},
 		},
-	},
-)
+	})
 
-var queryType = graphql.NewObject(
-	graphql.ObjectConfig{
+	queryType := graphql.NewObject(graphql.ObjectConfig{
 		Name: "Query",
 		Fields: graphql.Fields{
-			/* Get (read) single product by id
-			   http://localhost:30011/order?query={order(id:"e9caaa02-5c6a-4d2f-b795-11680de70401"){userId,status,total}}
-			*/
 			"order": &graphql.Field{
 				Type:        orderType,
 				Description: "Get order by id",
 				Args: graphql.FieldConfigArgument{
-					"id": &graphql.ArgumentConfig{
-						Type: graphql.String,
-					},
-				},
-				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
-					// Environment value
-					env := os.Getenv("environment")
-
-					// Get config
-					config := configs.GetConfig(env)
-
-					// Get collection
-					mongoOrderCollection := configs.ConnectDB(config.Database.Connection).
-						Database(config.Database.DatabaseName).
-						Collection(config.Database.OrderCollectionName)
-
-					id, ok := p.Args["id"].(string)
-
-					if ok {
-						cursor, err := mongoOrderCollection.Find(context.Background(),
-							bson.M{"_id": id})
-						if err != nil {
-							return nil, err
-						}
-						defer cursor.Close(context.Background())
-
-						var orders []models.Order
-						for cursor.Next(context.Background()) {
-							var order models.Order
-							err := cursor.Decode(&order)
-							if err != nil {
-								return nil, err
-							}
-							orders = append(orders, order)
-						}
-
-						return orders, nil
-					} else {
-						return nil, errors.New("bad request for graphQL")
-					}
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
 				},
+				Resolve: resolver.order,
+			},
+			"orders": &graphql.Field{
+				Type:        orderPageType,
+				Description: "Get a page of orders with filter, newest first",
+				Args:        pageArgs(true),
+				Resolve:     resolver.orders,
 			},
-			/* Get (read) product list
-			   http://localhost:30011/order?query={list{id,userId,status,total}}
-			*/
 			"list": &graphql.Field{
-				Type:        graphql.NewList(orderType),
-				Description: "Get order list",
-				Resolve: func(params graphql.ResolveParams) (interface{}, error) {
-					// Environment value
-					env := os.Getenv("environment")
-
-					// Get config
-					config := configs.GetConfig(env)
-
-					// Get collection
-					mongoOrderCollection := configs.ConnectDB(config.Database.Connection).
-						Database(config.Database.DatabaseName).
-						Collection(config.Database.OrderCollectionName)
-
-					cursor, err := mongoOrderCollection.Find(context.Background(),
-						bson.M{})
-					if err != nil {
-						return nil, err
-					}
-					defer cursor.Close(context.Background())
-
-					var orders []models.Order
-					for cursor.Next(context.Background()) {
-						var order models.Order
-						err := cursor.Decode(&order)
-						if err != nil {
-							return nil, err
-						}
-						orders = append(orders, order)
-					}
-
-					return orders, nil
+				Type:              graphql.NewList(orderType),
+				Description:       "Get order list",
+				DeprecationReason: "orders has filter and pagination",
+				Resolve:           resolver.list,
+			},
+			"user": &graphql.Field{
+				Type:        userType,
+				Description: "Get user with addresses by id",
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
 				},
+				Resolve: resolver.user,
 			},
 		},
 	})
 
-var Schema, _ = graphql.NewSchema(
-	graphql.SchemaConfig{
-		Query: queryType,
-	},
-)
+	mutationType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"createOrder": &graphql.Field{
+				Type:        orderType,
+				Description: "Create order, amounts are calculated from lines and promotions",
+				Args: graphql.FieldConfigArgument{
+					"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(createOrderInputType)},
+				},
+				Resolve: resolver.createOrder,
+			},
+			"updateOrder": &graphql.Field{
+				Type:        orderType,
+				Description: "Update addresses and lines of order",
+				Args: graphql.FieldConfigArgument{
+					"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(updateOrderInputType)},
+				},
+				Resolve: resolver.updateOrder,
+			},
+			"deleteOrder": &graphql.Field{
+				Type:        graphql.Boolean,
+				Description: "Delete order, it is soft deleted if soft delete is enabled",
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: resolver.deleteOrder,
+			},
+		},
+	})
+
+	// Subscriptions => changed orders of 'OrderChanged' events, see ServeSubscriptions for websocket protocol
+	subscriptionType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Subscription",
+		Fields: graphql.Fields{
+			"orderUpdated": &graphql.Field{
+				Type:        orderType,
+				Description: "Order after each change of it (status, shipments, cancellations etc.)",
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Subscribe: resolver.subscribeOrderUpdated,
+				Resolve:   resolver.orderUpdate,
+			},
+			"ordersForUser": &graphql.Field{
+				Type:        orderType,
+				Description: "Orders of user after each change of them, created orders too",
+				Args: graphql.FieldConfigArgument{
+					"userId": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Subscribe: resolver.subscribeOrdersForUser,
+				Resolve:   resolver.orderUpdate,
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{
+		Query:        queryType,
+		Mutation:     mutationType,
+		Subscription: subscriptionType,
+	})
+}
+
+// Request => body of POST /api/graphql
+type Request struct {
+	Query         string                 `json:"query" validate:"required"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// ExecuteQuery => run query (or mutation) with variables, context is passed to resolvers
+func ExecuteQuery(ctx context.Context, query string, variables map[string]interface{}, schema graphql.Schema) *graphql.Result {
+	return Execute(ctx, Request{Query: query, Variables: variables}, schema)
+}
 
-func ExecuteQuery(query string, schema graphql.Schema) *graphql.Result {
+// Execute => run operation of request, operation name selects one of operations of a document with many operations
+func Execute(ctx context.Context, request Request, schema graphql.Schema) *graphql.Result {
 	result := graphql.Do(graphql.Params{
-		Schema:        schema,
-		RequestString: query,
+		Schema:         schema,
+		RequestString:  request.Query,
+		VariableValues: request.Variables,
+		OperationName:  request.OperationName,
+		Context:        ctx,
 	})
 	if len(result.Errors) > 0 {
 		fmt.Printf("errors: %v", result.Errors)
@@ -0,0 +1,259 @@
+package graphQL
+
+import (
+	"OrderUserProject/internal/apps/order-api"
+	"OrderUserProject/internal/configs"
+	"OrderUserProject/internal/models"
+	"OrderUserProject/pkg/money"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/go-playground/assert/v2"
+	"github.com/go-playground/validator/v10"
+	"go.mongodb.org/mongo-driver/mongo"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeOrderService implements the methods of IOrderService which resolvers use, others panic
+type fakeOrderService struct {
+	order_api.IOrderService
+	orders  map[string]models.Order
+	filters []order_api.OrderFilter
+	deleted []string
+}
+
+func (f *fakeOrderService) GetOrderById(id string) (models.Order, error) {
+	order, ok := f.orders[id]
+	if !ok {
+		return models.Order{}, mongo.ErrNoDocuments
+	}
+	return order, nil
+}
+
+func (f *fakeOrderService) FindOrders(filter order_api.OrderFilter) (order_api.OrderPage, error) {
+	f.filters = append(f.filters, filter)
+	page := order_api.OrderPage{Limit: filter.Limit, Offset: filter.Offset}
+	for _, order := range f.orders {
+		if (filter.Status == "" || order.Status == filter.Status) && (filter.UserId == "" || order.UserId == filter.UserId) {
+			page.Orders = append(page.Orders, order)
+		}
+	}
+	page.TotalCount = int64(len(page.Orders))
+	return page, nil
+}
+
+// fakeUserService => users of user-api, batches are recorded
+type fakeUserService struct {
+	users   map[string]order_api.UserResponse
+	batches [][]string
+}
+
+func (f *fakeUserService) GetUser(userId string) (order_api.UserResponse, error) {
+	user, ok := f.users[userId]
+	if !ok {
+		return order_api.UserResponse{}, order_api.ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (f *fakeUserService) GetUsersByIds(ids []string) ([]order_api.UserResponse, error) {
+	f.batches = append(f.batches, ids)
+	var users []order_api.UserResponse
+	for _, id := range ids {
+		if user, ok := f.users[id]; ok {
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
+
+func (f *fakeOrderService) PrepareOrder(userId string, addressId string, invoiceAddressId string, lines []order_api.OrderProductRequest, userURL string, productURL string) (models.Order, error) {
+	order := models.Order{UserId: userId, Currency: "TRY", Address: models.Address{ID: addressId}, InvoiceAddress: models.Address{ID: invoiceAddressId}}
+	for _, line := range lines {
+		order.Product = append(order.Product, models.OrderProduct{Sku: line.Sku, Quantity: line.Quantity, Price: money.MustParse("100")})
+	}
+	return order, nil
+}
+
+func (f *fakeOrderService) Insert(order models.Order) (models.Order, error) {
+	order.ID = "0b6f2b43-6f5e-4a8e-9c53-5c8b7f3f2a11"
+	order.Total = money.MustParse("269.90")
+	f.orders[order.ID] = order
+	return order, nil
+}
+
+func (f *fakeOrderService) SoftDelete(id string) (bool, error) {
+	f.deleted = append(f.deleted, id)
+	return true, nil
+}
+
+const (
+	orderId = "2b45ac31-6906-4e1e-82db-d9bcdbdb2143"
+	userId  = "fcd20a19-6171-4737-a2ed-23e293cae7b5"
+)
+
+func newTestSchema(t *testing.T, hooks Hooks) (*fakeOrderService, *fakeUserService, func(query string, variables map[string]interface{}) (map[string]interface{}, string)) {
+	shippedAt := time.Date(2023, 5, 2, 9, 0, 0, 0, time.UTC)
+	service := &fakeOrderService{
+		orders: map[string]models.Order{orderId: {
+			ID: orderId, UserId: userId, Status: order_api.ShippedStatus, Currency: "TRY",
+			Address: models.Address{ID: "130beada-8339-4ee6-a754-725f43b8da98", City: "İstanbul", District: "Beşiktaş"},
+			Product: []models.OrderProduct{{Sku: "AIRPODS-3", Name: "AirPods", Quantity: 3, Price: money.MustParse("100"),
+				TaxRate: money.MustParseRate("20"), Total: money.MustParse("360")}},
+			Total:     money.MustParse("389.90"),
+			Shipments: []models.Shipment{{ID: "shipment", Status: order_api.ShipmentShipped, ShippedAt: &shippedAt}},
+			CreatedAt: time.Date(2023, 5, 1, 9, 0, 0, 0, time.UTC),
+		}},
+	}
+	users := &fakeUserService{
+		users: map[string]order_api.UserResponse{userId: {ID: userId, Name: "Ayşe", Addresses: []order_api.AddressResponse{{ID: "130beada-8339-4ee6-a754-725f43b8da98", City: "İstanbul"}}}},
+	}
+
+	config := configs.GetConfig("test")
+	config.SoftDelete.Enabled = true
+	resolver := NewResolver(service, users, NewOrderUpdates(), &config, validator.New(), hooks)
+	schema, err := NewSchema(resolver)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return service, users, func(query string, variables map[string]interface{}) (map[string]interface{}, string) {
+		// Every request has its own loaders like handler
+		result := ExecuteQuery(resolver.WithLoaders(context.Background()), query, variables, schema)
+		var messages []string
+		for _, err := range result.Errors {
+			messages = append(messages, err.Message)
+		}
+		// Data is written to json like the response of API
+		data, _ := json.Marshal(result.Data)
+		var decoded map[string]interface{}
+		_ = json.Unmarshal(data, &decoded)
+		return decoded, strings.Join(messages, "; ")
+	}
+}
+
+func TestSchema_Queries(t *testing.T) {
+	service, _, execute := newTestSchema(t, Hooks{})
+
+	data, errs := execute(`{ order(id: "`+orderId+`") { status total address { district } product { sku taxRate total }
+		user { name addresses { city } } history { event } } }`, nil)
+	assert.Equal(t, "", errs)
+	order := data["order"].(map[string]interface{})
+	assert.Equal(t, 389.9, order["total"])
+	assert.Equal(t, "Beşiktaş", order["address"].(map[string]interface{})["district"])
+	assert.Equal(t, map[string]interface{}{"sku": "AIRPODS-3", "taxRate": float64(20), "total": float64(360)}, order["product"].([]interface{})[0])
+	assert.Equal(t, "Ayşe", order["user"].(map[string]interface{})["name"])
+	assert.Equal(t, 3, len(order["history"].([]interface{})))
+
+	// Unknown order is null
+	data, errs = execute(`{ order(id: "unknown") { id } }`, nil)
+	assert.Equal(t, "", errs)
+	assert.Equal(t, nil, data["order"])
+
+	// Query of status is valid for schema and status is a filter
+	query, variables := GenerateGraphQLQuery(order_api.ShippedStatus)
+	data, errs = execute(query, variables)
+	assert.Equal(t, "", errs)
+	assert.Equal(t, float64(1), data["orders"].(map[string]interface{})["totalCount"])
+	assert.Equal(t, order_api.OrderFilter{Status: order_api.ShippedStatus}, service.filters[0])
+
+	// Orders of user are paged
+	data, errs = execute(`{ user(id: "`+userId+`") { orders(limit: 5, offset: 5) { totalCount } } }`, nil)
+	assert.Equal(t, "", errs)
+	assert.Equal(t, order_api.OrderFilter{UserId: userId, Limit: 5, Offset: 5}, service.filters[1])
+}
+
+func TestSchema_UserLoader(t *testing.T) {
+	service, users, execute := newTestSchema(t, Hooks{})
+	for i, id := range []string{"0f9d1c4e-6f0e-4f5c-9d55-1f1f3b7a2c01", "4c1d8e7a-2b5f-4d6a-8e3c-9a7b6c5d4e02", "7e2f9a1b-3c4d-4e5f-a6b7-c8d9e0f1a203"} {
+		order := service.orders[orderId]
+		order.ID = id
+		// Last order is of a deleted user
+		if i == 2 {
+			order.UserId = "deleted-user"
+		}
+		service.orders[id] = order
+	}
+
+	// Users of all orders are read with one batch, duplicate users once
+	data, errs := execute(`{ orders { orders { id user { name } } } }`, nil)
+	assert.Equal(t, "", errs)
+	assert.Equal(t, 1, len(users.batches))
+	assert.Equal(t, 2, len(users.batches[0]))
+	var names []interface{}
+	for _, order := range data["orders"].(map[string]interface{})["orders"].([]interface{}) {
+		if user := order.(map[string]interface{})["user"]; user != nil {
+			names = append(names, user.(map[string]interface{})["name"])
+		}
+	}
+	assert.Equal(t, []interface{}{"Ayşe", "Ayşe", "Ayşe"}, names)
+
+	// Unknown user of user query is an error, loaders are not shared by requests
+	_, errs = execute(`{ user(id: "deleted-user") { name } }`, nil)
+	assert.Equal(t, true, strings.Contains(errs, "user not found"))
+	assert.Equal(t, 2, len(users.batches))
+}
+
+func TestSchema_Mutations(t *testing.T) {
+	var created []models.Order
+	var deleted []*models.Order
+	service, _, execute := newTestSchema(t, Hooks{
+		Created: func(ctx context.Context, order models.Order) error {
+			created = append(created, order)
+			return nil
+		},
+		Updating: func(ctx context.Context, order models.Order) error {
+			return fmt.Errorf("%w: saga is %v", order_api.ErrStockLinesReserved, order_api.SagaReserved)
+		},
+		Deleted: func(ctx context.Context, id string, deletedOrder *models.Order) {
+			deleted = append(deleted, deletedOrder)
+		},
+	})
+
+	input := map[string]interface{}{
+		"userId":         userId,
+		"address":        "130beada-8339-4ee6-a754-725f43b8da98",
+		"invoiceAddress": "130beada-8339-4ee6-a754-725f43b8da98",
+		"product":        []interface{}{map[string]interface{}{"sku": "AIRPODS-3", "quantity": 2}},
+	}
+	data, errs := execute(`mutation ($input: CreateOrderInput!) { createOrder(input: $input) { id status total } }`,
+		map[string]interface{}{"input": input})
+	assert.Equal(t, "", errs)
+	assert.Equal(t, map[string]interface{}{"id": "0b6f2b43-6f5e-4a8e-9c53-5c8b7f3f2a11", "status": order_api.NotShippedStatus,
+		"total": 269.9}, data["createOrder"])
+	assert.Equal(t, 1, len(created))
+
+	// Update is rejected by hook before it is saved
+	updateInput := map[string]interface{}{"id": created[0].ID, "userId": userId, "address": input["address"],
+		"invoiceAddress": input["invoiceAddress"], "product": []interface{}{map[string]interface{}{"sku": "AIRPODS-3", "quantity": 1}}}
+	_, errs = execute(`mutation ($input: UpdateOrderInput!) { updateOrder(input: $input) { id } }`, map[string]interface{}{"input": updateInput})
+	assert.Equal(t, true, strings.Contains(errs, "stock of order lines is reserved"))
+
+	// Input is validated like REST API
+	input["userId"] = "not-uuid"
+	_, errs = execute(`mutation ($input: CreateOrderInput!) { createOrder(input: $input) { id } }`, map[string]interface{}{"input": input})
+	assert.Equal(t, true, strings.Contains(errs, "invalid order"))
+
+	// Order with shipments cannot be updated
+	input["userId"] = userId
+	input["id"] = orderId
+	_, errs = execute(`mutation ($input: UpdateOrderInput!) { updateOrder(input: $input) { id } }`, map[string]interface{}{"input": input})
+	assert.Equal(t, true, strings.Contains(errs, "order with shipments cannot be updated"))
+
+	// Canceled order cannot be updated, its refund is issued already
+	canceledOrder := service.orders[orderId]
+	canceledOrder.ID, canceledOrder.Status, canceledOrder.Shipments = "7d1c9a52-4e0b-4f3a-8b6d-2c5e9f1a3b47", order_api.CanceledStatus, nil
+	service.orders[canceledOrder.ID] = canceledOrder
+	input["id"] = canceledOrder.ID
+	_, errs = execute(`mutation ($input: UpdateOrderInput!) { updateOrder(input: $input) { id } }`, map[string]interface{}{"input": input})
+	assert.Equal(t, true, strings.Contains(errs, "order is closed: Canceled order cannot be updated"))
+
+	// Order is soft deleted with soft delete config
+	data, errs = execute(`mutation { deleteOrder(id: "`+orderId+`") }`, nil)
+	assert.Equal(t, "", errs)
+	assert.Equal(t, true, data["deleteOrder"])
+	assert.Equal(t, []string{orderId}, service.deleted)
+	assert.Equal(t, orderId, deleted[0].ID)
+}
@@ -0,0 +1,196 @@
+package graphQL
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"golang.org/x/net/websocket"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// SubscriptionProtocol => websocket sub protocol of GraphQL over WebSocket (graphql-ws library of clients)
+const SubscriptionProtocol = "graphql-transport-ws"
+
+// connectionInitTimeout => client must send 'connection_init' in this time after websocket is opened
+const connectionInitTimeout = 10 * time.Second
+
+// Message types of graphql-transport-ws protocol
+const (
+	messageConnectionInit = "connection_init"
+	messageConnectionAck  = "connection_ack"
+	messagePing           = "ping"
+	messagePong           = "pong"
+	messageSubscribe      = "subscribe"
+	messageNext           = "next"
+	messageError          = "error"
+	messageComplete       = "complete"
+)
+
+// subscriptionMessage => message of client, payload of 'subscribe' is a Request
+type subscriptionMessage struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// serverMessage => message of server, payload of 'next' is a result and payload of 'error' is a list of errors
+type serverMessage struct {
+	ID      string      `json:"id,omitempty"`
+	Type    string      `json:"type"`
+	Payload interface{} `json:"payload,omitempty"`
+}
+
+// SubscriptionServer => websocket server of schema, client must offer graphql-transport-ws sub protocol
+func SubscriptionServer(schema graphql.Schema) websocket.Server {
+	return websocket.Server{
+		Handshake: func(config *websocket.Config, request *http.Request) error {
+			for _, protocol := range config.Protocol {
+				if protocol == SubscriptionProtocol {
+					config.Protocol = []string{SubscriptionProtocol}
+					return nil
+				}
+			}
+			return fmt.Errorf("websocket sub protocol must be %v", SubscriptionProtocol)
+		},
+		Handler: func(ws *websocket.Conn) {
+			ServeSubscriptions(ws.Request().Context(), ws, schema)
+		},
+	}
+}
+
+// ServeSubscriptions => run operations of client with graphql-transport-ws protocol until connection is closed.
+// Subscriptions send a 'next' message for each changed order, queries and mutations send one 'next' message.
+func ServeSubscriptions(ctx context.Context, ws *websocket.Conn, schema graphql.Schema) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer ws.Close()
+
+	send := func(message serverMessage) {
+		// Write errors mean connection is closed, reading loop stops with it
+		_ = websocket.JSON.Send(ws, message)
+	}
+
+	// 'connection_init' is the first message of client
+	var init subscriptionMessage
+	_ = ws.SetReadDeadline(time.Now().Add(connectionInitTimeout))
+	if err := websocket.JSON.Receive(ws, &init); err != nil || init.Type != messageConnectionInit {
+		return
+	}
+	_ = ws.SetReadDeadline(time.Time{})
+	send(serverMessage{Type: messageConnectionAck})
+
+	var mutex sync.Mutex
+	operations := map[string]context.CancelFunc{}
+
+	for {
+		var message subscriptionMessage
+		if err := websocket.JSON.Receive(ws, &message); err != nil {
+			return
+		}
+
+		switch message.Type {
+		case messagePing:
+			send(serverMessage{Type: messagePong})
+		case messagePong:
+		case messageSubscribe:
+			var request Request
+			if err := json.Unmarshal(message.Payload, &request); err != nil || message.ID == "" || request.Query == "" {
+				send(serverMessage{ID: message.ID, Type: messageError, Payload: []map[string]string{{"message": "invalid subscribe message"}}})
+				continue
+			}
+
+			mutex.Lock()
+			if _, ok := operations[message.ID]; ok {
+				mutex.Unlock()
+				send(serverMessage{ID: message.ID, Type: messageError, Payload: []map[string]string{{"message": "operation id is already used"}}})
+				continue
+			}
+			operationCtx, cancelOperation := context.WithCancel(ctx)
+			operations[message.ID] = cancelOperation
+			mutex.Unlock()
+
+			go func(id string) {
+				defer func() {
+					// Operation which is completed by client is already removed, its id may be used by a new one
+					mutex.Lock()
+					if operationCtx.Err() == nil {
+						delete(operations, id)
+					}
+					mutex.Unlock()
+					cancelOperation()
+				}()
+
+				failed := false
+				// Results are read until channel is closed, so executor never waits for a canceled operation
+				for result := range execute(operationCtx, request, schema) {
+					if failed || operationCtx.Err() != nil {
+						continue
+					}
+					if result.Data == nil && result.HasErrors() {
+						failed = true
+						send(serverMessage{ID: id, Type: messageError, Payload: result.Errors})
+						continue
+					}
+					send(serverMessage{ID: id, Type: messageNext, Payload: result})
+				}
+
+				// Operation which is completed by client is not completed again
+				if !failed && operationCtx.Err() == nil {
+					send(serverMessage{ID: id, Type: messageComplete})
+				}
+			}(message.ID)
+		case messageComplete:
+			mutex.Lock()
+			if cancelOperation, ok := operations[message.ID]; ok {
+				delete(operations, message.ID)
+				cancelOperation()
+			}
+			mutex.Unlock()
+		default:
+			// Unknown message closes connection like graphql-ws
+			return
+		}
+	}
+}
+
+// execute => results of operation, a subscription has a result for each event, queries and mutations one result
+func execute(ctx context.Context, request Request, schema graphql.Schema) chan *graphql.Result {
+	if isSubscription(request) {
+		return graphql.Subscribe(graphql.Params{
+			Schema:         schema,
+			RequestString:  request.Query,
+			VariableValues: request.Variables,
+			OperationName:  request.OperationName,
+			Context:        ctx,
+		})
+	}
+
+	results := make(chan *graphql.Result, 1)
+	results <- Execute(ctx, request, schema)
+	close(results)
+	return results
+}
+
+// isSubscription => operation of request is a subscription, query which cannot parse is executed to get its errors
+func isSubscription(request Request) bool {
+	document, err := parser.Parse(parser.ParseParams{Source: request.Query})
+	if err != nil {
+		return false
+	}
+
+	for _, definition := range document.Definitions {
+		operation, ok := definition.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if request.OperationName == "" || (operation.Name != nil && operation.Name.Value == request.OperationName) {
+			return operation.Operation == ast.OperationTypeSubscription
+		}
+	}
+	return false
+}
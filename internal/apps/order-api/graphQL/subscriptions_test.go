@@ -0,0 +1,116 @@
+package graphQL
+
+import (
+	"OrderUserProject/internal/apps/order-api"
+	"OrderUserProject/internal/configs"
+	"OrderUserProject/internal/models"
+	"encoding/json"
+	"github.com/go-playground/assert/v2"
+	"github.com/go-playground/validator/v10"
+	"golang.org/x/net/websocket"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// dialSubscriptions => websocket of subscription server of schema after 'connection_ack'
+func dialSubscriptions(t *testing.T, updates *OrderUpdates) *websocket.Conn {
+	config := configs.GetConfig("test")
+	schema, err := NewSchema(NewResolver(&fakeOrderService{}, &fakeUserService{}, updates, &config, validator.New(), Hooks{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := SubscriptionServer(schema)
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+
+	wsConfig, _ := websocket.NewConfig(strings.Replace(httpServer.URL, "http", "ws", 1), httpServer.URL)
+	wsConfig.Protocol = []string{SubscriptionProtocol}
+	ws, err := websocket.DialConfig(wsConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ws.Close() })
+
+	send(t, ws, subscriptionMessage{Type: messageConnectionInit})
+	assert.Equal(t, messageConnectionAck, receive(t, ws).Type)
+	return ws
+}
+
+func send(t *testing.T, ws *websocket.Conn, message subscriptionMessage) {
+	if err := websocket.JSON.Send(ws, message); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func receive(t *testing.T, ws *websocket.Conn) subscriptionMessage {
+	_ = ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var message subscriptionMessage
+	if err := websocket.JSON.Receive(ws, &message); err != nil {
+		t.Fatal(err)
+	}
+	return message
+}
+
+// publishUntilReceived => subscription is registered asynchronously, so order is published until it is received
+func publishUntilReceived(t *testing.T, ws *websocket.Conn, updates *OrderUpdates, order models.Order) subscriptionMessage {
+	received := make(chan subscriptionMessage)
+	go func() {
+		received <- receive(t, ws)
+	}()
+	for {
+		updates.Publish(order)
+		select {
+		case message := <-received:
+			return message
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func TestSubscriptions_OrderUpdated(t *testing.T) {
+	updates := NewOrderUpdates()
+	ws := dialSubscriptions(t, updates)
+
+	payload, _ := json.Marshal(Request{Query: `subscription ($id: String!) { orderUpdated(id: $id) { id status } }`,
+		Variables: map[string]interface{}{"id": orderId}})
+	send(t, ws, subscriptionMessage{ID: "1", Type: messageSubscribe, Payload: payload})
+
+	// Orders of other ids are not sent
+	updates.Publish(models.Order{ID: "other", UserId: userId, Status: order_api.NotShippedStatus})
+	message := publishUntilReceived(t, ws, updates, models.Order{ID: orderId, UserId: userId, Status: order_api.ShippedStatus})
+	assert.Equal(t, messageNext, message.Type)
+	assert.Equal(t, "1", message.ID)
+	assert.Equal(t, `{"data":{"orderUpdated":{"id":"`+orderId+`","status":"`+order_api.ShippedStatus+`"}}}`, string(message.Payload))
+
+	// Ping is answered
+	send(t, ws, subscriptionMessage{Type: messagePing})
+	assert.Equal(t, messagePong, receive(t, ws).Type)
+
+	// Subscription which is completed by client doesn't get changes, its id can be used again
+	send(t, ws, subscriptionMessage{ID: "1", Type: messageComplete})
+	payload, _ = json.Marshal(Request{Query: `subscription { ordersForUser(userId: "` + userId + `") { id } }`})
+	send(t, ws, subscriptionMessage{ID: "1", Type: messageSubscribe, Payload: payload})
+	message = publishUntilReceived(t, ws, updates, models.Order{ID: "created", UserId: userId})
+	assert.Equal(t, `{"data":{"ordersForUser":{"id":"created"}}}`, string(message.Payload))
+}
+
+func TestSubscriptions_Errors(t *testing.T) {
+	ws := dialSubscriptions(t, NewOrderUpdates())
+
+	// Invalid operation is an error message
+	payload, _ := json.Marshal(Request{Query: `subscription { orderUpdated { id } }`})
+	send(t, ws, subscriptionMessage{ID: "1", Type: messageSubscribe, Payload: payload})
+	message := receive(t, ws)
+	assert.Equal(t, messageError, message.Type)
+	assert.Equal(t, true, strings.Contains(string(message.Payload), `argument \"id\" of type \"String!\" is required`))
+
+	// Query has one result and it is completed
+	payload, _ = json.Marshal(Request{Query: `{ order(id: "unknown") { id } }`})
+	send(t, ws, subscriptionMessage{ID: "2", Type: messageSubscribe, Payload: payload})
+	message = receive(t, ws)
+	assert.Equal(t, messageNext, message.Type)
+	assert.Equal(t, `{"data":{"order":null}}`, string(message.Payload))
+	assert.Equal(t, subscriptionMessage{ID: "2", Type: messageComplete}, receive(t, ws))
+}
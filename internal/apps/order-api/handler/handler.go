@@ -4,49 +4,93 @@ import (
 	"OrderUserProject/internal/apps/order-api"
 	"OrderUserProject/internal/apps/order-api/graphQL"
 	"OrderUserProject/internal/configs"
+	"OrderUserProject/internal/events"
 	"OrderUserProject/internal/models"
 	"OrderUserProject/pkg"
 	"OrderUserProject/pkg/kafka"
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"github.com/go-playground/validator/v10"
 	"github.com/graphql-go/graphql"
 	"github.com/labstack/echo/v4"
 	"go.mongodb.org/mongo-driver/mongo"
 	"net/http"
+	"strconv"
+	"strings"
 )
 
 type OrderHandler struct {
 	Service        order_api.IOrderService
+	UserService    order_api.IUserService
+	ChangeLog      *order_api.OrderChangeLog
+	SagaService    order_api.IInventorySagaService
+	InvoiceService order_api.IInvoiceService
 	ElasticService *order_api.ElasticService
 	Producer       *kafka.ProducerKafka
 	Config         *configs.Config
 	Validator      *validator.Validate
+	GraphQLSchema  graphql.Schema
+	// GraphQLResolver => resolvers of GraphQLSchema, every request gets its own loaders from it
+	GraphQLResolver *graphQL.Resolver
 }
 
-func NewOrderHandler(e *echo.Echo, service order_api.IOrderService, producer *kafka.ProducerKafka, config *configs.Config, v *validator.Validate, elasticService *order_api.ElasticService) *OrderHandler {
+func NewOrderHandler(e *echo.Echo, service order_api.IOrderService, userService order_api.IUserService, orderUpdates *graphQL.OrderUpdates, changeLog *order_api.OrderChangeLog, sagaService order_api.IInventorySagaService, invoiceService order_api.IInvoiceService, producer *kafka.ProducerKafka, config *configs.Config, v *validator.Validate, elasticService *order_api.ElasticService) *OrderHandler {
 	router := e.Group("api/orders")
-	b := &OrderHandler{Service: service, Producer: producer, Config: config, Validator: v, ElasticService: elasticService}
+	b := &OrderHandler{Service: service, UserService: userService, ChangeLog: changeLog, SagaService: sagaService, InvoiceService: invoiceService, Producer: producer, Config: config, Validator: v, ElasticService: elasticService}
 
 	// Check ram address
 	fmt.Printf("%s%p\n", "Order Service(handler.go):", service)
 
 	e.Use(pkg.CustomErrorMiddleware)
 
-	http.HandleFunc("/order", func(w http.ResponseWriter, r *http.Request) {
-		result := graphQL.ExecuteQuery(r.URL.Query().Get("query"), graphQL.Schema)
-		json.NewEncoder(w).Encode(result)
+	// GraphQL => orders are read and changed with order service, mutations send the same events as REST API
+	b.GraphQLResolver = graphQL.NewResolver(service, userService, orderUpdates, config, v, graphQL.Hooks{
+		Created: func(ctx context.Context, order models.Order) error {
+			return b.orderCreated(echoContext(ctx), order)
+		},
+		Updating: func(ctx context.Context, order models.Order) error {
+			return b.orderUpdating(order)
+		},
+		Updated: func(ctx context.Context, order models.Order) {
+			b.pushOrderEvent(echoContext(ctx), order.ID, "Updated", &order)
+		},
+		Deleted: func(ctx context.Context, id string, deletedOrder *models.Order) {
+			b.orderDeleted(echoContext(ctx), id, deletedOrder)
+		},
 	})
+	schema, err := graphQL.NewSchema(b.GraphQLResolver)
+	if err != nil {
+		e.Logger.Fatalf("GraphQL schema cannot create: %v", err)
+	}
+	b.GraphQLSchema = schema
 
 	//Routes
 	router.GET("", b.GetAllOrders)
 	router.GET("/:id", b.GetOrderById)
 	router.GET("/GraphQL", b.GraphQLWithStatus)
+	router.GET("/stream", b.StreamOrderChanges)
+	router.GET("/search", b.SearchOrders)
+	router.GET("/asyncapi.json", b.AsyncAPI)
+	router.GET("/sagas", b.GetInventorySagas, pkg.AdminOnly(config.Server.AdminToken))
+	router.GET("/internal/users/:userId/open-orders", b.GetOpenOrdersByUser, pkg.AdminOnly(config.Server.AdminToken))
+	router.GET("/internal/users/:userId/addresses/:addressId/open-orders", b.GetOpenOrdersByAddress, pkg.AdminOnly(config.Server.AdminToken))
 	router.POST("", b.CreateOrder, pkg.CheckOrderStatus)
 	router.POST("/GenericEndpointFromMongo", b.GenericEndpointFromMongo)
 	router.POST("/GenericEndpointFromElastic", b.GenericEndpointFromElastic)
 	router.PUT("", b.UpdateOrder, pkg.CheckOrderStatus)
 	router.DELETE("/:id", b.DeleteOrder)
+	router.POST("/:id/restore", b.RestoreOrder, pkg.AdminOnly(config.Server.AdminToken))
+	router.POST("/recalculate", b.RecalculateOrderTotals, pkg.AdminOnly(config.Server.AdminToken))
+	router.POST("/:id/cancel", b.CancelOrder)
+	router.PUT("/:id/refunds/:refundId", b.UpdateRefundStatus, pkg.AdminOnly(config.Server.AdminToken))
+	router.GET("/:id/shipments", b.GetShipments)
+	router.GET("/:id/shipments/:shipmentId", b.GetShipmentById)
+	router.POST("/:id/shipments", b.CreateShipment, pkg.AdminOnly(config.Server.AdminToken))
+	router.PUT("/:id/shipments/:shipmentId", b.UpdateShipment, pkg.AdminOnly(config.Server.AdminToken))
+	router.GET("/:id/invoice", b.GetInvoice)
+	e.POST("/api/graphql", b.GraphQL)
+	e.GET("/api/graphql", b.GraphQLSubscriptions)
 	return b
 }
 
@@ -69,30 +113,9 @@ func (h *OrderHandler) GetAllOrders(c echo.Context) error {
 	}
 
 	// We can use automapper, but it will cause performance loss.
-	var orderResponse order_api.OrderResponse
 	var ordersResponse []order_api.OrderResponse
 	for _, order := range orderList {
-		orderResponse.ID = order.ID
-		orderResponse.UserId = order.UserId
-		orderResponse.Product = order.Product
-		orderResponse.Address.ID = order.Address.ID
-		orderResponse.Address.Address = order.Address.Address
-		orderResponse.Address.City = order.Address.City
-		orderResponse.Address.District = order.Address.District
-		orderResponse.Address.Type = order.Address.Type
-		orderResponse.Address.Default = order.Address.Default
-		orderResponse.InvoiceAddress.ID = order.InvoiceAddress.ID
-		orderResponse.InvoiceAddress.Address = order.InvoiceAddress.Address
-		orderResponse.InvoiceAddress.City = order.InvoiceAddress.City
-		orderResponse.InvoiceAddress.District = order.InvoiceAddress.District
-		orderResponse.InvoiceAddress.Type = order.InvoiceAddress.Type
-		orderResponse.InvoiceAddress.Default = order.InvoiceAddress.Default
-		orderResponse.Product = order.Product
-		orderResponse.Total = order.Total
-		orderResponse.Status = order.Status
-		orderResponse.CreatedAt = order.CreatedAt
-		orderResponse.UpdatedAt = order.UpdatedAt
-		ordersResponse = append(ordersResponse, orderResponse)
+		ordersResponse = append(ordersResponse, toOrderResponse(order))
 	}
 
 	// Response success result data
@@ -135,44 +158,27 @@ func (h *OrderHandler) GetOrderById(c echo.Context) error {
 	}
 
 	// We can use automapper, but it will cause performance loss.
-	var orderResponse order_api.OrderResponse
-	orderResponse.ID = order.ID
-	orderResponse.UserId = order.UserId
-	orderResponse.Product = order.Product
-	orderResponse.Address.ID = order.Address.ID
-	orderResponse.Address.Address = order.Address.Address
-	orderResponse.Address.City = order.Address.City
-	orderResponse.Address.District = order.Address.District
-	orderResponse.Address.Type = order.Address.Type
-	orderResponse.Address.Default = order.Address.Default
-	orderResponse.InvoiceAddress.ID = order.InvoiceAddress.ID
-	orderResponse.InvoiceAddress.Address = order.InvoiceAddress.Address
-	orderResponse.InvoiceAddress.City = order.InvoiceAddress.City
-	orderResponse.InvoiceAddress.District = order.InvoiceAddress.District
-	orderResponse.InvoiceAddress.Type = order.InvoiceAddress.Type
-	orderResponse.InvoiceAddress.Default = order.InvoiceAddress.Default
-	orderResponse.Product = order.Product
-	orderResponse.Total = order.Total
-	orderResponse.Status = order.Status
-	orderResponse.CreatedAt = order.CreatedAt
-	orderResponse.UpdatedAt = order.UpdatedAt
+	orderResponse := toOrderResponse(order)
 
 	c.Logger().Info("{%v} with id is listed.", orderResponse.ID)
 	return c.JSON(http.StatusOK, orderResponse)
 }
 
 // GraphQLWithStatus godoc
-// @Summary get orders by status
+// @Summary get orders by status with GraphQL, or run the GraphQL query of query parameter
 // @ID get-order-by-status
 // @Produce json
-// @Param status path string true "status"
-// @Success 200 {object} order_api.OrderResponse
-// @Success 404 {object} pkg.CustomError
+// @Param status query string false "status of orders"
+// @Param query query string false "GraphQL query, e.g. {order(id:\"...\"){status total user{name}}}"
+// @Success 200 {object} models.JSONSuccessResultData
 // @Router /orders/GraphQL [get]
 func (h *OrderHandler) GraphQLWithStatus(c echo.Context) error {
-	query := c.QueryParam("query")
+	query, variables := graphQL.GenerateGraphQLQuery(c.QueryParam("status"))
+	if c.QueryParam("query") != "" {
+		query, variables = c.QueryParam("query"), nil
+	}
 
-	result := graphQL.ExecuteQuery(query, graphql.Schema{})
+	result := graphQL.ExecuteQuery(h.GraphQLResolver.WithLoaders(withEchoContext(c)), query, variables, h.GraphQLSchema)
 
 	// Response success result data
 	jsonSuccessResultData := models.JSONSuccessResultData{
@@ -184,6 +190,52 @@ func (h *OrderHandler) GraphQLWithStatus(c echo.Context) error {
 	return c.JSON(http.StatusOK, jsonSuccessResultData)
 }
 
+// GraphQL godoc
+// @Summary run GraphQL query or mutation, errors of fields are in 'errors' of result
+// @ID graphql
+// @Accept json
+// @Produce json
+// @Param data body graphQL.Request true "GraphQL request, e.g. {\"query\": \"{ orders(limit: 10) { orders { id user { name } } } }\"}"
+// @Success 200 {object} graphql.Result
+// @Success 400 {object} pkg.CustomError
+// @Router /graphql [post]
+func (h *OrderHandler) GraphQL(c echo.Context) error {
+	var request graphQL.Request
+	if err := c.Bind(&request); err != nil {
+		badRequestErr := pkg.CustomError{
+			Message:    fmt.Sprintf("invalid GraphQL request: %v", err),
+			StatusCode: http.StatusBadRequest,
+		}
+		return badRequestErr
+	}
+	if err := h.Validator.Struct(request); err != nil {
+		badRequestErr := pkg.CustomError{
+			Message:    fmt.Sprintf("invalid GraphQL request: %v", err),
+			StatusCode: http.StatusBadRequest,
+		}
+		return badRequestErr
+	}
+
+	result := graphQL.Execute(h.GraphQLResolver.WithLoaders(withEchoContext(c)), request, h.GraphQLSchema)
+
+	c.Logger().Info("GraphQL request is executed.")
+	return c.JSON(http.StatusOK, result)
+}
+
+// GraphQLSubscriptions godoc
+// @Summary GraphQL over WebSocket (graphql-transport-ws sub protocol), subscriptions orderUpdated(id) and
+// @Summary ordersForUser(userId) send changed orders in real time
+// @ID graphql-subscriptions
+// @Param Sec-WebSocket-Protocol header string true "graphql-transport-ws"
+// @Success 101
+// @Success 403
+// @Router /graphql [get]
+func (h *OrderHandler) GraphQLSubscriptions(c echo.Context) error {
+	server := graphQL.SubscriptionServer(h.GraphQLSchema)
+	server.ServeHTTP(c.Response(), c.Request())
+	return nil
+}
+
 // CreateOrder godoc
 // @Summary add a new item to the order list
 // @ID create-order
@@ -192,6 +244,7 @@ func (h *OrderHandler) GraphQLWithStatus(c echo.Context) error {
 // @Success 201 {object} models.JSONSuccessResultId
 // @Success 400 {object} pkg.CustomError
 // @Success 404 {object} pkg.CustomError
+// @Success 409 {object} pkg.CustomError
 // @Success 500 {object} pkg.CustomError
 // @Router /orders [post]
 func (h *OrderHandler) CreateOrder(c echo.Context) error {
@@ -217,72 +270,53 @@ func (h *OrderHandler) CreateOrder(c echo.Context) error {
 		return badRequestErr
 	}
 
-	// Check user with http.Client
-	user, err := h.Service.GetUser(orderRequest.UserId, h.Config.HttpClient.UserAPI)
-
-	if err != nil {
-		notFoundErr := pkg.CustomError{
-			Message:    fmt.Sprintf("Not Found Exception: %v", err),
-			StatusCode: http.StatusNotFound,
-		}
-		return notFoundErr
-	}
-
-	// Address check
-	regularAddressCheck := false
-	invoiceAddressCheck := false
-	for _, regularAddress := range user.Addresses {
-		if regularAddress.ID == orderRequest.Address {
-			regularAddressCheck = true
-		}
-
-		if regularAddress.ID == orderRequest.InvoiceAddress {
-			invoiceAddressCheck = true
+	// Amounts are calculated from lines, request with amounts is rejected instead of ignoring them silently
+	if fields := orderRequest.ClientTotals(); len(fields) > 0 {
+		badRequestErr := pkg.CustomError{
+			Message:    fmt.Sprintf("Bad Request. Totals are calculated by server, please don't send %v!", strings.Join(fields, ", ")),
+			StatusCode: http.StatusBadRequest,
 		}
+		return badRequestErr
 	}
 
-	if regularAddressCheck == false || invoiceAddressCheck == false {
-		notFoundErr := pkg.CustomError{
-			Message:    "Not Found Exception: Address not found. Before order processing please put correct address id.",
-			StatusCode: http.StatusNotFound,
-		}
-		return notFoundErr
+	// User, its addresses and products are checked, address snapshots and lines are set on order
+	order, err := h.Service.PrepareOrder(orderRequest.UserId, orderRequest.Address, orderRequest.InvoiceAddress,
+		orderRequest.Product, h.Config.HttpClient.UserAPI, h.Config.HttpClient.ProductAPI)
+	if err != nil {
+		return prepareOrderError(err)
 	}
+	// Status is derived from shipments
+	order.Status = order_api.NotShippedStatus
 
-	// Mapping => we can use automapper, but it will cause performance loss.
-	var order models.Order
-	order.UserId = orderRequest.UserId
-	order.Status = orderRequest.Status
-	for _, regularAddress := range user.Addresses {
-		if regularAddress.ID == orderRequest.Address {
-			order.Address.ID = regularAddress.ID
-			order.Address.Address = regularAddress.Address
-			order.Address.City = regularAddress.City
-			order.Address.District = regularAddress.District
-			order.Address.Type = regularAddress.Type
-			order.Address.Default = regularAddress.Default
-		}
-	}
-	for _, invoiceAddress := range user.Addresses {
-		if invoiceAddress.ID == orderRequest.InvoiceAddress {
-			order.InvoiceAddress.ID = invoiceAddress.ID
-			order.InvoiceAddress.Address = invoiceAddress.Address
-			order.InvoiceAddress.City = invoiceAddress.City
-			order.InvoiceAddress.District = invoiceAddress.District
-			order.InvoiceAddress.Type = invoiceAddress.Type
-			order.InvoiceAddress.Default = invoiceAddress.Default
-		}
-	}
-	order.Product = []struct {
-		Name     string  `json:"name" bson:"name"`
-		Quantity int     `json:"quantity" bson:"quantity"`
-		Price    float64 `json:"price" bson:"price"`
-	}(orderRequest.Product)
+	// Shipping fee before promotions, coupon is evaluated in service
+	order.Shipping = h.Config.Pricing.ShippingFee
+	order.CouponCode = orderRequest.CouponCode
 
 	// Service => Insert
 	result, err := h.Service.Insert(order)
 
 	if err != nil {
+		if errors.Is(err, order_api.ErrCouponNotFound) {
+			notFoundErr := pkg.CustomError{
+				Message:    fmt.Sprintf("Not Found Exception: %v", err),
+				StatusCode: http.StatusNotFound,
+			}
+			return notFoundErr
+		}
+		if errors.Is(err, order_api.ErrCouponNotApplicable) {
+			badRequestError := pkg.CustomError{
+				Message:    fmt.Sprintf("Bad Request. %v", err),
+				StatusCode: http.StatusBadRequest,
+			}
+			return badRequestError
+		}
+		if errors.Is(err, order_api.ErrPromotionLimitReached) {
+			conflictErr := pkg.CustomError{
+				Message:    fmt.Sprintf("Conflict. %v, please try again", err),
+				StatusCode: http.StatusConflict,
+			}
+			return conflictErr
+		}
 		internalServerError := pkg.CustomError{
 			Message:    fmt.Sprintf("StatusInternalServerError: {%v} ", err),
 			StatusCode: http.StatusInternalServerError,
@@ -290,21 +324,9 @@ func (h *OrderHandler) CreateOrder(c echo.Context) error {
 		return internalServerError
 	}
 
-	// => SEND MESSAGE (OrderID)
-	var orderKafka order_api.OrderResponseForElastic
-	orderKafka.OrderID = result.ID
-	orderKafka.Status = "Created"
-
-	resultJson, errJson := json.Marshal(orderKafka)
-	if errJson != nil {
-		c.Logger().Errorf("Something went wrong convert to byte: %v", err)
-	} else {
-		err = h.Producer.SendToKafkaWithMessage(resultJson, h.Config.Kafka.TopicName["OrderID"])
-		if err != nil {
-			c.Logger().Errorf("Something went wrong cannot pushed: %v", err)
-		} else {
-			c.Logger().Infof("Order (%v) Pushed Successfully.", result.ID)
-		}
+	// Events and inventory saga of created order
+	if err := h.orderCreated(c, result); err != nil {
+		return err
 	}
 
 	// To response id and success boolean
@@ -318,7 +340,7 @@ func (h *OrderHandler) CreateOrder(c echo.Context) error {
 }
 
 // GenericEndpointFromMongo godoc
-// @Summary get orders list with filter
+// @Summary get orders list with filter, filter has and/or/not groups of conditions. Fields are whitelisted and operators and values must fit their types, otherwise 400 names the clause
 // @ID get-orders-with-filter-from-mongoDB
 // @Produce json
 // @Param data body order_api.OrderGetRequest true "order filter data"
@@ -337,8 +359,15 @@ func (h *OrderHandler) GenericEndpointFromMongo(c echo.Context) error {
 		return badRequestErr
 	}
 
-	// Create filter and find options for mongoDB (exact filter,sort,field and match)
-	filter, findOptions := h.Service.FromModelConvertToFilter(orderGetRequest)
+	// Create filter and find options for mongoDB (exact filter,sort,field,match and filter)
+	filter, findOptions, err := h.Service.FromModelConvertToFilter(orderGetRequest)
+	if err != nil {
+		badRequestErr := pkg.CustomError{
+			Message:    fmt.Sprintf("Bad Request. %v", err),
+			StatusCode: http.StatusBadRequest,
+		}
+		return badRequestErr
+	}
 
 	// Get request with filter and find options for mongoDB
 	orderList, err := h.Service.GetOrdersWithFilter(filter, findOptions)
@@ -362,7 +391,7 @@ func (h *OrderHandler) GenericEndpointFromMongo(c echo.Context) error {
 }
 
 // GenericEndpointFromElastic godoc
-// @Summary get orders list with filter
+// @Summary get orders list with filter, filter has and/or/not groups of conditions. Fields are whitelisted and operators and values must fit their types, otherwise 400 names the clause
 // @ID get-orders-with-filter-from-elasticsearch
 // @Produce json
 // @Param data body order_api.OrderGetRequest true "order filter data"
@@ -381,8 +410,15 @@ func (h *OrderHandler) GenericEndpointFromElastic(c echo.Context) error {
 		return badRequestErr
 	}
 
-	// Create filter and find options (exact filter,sort,field and match)
-	elasticQuery := h.ElasticService.FromModelConvertToElasticQuery(orderGetRequest)
+	// Create filter and find options (exact filter,sort,field,match and filter)
+	elasticQuery, err := h.ElasticService.FromModelConvertToElasticQuery(orderGetRequest)
+	if err != nil {
+		badRequestErr := pkg.CustomError{
+			Message:    fmt.Sprintf("Bad Request. %v", err),
+			StatusCode: http.StatusBadRequest,
+		}
+		return badRequestErr
+	}
 
 	// Get orders from elasticsearch
 	orderList, err := h.ElasticService.GetFromElasticsearch(elasticQuery)
@@ -406,13 +442,14 @@ func (h *OrderHandler) GenericEndpointFromElastic(c echo.Context) error {
 }
 
 // UpdateOrder godoc
-// @Summary update an item to the order list
+// @Summary update an item to the order list, status is derived from shipments and closed orders or orders with shipments cannot be updated (409). Lines cannot change while stock of order is reserved (409), lines can be canceled instead. Promotions are evaluated again with new lines, coupon which doesn't fit them is a conflict (409)
 // @ID update-order
 // @Produce json
 // @Param data body order_api.OrderUpdateRequest true "order data"
 // @Success 200 {object} models.JSONSuccessResultId
 // @Success 400 {object} pkg.CustomError
 // @Success 404 {object} pkg.CustomError
+// @Success 409 {object} pkg.CustomError
 // @Success 500 {object} pkg.CustomError
 // @Router /orders [put]
 func (h *OrderHandler) UpdateOrder(c echo.Context) error {
@@ -437,80 +474,71 @@ func (h *OrderHandler) UpdateOrder(c echo.Context) error {
 		return badRequestErr
 	}
 
-	// Check order using with service
-	if _, err := h.Service.GetOrderById(orderUpdateRequest.ID); err != nil {
-		notFoundErr := pkg.CustomError{
-			Message:    fmt.Sprintf("Not found exception: {%v} with id not found!", orderUpdateRequest.ID),
-			StatusCode: http.StatusNotFound,
+	// Amounts are calculated from lines, request with amounts is rejected instead of ignoring them silently
+	if fields := orderUpdateRequest.ClientTotals(); len(fields) > 0 {
+		badRequestErr := pkg.CustomError{
+			Message:    fmt.Sprintf("Bad Request. Totals are calculated by server, please don't send %v!", strings.Join(fields, ", ")),
+			StatusCode: http.StatusBadRequest,
 		}
-		return notFoundErr
+		return badRequestErr
 	}
 
-	// Check user with http.Client
-	user, err := h.Service.GetUser(orderUpdateRequest.UserId, h.Config.HttpClient.UserAPI)
+	// Check order using with service, order before update is used for domain event
+	oldOrder, err := h.Service.GetOrderById(orderUpdateRequest.ID)
 	if err != nil {
 		notFoundErr := pkg.CustomError{
-			Message:    fmt.Sprintf("User with id (%v) cannot find!", orderUpdateRequest.UserId),
+			Message:    fmt.Sprintf("Not found exception: {%v} with id not found!", orderUpdateRequest.ID),
 			StatusCode: http.StatusNotFound,
 		}
 		return notFoundErr
 	}
 
-	// Address check
-	regularAddressCheck := false
-	invoiceAddressCheck := false
-	for _, regularAddress := range user.Addresses {
-		if regularAddress.ID == orderUpdateRequest.Address {
-			regularAddressCheck = true
-		}
-		if regularAddress.ID == orderUpdateRequest.InvoiceAddress {
-			invoiceAddressCheck = true
+	// Closed orders and orders whose shipment is prepared cannot change
+	if err := order_api.CheckUpdatable(oldOrder); err != nil {
+		conflictErr := pkg.CustomError{
+			Message:    fmt.Sprintf("Conflict. %v", err),
+			StatusCode: http.StatusConflict,
 		}
+		return conflictErr
 	}
 
-	if regularAddressCheck == false || invoiceAddressCheck == false {
-		notFoundErr := pkg.CustomError{
-			Message:    fmt.Sprintf("Not Found Exception: Address not found. Before order processing please put correct address id."),
-			StatusCode: http.StatusNotFound,
-		}
-		return notFoundErr
+	// User, its addresses and products are checked, address snapshots and lines are set on order
+	order, err := h.Service.PrepareOrder(orderUpdateRequest.UserId, orderUpdateRequest.Address, orderUpdateRequest.InvoiceAddress,
+		orderUpdateRequest.Product, h.Config.HttpClient.UserAPI, h.Config.HttpClient.ProductAPI)
+	if err != nil {
+		return prepareOrderError(err)
 	}
-
-	// Mapping => we can use automapper, but it will cause performance loss.
-	var order models.Order
 	order.ID = orderUpdateRequest.ID
-	order.UserId = orderUpdateRequest.UserId
-	order.Status = orderUpdateRequest.Status
-	for _, regularAddress := range user.Addresses {
-		if regularAddress.ID == orderUpdateRequest.Address {
-			order.Address.ID = regularAddress.ID
-			order.Address.Address = regularAddress.Address
-			order.Address.City = regularAddress.City
-			order.Address.District = regularAddress.District
-			order.Address.Type = regularAddress.Type
-			order.Address.Default = regularAddress.Default
-		}
-	}
-	for _, invoiceAddress := range user.Addresses {
-		if invoiceAddress.ID == orderUpdateRequest.InvoiceAddress {
-			order.InvoiceAddress.ID = invoiceAddress.ID
-			order.InvoiceAddress.Address = invoiceAddress.Address
-			order.InvoiceAddress.City = invoiceAddress.City
-			order.InvoiceAddress.District = invoiceAddress.District
-			order.InvoiceAddress.Type = invoiceAddress.Type
-			order.InvoiceAddress.Default = invoiceAddress.Default
-		}
-	}
-	order.Product = []struct {
-		Name     string  `json:"name" bson:"name"`
-		Quantity int     `json:"quantity" bson:"quantity"`
-		Price    float64 `json:"price" bson:"price"`
-	}(orderUpdateRequest.Product)
+	// Status is derived from shipments, it is not changed by update
+	order.Status = oldOrder.Status
+	// Coupon and promotions of order are evaluated again with new lines in service, amounts are calculated again
+	order.CouponCode = oldOrder.CouponCode
+	order.Promotions = oldOrder.Promotions
+	order.Discount = oldOrder.Discount
+	order.Shipping = oldOrder.Shipping
+
+	if err := h.orderUpdating(order); err != nil {
+		return err
+	}
 
 	// Service => Update
 	result, err := h.Service.Update(order)
 
 	if err != nil || result == false {
+		if errors.Is(err, order_api.ErrCouponNotApplicable) || errors.Is(err, order_api.ErrCouponNotFound) {
+			conflictErr := pkg.CustomError{
+				Message:    fmt.Sprintf("Conflict. Coupon of order doesn't fit new lines: %v", err),
+				StatusCode: http.StatusConflict,
+			}
+			return conflictErr
+		}
+		if errors.Is(err, order_api.ErrPromotionLimitReached) {
+			conflictErr := pkg.CustomError{
+				Message:    fmt.Sprintf("Conflict. %v, please try again", err),
+				StatusCode: http.StatusConflict,
+			}
+			return conflictErr
+		}
 		internalServerError := pkg.CustomError{
 			Message:    fmt.Sprintf("StatusInternalServerError: {%v} ", err),
 			StatusCode: http.StatusInternalServerError,
@@ -519,21 +547,7 @@ func (h *OrderHandler) UpdateOrder(c echo.Context) error {
 	}
 
 	// => SEND MESSAGE (OrderID)
-	var orderKafka order_api.OrderResponseForElastic
-	orderKafka.OrderID = order.ID
-	orderKafka.Status = "Updated"
-
-	resultJson, errJson := json.Marshal(orderKafka)
-	if errJson != nil {
-		c.Logger().Errorf("Something went wrong convert to byte: %v", err)
-	} else {
-		err = h.Producer.SendToKafkaWithMessage(resultJson, h.Config.Kafka.TopicName["OrderID"])
-		if err != nil {
-			c.Logger().Errorf("Something went wrong cannot pushed: %v", err)
-		} else {
-			c.Logger().Infof("Order (%v) Pushed Successfully.", order.ID)
-		}
-	}
+	h.orderUpdated(c, order.ID)
 
 	// To response id and success boolean
 	jsonSuccessResultId := models.JSONSuccessResultId{
@@ -556,7 +570,17 @@ func (h *OrderHandler) UpdateOrder(c echo.Context) error {
 func (h *OrderHandler) DeleteOrder(c echo.Context) error {
 	query := c.Param("id")
 
-	result, err := h.Service.Delete(query)
+	// Order before delete is used for domain event
+	deletedOrder, getErr := h.Service.GetOrderById(query)
+
+	// In soft delete mode order is kept until retention period, admin can restore it
+	var result bool
+	var err error
+	if h.Config.SoftDelete.Enabled {
+		result, err = h.Service.SoftDelete(query)
+	} else {
+		result, err = h.Service.Delete(query)
+	}
 
 	if err != nil || result == false {
 		notFoundErr := pkg.CustomError{
@@ -566,22 +590,12 @@ func (h *OrderHandler) DeleteOrder(c echo.Context) error {
 		return notFoundErr
 	}
 
-	// => SEND MESSAGE (OrderID)
-	var orderKafka order_api.OrderResponseForElastic
-	orderKafka.OrderID = query
-	orderKafka.Status = "Deleted"
-
-	resultJson, errJson := json.Marshal(orderKafka)
-	if errJson != nil {
-		c.Logger().Errorf("Something went wrong convert to byte: %v", err)
-	} else {
-		err = h.Producer.SendToKafkaWithMessage(resultJson, h.Config.Kafka.TopicName["OrderID"])
-		if err != nil {
-			c.Logger().Errorf("Something went wrong cannot pushed: %v", err)
-		} else {
-			c.Logger().Infof("Order (%v) Pushed Successfully.", query)
-		}
+	// Events and inventory saga of deleted order
+	var deleted *models.Order
+	if getErr == nil {
+		deleted = &deletedOrder
 	}
+	h.orderDeleted(c, query, deleted)
 
 	// To response id and success boolean
 	jsonSuccessResultId := models.JSONSuccessResultId{
@@ -592,3 +606,587 @@ func (h *OrderHandler) DeleteOrder(c echo.Context) error {
 	c.Logger().Infof("{%v} with id is deleted.", jsonSuccessResultId.ID)
 	return c.JSON(http.StatusOK, jsonSuccessResultId)
 }
+
+// RestoreOrder godoc
+// @Summary restore a soft deleted order by ID (admin only), order is indexed on elasticsearch again
+// @ID restore-order-by-id
+// @Produce json
+// @Param id path string true "order ID"
+// @Param X-Admin-Token header string true "admin token"
+// @Success 200 {object} models.JSONSuccessResultId
+// @Success 403 {object} pkg.CustomError
+// @Success 404 {object} pkg.CustomError
+// @Success 500 {object} pkg.CustomError
+// @Router /orders/{id}/restore [post]
+func (h *OrderHandler) RestoreOrder(c echo.Context) error {
+	query := c.Param("id")
+
+	order, err := h.Service.Restore(query)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			notFoundErr := pkg.CustomError{
+				Message:    fmt.Sprintf("Not found exception: deleted order with {%v} id not found!", query),
+				StatusCode: http.StatusNotFound,
+			}
+			return notFoundErr
+		}
+		internalServerError := pkg.CustomError{
+			Message:    fmt.Sprintf("StatusInternalServerError: %v", err),
+			StatusCode: http.StatusInternalServerError,
+		}
+		return internalServerError
+	}
+
+	// => SEND MESSAGE (OrderID) => order-elastic indexes restored order again
+	h.pushOrderEvent(c, order.ID, "Updated", &order)
+
+	// To response id and success boolean
+	jsonSuccessResultId := models.JSONSuccessResultId{
+		ID:      order.ID,
+		Success: true,
+	}
+
+	c.Logger().Infof("{%v} with id is restored.", jsonSuccessResultId.ID)
+	return c.JSON(http.StatusOK, jsonSuccessResultId)
+}
+
+// RecalculateOrderTotals godoc
+// @Summary calculate amounts of every order again from its lines and repair different ones (admin only), repaired orders are indexed on elasticsearch again. '?dryRun=true' only reports the differences
+// @ID recalculate-order-totals
+// @Produce json
+// @Param dryRun query bool false "only report differences"
+// @Param X-Admin-Token header string true "admin token"
+// @Success 200 {object} order_api.RecalculationReport
+// @Success 400 {object} pkg.CustomError
+// @Success 403 {object} pkg.CustomError
+// @Success 500 {object} pkg.CustomError
+// @Router /orders/recalculate [post]
+func (h *OrderHandler) RecalculateOrderTotals(c echo.Context) error {
+	dryRun := false
+	if query := c.QueryParam("dryRun"); query != "" {
+		value, err := strconv.ParseBool(query)
+		if err != nil {
+			badRequestErr := pkg.CustomError{
+				Message:    fmt.Sprintf("Bad Request. dryRun must be true or false! %v", err),
+				StatusCode: http.StatusBadRequest,
+			}
+			return badRequestErr
+		}
+		dryRun = value
+	}
+
+	report, repaired, err := h.Service.RecalculateTotals(h.Config.Pricing.Currency, dryRun)
+	if err != nil {
+		internalServerError := pkg.CustomError{
+			Message:    fmt.Sprintf("StatusInternalServerError: %v", err),
+			StatusCode: http.StatusInternalServerError,
+		}
+		return internalServerError
+	}
+
+	// => SEND MESSAGE (OrderID) => order-elastic indexes repaired amounts
+	for i := range repaired {
+		h.pushOrderEvent(c, repaired[i].ID, "Updated", &repaired[i])
+	}
+
+	c.Logger().Infof("Totals of orders are recalculated (checked: %v, changed: %v, repaired: %v, dry run: %v).",
+		report.Checked, report.Changed, report.Repaired, report.DryRun)
+	return c.JSON(http.StatusOK, report)
+}
+
+// CancelOrder godoc
+// @Summary cancel an order or quantities of its lines with a reason, paid amount of canceled part is refunded. Without lines whole order is canceled, shipped orders cannot be canceled
+// @ID cancel-order
+// @Produce json
+// @Param id path string true "order ID"
+// @Param data body order_api.OrderCancelRequest true "cancel data"
+// @Success 200 {object} order_api.OrderCancelResponse
+// @Success 400 {object} pkg.CustomError
+// @Success 404 {object} pkg.CustomError
+// @Success 409 {object} pkg.CustomError
+// @Success 500 {object} pkg.CustomError
+// @Router /orders/{id}/cancel [post]
+func (h *OrderHandler) CancelOrder(c echo.Context) error {
+	query := c.Param("id")
+	var cancelRequest order_api.OrderCancelRequest
+
+	// We parse the data as json into the struct
+	if err := c.Bind(&cancelRequest); err != nil {
+		badRequestErr := pkg.CustomError{
+			Message:    fmt.Sprintf("Bad Request. It cannot be binding! %v", err),
+			StatusCode: http.StatusBadRequest,
+		}
+		return badRequestErr
+	}
+
+	// Validate cancel input using the validator instance
+	if err := h.Validator.Struct(cancelRequest); err != nil {
+		badRequestErr := pkg.CustomError{
+			Message:    fmt.Sprintf("Bad Request. Please put valid cancel model! %v", err),
+			StatusCode: http.StatusBadRequest,
+		}
+		return badRequestErr
+	}
+
+	result, err := h.Service.Cancel(query, cancelRequest)
+	if err != nil {
+		switch {
+		case err == mongo.ErrNoDocuments:
+			notFoundErr := pkg.CustomError{
+				Message:    fmt.Sprintf("Not found exception: {%v} with id not found!", query),
+				StatusCode: http.StatusNotFound,
+			}
+			return notFoundErr
+		case errors.Is(err, order_api.ErrInvalidCancelLines):
+			badRequestErr := pkg.CustomError{
+				Message:    fmt.Sprintf("Bad Request. %v", err),
+				StatusCode: http.StatusBadRequest,
+			}
+			return badRequestErr
+		case errors.Is(err, order_api.ErrOrderNotCancelable):
+			conflictErr := pkg.CustomError{
+				Message:    fmt.Sprintf("Conflict. %v", err),
+				StatusCode: http.StatusConflict,
+			}
+			return conflictErr
+		}
+		internalServerError := pkg.CustomError{
+			Message:    fmt.Sprintf("StatusInternalServerError: %v", err),
+			StatusCode: http.StatusInternalServerError,
+		}
+		return internalServerError
+	}
+
+	// => SEND MESSAGE (OrderID and OrderEvents)
+	h.pushOrderCanceled(c, result)
+
+	// Inventory saga => stock of canceled order is released. Canceled quantities of partially canceled order are given
+	// back (released, or restocked if the order is shipped partly), remaining lines are committed or released later.
+	if h.Config.Inventory.Enabled && result.Cancellation.Partial {
+		if err := h.SagaService.Adjust(result.After.ID, result.Cancellation.ID, result.Cancellation.Lines); err != nil {
+			c.Logger().Errorf("Inventory saga of order (%v) cannot adjust: %v", result.After.ID, err)
+		}
+	} else if h.Config.Inventory.Enabled {
+		if err := h.SagaService.Release(result.After.ID, "order is canceled: "+result.Cancellation.Reason); err != nil {
+			c.Logger().Errorf("Inventory saga of order (%v) cannot release: %v", result.After.ID, err)
+		}
+	}
+
+	cancelResponse := order_api.OrderCancelResponse{
+		OrderID:      result.After.ID,
+		Status:       result.After.Status,
+		Cancellation: result.Cancellation,
+		Refund:       result.Refund,
+	}
+
+	c.Logger().Infof("{%v} with id is canceled (partial: %v).", cancelResponse.OrderID, result.Cancellation.Partial)
+	return c.JSON(http.StatusOK, cancelResponse)
+}
+
+// UpdateRefundStatus godoc
+// @Summary payment side reports result of a pending refund (admin only), 'RefundIssued' event is published when it is issued
+// @ID update-refund-status
+// @Produce json
+// @Param id path string true "order ID"
+// @Param refundId path string true "refund ID"
+// @Param data body order_api.RefundStatusRequest true "refund status"
+// @Param X-Admin-Token header string true "admin token"
+// @Success 200 {object} models.Refund
+// @Success 400 {object} pkg.CustomError
+// @Success 403 {object} pkg.CustomError
+// @Success 404 {object} pkg.CustomError
+// @Success 409 {object} pkg.CustomError
+// @Success 500 {object} pkg.CustomError
+// @Router /orders/{id}/refunds/{refundId} [put]
+func (h *OrderHandler) UpdateRefundStatus(c echo.Context) error {
+	query := c.Param("id")
+	refundId := c.Param("refundId")
+	var refundStatusRequest order_api.RefundStatusRequest
+
+	// We parse the data as json into the struct
+	if err := c.Bind(&refundStatusRequest); err != nil {
+		badRequestErr := pkg.CustomError{
+			Message:    fmt.Sprintf("Bad Request. It cannot be binding! %v", err),
+			StatusCode: http.StatusBadRequest,
+		}
+		return badRequestErr
+	}
+
+	// Validate refund status using the validator instance
+	if err := h.Validator.Struct(refundStatusRequest); err != nil {
+		badRequestErr := pkg.CustomError{
+			Message:    fmt.Sprintf("Bad Request. Please put valid refund status! %v", err),
+			StatusCode: http.StatusBadRequest,
+		}
+		return badRequestErr
+	}
+
+	order, refund, err := h.Service.UpdateRefundStatus(query, refundId, refundStatusRequest.Status)
+	if err != nil {
+		switch {
+		case err == mongo.ErrNoDocuments || errors.Is(err, order_api.ErrRefundNotFound):
+			notFoundErr := pkg.CustomError{
+				Message:    fmt.Sprintf("Not found exception: refund {%v} of order {%v} not found!", refundId, query),
+				StatusCode: http.StatusNotFound,
+			}
+			return notFoundErr
+		case errors.Is(err, order_api.ErrRefundNotPending):
+			conflictErr := pkg.CustomError{
+				Message:    fmt.Sprintf("Conflict. %v", err),
+				StatusCode: http.StatusConflict,
+			}
+			return conflictErr
+		}
+		internalServerError := pkg.CustomError{
+			Message:    fmt.Sprintf("StatusInternalServerError: %v", err),
+			StatusCode: http.StatusInternalServerError,
+		}
+		return internalServerError
+	}
+
+	// => SEND MESSAGE (OrderEvents)
+	if refund.Status == order_api.RefundIssued {
+		h.pushDomainEvent(c, events.NewRefundIssued(order, refund))
+	}
+
+	c.Logger().Infof("Refund {%v} of order {%v} is %v.", refund.ID, order.ID, refund.Status)
+	return c.JSON(http.StatusOK, refund)
+}
+
+// orderCreated => send events of created order and start its inventory saga. Order which cannot start its saga is
+// canceled, because it would never reserve stock. REST API and GraphQL mutations share it.
+func (h *OrderHandler) orderCreated(c echo.Context, order models.Order) error {
+	// => SEND MESSAGE (OrderID)
+	h.pushOrderEvent(c, order.ID, "Created", &order)
+	// => SEND MESSAGE (OrderEvents)
+	h.pushDomainEvent(c, events.NewOrderCreated(events.NewOrder(order)))
+
+	// Inventory saga => stock of order is reserved asynchronously, order is canceled if it cannot be reserved
+	if h.Config.Inventory.Enabled {
+		if err := h.SagaService.Start(order); err != nil {
+			// Compensation => order without saga would never reserve stock
+			if result, cancelErr := h.SagaService.CancelOrder(order.ID, order_api.CancelReasonOther, "stock of order cannot reserve"); cancelErr != nil {
+				c.Logger().Errorf("Order (%v) without inventory saga cannot cancel: %v", order.ID, cancelErr)
+			} else if result != nil {
+				h.pushOrderCanceled(c, *result)
+			}
+			internalServerError := pkg.CustomError{
+				Message:    fmt.Sprintf("StatusInternalServerError: stock of order cannot reserve, order is canceled: %v", err),
+				StatusCode: http.StatusInternalServerError,
+			}
+			return internalServerError
+		}
+	}
+	return nil
+}
+
+// orderUpdating => check order before update, lines of order cannot change while its stock is reserved
+func (h *OrderHandler) orderUpdating(order models.Order) error {
+	if !h.Config.Inventory.Enabled {
+		return nil
+	}
+
+	if err := h.SagaService.CheckLines(order); err != nil {
+		if errors.Is(err, order_api.ErrStockLinesReserved) {
+			conflictErr := pkg.CustomError{
+				Message:    fmt.Sprintf("Conflict. %v", err),
+				StatusCode: http.StatusConflict,
+			}
+			return conflictErr
+		}
+		internalServerError := pkg.CustomError{
+			Message:    fmt.Sprintf("StatusInternalServerError: %v", err),
+			StatusCode: http.StatusInternalServerError,
+		}
+		return internalServerError
+	}
+	return nil
+}
+
+// orderUpdated => send event of updated order, total and createdAt are set in service, so we push the stored order.
+// Returns the stored order, nil if it cannot be read.
+func (h *OrderHandler) orderUpdated(c echo.Context, id string) *models.Order {
+	var updatedOrder *models.Order
+	if storedOrder, err := h.Service.GetOrderById(id); err == nil {
+		updatedOrder = &storedOrder
+	} else {
+		c.Logger().Errorf("Updated order (%v) cannot read for events: %v", id, err)
+	}
+	h.pushOrderEvent(c, id, "Updated", updatedOrder)
+	return updatedOrder
+}
+
+// orderDeleted => send events of deleted order and release its stock, domain event needs the order before delete
+func (h *OrderHandler) orderDeleted(c echo.Context, id string, deletedOrder *models.Order) {
+	// => SEND MESSAGE (OrderID)
+	h.pushOrderEvent(c, id, order_api.OrderChangeDeleted, deletedOrder)
+	// => SEND MESSAGE (OrderEvents)
+	if deletedOrder != nil {
+		h.pushDomainEvent(c, events.NewOrderDeleted(events.NewOrder(*deletedOrder)))
+	}
+
+	// Inventory saga => stock of deleted order is released
+	if h.Config.Inventory.Enabled {
+		if err := h.SagaService.Release(id, "order is deleted"); err != nil {
+			c.Logger().Errorf("Inventory saga of order (%v) cannot release: %v", id, err)
+		}
+	}
+}
+
+// pushOrderEvent => send 'OrderChanged' event to 'OrderID' topic. In "full" event mode the order snapshot is sent too,
+// so order-elastic doesn't need to call back order-api. Errors are only logged, the request is already done.
+func (h *OrderHandler) pushOrderEvent(c echo.Context, orderID string, status string, order *models.Order) {
+	envelope, err := order_api.NewOrderChangedEnvelope(orderID, status, order, h.Config.Kafka.OrderEventMode)
+	if err != nil {
+		c.Logger().Errorf("Something went wrong convert to event: %v", err)
+		return
+	}
+
+	// Serializer (json or avro) of topic is set in main
+	err = h.Producer.SendToKafkaWithValue(envelope, h.Config.Kafka.TopicName["OrderID"])
+	if err != nil {
+		c.Logger().Errorf("Something went wrong cannot pushed: %v", err)
+	} else {
+		c.Logger().Infof("Order (%v) Pushed Successfully.", orderID)
+	}
+}
+
+// GetOpenOrdersByUser godoc
+// @Summary internal endpoint, open (not delivered, canceled or closed) orders of user. user-api checks it before delete
+// @ID get-open-orders-by-user
+// @Produce json
+// @Param userId path string true "user ID"
+// @Param X-Admin-Token header string true "admin token (internal endpoints are called with admin token of config)"
+// @Success 200 {object} order_api.OpenOrdersResponse
+// @Success 403 {object} pkg.CustomError
+// @Success 500 {object} pkg.CustomError
+// @Router /orders/internal/users/{userId}/open-orders [get]
+func (h *OrderHandler) GetOpenOrdersByUser(c echo.Context) error {
+	userId := c.Param("userId")
+
+	orders, err := h.Service.GetOpenOrdersByUser(userId)
+	if err != nil {
+		internalServerError := pkg.CustomError{
+			Message:    fmt.Sprintf("StatusInternalServerError: %v", err),
+			StatusCode: http.StatusInternalServerError,
+		}
+		return internalServerError
+	}
+
+	openOrdersResponse := order_api.OpenOrdersResponse{
+		UserId:   userId,
+		Count:    len(orders),
+		OrderIds: []string{},
+	}
+	for _, order := range orders {
+		openOrdersResponse.OrderIds = append(openOrdersResponse.OrderIds, order.ID)
+	}
+
+	c.Logger().Infof("User (%v) has %v open orders.", userId, openOrdersResponse.Count)
+	return c.JSON(http.StatusOK, openOrdersResponse)
+}
+
+// GetOpenOrdersByAddress godoc
+// @Summary internal endpoint, open orders of user which use the address as regular or invoice address. user-api checks it before address delete
+// @ID get-open-orders-by-address
+// @Produce json
+// @Param userId path string true "user ID"
+// @Param addressId path string true "address ID"
+// @Param X-Admin-Token header string true "admin token (internal endpoints are called with admin token of config)"
+// @Success 200 {object} order_api.OpenOrdersResponse
+// @Success 403 {object} pkg.CustomError
+// @Success 500 {object} pkg.CustomError
+// @Router /orders/internal/users/{userId}/addresses/{addressId}/open-orders [get]
+func (h *OrderHandler) GetOpenOrdersByAddress(c echo.Context) error {
+	userId := c.Param("userId")
+	addressId := c.Param("addressId")
+
+	orders, err := h.Service.GetOpenOrdersByAddress(userId, addressId)
+	if err != nil {
+		internalServerError := pkg.CustomError{
+			Message:    fmt.Sprintf("StatusInternalServerError: %v", err),
+			StatusCode: http.StatusInternalServerError,
+		}
+		return internalServerError
+	}
+
+	openOrdersResponse := order_api.OpenOrdersResponse{
+		UserId:    userId,
+		AddressId: addressId,
+		Count:     len(orders),
+		OrderIds:  []string{},
+	}
+	for _, order := range orders {
+		openOrdersResponse.OrderIds = append(openOrdersResponse.OrderIds, order.ID)
+	}
+
+	c.Logger().Infof("Address (%v) of user (%v) is used by %v open orders.", addressId, userId, openOrdersResponse.Count)
+	return c.JSON(http.StatusOK, openOrdersResponse)
+}
+
+// GetInventorySagas godoc
+// @Summary inventory sagas of orders (admin only), in-flight (reserving, reserved, releasing, committing) sagas if there is no state
+// @ID get-inventory-sagas
+// @Produce json
+// @Param state query []string false "saga states" collectionFormat(multi)
+// @Param X-Admin-Token header string true "admin token"
+// @Success 200 {object} models.JSONSuccessResultData
+// @Success 403 {object} pkg.CustomError
+// @Success 500 {object} pkg.CustomError
+// @Router /orders/sagas [get]
+func (h *OrderHandler) GetInventorySagas(c echo.Context) error {
+	sagas, err := h.SagaService.GetSagas(c.QueryParams()["state"])
+	if err != nil {
+		internalServerError := pkg.CustomError{
+			Message:    fmt.Sprintf("StatusInternalServerError: %v", err),
+			StatusCode: http.StatusInternalServerError,
+		}
+		return internalServerError
+	}
+
+	if sagas == nil {
+		sagas = []models.InventorySaga{}
+	}
+
+	// Response success result data
+	jsonSuccessResultData := models.JSONSuccessResultData{
+		TotalItemCount: len(sagas),
+		Data:           sagas,
+	}
+
+	c.Logger().Info("Inventory sagas are successfully listed.")
+	return c.JSON(http.StatusOK, jsonSuccessResultData)
+}
+
+// pushOrderCanceled => send 'OrderChanged', 'OrderCanceled' and 'RefundIssued' (if refund is issued immediately) events
+// of order which is canceled by request or by inventory saga
+func (h *OrderHandler) pushOrderCanceled(c echo.Context, result order_api.OrderCancelResult) {
+	h.pushOrderEvent(c, result.After.ID, "Updated", &result.After)
+	h.pushDomainEvent(c, events.NewOrderCanceled(events.NewOrder(result.Before), events.NewOrder(result.After), result.Cancellation))
+	if result.Refund != nil && result.Refund.Status == order_api.RefundIssued {
+		h.pushDomainEvent(c, events.NewRefundIssued(result.After, *result.Refund))
+	}
+}
+
+// pushDomainEvent => send domain event to public 'OrderEvents' topic. Order id is the message key, so events of
+// an order are consumed in order. Errors are only logged like 'OrderChanged' event.
+func (h *OrderHandler) pushDomainEvent(c echo.Context, domainEvent events.DomainEvent) {
+	envelope, err := domainEvent.Envelope()
+	if err != nil {
+		c.Logger().Errorf("Something went wrong convert to %v event: %v", domainEvent.Type, err)
+		return
+	}
+
+	err = h.Producer.SendToKafkaWithKey(domainEvent.Key, envelope, h.Config.Kafka.TopicName["OrderEvents"])
+	if err != nil {
+		c.Logger().Errorf("Something went wrong %v event cannot pushed: %v", domainEvent.Type, err)
+	} else {
+		c.Logger().Infof("%v event of order (%v) pushed successfully.", domainEvent.Type, domainEvent.Key)
+	}
+}
+
+// AsyncAPI godoc
+// @Summary asyncapi document of kafka events which are published by order-api
+// @ID get-order-asyncapi
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Success 500 {object} pkg.CustomError
+// @Router /orders/asyncapi.json [get]
+func (h *OrderHandler) AsyncAPI(c echo.Context) error {
+	serialization := h.Config.Kafka.Serialization
+	document, err := events.NewAsyncAPI("Order Microservice Events", "1.0.0", h.Config.Kafka.Address,
+		events.OrderEventsChannel(h.Config.Kafka.TopicName["OrderEvents"]).WithFormat(serialization["OrderEvents"]),
+		events.OrderChangedChannel(h.Config.Kafka.TopicName["OrderID"]).WithFormat(serialization["OrderID"]),
+		events.InventoryCommandsChannel(h.Config.Kafka.TopicName["InventoryCommands"]).WithFormat(serialization["InventoryCommands"]))
+	if err != nil {
+		internalServerErr := pkg.CustomError{
+			Message:    fmt.Sprintf("InternalServerError. AsyncAPI document cannot generate: %v", err),
+			StatusCode: http.StatusInternalServerError,
+		}
+		return internalServerErr
+	}
+
+	return c.JSON(http.StatusOK, document)
+}
+
+type echoContextKey struct{}
+
+// withEchoContext => context of request which carries echo context, GraphQL hooks log and send events with it
+func withEchoContext(c echo.Context) context.Context {
+	return context.WithValue(c.Request().Context(), echoContextKey{}, c)
+}
+
+func echoContext(ctx context.Context) echo.Context {
+	c, _ := ctx.Value(echoContextKey{}).(echo.Context)
+	return c
+}
+
+// prepareOrderError => unknown user, address or product is not found, products with different currencies are bad request
+func prepareOrderError(err error) error {
+	switch {
+	case errors.Is(err, order_api.ErrUserNotFound):
+		notFoundErr := pkg.CustomError{
+			Message:    fmt.Sprintf("Not Found Exception: %v", err),
+			StatusCode: http.StatusNotFound,
+		}
+		return notFoundErr
+	case errors.Is(err, order_api.ErrAddressNotFound):
+		notFoundErr := pkg.CustomError{
+			Message:    fmt.Sprintf("Not Found Exception: %v. Before order processing please put correct address id.", err),
+			StatusCode: http.StatusNotFound,
+		}
+		return notFoundErr
+	case errors.Is(err, order_api.ErrProductNotFound):
+		notFoundErr := pkg.CustomError{
+			Message:    fmt.Sprintf("Not Found Exception: %v. Before order processing please put correct product sku.", err),
+			StatusCode: http.StatusNotFound,
+		}
+		return notFoundErr
+	case errors.Is(err, order_api.ErrCurrencyMismatch):
+		badRequestError := pkg.CustomError{
+			Message:    fmt.Sprintf("Bad Request. Products of an order must be in the same currency: %v", err),
+			StatusCode: http.StatusBadRequest,
+		}
+		return badRequestError
+	}
+	internalServerError := pkg.CustomError{
+		Message:    fmt.Sprintf("StatusInternalServerError: products cannot resolve: %v", err),
+		StatusCode: http.StatusInternalServerError,
+	}
+	return internalServerError
+}
+
+// toOrderResponse => mapping from order model to response, we can use automapper, but it will cause performance loss.
+func toOrderResponse(order models.Order) order_api.OrderResponse {
+	var orderResponse order_api.OrderResponse
+	orderResponse.ID = order.ID
+	orderResponse.UserId = order.UserId
+	orderResponse.Address.ID = order.Address.ID
+	orderResponse.Address.Address = order.Address.Address
+	orderResponse.Address.City = order.Address.City
+	orderResponse.Address.District = order.Address.District
+	orderResponse.Address.Type = order.Address.Type
+	orderResponse.Address.Default = order.Address.Default
+	orderResponse.InvoiceAddress.ID = order.InvoiceAddress.ID
+	orderResponse.InvoiceAddress.Address = order.InvoiceAddress.Address
+	orderResponse.InvoiceAddress.City = order.InvoiceAddress.City
+	orderResponse.InvoiceAddress.District = order.InvoiceAddress.District
+	orderResponse.InvoiceAddress.Type = order.InvoiceAddress.Type
+	orderResponse.InvoiceAddress.Default = order.InvoiceAddress.Default
+	orderResponse.Product = order.Product
+	orderResponse.Currency = order.Currency
+	orderResponse.Subtotal = order.Subtotal
+	orderResponse.Discount = order.Discount
+	orderResponse.Tax = order.Tax
+	orderResponse.Shipping = order.Shipping
+	orderResponse.Total = order.Total
+	orderResponse.CouponCode = order.CouponCode
+	orderResponse.Promotions = order.Promotions
+	orderResponse.Shipments = order.Shipments
+	orderResponse.Cancellations = order.Cancellations
+	orderResponse.Refunds = order.Refunds
+	orderResponse.Status = order.Status
+	orderResponse.CreatedAt = order.CreatedAt
+	orderResponse.UpdatedAt = order.UpdatedAt
+	return orderResponse
+}
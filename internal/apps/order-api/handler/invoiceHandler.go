@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"OrderUserProject/internal/apps/order-api"
+	"OrderUserProject/pkg"
+	"errors"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/mongo"
+	"net/http"
+)
+
+// invoiceContentTypes => content types of invoice formats
+var invoiceContentTypes = map[string]string{
+	order_api.InvoicePDF:  "application/pdf",
+	order_api.InvoiceHTML: echo.MIMETextHTMLCharsetUTF8,
+}
+
+// GetInvoice godoc
+// @Summary download invoice of an order as PDF (default) or HTML. Invoice is generated once when order is shipped, it is generated on first download if it is missing
+// @ID get-order-invoice
+// @Produce application/pdf
+// @Produce text/html
+// @Param id path string true "order ID"
+// @Param format query string false "pdf or html"
+// @Success 200 {file} file
+// @Success 400 {object} pkg.CustomError
+// @Success 404 {object} pkg.CustomError
+// @Success 409 {object} pkg.CustomError
+// @Success 500 {object} pkg.CustomError
+// @Router /orders/{id}/invoice [get]
+func (h *OrderHandler) GetInvoice(c echo.Context) error {
+	query := c.Param("id")
+	format := c.QueryParam("format")
+	if format == "" {
+		format = order_api.InvoicePDF
+	}
+	if _, ok := invoiceContentTypes[format]; !ok {
+		badRequestErr := pkg.CustomError{
+			Message:    fmt.Sprintf("Bad Request. Format of invoice must be pdf or html, not {%v}!", format),
+			StatusCode: http.StatusBadRequest,
+		}
+		return badRequestErr
+	}
+
+	order, err := h.Service.GetOrderById(query)
+	if err != nil {
+		notFoundErr := pkg.CustomError{
+			Message:    fmt.Sprintf("Not found exception: {%v} with id not found!", query),
+			StatusCode: http.StatusNotFound,
+		}
+		return notFoundErr
+	}
+
+	invoice, err := h.InvoiceService.GetInvoice(order.ID)
+	if err == mongo.ErrNoDocuments || (err == nil && invoice.Status != order_api.InvoiceIssued) {
+		invoice, err = h.InvoiceService.Generate(order)
+	}
+	if err != nil {
+		return invoiceError(query, err)
+	}
+
+	document, err := h.InvoiceService.Document(invoice, format)
+	if err != nil {
+		internalServerError := pkg.CustomError{
+			Message:    fmt.Sprintf("StatusInternalServerError: %v", err),
+			StatusCode: http.StatusInternalServerError,
+		}
+		return internalServerError
+	}
+
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", invoice.Number+"."+format))
+	c.Logger().Infof("Invoice {%v} of order {%v} is downloaded as %v.", invoice.Number, order.ID, format)
+	return c.Blob(http.StatusOK, invoiceContentTypes[format], document)
+}
+
+// invoiceError => orders which are not billable have no invoice, invoice which is generated by another request is
+// conflict
+func invoiceError(orderId string, err error) error {
+	switch {
+	case errors.Is(err, order_api.ErrInvoiceNotBillable):
+		notFoundErr := pkg.CustomError{
+			Message:    fmt.Sprintf("Not found exception: invoice of order {%v} not found, %v", orderId, err),
+			StatusCode: http.StatusNotFound,
+		}
+		return notFoundErr
+	case errors.Is(err, order_api.ErrInvoiceInProgress):
+		conflictErr := pkg.CustomError{
+			Message:    fmt.Sprintf("Conflict. %v, please try again", err),
+			StatusCode: http.StatusConflict,
+		}
+		return conflictErr
+	}
+	internalServerError := pkg.CustomError{
+		Message:    fmt.Sprintf("StatusInternalServerError: %v", err),
+		StatusCode: http.StatusInternalServerError,
+	}
+	return internalServerError
+}
@@ -0,0 +1,259 @@
+package handler
+
+import (
+	"OrderUserProject/internal/apps/order-api"
+	"OrderUserProject/internal/configs"
+	"OrderUserProject/internal/models"
+	"OrderUserProject/pkg"
+	"errors"
+	"fmt"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/mongo"
+	"net/http"
+)
+
+type PromotionHandler struct {
+	Service   order_api.IPromotionService
+	Config    *configs.Config
+	Validator *validator.Validate
+}
+
+// NewPromotionHandler => promotions are managed by admin only
+func NewPromotionHandler(e *echo.Echo, service order_api.IPromotionService, config *configs.Config, v *validator.Validate) *PromotionHandler {
+	router := e.Group("api/promotions", pkg.AdminOnly(config.Server.AdminToken))
+	b := &PromotionHandler{Service: service, Config: config, Validator: v}
+
+	//Routes
+	router.GET("", b.GetAllPromotions)
+	router.GET("/:id", b.GetPromotionById)
+	router.POST("", b.CreatePromotion)
+	router.PUT("", b.UpdatePromotion)
+
+	return b
+}
+
+// GetAllPromotions godoc
+// @Summary get all promotions and coupons (admin only)
+// @ID get-all-promotions
+// @Produce json
+// @Param X-Admin-Token header string true "admin token"
+// @Success 200 {object} models.JSONSuccessResultData
+// @Success 403 {object} pkg.CustomError
+// @Success 500 {object} pkg.CustomError
+// @Router /promotions [get]
+func (h *PromotionHandler) GetAllPromotions(c echo.Context) error {
+	promotions, err := h.Service.GetAll()
+	if err != nil {
+		internalServerError := pkg.CustomError{
+			Message:    fmt.Sprintf("StatusInternalServerError: %v", err),
+			StatusCode: http.StatusInternalServerError,
+		}
+		return internalServerError
+	}
+
+	if promotions == nil {
+		promotions = []models.Promotion{}
+	}
+
+	// Response success result data
+	jsonSuccessResultData := models.JSONSuccessResultData{
+		TotalItemCount: len(promotions),
+		Data:           promotions,
+	}
+
+	c.Logger().Info("Promotions are successfully listed.")
+	return c.JSON(http.StatusOK, jsonSuccessResultData)
+}
+
+// GetPromotionById godoc
+// @Summary get a promotion by ID with its usage count (admin only)
+// @ID get-promotion-by-id
+// @Produce json
+// @Param id path string true "promotion ID"
+// @Param X-Admin-Token header string true "admin token"
+// @Success 200 {object} models.Promotion
+// @Success 403 {object} pkg.CustomError
+// @Success 404 {object} pkg.CustomError
+// @Success 500 {object} pkg.CustomError
+// @Router /promotions/{id} [get]
+func (h *PromotionHandler) GetPromotionById(c echo.Context) error {
+	query := c.Param("id")
+
+	promotion, err := h.Service.GetPromotionById(query)
+	if err != nil {
+		return promotionNotFoundOrError(query, err)
+	}
+
+	c.Logger().Infof("{%v} with id is listed.", promotion.ID)
+	return c.JSON(http.StatusOK, promotion)
+}
+
+// CreatePromotion godoc
+// @Summary add a promotion (admin only), promotion without code is applied automatically to every eligible order
+// @ID create-promotion
+// @Produce json
+// @Param data body order_api.PromotionCreateRequest true "promotion data"
+// @Param X-Admin-Token header string true "admin token"
+// @Success 201 {object} models.JSONSuccessResultId
+// @Success 400 {object} pkg.CustomError
+// @Success 403 {object} pkg.CustomError
+// @Success 409 {object} pkg.CustomError
+// @Success 500 {object} pkg.CustomError
+// @Router /promotions [post]
+func (h *PromotionHandler) CreatePromotion(c echo.Context) error {
+	var promotionRequest order_api.PromotionCreateRequest
+
+	// We parse the data as json into the struct
+	if err := c.Bind(&promotionRequest); err != nil {
+		badRequestError := pkg.CustomError{
+			Message:    fmt.Sprintf("Bad Request. It cannot be binding! %v", err),
+			StatusCode: http.StatusBadRequest,
+		}
+		return badRequestError
+	}
+
+	// Validate promotion input using the validator instance
+	if err := h.Validator.Struct(promotionRequest); err != nil {
+		badRequestError := pkg.CustomError{
+			Message:    fmt.Sprintf("Bad Request. Please put valid promotion model! %v", err),
+			StatusCode: http.StatusBadRequest,
+		}
+		return badRequestError
+	}
+
+	promotion := toPromotion(promotionRequest.PromotionRules)
+	promotion.Code = promotionRequest.Code
+
+	result, err := h.Service.Insert(promotion)
+	if err != nil {
+		if errors.Is(err, order_api.ErrCouponCodeExists) {
+			conflictError := pkg.CustomError{
+				Message:    fmt.Sprintf("Conflict. Coupon with {%v} code already exists!", promotionRequest.Code),
+				StatusCode: http.StatusConflict,
+			}
+			return conflictError
+		}
+		return promotionError(err)
+	}
+
+	// Response id and success boolean
+	jsonSuccessResultId := models.JSONSuccessResultId{
+		ID:      result.ID,
+		Success: true,
+	}
+
+	c.Logger().Infof("{%v} with id is created.", jsonSuccessResultId.ID)
+	return c.JSON(http.StatusCreated, jsonSuccessResultId)
+}
+
+// UpdatePromotion godoc
+// @Summary update rules of a promotion (admin only), code and usage counts cannot be changed. Orders which used it are not changed
+// @ID update-promotion
+// @Produce json
+// @Param data body order_api.PromotionUpdateRequest true "promotion data"
+// @Param X-Admin-Token header string true "admin token"
+// @Success 200 {object} models.JSONSuccessResultId
+// @Success 400 {object} pkg.CustomError
+// @Success 403 {object} pkg.CustomError
+// @Success 404 {object} pkg.CustomError
+// @Success 500 {object} pkg.CustomError
+// @Router /promotions [put]
+func (h *PromotionHandler) UpdatePromotion(c echo.Context) error {
+	var promotionUpdateRequest order_api.PromotionUpdateRequest
+
+	// We parse the data as json into the struct
+	if err := c.Bind(&promotionUpdateRequest); err != nil {
+		badRequestError := pkg.CustomError{
+			Message:    fmt.Sprintf("Bad Request. It cannot be binding! %v", err),
+			StatusCode: http.StatusBadRequest,
+		}
+		return badRequestError
+	}
+
+	// Validate promotion input using the validator instance
+	if err := h.Validator.Struct(promotionUpdateRequest); err != nil {
+		badRequestError := pkg.CustomError{
+			Message:    fmt.Sprintf("Bad Request. Please put valid promotion model! %v", err),
+			StatusCode: http.StatusBadRequest,
+		}
+		return badRequestError
+	}
+
+	existing, err := h.Service.GetPromotionById(promotionUpdateRequest.ID)
+	if err != nil {
+		return promotionNotFoundOrError(promotionUpdateRequest.ID, err)
+	}
+
+	promotion := toPromotion(promotionUpdateRequest.PromotionRules)
+	promotion.ID = existing.ID
+	promotion.Code = existing.Code
+
+	result, err := h.Service.Update(promotion)
+	if err != nil {
+		return promotionError(err)
+	}
+
+	// Response id and success boolean
+	jsonSuccessResultId := models.JSONSuccessResultId{
+		ID:      promotion.ID,
+		Success: result,
+	}
+
+	c.Logger().Infof("{%v} with id is updated.", jsonSuccessResultId.ID)
+	return c.JSON(http.StatusOK, jsonSuccessResultId)
+}
+
+// toPromotion => mapping from request, we can use automapper, but it will cause performance loss.
+func toPromotion(rules order_api.PromotionRules) models.Promotion {
+	return models.Promotion{
+		Name:         rules.Name,
+		Type:         rules.Type,
+		Percent:      rules.Percent,
+		Amount:       rules.Amount,
+		MaxDiscount:  rules.MaxDiscount,
+		Sku:          rules.Sku,
+		BuyQuantity:  rules.BuyQuantity,
+		GetQuantity:  rules.GetQuantity,
+		Currency:     rules.Currency,
+		MinSubtotal:  rules.MinSubtotal,
+		StartsAt:     rules.StartsAt,
+		EndsAt:       rules.EndsAt,
+		UsageLimit:   rules.UsageLimit,
+		PerUserLimit: rules.PerUserLimit,
+		Stackable:    rules.Stackable,
+		Priority:     rules.Priority,
+		Active:       rules.Active,
+	}
+}
+
+func promotionNotFoundOrError(id string, err error) error {
+	if err == mongo.ErrNoDocuments {
+		notFoundError := pkg.CustomError{
+			Message:    fmt.Sprintf("Not found exception: {%v} with id not found!", id),
+			StatusCode: http.StatusNotFound,
+		}
+		return notFoundError
+	}
+	internalServerError := pkg.CustomError{
+		Message:    fmt.Sprintf("StatusInternalServerError: %v", err),
+		StatusCode: http.StatusInternalServerError,
+	}
+	return internalServerError
+}
+
+// promotionError => invalid rules are bad request, other errors are internal server error
+func promotionError(err error) error {
+	if errors.Is(err, order_api.ErrInvalidPromotion) {
+		badRequestError := pkg.CustomError{
+			Message:    fmt.Sprintf("Bad Request. %v", err),
+			StatusCode: http.StatusBadRequest,
+		}
+		return badRequestError
+	}
+	internalServerError := pkg.CustomError{
+		Message:    fmt.Sprintf("StatusInternalServerError: %v", err),
+		StatusCode: http.StatusInternalServerError,
+	}
+	return internalServerError
+}
@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"OrderUserProject/pkg"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// SearchOrders godoc
+// @Summary full-text search of orders in product names, addresses (with city and district) and user name, most relevant first. Words may have typos and texts are analyzed as Turkish, matched words are highlighted in <em> tags
+// @ID search-orders
+// @Produce json
+// @Param q query string true "search text"
+// @Param limit query int false "page size (20 by default, at most 100)"
+// @Param offset query int false "orders to skip"
+// @Success 200 {object} order_api.OrderSearchResult
+// @Success 400 {object} pkg.CustomError
+// @Success 500 {object} pkg.CustomError
+// @Router /orders/search [get]
+func (h *OrderHandler) SearchOrders(c echo.Context) error {
+	text := strings.TrimSpace(c.QueryParam("q"))
+	if text == "" {
+		badRequestErr := pkg.CustomError{
+			Message:    "Bad Request. Search text (q) is required!",
+			StatusCode: http.StatusBadRequest,
+		}
+		return badRequestErr
+	}
+
+	// Empty limit and offset are defaults of search
+	page := [2]int{}
+	for i, name := range []string{"limit", "offset"} {
+		value := c.QueryParam(name)
+		if value == "" {
+			continue
+		}
+		number, err := strconv.Atoi(value)
+		if err != nil {
+			badRequestErr := pkg.CustomError{
+				Message:    fmt.Sprintf("Bad Request. %v {%v} is not a number!", name, value),
+				StatusCode: http.StatusBadRequest,
+			}
+			return badRequestErr
+		}
+		page[i] = number
+	}
+
+	result, err := h.ElasticService.SearchOrders(text, page[0], page[1])
+	if err != nil {
+		internalServerErr := pkg.CustomError{
+			Message:    fmt.Sprintf("InternalServerError. %v", err),
+			StatusCode: http.StatusInternalServerError,
+		}
+		return internalServerErr
+	}
+
+	c.Logger().Infof("Orders are searched with {%v}, %v orders are found.", text, result.TotalCount)
+	return c.JSON(http.StatusOK, result)
+}
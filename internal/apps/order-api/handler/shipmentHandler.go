@@ -0,0 +1,253 @@
+package handler
+
+import (
+	"OrderUserProject/internal/apps/order-api"
+	"OrderUserProject/internal/events"
+	"OrderUserProject/internal/models"
+	"OrderUserProject/pkg"
+	"errors"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/mongo"
+	"net/http"
+)
+
+// GetShipments godoc
+// @Summary get shipments of an order
+// @ID get-order-shipments
+// @Produce json
+// @Param id path string true "order ID"
+// @Success 200 {object} models.JSONSuccessResultData
+// @Success 404 {object} pkg.CustomError
+// @Router /orders/{id}/shipments [get]
+func (h *OrderHandler) GetShipments(c echo.Context) error {
+	query := c.Param("id")
+
+	order, err := h.Service.GetOrderById(query)
+	if err != nil {
+		notFoundErr := pkg.CustomError{
+			Message:    fmt.Sprintf("Not found exception: {%v} with id not found!", query),
+			StatusCode: http.StatusNotFound,
+		}
+		return notFoundErr
+	}
+
+	shipments := order.Shipments
+	if shipments == nil {
+		shipments = []models.Shipment{}
+	}
+
+	// Response success result data
+	jsonSuccessResultData := models.JSONSuccessResultData{
+		TotalItemCount: len(shipments),
+		Data:           shipments,
+	}
+
+	c.Logger().Infof("Shipments of order {%v} are successfully listed.", order.ID)
+	return c.JSON(http.StatusOK, jsonSuccessResultData)
+}
+
+// GetShipmentById godoc
+// @Summary get a shipment of an order by ID
+// @ID get-order-shipment-by-id
+// @Produce json
+// @Param id path string true "order ID"
+// @Param shipmentId path string true "shipment ID"
+// @Success 200 {object} models.Shipment
+// @Success 404 {object} pkg.CustomError
+// @Router /orders/{id}/shipments/{shipmentId} [get]
+func (h *OrderHandler) GetShipmentById(c echo.Context) error {
+	query := c.Param("id")
+	shipmentId := c.Param("shipmentId")
+
+	order, err := h.Service.GetOrderById(query)
+	if err == nil {
+		for _, shipment := range order.Shipments {
+			if shipment.ID == shipmentId {
+				c.Logger().Infof("{%v} with id is listed.", shipment.ID)
+				return c.JSON(http.StatusOK, shipment)
+			}
+		}
+	}
+
+	notFoundErr := pkg.CustomError{
+		Message:    fmt.Sprintf("Not found exception: shipment {%v} of order {%v} not found!", shipmentId, query),
+		StatusCode: http.StatusNotFound,
+	}
+	return notFoundErr
+}
+
+// CreateShipment godoc
+// @Summary prepare a shipment of an order (admin only), without items every unit which is not in a shipment is added. An order can be split to many shipments
+// @ID create-order-shipment
+// @Produce json
+// @Param id path string true "order ID"
+// @Param data body order_api.ShipmentCreateRequest true "shipment data"
+// @Param X-Admin-Token header string true "admin token"
+// @Success 201 {object} models.Shipment
+// @Success 400 {object} pkg.CustomError
+// @Success 403 {object} pkg.CustomError
+// @Success 404 {object} pkg.CustomError
+// @Success 409 {object} pkg.CustomError
+// @Success 500 {object} pkg.CustomError
+// @Router /orders/{id}/shipments [post]
+func (h *OrderHandler) CreateShipment(c echo.Context) error {
+	query := c.Param("id")
+	var shipmentRequest order_api.ShipmentCreateRequest
+
+	// We parse the data as json into the struct
+	if err := c.Bind(&shipmentRequest); err != nil {
+		badRequestErr := pkg.CustomError{
+			Message:    fmt.Sprintf("Bad Request. It cannot be binding! %v", err),
+			StatusCode: http.StatusBadRequest,
+		}
+		return badRequestErr
+	}
+
+	// Validate shipment input using the validator instance
+	if err := h.Validator.Struct(shipmentRequest); err != nil {
+		badRequestErr := pkg.CustomError{
+			Message:    fmt.Sprintf("Bad Request. Please put valid shipment model! %v", err),
+			StatusCode: http.StatusBadRequest,
+		}
+		return badRequestErr
+	}
+
+	change, err := h.Service.CreateShipment(query, shipmentRequest)
+	if err != nil {
+		return shipmentError(query, err)
+	}
+
+	h.pushShipmentChange(c, change)
+
+	c.Logger().Infof("{%v} with id is created for order {%v}.", change.Shipment.ID, change.After.ID)
+	return c.JSON(http.StatusCreated, change.Shipment)
+}
+
+// UpdateShipment godoc
+// @Summary change status of a shipment (admin only), Preparing => Shipped (tracking number is required) => Delivered, or Failed. Status of order is derived from its shipments
+// @ID update-order-shipment
+// @Produce json
+// @Param id path string true "order ID"
+// @Param shipmentId path string true "shipment ID"
+// @Param data body order_api.ShipmentUpdateRequest true "shipment status"
+// @Param X-Admin-Token header string true "admin token"
+// @Success 200 {object} models.Shipment
+// @Success 400 {object} pkg.CustomError
+// @Success 403 {object} pkg.CustomError
+// @Success 404 {object} pkg.CustomError
+// @Success 409 {object} pkg.CustomError
+// @Success 500 {object} pkg.CustomError
+// @Router /orders/{id}/shipments/{shipmentId} [put]
+func (h *OrderHandler) UpdateShipment(c echo.Context) error {
+	query := c.Param("id")
+	shipmentId := c.Param("shipmentId")
+	var shipmentUpdateRequest order_api.ShipmentUpdateRequest
+
+	// We parse the data as json into the struct
+	if err := c.Bind(&shipmentUpdateRequest); err != nil {
+		badRequestErr := pkg.CustomError{
+			Message:    fmt.Sprintf("Bad Request. It cannot be binding! %v", err),
+			StatusCode: http.StatusBadRequest,
+		}
+		return badRequestErr
+	}
+
+	// Validate shipment input using the validator instance
+	if err := h.Validator.Struct(shipmentUpdateRequest); err != nil {
+		badRequestErr := pkg.CustomError{
+			Message:    fmt.Sprintf("Bad Request. Please put valid shipment status! %v", err),
+			StatusCode: http.StatusBadRequest,
+		}
+		return badRequestErr
+	}
+
+	// Order can ship only after its stock is reserved
+	shipped := shipmentUpdateRequest.Status == order_api.ShipmentShipped
+	if h.Config.Inventory.Enabled && shipped {
+		if err := h.SagaService.CheckShipment(query); err != nil {
+			if errors.Is(err, order_api.ErrStockNotReserved) {
+				conflictErr := pkg.CustomError{
+					Message:    fmt.Sprintf("Conflict. Order cannot ship: %v", err),
+					StatusCode: http.StatusConflict,
+				}
+				return conflictErr
+			}
+			internalServerError := pkg.CustomError{
+				Message:    fmt.Sprintf("StatusInternalServerError: %v", err),
+				StatusCode: http.StatusInternalServerError,
+			}
+			return internalServerError
+		}
+	}
+
+	change, err := h.Service.UpdateShipment(query, shipmentId, shipmentUpdateRequest)
+	if err != nil {
+		return shipmentError(query, err)
+	}
+
+	h.pushShipmentChange(c, change)
+
+	// Inventory saga => reserved stock is committed with the first shipment, commit is sent once
+	if h.Config.Inventory.Enabled && shipped {
+		if err := h.SagaService.Commit(change.After.ID); err != nil {
+			c.Logger().Errorf("Inventory saga of order (%v) cannot commit: %v", change.After.ID, err)
+		}
+	}
+
+	c.Logger().Infof("{%v} with id is %v, order {%v} is %v.", change.Shipment.ID, change.Shipment.Status, change.After.ID, change.After.Status)
+	return c.JSON(http.StatusOK, change.Shipment)
+}
+
+// pushShipmentChange => send 'OrderChanged' event, and 'OrderStatusChanged' event if derived status of order is changed.
+// Invoice of order is generated when order becomes billable, it can be generated later by downloading it.
+func (h *OrderHandler) pushShipmentChange(c echo.Context, change order_api.ShipmentChange) {
+	h.pushOrderEvent(c, change.After.ID, "Updated", &change.After)
+	if domainEvent, ok := events.NewOrderUpdated(events.NewOrder(change.Before), events.NewOrder(change.After)); ok {
+		h.pushDomainEvent(c, domainEvent)
+	}
+
+	if h.InvoiceService.IsBillable(change.After) && !h.InvoiceService.IsBillable(change.Before) {
+		if invoice, err := h.InvoiceService.Generate(change.After); err != nil {
+			c.Logger().Errorf("Invoice of order (%v) cannot be generated: %v", change.After.ID, err)
+		} else {
+			c.Logger().Infof("Invoice {%v} of order {%v} is issued.", invoice.Number, change.After.ID)
+		}
+	}
+}
+
+// shipmentError => unknown order or shipment is not found, invalid items are bad request, shipments of closed orders
+// and invalid transitions are conflict
+func shipmentError(orderId string, err error) error {
+	switch {
+	case err == mongo.ErrNoDocuments:
+		notFoundErr := pkg.CustomError{
+			Message:    fmt.Sprintf("Not found exception: {%v} with id not found!", orderId),
+			StatusCode: http.StatusNotFound,
+		}
+		return notFoundErr
+	case errors.Is(err, order_api.ErrShipmentNotFound):
+		notFoundErr := pkg.CustomError{
+			Message:    fmt.Sprintf("Not found exception: %v", err),
+			StatusCode: http.StatusNotFound,
+		}
+		return notFoundErr
+	case errors.Is(err, order_api.ErrInvalidShipmentItems):
+		badRequestErr := pkg.CustomError{
+			Message:    fmt.Sprintf("Bad Request. %v", err),
+			StatusCode: http.StatusBadRequest,
+		}
+		return badRequestErr
+	case errors.Is(err, order_api.ErrOrderNotShippable), errors.Is(err, order_api.ErrInvalidShipmentTransition):
+		conflictErr := pkg.CustomError{
+			Message:    fmt.Sprintf("Conflict. %v", err),
+			StatusCode: http.StatusConflict,
+		}
+		return conflictErr
+	}
+	internalServerError := pkg.CustomError{
+		Message:    fmt.Sprintf("StatusInternalServerError: %v", err),
+		StatusCode: http.StatusInternalServerError,
+	}
+	return internalServerError
+}
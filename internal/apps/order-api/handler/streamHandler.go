@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"OrderUserProject/internal/apps/order-api"
+	"OrderUserProject/pkg"
+	"encoding/json"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"time"
+)
+
+// streamResetEvent => event name of stream which cannot resume, client should read orders again
+const streamResetEvent = "Reset"
+
+// StreamOrderChanges godoc
+// @Summary Server-Sent Events of created, updated and deleted orders (event names Created, Updated, Deleted). Stream resumes after Last-Event-ID if the change is still in change log of the same order-api instance, otherwise a Reset event is sent first
+// @ID stream-order-changes
+// @Produce text/event-stream
+// @Param userId query string false "changes of orders of user"
+// @Param status query string false "changes of orders with status"
+// @Param Last-Event-ID header string false "id of last received event"
+// @Success 200 {object} order_api.OrderStreamEvent
+// @Success 400 {object} pkg.CustomError
+// @Router /orders/stream [get]
+func (h *OrderHandler) StreamOrderChanges(c echo.Context) error {
+	filter := order_api.OrderChangeFilter{UserId: c.QueryParam("userId"), Status: c.QueryParam("status")}
+
+	lastEventID := c.Request().Header.Get("Last-Event-ID")
+	if lastEventID != "" {
+		if _, _, err := order_api.ParseOrderEventID(lastEventID); err != nil {
+			badRequestErr := pkg.CustomError{
+				Message:    fmt.Sprintf("Bad Request. Last-Event-ID {%v} is not an event id!", lastEventID),
+				StatusCode: http.StatusBadRequest,
+			}
+			return badRequestErr
+		}
+	}
+
+	ctx := c.Request().Context()
+	missed, changes, complete := h.ChangeLog.Subscribe(ctx, filter, lastEventID)
+
+	response := c.Response()
+	response.Header().Set(echo.HeaderContentType, "text/event-stream")
+	response.Header().Set(echo.HeaderCacheControl, "no-cache")
+	response.Header().Set(echo.HeaderConnection, "keep-alive")
+	response.WriteHeader(http.StatusOK)
+
+	if !complete {
+		_, _ = fmt.Fprintf(response, "event: %v\ndata: {\"lastEventId\":%q}\n\n", streamResetEvent, lastEventID)
+	}
+	for _, change := range missed {
+		if err := writeOrderChange(response, change); err != nil {
+			return nil
+		}
+	}
+	response.Flush()
+
+	// Streams aren't kept alive without keep alive period
+	var keepAlive <-chan time.Time
+	if h.Config.Stream.KeepAlive > 0 {
+		ticker := time.NewTicker(h.Config.Stream.KeepAlive)
+		defer ticker.Stop()
+		keepAlive = ticker.C
+	}
+
+	c.Logger().Infof("Order stream is opened (user: %v, status: %v, last event: %v).", filter.UserId, filter.Status, lastEventID)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case change, ok := <-changes:
+			// Channel of a slow stream is closed, client reconnects with Last-Event-ID
+			if !ok {
+				return nil
+			}
+			if err := writeOrderChange(response, change); err != nil {
+				return nil
+			}
+		case <-keepAlive:
+			if _, err := fmt.Fprint(response, ": keep-alive\n\n"); err != nil {
+				return nil
+			}
+		}
+		response.Flush()
+	}
+}
+
+// writeOrderChange => change as Server-Sent Event, event name is the type of change
+func writeOrderChange(response *echo.Response, change order_api.OrderChange) error {
+	event := order_api.OrderStreamEvent{
+		Type:    change.Type,
+		OrderID: change.OrderID,
+		UserId:  change.UserId,
+		Status:  change.Status,
+		At:      change.At,
+	}
+	if change.Order != nil {
+		orderResponse := toOrderResponse(*change.Order)
+		event.Order = &orderResponse
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(response, "id: %v\nevent: %v\ndata: %s\n\n", change.EventID(), change.Type, data)
+	return err
+}
@@ -0,0 +1,77 @@
+package order_api
+
+import (
+	"OrderUserProject/internal/models"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Events of order history
+const (
+	HistoryCreated           = "Created"
+	HistoryShipmentPrepared  = "ShipmentPrepared"
+	HistoryShipmentShipped   = "ShipmentShipped"
+	HistoryShipmentDelivered = "ShipmentDelivered"
+	HistoryShipmentFailed    = "ShipmentFailed"
+	HistoryCanceled          = "Canceled"
+	HistoryPartiallyCanceled = "PartiallyCanceled"
+	HistoryRefundCreated     = "RefundCreated"
+	HistoryRefundIssued      = "RefundIssued"
+	HistoryRefundFailed      = "RefundFailed"
+	HistoryAddressChanged    = "AddressChanged"
+	HistoryDeleted           = "Deleted"
+)
+
+// OrderHistory => timeline of order, oldest first. It is derived from what is recorded on order (shipments,
+// cancellations, refunds and address changes), so it has no separate storage.
+func OrderHistory(order models.Order) []OrderHistoryEntry {
+	history := []OrderHistoryEntry{{At: order.CreatedAt, Event: HistoryCreated, Detail: order.Status}}
+
+	for _, shipment := range order.Shipments {
+		history = append(history, OrderHistoryEntry{At: shipment.CreatedAt, Event: HistoryShipmentPrepared, Detail: shipment.ID})
+		if shipment.ShippedAt != nil {
+			detail := strings.TrimSpace(fmt.Sprintf("%v %v %v", shipment.ID, shipment.Carrier, shipment.TrackingNumber))
+			history = append(history, OrderHistoryEntry{At: *shipment.ShippedAt, Event: HistoryShipmentShipped, Detail: detail})
+		}
+		if shipment.DeliveredAt != nil {
+			history = append(history, OrderHistoryEntry{At: *shipment.DeliveredAt, Event: HistoryShipmentDelivered, Detail: shipment.ID})
+		}
+		if shipment.Status == ShipmentFailed {
+			history = append(history, OrderHistoryEntry{At: shipment.UpdatedAt, Event: HistoryShipmentFailed, Detail: shipment.ID})
+		}
+	}
+
+	for _, cancellation := range order.Cancellations {
+		event := HistoryCanceled
+		if cancellation.Partial {
+			event = HistoryPartiallyCanceled
+		}
+		history = append(history, OrderHistoryEntry{At: cancellation.CanceledAt, Event: event, Detail: cancellation.Reason})
+	}
+
+	for _, refund := range order.Refunds {
+		detail := fmt.Sprintf("%v %v %v", refund.Amount, refund.Currency, refund.Method)
+		history = append(history, OrderHistoryEntry{At: refund.CreatedAt, Event: HistoryRefundCreated, Detail: detail})
+		switch refund.Status {
+		case RefundIssued:
+			history = append(history, OrderHistoryEntry{At: refund.UpdatedAt, Event: HistoryRefundIssued, Detail: detail})
+		case RefundFailed:
+			history = append(history, OrderHistoryEntry{At: refund.UpdatedAt, Event: HistoryRefundFailed, Detail: detail})
+		}
+	}
+
+	for _, change := range order.AddressChanges {
+		history = append(history, OrderHistoryEntry{At: change.DecidedAt, Event: HistoryAddressChanged, Detail: change.Decision})
+	}
+
+	if order.DeletedAt != nil {
+		history = append(history, OrderHistoryEntry{At: *order.DeletedAt, Event: HistoryDeleted})
+	}
+
+	// Events at the same time (e.g. store credit refund and its cancellation) keep the order above
+	sort.SliceStable(history, func(i, j int) bool {
+		return history[i].At.Before(history[j].At)
+	})
+	return history
+}
@@ -0,0 +1,380 @@
+package order_api
+
+import (
+	"OrderUserProject/internal/events"
+	"OrderUserProject/internal/models"
+	"OrderUserProject/internal/repository"
+	"fmt"
+	"github.com/labstack/gommon/log"
+	"go.mongodb.org/mongo-driver/mongo"
+	"time"
+)
+
+// InventorySagaService => orchestrator of inventory saga. Order-api keeps the saga state, sends commands to product-api
+// and moves the saga with replies. Replies and commands may be lost or repeated: state changes are conditional, and
+// commands of waiting sagas are sent again until product-api replies.
+type InventorySagaService struct {
+	SagaRepository  repository.IInventorySagaRepository
+	OrderRepository repository.IOrderRepository
+	// PromotionService => usages of promotions of orders which are canceled by saga are released
+	PromotionService IPromotionService
+	// Send => send command to product-api ('InventoryCommands' topic)
+	Send func(command events.DomainEvent) error
+}
+
+func NewInventorySagaService(sagaRepository repository.IInventorySagaRepository, orderRepository repository.IOrderRepository, promotionService IPromotionService, send func(command events.DomainEvent) error) IInventorySagaService {
+	inventorySagaService := &InventorySagaService{
+		SagaRepository:   sagaRepository,
+		OrderRepository:  orderRepository,
+		PromotionService: promotionService,
+		Send:             send,
+	}
+	return inventorySagaService
+}
+
+type IInventorySagaService interface {
+	Start(order models.Order) error
+	Release(orderId string, reason string) error
+	CheckShipment(orderId string) error
+	CheckLines(order models.Order) error
+	Commit(orderId string) error
+	Adjust(orderId string, adjustmentId string, canceled []models.CanceledLine) error
+	HandleReply(envelope events.Envelope) (*OrderCancelResult, error)
+	RetryWaiting(replyTimeout time.Duration, maxReserveAttempts int) ([]OrderCancelResult, error)
+	CancelOrder(orderId string, reason string, note string) (*OrderCancelResult, error)
+	GetSagas(states []string) ([]models.InventorySaga, error)
+}
+
+// Start => saga of created order, stock of order lines is reserved. If command cannot send, it is sent again by retry job.
+func (b *InventorySagaService) Start(order models.Order) error {
+	now := time.Now()
+	saga := models.InventorySaga{
+		ID:        order.ID,
+		OrderID:   order.ID,
+		Lines:     stockLines(order.Product),
+		State:     SagaReserving,
+		Steps:     []models.SagaStep{{State: SagaReserving, At: now}},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	if _, err := b.SagaRepository.Insert(saga); err != nil {
+		return err
+	}
+
+	b.send(events.NewReserveStock(saga.ID, saga.OrderID, saga.Lines))
+	return nil
+}
+
+// Release => order is canceled or deleted, reserved (or reserving) stock is released. Orders without saga (created
+// before inventory) and sagas which are finished are not changed.
+func (b *InventorySagaService) Release(orderId string, reason string) error {
+	result, err := b.SagaRepository.UpdateState(orderId, []string{SagaReserving, SagaReserved}, newSagaStep(SagaReleasing, reason))
+	if err != nil || result == false {
+		return err
+	}
+
+	b.send(events.NewReleaseStock(orderId, orderId))
+	return nil
+}
+
+// CheckShipment => order can ship only after its stock is reserved. Orders without saga are not checked.
+func (b *InventorySagaService) CheckShipment(orderId string) error {
+	saga, err := b.SagaRepository.GetSagaById(orderId)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if saga.State != SagaReserved && saga.State != SagaCommitting && saga.State != SagaCommitted {
+		return fmt.Errorf("%w: saga is %v", ErrStockNotReserved, saga.State)
+	}
+
+	return nil
+}
+
+// CheckLines => lines of order cannot change while its stock is reserved (or committed), reservation has the old
+// quantities. Order can change with the same lines, e.g. its address. Orders without saga are not checked.
+func (b *InventorySagaService) CheckLines(order models.Order) error {
+	saga, err := b.SagaRepository.GetSagaById(order.ID)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if isOneOf(saga.State, AdjustableSagaStates) && !sameStockLines(saga.Lines, stockLines(order.Product)) {
+		return fmt.Errorf("%w: saga is %v, please cancel lines instead of changing them", ErrStockLinesReserved, saga.State)
+	}
+
+	return nil
+}
+
+// Commit => order is shipped, reserved stock is committed
+func (b *InventorySagaService) Commit(orderId string) error {
+	result, err := b.SagaRepository.UpdateState(orderId, []string{SagaReserved}, newSagaStep(SagaCommitting, "order is shipped"))
+	if err != nil || result == false {
+		return err
+	}
+
+	b.send(events.NewCommitStock(orderId, orderId))
+	return nil
+}
+
+// Adjust => canceled quantities of a partially canceled order are given back, reserved stock is released and committed
+// stock (order is shipped partly) is added to stock again. Lines of saga become the remaining lines, so a reservation
+// which is sent again reserves only them. Adjustment id is the cancellation id, product-api applies it once.
+func (b *InventorySagaService) Adjust(orderId string, adjustmentId string, canceled []models.CanceledLine) error {
+	saga, err := b.SagaRepository.GetSagaById(orderId)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	adjustment := models.StockAdjustment{ID: adjustmentId, At: time.Now()}
+	for _, line := range canceled {
+		adjustment.Lines = append(adjustment.Lines, models.StockLine{Sku: line.Sku, Quantity: line.Quantity})
+	}
+	if len(adjustment.Lines) == 0 {
+		return nil
+	}
+
+	// Released or failed sagas have nothing to give back
+	result, err := b.SagaRepository.AddAdjustment(saga.ID, AdjustableSagaStates, reduceStockLines(saga.Lines, adjustment.Lines), adjustment)
+	if err != nil || result == false {
+		return err
+	}
+
+	b.send(events.NewAdjustStock(saga.ID, saga.OrderID, adjustment.ID, adjustment.Lines))
+	return nil
+}
+
+// HandleReply => move saga with reply of product-api. If reservation fails the order is canceled (compensation),
+// canceled order is returned to publish its events. Repeated or late replies don't change the saga.
+func (b *InventorySagaService) HandleReply(envelope events.Envelope) (*OrderCancelResult, error) {
+	var reply struct {
+		SagaID       string `json:"sagaID"`
+		Reason       string `json:"reason"`
+		AdjustmentID string `json:"adjustmentID"`
+	}
+	if err := envelope.DecodePayload(&reply); err != nil {
+		return nil, err
+	}
+
+	saga, err := b.SagaRepository.GetSagaById(reply.SagaID)
+	if err == mongo.ErrNoDocuments {
+		log.Warnf("Saga (%v) of %v reply is not found.", reply.SagaID, envelope.Type)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch envelope.Type {
+	case events.StockReservedType:
+		// Order is canceled while reservation was waiting, release command is sent after the reservation again
+		if saga.State == SagaReleasing {
+			b.send(events.NewReleaseStock(saga.ID, saga.OrderID))
+			return nil, nil
+		}
+		_, err := b.SagaRepository.UpdateState(saga.ID, []string{SagaReserving}, newSagaStep(SagaReserved, ""))
+		return nil, err
+	case events.StockReservationFailedType:
+		switch saga.State {
+		case SagaReserving:
+			result, err := b.SagaRepository.UpdateState(saga.ID, []string{SagaReserving}, newSagaStep(SagaFailed, reply.Reason))
+			if err != nil || result == false {
+				return nil, err
+			}
+			return b.CancelOrder(saga.OrderID, CancelReasonOutOfStock, reply.Reason)
+		case SagaReleasing:
+			// Nothing is reserved, so nothing to release
+			_, err := b.SagaRepository.UpdateState(saga.ID, []string{SagaReleasing}, newSagaStep(SagaReleased, reply.Reason))
+			return nil, err
+		case SagaCommitting:
+			// Order is shipped without committed stock, saga is failed and it has to be checked by hand
+			_, err := b.SagaRepository.UpdateState(saga.ID, []string{SagaCommitting}, newSagaStep(SagaFailed, reply.Reason))
+			return nil, err
+		}
+	case events.StockReleasedType:
+		_, err := b.SagaRepository.UpdateState(saga.ID, []string{SagaReleasing}, newSagaStep(SagaReleased, ""))
+		return nil, err
+	case events.StockCommittedType:
+		_, err := b.SagaRepository.UpdateState(saga.ID, []string{SagaCommitting}, newSagaStep(SagaCommitted, ""))
+		return nil, err
+	case events.StockAdjustedType:
+		return nil, b.SagaRepository.RemoveAdjustment(saga.ID, reply.AdjustmentID)
+	}
+
+	return nil, nil
+}
+
+// RetryWaiting => command of sagas which wait for a reply longer than reply timeout is sent again. Reservation is given
+// up after max attempts: order is canceled and stock which may be reserved is released. Canceled orders are returned.
+// Adjustments which are not replied in time are sent again too.
+func (b *InventorySagaService) RetryWaiting(replyTimeout time.Duration, maxReserveAttempts int) ([]OrderCancelResult, error) {
+	if err := b.retryAdjustments(time.Now().Add(-replyTimeout)); err != nil {
+		return nil, err
+	}
+
+	sagas, err := b.SagaRepository.GetSagasByState(WaitingSagaStates, time.Now().Add(-replyTimeout))
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []OrderCancelResult
+	for _, saga := range sagas {
+		if saga.State == SagaReserving && saga.Attempts >= maxReserveAttempts {
+			result, err := b.SagaRepository.UpdateState(saga.ID, []string{SagaReserving}, newSagaStep(SagaReleasing, "reservation timed out"))
+			if err != nil {
+				return changes, err
+			}
+			if result == false {
+				continue
+			}
+			b.send(events.NewReleaseStock(saga.ID, saga.OrderID))
+
+			change, err := b.CancelOrder(saga.OrderID, CancelReasonOther, "reservation timed out")
+			if err != nil {
+				return changes, err
+			}
+			if change != nil {
+				changes = append(changes, *change)
+			}
+			continue
+		}
+
+		result, err := b.SagaRepository.AddAttempt(saga.ID, saga.State, time.Now())
+		if err != nil {
+			return changes, err
+		}
+		if result {
+			b.send(sagaCommand(saga))
+		}
+	}
+
+	return changes, nil
+}
+
+// retryAdjustments => adjustments which are added before addedBefore and not replied are sent again
+func (b *InventorySagaService) retryAdjustments(addedBefore time.Time) error {
+	sagas, err := b.SagaRepository.GetSagasWithPendingAdjustments(addedBefore)
+	if err != nil {
+		return err
+	}
+
+	for _, saga := range sagas {
+		for _, adjustment := range saga.PendingAdjustments {
+			if adjustment.At.Before(addedBefore) {
+				b.send(events.NewAdjustStock(saga.ID, saga.OrderID, adjustment.ID, adjustment.Lines))
+			}
+		}
+	}
+	return nil
+}
+
+// CancelOrder => compensation of saga, order is canceled with reason if it is still open. Its cancellation and refund
+// are recorded and usages of its promotions are released like cancel request. Canceled order is returned (nil if the
+// order is already closed) to publish its events.
+func (b *InventorySagaService) CancelOrder(orderId string, reason string, note string) (*OrderCancelResult, error) {
+	order, err := b.OrderRepository.GetOrderById(orderId)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return cancelOpenOrder(b.OrderRepository, b.PromotionService, order, reason, note)
+}
+
+// GetSagas => sagas in the states, in-flight sagas if there is no state
+func (b *InventorySagaService) GetSagas(states []string) ([]models.InventorySaga, error) {
+	if len(states) == 0 {
+		states = InFlightSagaStates
+	}
+
+	return b.SagaRepository.GetSagasByState(states, time.Time{})
+}
+
+// send => errors are only logged, saga state is already saved and retry job sends the command again
+func (b *InventorySagaService) send(command events.DomainEvent) {
+	if err := b.Send(command); err != nil {
+		log.Errorf("%v command of order (%v) cannot send, it is sent again later: %v", command.Type, command.Key, err)
+	}
+}
+
+// sagaCommand => command which is waited by saga
+func sagaCommand(saga models.InventorySaga) events.DomainEvent {
+	switch saga.State {
+	case SagaReleasing:
+		return events.NewReleaseStock(saga.ID, saga.OrderID)
+	case SagaCommitting:
+		return events.NewCommitStock(saga.ID, saga.OrderID)
+	default:
+		return events.NewReserveStock(saga.ID, saga.OrderID, saga.Lines)
+	}
+}
+
+// stockLines => quantity of every sku in order, lines with the same sku are merged
+func stockLines(products []models.OrderProduct) []models.StockLine {
+	var lines []models.StockLine
+	index := map[string]int{}
+	for _, product := range products {
+		if i, ok := index[product.Sku]; ok {
+			lines[i].Quantity += product.Quantity
+			continue
+		}
+		index[product.Sku] = len(lines)
+		lines = append(lines, models.StockLine{Sku: product.Sku, Quantity: product.Quantity})
+	}
+	return lines
+}
+
+// sameStockLines => lines have the same quantity of every sku, order of lines doesn't matter
+func sameStockLines(lines []models.StockLine, others []models.StockLine) bool {
+	quantities := map[string]int{}
+	for _, line := range lines {
+		quantities[line.Sku] += line.Quantity
+	}
+	for _, line := range others {
+		quantities[line.Sku] -= line.Quantity
+	}
+	for _, quantity := range quantities {
+		if quantity != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// reduceStockLines => lines after canceled quantities, lines without quantity are removed
+func reduceStockLines(lines []models.StockLine, canceled []models.StockLine) []models.StockLine {
+	quantities := map[string]int{}
+	for _, line := range canceled {
+		quantities[line.Sku] += line.Quantity
+	}
+
+	left := []models.StockLine{}
+	for _, line := range lines {
+		quantity := quantities[line.Sku]
+		if quantity > line.Quantity {
+			quantity = line.Quantity
+		}
+		quantities[line.Sku] -= quantity
+		if line.Quantity > quantity {
+			line.Quantity -= quantity
+			left = append(left, line)
+		}
+	}
+	return left
+}
+
+func newSagaStep(state string, reason string) models.SagaStep {
+	return models.SagaStep{State: state, Reason: reason, At: time.Now()}
+}
@@ -0,0 +1,81 @@
+package order_api
+
+import (
+	"bytes"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"os"
+	"path/filepath"
+)
+
+// IInvoiceStorage => rendered invoice documents are kept by their names (e.g. "INV-2024-000001.pdf")
+type IInvoiceStorage interface {
+	Save(name string, content []byte) error
+	Open(name string) ([]byte, error)
+}
+
+// FileSystemInvoiceStorage => documents are files of a directory
+type FileSystemInvoiceStorage struct {
+	Directory string
+}
+
+func NewFileSystemInvoiceStorage(directory string) IInvoiceStorage {
+	fileSystemInvoiceStorage := &FileSystemInvoiceStorage{Directory: directory}
+	return fileSystemInvoiceStorage
+}
+
+// Save => document is written to a temporary file which is renamed, so a half written document is never opened
+func (s *FileSystemInvoiceStorage) Save(name string, content []byte) error {
+	if err := os.MkdirAll(s.Directory, 0o755); err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(s.Directory, ".invoice-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := file.Write(content); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), filepath.Join(s.Directory, filepath.Base(name)))
+}
+
+func (s *FileSystemInvoiceStorage) Open(name string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(s.Directory, filepath.Base(name)))
+}
+
+// GridFSInvoiceStorage => documents are files of a GridFS bucket, so every instance of order-api reads the same files
+type GridFSInvoiceStorage struct {
+	Bucket *gridfs.Bucket
+}
+
+func NewGridFSInvoiceStorage(database *mongo.Database, bucket string) (IInvoiceStorage, error) {
+	gridFSBucket, err := gridfs.NewBucket(database, options.GridFSBucket().SetName(bucket))
+	if err != nil {
+		return nil, err
+	}
+	gridFSInvoiceStorage := &GridFSInvoiceStorage{Bucket: gridFSBucket}
+	return gridFSInvoiceStorage, nil
+}
+
+// Save => document is uploaded as a new revision of its name
+func (s *GridFSInvoiceStorage) Save(name string, content []byte) error {
+	_, err := s.Bucket.UploadFromStream(name, bytes.NewReader(content))
+	return err
+}
+
+// Open => latest revision of document is downloaded
+func (s *GridFSInvoiceStorage) Open(name string) ([]byte, error) {
+	var buffer bytes.Buffer
+	if _, err := s.Bucket.DownloadToStreamByName(name, &buffer); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
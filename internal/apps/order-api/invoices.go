@@ -0,0 +1,223 @@
+package order_api
+
+import (
+	"OrderUserProject/internal/models"
+	"OrderUserProject/internal/repository"
+	"OrderUserProject/pkg/pdf"
+	"bytes"
+	"embed"
+	"fmt"
+	"github.com/labstack/gommon/log"
+	"go.mongodb.org/mongo-driver/mongo"
+	htmlTemplate "html/template"
+	"strings"
+	textTemplate "text/template"
+	"time"
+)
+
+// invoiceClaimTimeout => invoice which is still Generating after this duration is claimed again (e.g. instance which
+// generates it is stopped)
+const invoiceClaimTimeout = time.Minute
+
+//go:embed templates
+var invoiceTemplates embed.FS
+
+var (
+	invoiceHTMLTemplate = htmlTemplate.Must(htmlTemplate.ParseFS(invoiceTemplates, "templates/invoice.html"))
+	invoiceTextTemplate = textTemplate.Must(textTemplate.ParseFS(invoiceTemplates, "templates/invoice.txt"))
+)
+
+// InvoiceService => invoices of orders. Invoice of an order is generated once, when order reaches a billable status.
+// Numbers are sequential per year and a number is never given to two invoices or skipped: invoice is claimed first,
+// number is reserved for the invoice on the counter of its year and saved to claimed invoice, so an interrupted
+// generation gets the same number again.
+type InvoiceService struct {
+	InvoiceRepository repository.IInvoiceRepository
+	Storage           IInvoiceStorage
+	NumberPrefix      string
+	BillableStatuses  []string
+	Seller            InvoiceSeller
+}
+
+func NewInvoiceService(invoiceRepository repository.IInvoiceRepository, storage IInvoiceStorage, numberPrefix string, billableStatuses []string, seller InvoiceSeller) IInvoiceService {
+	invoiceService := &InvoiceService{
+		InvoiceRepository: invoiceRepository,
+		Storage:           storage,
+		NumberPrefix:      numberPrefix,
+		BillableStatuses:  billableStatuses,
+		Seller:            seller,
+	}
+	return invoiceService
+}
+
+type IInvoiceService interface {
+	IsBillable(order models.Order) bool
+	GetInvoice(orderId string) (models.Invoice, error)
+	Generate(order models.Order) (models.Invoice, error)
+	Document(invoice models.Invoice, format string) ([]byte, error)
+}
+
+// IsBillable => order reached a status which is billed (e.g. Shipped)
+func (b *InvoiceService) IsBillable(order models.Order) bool {
+	return isOneOf(order.Status, b.BillableStatuses)
+}
+
+func (b *InvoiceService) GetInvoice(orderId string) (models.Invoice, error) {
+	return b.InvoiceRepository.GetInvoiceByOrderId(orderId)
+}
+
+// Generate => invoice of billable order is numbered, rendered to PDF and HTML, stored and issued. Issued invoice is
+// returned as it is, it isn't generated again when order changes.
+func (b *InvoiceService) Generate(order models.Order) (models.Invoice, error) {
+	if !b.IsBillable(order) {
+		return models.Invoice{}, fmt.Errorf("%w: order is %v", ErrInvoiceNotBillable, order.Status)
+	}
+
+	invoice, err := b.InvoiceRepository.GetInvoiceByOrderId(order.ID)
+	if err == nil && invoice.Status == InvoiceIssued {
+		return invoice, nil
+	}
+	if err != nil && err != mongo.ErrNoDocuments {
+		return models.Invoice{}, err
+	}
+
+	now := time.Now()
+	claim := models.Invoice{ID: order.ID, OrderID: order.ID, Status: InvoiceGenerating, CreatedAt: now, UpdatedAt: now}
+	claimed, err := b.InvoiceRepository.Claim(claim, now.Add(-invoiceClaimTimeout))
+	if err != nil {
+		return models.Invoice{}, err
+	}
+	if claimed == false {
+		invoice, err := b.InvoiceRepository.GetInvoiceByOrderId(order.ID)
+		if err == nil && invoice.Status == InvoiceIssued {
+			return invoice, nil
+		}
+		return models.Invoice{}, ErrInvoiceInProgress
+	}
+
+	// Invoice which is claimed again keeps the number of interrupted generation
+	invoice, err = b.InvoiceRepository.GetInvoiceByOrderId(order.ID)
+	if err != nil {
+		return models.Invoice{}, err
+	}
+	if invoice.Number == "" {
+		if invoice.Number, err = b.nextNumber(order.ID, invoice.CreatedAt); err != nil {
+			return models.Invoice{}, err
+		}
+	}
+
+	invoice.Status = InvoiceIssued
+	invoice.UserId = order.UserId
+	invoice.InvoiceAddress = order.InvoiceAddress
+	invoice.Lines = order.Product
+	invoice.Currency = order.Currency
+	invoice.Subtotal = order.Subtotal
+	invoice.Discount = order.Discount
+	invoice.Tax = order.Tax
+	invoice.Shipping = order.Shipping
+	invoice.Total = order.Total
+	invoice.PdfFile = invoice.Number + "." + InvoicePDF
+	invoice.HtmlFile = invoice.Number + "." + InvoiceHTML
+	invoice.IssuedAt = &now
+	invoice.UpdatedAt = now
+
+	html, err := b.RenderInvoiceHTML(invoice)
+	if err != nil {
+		return models.Invoice{}, err
+	}
+	document, err := b.RenderInvoicePDF(invoice)
+	if err != nil {
+		return models.Invoice{}, err
+	}
+	if err := b.Storage.Save(invoice.HtmlFile, html); err != nil {
+		return models.Invoice{}, err
+	}
+	if err := b.Storage.Save(invoice.PdfFile, document); err != nil {
+		return models.Invoice{}, err
+	}
+
+	issued, err := b.InvoiceRepository.Issue(invoice, InvoiceGenerating)
+	if err != nil {
+		return models.Invoice{}, err
+	}
+	if issued == false {
+		return models.Invoice{}, ErrInvoiceInProgress
+	}
+
+	return invoice, nil
+}
+
+// nextNumber => number which is reserved for invoice on the counter of year (year of first claim, so a generation
+// which is interrupted at new year gets the same number) is saved to claimed invoice. If another request saved a
+// number before (claim is timed out) its number is used.
+func (b *InvoiceService) nextNumber(orderId string, claimedAt time.Time) (string, error) {
+	counter := fmt.Sprintf("invoice-%d", claimedAt.Year())
+	sequence, err := b.InvoiceRepository.NextSequence(counter, orderId)
+	if err != nil {
+		return "", err
+	}
+
+	number := fmt.Sprintf("%v-%d-%06d", b.NumberPrefix, claimedAt.Year(), sequence)
+	assigned, err := b.InvoiceRepository.AssignNumber(orderId, number)
+	if err != nil {
+		return "", err
+	}
+	if assigned == false {
+		invoice, err := b.InvoiceRepository.GetInvoiceByOrderId(orderId)
+		if err != nil {
+			return "", err
+		}
+		number = invoice.Number
+	}
+
+	// Number is on invoice, reservation which cannot be removed is only a leftover
+	if err := b.InvoiceRepository.ReleaseSequence(counter, orderId); err != nil {
+		log.Errorf("Invoice number reservation of order (%v) cannot release: %v", orderId, err)
+	}
+
+	return number, nil
+}
+
+// Document => rendered document of issued invoice, format is "pdf" or "html"
+func (b *InvoiceService) Document(invoice models.Invoice, format string) ([]byte, error) {
+	switch format {
+	case InvoicePDF:
+		return b.Storage.Open(invoice.PdfFile)
+	case InvoiceHTML:
+		return b.Storage.Open(invoice.HtmlFile)
+	}
+	return nil, fmt.Errorf("unknown invoice format: %v", format)
+}
+
+// RenderInvoiceHTML => invoice as HTML page
+func (b *InvoiceService) RenderInvoiceHTML(invoice models.Invoice) ([]byte, error) {
+	var buffer bytes.Buffer
+	if err := invoiceHTMLTemplate.Execute(&buffer, b.invoiceData(invoice)); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// RenderInvoicePDF => invoice as PDF document, text template is written with monospaced font, so its columns line up
+func (b *InvoiceService) RenderInvoicePDF(invoice models.Invoice) ([]byte, error) {
+	var buffer bytes.Buffer
+	if err := invoiceTextTemplate.Execute(&buffer, b.invoiceData(invoice)); err != nil {
+		return nil, err
+	}
+	lines := strings.Split(strings.TrimRight(buffer.String(), "\n"), "\n")
+	return pdf.FromText("Invoice "+invoice.Number, lines), nil
+}
+
+type invoiceData struct {
+	Invoice  models.Invoice
+	Seller   InvoiceSeller
+	IssuedAt string
+}
+
+func (b *InvoiceService) invoiceData(invoice models.Invoice) invoiceData {
+	data := invoiceData{Invoice: invoice, Seller: b.Seller}
+	if invoice.IssuedAt != nil {
+		data.IssuedAt = invoice.IssuedAt.Format("02.01.2006")
+	}
+	return data
+}
@@ -0,0 +1,170 @@
+package order_api
+
+import (
+	"OrderUserProject/internal/events"
+	"OrderUserProject/internal/models"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// orderChangeBufferSize => changes which wait for a slow stream, the stream is closed after it and client resumes
+// with Last-Event-ID
+const orderChangeBufferSize = 64
+
+// OrderChange => change of order in change log, ID is the sequence of change in this order-api instance and Epoch
+// is the id of instance (it is different after restart), event id of stream has both of them
+type OrderChange struct {
+	ID      int64
+	Epoch   string
+	Type    string
+	OrderID string
+	UserId  string
+	Status  string
+	// Order => order after change, it is nil for deleted orders
+	Order *models.Order
+	At    time.Time
+}
+
+// EventID => id of change on stream, "<epoch>-<sequence>"
+func (c OrderChange) EventID() string {
+	return fmt.Sprintf("%v-%d", c.Epoch, c.ID)
+}
+
+// ParseOrderEventID => epoch and sequence of event id. Event id without epoch (before epochs) has an empty epoch, so
+// it never resumes.
+func ParseOrderEventID(eventID string) (string, int64, error) {
+	epoch := ""
+	sequence := eventID
+	if i := strings.LastIndex(eventID, "-"); i >= 0 {
+		epoch, sequence = eventID[:i], eventID[i+1:]
+	}
+
+	id, err := strconv.ParseInt(sequence, 10, 64)
+	if err != nil || id < 0 {
+		return "", 0, fmt.Errorf("%v is not an event id", eventID)
+	}
+	return epoch, id, nil
+}
+
+// OrderChangeFilter => filter of order stream, empty fields match every change
+type OrderChangeFilter struct {
+	UserId string
+	Status string
+}
+
+func (f OrderChangeFilter) Matches(change OrderChange) bool {
+	return (f.UserId == "" || change.UserId == f.UserId) && (f.Status == "" || change.Status == f.Status)
+}
+
+// OrderChangeLog => bounded log of last order changes of 'OrderChanged' events, streams read the changes which
+// their clients missed from it and wait for next changes
+type OrderChangeLog struct {
+	mutex       sync.Mutex
+	epoch       string
+	size        int
+	changes     []OrderChange
+	lastID      int64
+	subscribers map[*orderChangeSubscriber]struct{}
+}
+
+type orderChangeSubscriber struct {
+	filter  OrderChangeFilter
+	changes chan OrderChange
+}
+
+func NewOrderChangeLog(size int) *OrderChangeLog {
+	// Epoch => ids of changes are meaningful only in this instance, event ids of another instance are not resumed
+	epoch := strconv.FormatInt(time.Now().UnixNano(), 36)
+	return &OrderChangeLog{epoch: epoch, size: size, subscribers: map[*orderChangeSubscriber]struct{}{}}
+}
+
+// NewDeletedOrderChange => change of 'OrderChanged' event of deleted order, user and status are of order in event
+func NewDeletedOrderChange(orderChanged events.OrderChanged) OrderChange {
+	change := OrderChange{Type: OrderChangeDeleted, OrderID: orderChanged.OrderID}
+	if orderChanged.Order != nil {
+		change.UserId, change.Status = orderChanged.Order.UserId, orderChanged.Order.Status
+	}
+	return change
+}
+
+// Append => add change to log with next id and send it to streams which match. Deleted order without user (e.g. event
+// without order) gets user and status of its previous change in log.
+func (l *OrderChangeLog) Append(change OrderChange) OrderChange {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.lastID++
+	change.ID = l.lastID
+	change.Epoch = l.epoch
+	if change.At.IsZero() {
+		change.At = time.Now().UTC()
+	}
+	if change.Type == OrderChangeDeleted && change.UserId == "" {
+		for i := len(l.changes) - 1; i >= 0; i-- {
+			if l.changes[i].OrderID == change.OrderID {
+				change.UserId, change.Status = l.changes[i].UserId, l.changes[i].Status
+				break
+			}
+		}
+	}
+
+	l.changes = append(l.changes, change)
+	if len(l.changes) > l.size {
+		l.changes = l.changes[len(l.changes)-l.size:]
+	}
+
+	for subscriber := range l.subscribers {
+		if !subscriber.filter.Matches(change) {
+			continue
+		}
+		select {
+		case subscriber.changes <- change:
+		default:
+			// Slow stream is closed instead of losing changes silently
+			delete(l.subscribers, subscriber)
+			close(subscriber.changes)
+		}
+	}
+
+	return change
+}
+
+// Subscribe => changes after lastEventID which match filter and channel of next changes, channel is closed when
+// context is done. Empty lastEventID means no missed changes. complete is false if some changes after lastEventID are
+// not in log anymore or lastEventID is of another instance (other epoch, e.g. order-api is restarted or client
+// reconnects to another instance), then all changes in log are returned.
+func (l *OrderChangeLog) Subscribe(ctx context.Context, filter OrderChangeFilter, lastEventID string) ([]OrderChange, <-chan OrderChange, bool) {
+	subscriber := &orderChangeSubscriber{filter: filter, changes: make(chan OrderChange, orderChangeBufferSize)}
+
+	l.mutex.Lock()
+	var missed []OrderChange
+	complete := true
+	if lastEventID != "" {
+		epoch, lastID, err := ParseOrderEventID(lastEventID)
+		oldestID := l.lastID - int64(len(l.changes)) + 1
+		complete = err == nil && epoch == l.epoch && lastID >= oldestID-1 && lastID <= l.lastID
+		for _, change := range l.changes {
+			if (change.ID > lastID || !complete) && filter.Matches(change) {
+				missed = append(missed, change)
+			}
+		}
+	}
+	l.subscribers[subscriber] = struct{}{}
+	l.mutex.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		l.mutex.Lock()
+		if _, ok := l.subscribers[subscriber]; ok {
+			delete(l.subscribers, subscriber)
+			close(subscriber.changes)
+		}
+		l.mutex.Unlock()
+	}()
+
+	return missed, subscriber.changes, complete
+}
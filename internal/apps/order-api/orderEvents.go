@@ -0,0 +1,25 @@
+package order_api
+
+import (
+	"OrderUserProject/internal/events"
+	"OrderUserProject/internal/models"
+)
+
+// NewOrderChangedEnvelope => 'OrderChanged' event of order for 'OrderID' topic. In "full" event mode the order snapshot
+// is sent too, so order-elastic doesn't need to call back order-api. Deleted order cannot be read back, so its snapshot
+// is always sent (order streams filter it by its user and status).
+func NewOrderChangedEnvelope(orderID string, status string, order *models.Order, eventMode string) (events.Envelope, error) {
+	orderChanged := events.OrderChanged{
+		OrderID: orderID,
+		Status:  status,
+	}
+	version := events.OrderChangedThinVersion
+
+	if order != nil && (eventMode == OrderEventModeFull || status == OrderChangeDeleted) {
+		orderEvent := events.NewOrder(*order)
+		orderChanged.Order = &orderEvent
+		version = events.OrderChangedFullVersion
+	}
+
+	return events.New(events.OrderChangedType, version, orderChanged)
+}
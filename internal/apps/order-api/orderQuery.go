@@ -0,0 +1,119 @@
+package order_api
+
+import (
+	"OrderUserProject/internal/query"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// NewOrderQuery => query of generic endpoint request, it is emitted as MongoDB and Elasticsearch queries. Error wraps
+// ErrInvalidFilter and names the clause which is not valid.
+func NewOrderQuery(req OrderGetRequest) (query.Query, error) {
+	var orderQuery query.Query
+	var filters []query.Filter
+
+	// Exact filters are sorted, so same request is always the same query
+	exactFields := make([]string, 0, len(req.ExactFilters))
+	for field := range req.ExactFilters {
+		exactFields = append(exactFields, field)
+	}
+	sort.Strings(exactFields)
+	for _, field := range exactFields {
+		condition, err := query.NewCondition("exact_filters."+field, field, query.OperatorIn, req.ExactFilters[field])
+		if err != nil {
+			return query.Query{}, err
+		}
+		filters = append(filters, condition)
+	}
+
+	for i, match := range req.Match {
+		condition, err := query.NewCondition(fmt.Sprintf("match[%d]", i), match.MatchField, match.Parameter, match.Value)
+		if err != nil {
+			return query.Query{}, err
+		}
+		filters = append(filters, condition)
+	}
+
+	if req.Filter != nil {
+		filter, err := query.NewFilter("filter", *req.Filter)
+		if err != nil {
+			return query.Query{}, err
+		}
+		filters = append(filters, filter)
+	}
+	orderQuery.Filter = query.And(filters...)
+
+	fields, err := query.NewFields("fields", req.Fields)
+	if err != nil {
+		return query.Query{}, err
+	}
+	orderQuery.Fields = fields
+
+	for _, field := range req.orderedSortFields() {
+		sortField, err := query.NewSortField("sort."+field, field, req.Sort[field])
+		if err != nil {
+			return query.Query{}, err
+		}
+		orderQuery.Sort = append(orderQuery.Sort, sortField)
+	}
+
+	return orderQuery, nil
+}
+
+// UnmarshalJSON => sort is decoded to a map without order of fields, so order of fields in body is kept separately
+func (r *OrderGetRequest) UnmarshalJSON(data []byte) error {
+	type orderGetRequest OrderGetRequest
+	if err := json.Unmarshal(data, (*orderGetRequest)(r)); err != nil {
+		return err
+	}
+
+	var body struct {
+		Sort json.RawMessage `json:"sort"`
+	}
+	if err := json.Unmarshal(data, &body); err != nil || len(body.Sort) == 0 {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body.Sort))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return err
+	}
+	r.sortFields = nil
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		r.sortFields = append(r.sortFields, token.(string))
+
+		var direction json.RawMessage
+		if err := decoder.Decode(&direction); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// orderedSortFields => fields of sort in order of body, fields which are not in body (e.g. request is not decoded from
+// a body) follow them by name
+func (r OrderGetRequest) orderedSortFields() []string {
+	fields := make([]string, 0, len(r.Sort))
+	ordered := map[string]bool{}
+	for _, field := range r.sortFields {
+		if _, ok := r.Sort[field]; ok && !ordered[field] {
+			fields = append(fields, field)
+			ordered[field] = true
+		}
+	}
+
+	var rest []string
+	for field := range r.Sort {
+		if !ordered[field] {
+			rest = append(rest, field)
+		}
+	}
+	sort.Strings(rest)
+	return append(fields, rest...)
+}
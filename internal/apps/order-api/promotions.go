@@ -0,0 +1,360 @@
+package order_api
+
+import (
+	"OrderUserProject/internal/models"
+	"OrderUserProject/internal/repository"
+	"OrderUserProject/pkg/money"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/labstack/gommon/log"
+	"go.mongodb.org/mongo-driver/mongo"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PromotionService => promotions and coupons of orders. Promotions are evaluated when an order is created: entered
+// coupon is applied first, then automatic promotions by priority. A promotion which is not stackable is never
+// combined with another one, so after it nothing is applied and it is skipped when something is already applied.
+type PromotionService struct {
+	PromotionRepository repository.IPromotionRepository
+}
+
+func NewPromotionService(promotionRepository repository.IPromotionRepository) IPromotionService {
+	promotionService := &PromotionService{
+		PromotionRepository: promotionRepository,
+	}
+	return promotionService
+}
+
+type IPromotionService interface {
+	GetAll() ([]models.Promotion, error)
+	GetPromotionById(id string) (models.Promotion, error)
+	Insert(promotion models.Promotion) (models.Promotion, error)
+	Update(promotion models.Promotion) (bool, error)
+	Apply(order *models.Order, now time.Time) ([]models.Promotion, error)
+	Redeem(userId string, promotions []models.Promotion) error
+	Release(userId string, promotions []models.Promotion)
+}
+
+func (b *PromotionService) GetAll() ([]models.Promotion, error) {
+	return b.PromotionRepository.GetAll()
+}
+
+func (b *PromotionService) GetPromotionById(id string) (models.Promotion, error) {
+	return b.PromotionRepository.GetPromotionById(id)
+}
+
+// Insert => promotion is checked and created, coupon codes are unique and kept in upper case
+func (b *PromotionService) Insert(promotion models.Promotion) (models.Promotion, error) {
+	promotion.Code = NormalizeCouponCode(promotion.Code)
+	if err := ValidatePromotion(promotion); err != nil {
+		return models.Promotion{}, err
+	}
+
+	if promotion.Code != "" {
+		_, err := b.PromotionRepository.GetPromotionByCode(promotion.Code)
+		if err == nil {
+			return models.Promotion{}, ErrCouponCodeExists
+		}
+		if err != mongo.ErrNoDocuments {
+			return models.Promotion{}, err
+		}
+	}
+
+	promotion.ID = uuid.New().String()
+	promotion.CreatedAt = time.Now()
+	promotion.UpdatedAt = promotion.CreatedAt
+
+	if _, err := b.PromotionRepository.Insert(promotion); err != nil {
+		return models.Promotion{}, err
+	}
+
+	return promotion, nil
+}
+
+// Update => rules of promotion are changed, orders which already used it are not changed
+func (b *PromotionService) Update(promotion models.Promotion) (bool, error) {
+	if err := ValidatePromotion(promotion); err != nil {
+		return false, err
+	}
+
+	promotion.UpdatedAt = time.Now()
+	return b.PromotionRepository.Update(promotion)
+}
+
+// Apply => coupon of order (if any) and automatic promotions are evaluated, discount breakdown, discount and shipping
+// of order are set. Shipping of order is the fee before promotions. Coupon which cannot be applied is an error,
+// automatic promotions which cannot be applied are skipped. Returns the applied promotions to redeem. Usage of
+// promotions which are in discount breakdown of order (order is updated) is not counted against their limits.
+func (b *PromotionService) Apply(order *models.Order, now time.Time) ([]models.Promotion, error) {
+	var candidates []models.Promotion
+	used := map[string]bool{}
+	for _, promotion := range order.Promotions {
+		used[promotion.PromotionID] = true
+	}
+
+	if order.CouponCode != "" {
+		order.CouponCode = NormalizeCouponCode(order.CouponCode)
+		coupon, err := b.PromotionRepository.GetPromotionByCode(order.CouponCode)
+		if err == mongo.ErrNoDocuments {
+			return nil, fmt.Errorf("%w: %v", ErrCouponNotFound, order.CouponCode)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if used[coupon.ID] {
+			coupon = withoutUsageOf(coupon, order.UserId)
+		}
+		if reason := notApplicable(coupon, *order, now); reason != "" {
+			return nil, fmt.Errorf("%w: %v", ErrCouponNotApplicable, reason)
+		}
+		candidates = append(candidates, coupon)
+	}
+
+	automatic, err := b.PromotionRepository.GetAutomatic(now)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(automatic, func(i, j int) bool { return automatic[i].Priority > automatic[j].Priority })
+	for _, promotion := range automatic {
+		if used[promotion.ID] {
+			promotion = withoutUsageOf(promotion, order.UserId)
+		}
+		if notApplicable(promotion, *order, now) == "" {
+			candidates = append(candidates, promotion)
+		}
+	}
+
+	applied := EvaluatePromotions(order, candidates)
+
+	if order.CouponCode != "" && (len(applied) == 0 || applied[0].ID != candidates[0].ID) {
+		return nil, fmt.Errorf("%w: coupon gives no discount to this order", ErrCouponNotApplicable)
+	}
+
+	return applied, nil
+}
+
+// Redeem => usage of every applied promotion is counted for user. If a limit is reached in the meantime, counted
+// usages are released and ErrPromotionLimitReached is returned.
+func (b *PromotionService) Redeem(userId string, promotions []models.Promotion) error {
+	for i, promotion := range promotions {
+		result, err := b.PromotionRepository.Redeem(promotion.ID, userId, promotion.UsageLimit, promotion.PerUserLimit)
+		if err != nil || result == false {
+			b.Release(userId, promotions[:i])
+		}
+		if err != nil {
+			return err
+		}
+		if result == false {
+			return fmt.Errorf("%w: %v", ErrPromotionLimitReached, promotion.Name)
+		}
+	}
+	return nil
+}
+
+// Release => usages of promotions are given back, e.g. order cannot be saved. Errors are only logged.
+func (b *PromotionService) Release(userId string, promotions []models.Promotion) {
+	for _, promotion := range promotions {
+		if _, err := b.PromotionRepository.Release(promotion.ID, userId); err != nil {
+			log.Errorf("Usage of promotion (%v) cannot release: %v", promotion.ID, err)
+		}
+	}
+}
+
+// withoutUsageOf => promotion without one usage of user, order which already uses the promotion can keep it
+func withoutUsageOf(promotion models.Promotion, userId string) models.Promotion {
+	promotion.UsedCount--
+	usage := map[string]int{}
+	for user, count := range promotion.UserUsage {
+		usage[user] = count
+	}
+	usage[userId]--
+	promotion.UserUsage = usage
+	return promotion
+}
+
+// usedPromotions => promotions of discount breakdown of order, a promotion is listed once
+func usedPromotions(applied []models.AppliedPromotion) []models.Promotion {
+	var promotions []models.Promotion
+	seen := map[string]bool{}
+	for _, promotion := range applied {
+		if !seen[promotion.PromotionID] {
+			seen[promotion.PromotionID] = true
+			promotions = append(promotions, models.Promotion{ID: promotion.PromotionID, Name: promotion.Name, Code: promotion.Code})
+		}
+	}
+	return promotions
+}
+
+// promotionDifference => promotions of after which are not in before and promotions of before which are not in after
+func promotionDifference(before []models.Promotion, after []models.Promotion) ([]models.Promotion, []models.Promotion) {
+	var added, removed []models.Promotion
+	for _, promotion := range after {
+		if !containsPromotion(before, promotion.ID) {
+			added = append(added, promotion)
+		}
+	}
+	for _, promotion := range before {
+		if !containsPromotion(after, promotion.ID) {
+			removed = append(removed, promotion)
+		}
+	}
+	return added, removed
+}
+
+func containsPromotion(promotions []models.Promotion, id string) bool {
+	for _, promotion := range promotions {
+		if promotion.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// shippingBeforePromotions => shipping fee of order before its free shipping promotions
+func shippingBeforePromotions(order models.Order) money.Amount {
+	shipping := order.Shipping
+	for _, promotion := range order.Promotions {
+		if promotion.Type == PromotionFreeShipping {
+			shipping = shipping.Add(promotion.Amount)
+		}
+	}
+	return shipping
+}
+
+// EvaluatePromotions => candidates are applied in order, discount breakdown, discount and shipping of order are set.
+// Percentage and fixed amount discounts are calculated on the subtotal which is left after previous promotions.
+// Returns the promotions which give a discount.
+func EvaluatePromotions(order *models.Order, candidates []models.Promotion) []models.Promotion {
+	remaining := subtotalOf(order.Product)
+	var applied []models.Promotion
+	var breakdown []models.AppliedPromotion
+	var discount money.Amount
+	exclusive := false
+
+	for _, promotion := range candidates {
+		if len(applied) > 0 && (exclusive || !promotion.Stackable) {
+			continue
+		}
+
+		amount := promotionDiscount(promotion, order.Product, remaining, order.Shipping)
+		if !amount.IsPositive() {
+			continue
+		}
+
+		if promotion.Type == PromotionFreeShipping {
+			order.Shipping = order.Shipping.Sub(amount)
+		} else {
+			remaining = remaining.Sub(amount)
+			discount = discount.Add(amount)
+		}
+
+		applied = append(applied, promotion)
+		breakdown = append(breakdown, models.AppliedPromotion{
+			PromotionID: promotion.ID,
+			Code:        promotion.Code,
+			Name:        promotion.Name,
+			Type:        promotion.Type,
+			Sku:         skuOf(promotion),
+			Amount:      amount,
+		})
+		exclusive = !promotion.Stackable
+	}
+
+	order.Promotions = breakdown
+	order.Discount = discount
+	return applied
+}
+
+// ValidatePromotion => rules which validator tags cannot check
+func ValidatePromotion(promotion models.Promotion) error {
+	var reason string
+	switch {
+	case promotion.Type == PromotionPercentage && promotion.Percent.IsZero():
+		reason = "percent is required"
+	case promotion.Type == PromotionFixedAmount && !promotion.Amount.IsPositive():
+		reason = "amount is required"
+	case promotion.Type == PromotionFixedAmount && promotion.Currency == "":
+		reason = "currency of amount is required"
+	case promotion.Type == PromotionBuyXGetY && (promotion.Sku == "" || promotion.BuyQuantity < 1 || promotion.GetQuantity < 1):
+		reason = "sku, buyQuantity and getQuantity are required"
+	case !promotion.MinSubtotal.IsZero() && promotion.Currency == "":
+		reason = "currency of minSubtotal is required"
+	case !promotion.EndsAt.IsZero() && !promotion.EndsAt.After(promotion.StartsAt):
+		reason = "endsAt must be after startsAt"
+	}
+
+	if reason != "" {
+		return fmt.Errorf("%w: %v", ErrInvalidPromotion, reason)
+	}
+	return nil
+}
+
+// NormalizeCouponCode => coupon codes are not case sensitive
+func NormalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// notApplicable => reason why promotion cannot be applied to order, empty if it can be applied
+func notApplicable(promotion models.Promotion, order models.Order, now time.Time) string {
+	switch {
+	case !promotion.Active:
+		return "promotion is not active"
+	case now.Before(promotion.StartsAt):
+		return "promotion is not started yet"
+	case !promotion.EndsAt.IsZero() && !now.Before(promotion.EndsAt):
+		return "promotion is ended"
+	case promotion.Currency != "" && promotion.Currency != order.Currency:
+		return fmt.Sprintf("promotion is only for orders in %v", promotion.Currency)
+	case subtotalOf(order.Product).Cmp(promotion.MinSubtotal) < 0:
+		return fmt.Sprintf("subtotal of order must be at least %v", promotion.MinSubtotal)
+	case promotion.UsageLimit > 0 && promotion.UsedCount >= promotion.UsageLimit:
+		return "usage limit is reached"
+	case promotion.PerUserLimit > 0 && promotion.UserUsage[order.UserId] >= promotion.PerUserLimit:
+		return "usage limit of user is reached"
+	}
+	return ""
+}
+
+// promotionDiscount => discount of promotion, it is never more than remaining subtotal (or shipping for free shipping)
+func promotionDiscount(promotion models.Promotion, lines []models.OrderProduct, remaining money.Amount, shipping money.Amount) money.Amount {
+	var amount money.Amount
+
+	switch promotion.Type {
+	case PromotionPercentage:
+		amount = promotion.Percent.Of(remaining)
+		if promotion.MaxDiscount.IsPositive() {
+			amount = money.Min(amount, promotion.MaxDiscount)
+		}
+	case PromotionFixedAmount:
+		amount = promotion.Amount
+	case PromotionFreeShipping:
+		return shipping
+	case PromotionBuyXGetY:
+		for _, line := range lines {
+			if line.Sku == promotion.Sku {
+				free := line.Quantity / (promotion.BuyQuantity + promotion.GetQuantity) * promotion.GetQuantity
+				amount = amount.Add(line.Price.Mul(free))
+			}
+		}
+	}
+
+	return money.Min(amount, remaining)
+}
+
+// skuOf => discount of buy X get Y promotion belongs to lines of its sku
+func skuOf(promotion models.Promotion) string {
+	if promotion.Type == PromotionBuyXGetY {
+		return promotion.Sku
+	}
+	return ""
+}
+
+func subtotalOf(lines []models.OrderProduct) money.Amount {
+	var subtotal money.Amount
+	for _, line := range lines {
+		subtotal = subtotal.Add(line.Price.Mul(line.Quantity))
+	}
+	return subtotal
+}
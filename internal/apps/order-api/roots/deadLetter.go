@@ -0,0 +1,49 @@
+package roots
+
+import (
+	kafkaPackage "OrderUserProject/pkg/kafka"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/labstack/echo/v4"
+	"time"
+)
+
+// batchRetryDelay => wait before messages which are neither handled nor sent to dead letter topic are read again
+const batchRetryDelay = 5 * time.Second
+
+// handleBatch => every message is tried maxHandleAttempt times, a message which still cannot handle is sent to dead
+// letter topic. If dead letter cannot be sent, messages before it are acked and batch is read again from it, so a
+// message is never acked before it is handled or dead lettered.
+func handleBatch(messages []kafka.Message, handle func(kafka.Message) error, consumer *kafkaPackage.ConsumerKafka, producer *kafkaPackage.ProducerKafka, deadLetterTopic string, logger echo.Logger) {
+	for i, message := range messages {
+		var err error
+		for attempt := 1; attempt <= maxHandleAttempt; attempt++ {
+			if err = handle(message); err == nil {
+				break
+			}
+			logger.Errorf("An error when handle message (attempt %v). | Error: %v\n", attempt, err)
+		}
+		if err == nil {
+			continue
+		}
+
+		deadLetterErr := producer.SendToDeadLetter(message, deadLetterTopic, err)
+		if deadLetterErr == nil {
+			logger.Errorf("Message (offset %v) cannot handle, it is sent to %v.", message.TopicPartition.Offset, deadLetterTopic)
+			continue
+		}
+
+		logger.Errorf("Message cannot send to dead letter topic, it is read again. | Error: %v\n", deadLetterErr)
+		if i > 0 {
+			consumer.AckMessage(messages[i-1])
+		}
+		if err := consumer.Rewind(messages[i:]); err != nil {
+			logger.Errorf("Messages cannot rewind. | Error: %v\n", err)
+		}
+		time.Sleep(batchRetryDelay)
+		return
+	}
+
+	if len(messages) > 0 {
+		consumer.AckLastMessage()
+	}
+}
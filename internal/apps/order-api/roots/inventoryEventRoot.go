@@ -0,0 +1,105 @@
+package roots
+
+import (
+	"OrderUserProject/internal/apps/order-api"
+	"OrderUserProject/internal/configs"
+	"OrderUserProject/internal/events"
+	kafkaPackage "OrderUserProject/pkg/kafka"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/labstack/echo/v4"
+)
+
+// InventoryEventRoot => consume replies of inventory saga from product-api. Orders which are canceled by saga
+// (compensation) are published like other canceled orders.
+type InventoryEventRoot struct {
+	Service  order_api.IInventorySagaService
+	Consumer *kafkaPackage.ConsumerKafka
+	Producer *kafkaPackage.ProducerKafka
+	Config   *configs.Config
+	Logger   echo.Logger
+}
+
+func NewInventoryEventRoot(service order_api.IInventorySagaService, consumer *kafkaPackage.ConsumerKafka, producer *kafkaPackage.ProducerKafka, config *configs.Config, logger echo.Logger) *InventoryEventRoot {
+	return &InventoryEventRoot{
+		Service:  service,
+		Consumer: consumer,
+		Producer: producer,
+		Config:   config,
+		Logger:   logger,
+	}
+}
+
+// StartConsumeInventoryEvents => Get message from Kafka to consume 'InventoryEvents'
+func (i *InventoryEventRoot) StartConsumeInventoryEvents() {
+	i.Logger.Info("InventoryEventRoot starting for consume 'InventoryEvents'.")
+	err := i.Consumer.SubscribeToTopics([]string{i.Config.Kafka.TopicName["InventoryEvents"]})
+	if err != nil {
+		i.Logger.Errorf("Kafka connection failed. | Error: %v\n", err)
+	}
+
+	for {
+		fromTopics, err := i.Consumer.ConsumeFromTopics(1, 5, 10)
+		if err != nil {
+			i.Logger.Errorf("An error when consume from topic. | Error: %v\n", err)
+		}
+
+		// A reply which cannot handle is dead lettered, saga command is sent again after reply timeout
+		handleBatch(fromTopics, i.handleMessage, i.Consumer, i.Producer, i.Config.Kafka.TopicName["InventoryEventsDeadLetter"], i.Logger)
+	}
+}
+
+// RetryWaitingSagas => job of waiting sagas, their commands are sent again or order is canceled after max attempts
+func (i *InventoryEventRoot) RetryWaitingSagas() error {
+	changes, err := i.Service.RetryWaiting(i.Config.Inventory.ReplyTimeout, i.Config.Inventory.MaxReserveAttempts)
+
+	// Orders which are canceled before error are still published
+	for _, change := range changes {
+		i.pushOrderCanceled(change)
+		i.Logger.Infof("Stock of order (%v) cannot reserve in time, order is canceled.", change.After.ID)
+	}
+
+	return err
+}
+
+func (i *InventoryEventRoot) handleMessage(message kafka.Message) error {
+	var envelope events.Envelope
+	if err := i.Consumer.Deserialize(message, &envelope); err != nil {
+		return err
+	}
+	if err := events.Validate(envelope); err != nil {
+		return err
+	}
+
+	change, err := i.Service.HandleReply(envelope)
+	if err != nil {
+		return err
+	}
+
+	if change != nil {
+		i.pushOrderCanceled(*change)
+		i.Logger.Infof("Stock of order (%v) cannot reserve, order is canceled.", change.After.ID)
+	}
+
+	i.Logger.Infof("%v event of order (%v) is handled.", envelope.Type, string(message.Key))
+	return nil
+}
+
+// pushOrderCanceled => send 'OrderChanged' event for elasticsearch duplicate and 'OrderCanceled' event of canceled order
+func (i *InventoryEventRoot) pushOrderCanceled(change order_api.OrderCancelResult) {
+	orderChanged, err := order_api.NewOrderChangedEnvelope(change.After.ID, "Updated", &change.After, i.Config.Kafka.OrderEventMode)
+	if err != nil {
+		i.Logger.Errorf("Something went wrong convert to event: %v", err)
+	} else if err := i.Producer.SendToKafkaWithValue(orderChanged, i.Config.Kafka.TopicName["OrderID"]); err != nil {
+		i.Logger.Errorf("Something went wrong cannot pushed: %v", err)
+	}
+
+	domainEvent := events.NewOrderCanceled(events.NewOrder(change.Before), events.NewOrder(change.After), change.Cancellation)
+	envelope, err := domainEvent.Envelope()
+	if err != nil {
+		i.Logger.Errorf("Something went wrong convert to %v event: %v", domainEvent.Type, err)
+		return
+	}
+	if err := i.Producer.SendToKafkaWithKey(domainEvent.Key, envelope, i.Config.Kafka.TopicName["OrderEvents"]); err != nil {
+		i.Logger.Errorf("Something went wrong %v event cannot pushed: %v", domainEvent.Type, err)
+	}
+}
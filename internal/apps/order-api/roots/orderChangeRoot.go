@@ -0,0 +1,91 @@
+package roots
+
+import (
+	"OrderUserProject/internal/apps/order-api"
+	"OrderUserProject/internal/apps/order-api/graphQL"
+	"OrderUserProject/internal/configs"
+	"OrderUserProject/internal/events"
+	kafkaPackage "OrderUserProject/pkg/kafka"
+	"fmt"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/labstack/echo/v4"
+)
+
+// OrderChangeRoot => consume 'OrderChanged' events which order-api publishes, changed orders are sent to GraphQL
+// subscriptions and order stream (change log). Every order-api instance reads all events, because its clients may
+// subscribe to any order.
+type OrderChangeRoot struct {
+	Service  order_api.IOrderService
+	Updates  *graphQL.OrderUpdates
+	Changes  *order_api.OrderChangeLog
+	Consumer *kafkaPackage.ConsumerKafka
+	Config   *configs.Config
+	Logger   echo.Logger
+}
+
+func NewOrderChangeRoot(service order_api.IOrderService, updates *graphQL.OrderUpdates, changes *order_api.OrderChangeLog, consumer *kafkaPackage.ConsumerKafka, config *configs.Config, logger echo.Logger) *OrderChangeRoot {
+	return &OrderChangeRoot{
+		Service:  service,
+		Updates:  updates,
+		Changes:  changes,
+		Consumer: consumer,
+		Config:   config,
+		Logger:   logger,
+	}
+}
+
+// StartConsumeOrderChanges => Get message from Kafka to consume 'OrderID'. Messages are read one by one, so
+// subscriptions get changes in real time.
+func (o *OrderChangeRoot) StartConsumeOrderChanges() {
+	o.Logger.Info("OrderChangeRoot starting for consume 'OrderID'.")
+	err := o.Consumer.SubscribeToTopics([]string{o.Config.Kafka.TopicName["OrderID"]})
+	if err != nil {
+		o.Logger.Errorf("Kafka connection failed. | Error: %v\n", err)
+	}
+
+	for {
+		fromTopics, err := o.Consumer.ConsumeFromTopics(1, 1, 1)
+		if err != nil {
+			o.Logger.Errorf("An error when consume from topic. | Error: %v\n", err)
+		}
+
+		for _, message := range fromTopics {
+			if err := o.handleMessage(message); err != nil {
+				o.Logger.Errorf("An error when handle order change. | Error: %v\n", err)
+			}
+		}
+	}
+}
+
+// handleMessage => order of event is read again, events don't carry shipments and cancellations of order.
+// Deleted orders are only in order stream, their GraphQL subscriptions don't get changes anymore.
+func (o *OrderChangeRoot) handleMessage(message kafka.Message) error {
+	var envelope events.Envelope
+	if err := o.Consumer.Deserialize(message, &envelope); err != nil {
+		return err
+	}
+	if err := events.Validate(envelope); err != nil {
+		return err
+	}
+	if envelope.Type != events.OrderChangedType {
+		return fmt.Errorf("unexpected event type: %v", envelope.Type)
+	}
+
+	var orderChanged events.OrderChanged
+	if err := envelope.DecodePayload(&orderChanged); err != nil {
+		return err
+	}
+	if orderChanged.Status == order_api.OrderChangeDeleted {
+		o.Changes.Append(order_api.NewDeletedOrderChange(orderChanged))
+		return nil
+	}
+
+	order, err := o.Service.GetOrderById(orderChanged.OrderID)
+	if err != nil {
+		return fmt.Errorf("order (%v) cannot get: %w", orderChanged.OrderID, err)
+	}
+
+	o.Updates.Publish(order)
+	o.Changes.Append(order_api.OrderChange{Type: orderChanged.Status, OrderID: order.ID, UserId: order.UserId, Status: order.Status, Order: &order})
+	return nil
+}
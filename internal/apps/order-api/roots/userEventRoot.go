@@ -0,0 +1,160 @@
+package roots
+
+import (
+	"OrderUserProject/internal/apps/order-api"
+	"OrderUserProject/internal/configs"
+	"OrderUserProject/internal/events"
+	"OrderUserProject/internal/models"
+	kafkaPackage "OrderUserProject/pkg/kafka"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/labstack/echo/v4"
+)
+
+// maxHandleAttempt => a consumed event is tried this many times, after that it is sent to dead letter topic
+const maxHandleAttempt = 3
+
+// UserEventRoot => consume user domain events, address changes are applied to open orders by config policy
+type UserEventRoot struct {
+	Service     order_api.IOrderService
+	SagaService order_api.IInventorySagaService
+	Consumer    *kafkaPackage.ConsumerKafka
+	Producer    *kafkaPackage.ProducerKafka
+	Config      *configs.Config
+	Logger      echo.Logger
+}
+
+func NewUserEventRoot(service order_api.IOrderService, sagaService order_api.IInventorySagaService, consumer *kafkaPackage.ConsumerKafka, producer *kafkaPackage.ProducerKafka, config *configs.Config, logger echo.Logger) *UserEventRoot {
+	return &UserEventRoot{
+		Service:     service,
+		SagaService: sagaService,
+		Consumer:    consumer,
+		Producer:    producer,
+		Config:      config,
+		Logger:      logger,
+	}
+}
+
+// StartConsumeUserEvents => Get message from Kafka to consume 'UserEvents'. 'AddressChanged' and 'AddressDeleted'
+// events change open orders, open orders of deleted users are canceled. Other user events are not interesting for order-api.
+func (u *UserEventRoot) StartConsumeUserEvents() {
+	u.Logger.Info("UserEventRoot starting for consume 'UserEvents'.")
+	err := u.Consumer.SubscribeToTopics([]string{u.Config.Kafka.TopicName["UserEvents"]})
+	if err != nil {
+		u.Logger.Errorf("Kafka connection failed. | Error: %v\n", err)
+	}
+
+	for {
+		fromTopics, err := u.Consumer.ConsumeFromTopics(1, 5, 10)
+		if err != nil {
+			u.Logger.Errorf("An error when consume from topic. | Error: %v\n", err)
+		}
+
+		// Event is tried again if an order cannot update, orders which already recorded the event are skipped
+		handleBatch(fromTopics, u.handleMessage, u.Consumer, u.Producer, u.Config.Kafka.TopicName["UserEventsDeadLetter"], u.Logger)
+	}
+}
+
+func (u *UserEventRoot) handleMessage(message kafka.Message) error {
+	var envelope events.Envelope
+	if err := u.Consumer.Deserialize(message, &envelope); err != nil {
+		return err
+	}
+	if err := events.Validate(envelope); err != nil {
+		return err
+	}
+
+	change := models.AddressChange{
+		EventID:   envelope.ID,
+		EventType: envelope.Type,
+	}
+	policy := u.Config.Order.AddressChangePolicy
+	var userID string
+
+	switch envelope.Type {
+	case events.UserDeletedType:
+		var userDeleted events.UserDeleted
+		if err := envelope.DecodePayload(&userDeleted); err != nil {
+			return err
+		}
+		return u.cancelOrdersOfUser(userDeleted.UserID)
+	case events.AddressChangedType:
+		var addressChanged events.AddressChanged
+		if err := envelope.DecodePayload(&addressChanged); err != nil {
+			return err
+		}
+		userID = addressChanged.UserID
+		change.AddressID = addressChanged.After.ID
+		change.Address = addressChanged.After.Model()
+	case events.AddressDeletedType:
+		var addressDeleted events.AddressDeleted
+		if err := envelope.DecodePayload(&addressDeleted); err != nil {
+			return err
+		}
+		// Deleted address cannot be replaced, order keeps its snapshot
+		userID = addressDeleted.UserID
+		change.AddressID = addressDeleted.Before.ID
+		change.Address = addressDeleted.Before.Model()
+		policy = order_api.AddressChangePolicyKeep
+	default:
+		return nil
+	}
+
+	updatedOrders, err := u.Service.ApplyAddressChange(userID, change, policy)
+	if err != nil {
+		return err
+	}
+
+	// => SEND MESSAGE (OrderID) => elasticsearch duplicate of updated orders
+	for i := range updatedOrders {
+		u.pushOrderChanged(&updatedOrders[i])
+	}
+
+	u.Logger.Infof("%v event of user (%v) is applied to %v orders with '%v' policy.", envelope.Type, userID, len(updatedOrders), policy)
+	return nil
+}
+
+// cancelOrdersOfUser => open orders of deleted user are canceled, 'OrderChanged' and 'OrderCanceled' events are sent
+func (u *UserEventRoot) cancelOrdersOfUser(userID string) error {
+	changes, err := u.Service.CancelOpenOrdersOfUser(userID)
+
+	// Orders which are canceled before error are still published
+	for i := range changes {
+		u.pushOrderChanged(&changes[i].After)
+
+		// Inventory saga => stock of canceled order is released
+		if u.Config.Inventory.Enabled {
+			if releaseErr := u.SagaService.Release(changes[i].After.ID, "user is deleted"); releaseErr != nil {
+				u.Logger.Errorf("Inventory saga of order (%v) cannot release: %v", changes[i].After.ID, releaseErr)
+			}
+		}
+
+		domainEvent := events.NewOrderCanceled(events.NewOrder(changes[i].Before), events.NewOrder(changes[i].After), changes[i].Cancellation)
+		envelope, envelopeErr := domainEvent.Envelope()
+		if envelopeErr != nil {
+			u.Logger.Errorf("Something went wrong convert to %v event: %v", domainEvent.Type, envelopeErr)
+			continue
+		}
+		if sendErr := u.Producer.SendToKafkaWithKey(domainEvent.Key, envelope, u.Config.Kafka.TopicName["OrderEvents"]); sendErr != nil {
+			u.Logger.Errorf("Something went wrong %v event cannot pushed: %v", domainEvent.Type, sendErr)
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	u.Logger.Infof("User (%v) is deleted, %v open orders are canceled.", userID, len(changes))
+	return nil
+}
+
+// pushOrderChanged => send 'OrderChanged' event of changed order for elasticsearch duplicate
+func (u *UserEventRoot) pushOrderChanged(order *models.Order) {
+	envelope, err := order_api.NewOrderChangedEnvelope(order.ID, "Updated", order, u.Config.Kafka.OrderEventMode)
+	if err != nil {
+		u.Logger.Errorf("Something went wrong convert to event: %v", err)
+		return
+	}
+	if err := u.Producer.SendToKafkaWithValue(envelope, u.Config.Kafka.TopicName["OrderID"]); err != nil {
+		u.Logger.Errorf("Something went wrong cannot pushed: %v", err)
+	}
+}
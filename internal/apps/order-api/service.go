@@ -3,26 +3,30 @@ package order_api
 import (
 	"OrderUserProject/internal/configs"
 	"OrderUserProject/internal/models"
+	"OrderUserProject/internal/query"
 	"OrderUserProject/internal/repository"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/labstack/gommon/log"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
-	"io"
 	"net/http"
+	"net/url"
 	"time"
 )
 
 type OrderService struct {
-	OrderRepository repository.IOrderRepository
+	OrderRepository  repository.IOrderRepository
+	PromotionService IPromotionService
 }
 
-func NewOrderService(orderRepository repository.IOrderRepository) IOrderService {
+func NewOrderService(orderRepository repository.IOrderRepository, promotionService IPromotionService) IOrderService {
 	orderService := &OrderService{
-		OrderRepository: orderRepository,
+		OrderRepository:  orderRepository,
+		PromotionService: promotionService,
 	}
 	// Check ram address
 	fmt.Printf("%s%p\n", "Order Service(service.go):", orderService)
@@ -36,8 +40,23 @@ type IOrderService interface {
 	Update(user models.Order) (bool, error)
 	Delete(id string) (bool, error)
 	GetUser(userId string, userURL string) (UserResponse, error)
-	FromModelConvertToFilter(req OrderGetRequest) (bson.M, *options.FindOptions)
+	ResolveProducts(lines []OrderProductRequest, productURL string) ([]models.OrderProduct, string, error)
+	FromModelConvertToFilter(req OrderGetRequest) (bson.M, *options.FindOptions, error)
 	GetOrdersWithFilter(filter bson.M, opt *options.FindOptions) ([]interface{}, error)
+	FindOrders(filter OrderFilter) (OrderPage, error)
+	PrepareOrder(userId string, addressId string, invoiceAddressId string, lines []OrderProductRequest, userURL string, productURL string) (models.Order, error)
+	ApplyAddressChange(userId string, change models.AddressChange, policy string) ([]models.Order, error)
+	GetOpenOrdersByUser(userId string) ([]models.Order, error)
+	GetOpenOrdersByAddress(userId string, addressId string) ([]models.Order, error)
+	CancelOpenOrdersOfUser(userId string) ([]OrderCancelResult, error)
+	SoftDelete(id string) (bool, error)
+	Restore(id string) (models.Order, error)
+	PurgeDeleted(retention time.Duration) (int64, error)
+	RecalculateTotals(defaultCurrency string, dryRun bool) (RecalculationReport, []models.Order, error)
+	Cancel(id string, request OrderCancelRequest) (OrderCancelResult, error)
+	UpdateRefundStatus(id string, refundId string, status string) (models.Order, models.Refund, error)
+	CreateShipment(orderId string, request ShipmentCreateRequest) (ShipmentChange, error)
+	UpdateShipment(orderId string, shipmentId string, request ShipmentUpdateRequest) (ShipmentChange, error)
 }
 
 func (b *OrderService) GetAll() ([]models.Order, error) {
@@ -68,37 +87,64 @@ func (b *OrderService) Insert(order models.Order) (models.Order, error) {
 	// We don't want to set null, so we put CreatedAt value.
 	order.UpdatedAt = order.CreatedAt
 
-	var total float64
-	for _, product := range order.Product {
-		total = product.Price * float64(product.Quantity)
-		order.Total += total
+	// Coupon and automatic promotions set discount breakdown, discount and shipping of order
+	promotions, err := b.PromotionService.Apply(&order, order.CreatedAt)
+	if err != nil {
+		return models.Order{}, err
+	}
+
+	if err := CalculateTotals(&order); err != nil {
+		return models.Order{}, err
+	}
+
+	if err := b.PromotionService.Redeem(order.UserId, promotions); err != nil {
+		return models.Order{}, err
 	}
 
 	result, err := b.OrderRepository.Insert(order)
 
 	if err != nil || result == false {
+		// Order is not saved, so it doesn't use the promotions
+		b.PromotionService.Release(order.UserId, promotions)
 		return models.Order{}, err
 	}
 
 	return order, nil
 }
 
+// Update => order with new lines is saved. Order has coupon code, discount breakdown and shipping of stored order, its
+// coupon and automatic promotions are evaluated again with new lines. Promotions which are applied newly are redeemed
+// and promotions which are not applied anymore are released. Coupon which cannot be applied to new lines returns
+// ErrCouponNotApplicable.
 func (b *OrderService) Update(order models.Order) (bool, error) {
 	// Create updated date value
 	order.UpdatedAt = time.Now()
 
-	var total float64
-	for _, product := range order.Product {
-		total = product.Price * float64(product.Quantity)
-		order.Total += total
+	previous := usedPromotions(order.Promotions)
+	order.Shipping = shippingBeforePromotions(order)
+	promotions, err := b.PromotionService.Apply(&order, order.UpdatedAt)
+	if err != nil {
+		return false, err
+	}
+
+	if err := CalculateTotals(&order); err != nil {
+		return false, err
+	}
+
+	added, removed := promotionDifference(previous, promotions)
+	if err := b.PromotionService.Redeem(order.UserId, added); err != nil {
+		return false, err
 	}
 
 	result, err := b.OrderRepository.Update(order)
 
 	if err != nil || result == false {
+		// Order is not saved, so it doesn't use the new promotions
+		b.PromotionService.Release(order.UserId, added)
 		return false, err
 	}
 
+	b.PromotionService.Release(order.UserId, removed)
 	return true, nil
 }
 
@@ -112,112 +158,240 @@ func (b *OrderService) Delete(id string) (bool, error) {
 	return true, nil
 }
 
-func (b *OrderService) GetUser(userId string, userURL string) (UserResponse, error) {
-	// => HTTP.CLIENT FIND USER
-	// Create a new HTTP client with a timeout (to check user)
-	client := http.Client{
-		Timeout: time.Second * 20,
+// ApplyAddressChange => open orders which have snapshot of the address are updated or kept by policy (deleted addresses
+// are applied with "keep"). Decision is recorded on every order. Returns the orders which are updated with new address.
+func (b *OrderService) ApplyAddressChange(userId string, change models.AddressChange, policy string) ([]models.Order, error) {
+	orders, err := b.OrderRepository.GetOpenOrdersByAddress(userId, change.AddressID, ClosedOrderStatuses)
+	if err != nil {
+		return nil, err
 	}
 
-	// Send a GET request to the User service to retrieve user information
-	respUser, err := client.Get(userURL + "/" + userId)
-	if err != nil || respUser.StatusCode != http.StatusOK {
-		return UserResponse{}, errors.New("user cannot find")
-	}
-	defer func() {
-		if err := respUser.Body.Close(); err != nil {
-			log.Errorf("Something went wrong: %v", err)
+	var updatedOrders []models.Order
+	for _, order := range orders {
+		orderChange := change
+		orderChange.Decision = AddressChangeKept
+		orderChange.DecidedAt = time.Now()
+
+		set := bson.M{}
+		if policy == AddressChangePolicyUpdate {
+			orderChange.Decision = AddressChangeUpdated
+			order.UpdatedAt = orderChange.DecidedAt
+			set["updatedAt"] = order.UpdatedAt
+			if order.Address.ID == change.AddressID {
+				order.Address = change.Address
+				set["address"] = change.Address
+			}
+			if order.InvoiceAddress.ID == change.AddressID {
+				order.InvoiceAddress = change.Address
+				set["invoiceAddress"] = change.Address
+			}
 		}
-	}()
 
-	// Read the response body
-	respUserBody, err := io.ReadAll(respUser.Body)
+		result, err := b.OrderRepository.AddAddressChange(order.ID, orderChange, set)
+		if err != nil {
+			return updatedOrders, err
+		}
+
+		// result is false if event is already recorded
+		if result && orderChange.Decision == AddressChangeUpdated {
+			order.AddressChanges = append(order.AddressChanges, orderChange)
+			updatedOrders = append(updatedOrders, order)
+		}
+	}
+
+	return updatedOrders, nil
+}
+
+// GetOpenOrdersByUser => orders of user which are not delivered, canceled or closed
+func (b *OrderService) GetOpenOrdersByUser(userId string) ([]models.Order, error) {
+	return b.OrderRepository.GetOpenOrdersByUser(userId, ClosedOrderStatuses)
+}
+
+// GetOpenOrdersByAddress => open orders of user which use the address as regular or invoice address
+func (b *OrderService) GetOpenOrdersByAddress(userId string, addressId string) ([]models.Order, error) {
+	return b.OrderRepository.GetOpenOrdersByAddress(userId, addressId, ClosedOrderStatuses)
+}
+
+// CancelOpenOrdersOfUser => cancel every open order of user (user is deleted) with its cancellation and refund, usages
+// of its promotions are released. Orders which are closed in the meantime are not changed. Returns the canceled orders.
+func (b *OrderService) CancelOpenOrdersOfUser(userId string) ([]OrderCancelResult, error) {
+	orders, err := b.OrderRepository.GetOpenOrdersByUser(userId, ClosedOrderStatuses)
 	if err != nil {
-		return UserResponse{}, err
+		return nil, err
+	}
+
+	var results []OrderCancelResult
+	for _, order := range orders {
+		result, err := cancelOpenOrder(b.OrderRepository, b.PromotionService, order, CancelReasonOther, "user is deleted")
+		if err != nil {
+			return results, err
+		}
+
+		if result != nil {
+			results = append(results, *result)
+		}
+	}
+
+	return results, nil
+}
+
+// SoftDelete => order is marked as deleted instead of removing
+func (b *OrderService) SoftDelete(id string) (bool, error) {
+	result, err := b.OrderRepository.SoftDelete(id, time.Now())
+
+	if err != nil || result == false {
+		return false, err
 	}
 
-	// Unmarshal the response body into an Order struct
-	var userResponse UserResponse
-	err = json.Unmarshal(respUserBody, &userResponse)
+	return true, nil
+}
+
+// Restore => soft deleted order is restored, restored order is returned
+func (b *OrderService) Restore(id string) (models.Order, error) {
+	result, err := b.OrderRepository.Restore(id)
 	if err != nil {
-		return UserResponse{}, err
+		return models.Order{}, err
+	}
+	if result == false {
+		return models.Order{}, mongo.ErrNoDocuments
 	}
 
-	return userResponse, nil
+	return b.OrderRepository.GetOrderById(id)
 }
 
-func (b *OrderService) FromModelConvertToFilter(req OrderGetRequest) (bson.M, *options.FindOptions) {
-	// Get config for generic endpoint
-	config := configs.GetGenericEndpointConfig("mongoDB")
+// PurgeDeleted => hard delete orders which are soft deleted before retention period
+func (b *OrderService) PurgeDeleted(retention time.Duration) (int64, error) {
+	return b.OrderRepository.PurgeDeleted(time.Now().Add(-retention))
+}
 
-	// Create a filter based on the exact filters and matches provided in the request
-	filter := bson.M{}
+func (b *OrderService) GetUser(userId string, userURL string) (UserResponse, error) {
+	// => HTTP.CLIENT FIND USER
+	return NewUserService(userURL).GetUser(userId)
+}
 
-	// Add exact filter criteria to filter if provided
-	if len(req.ExactFilters) > 0 {
-		for keyModel, values := range req.ExactFilters {
-			keyMongo := config.ExactFilterArea[keyModel]
-			filter[keyMongo] = bson.M{"$in": values}
-		}
+// PrepareOrder => order of user with snapshots of its addresses (user-api) and lines of product catalog (product-api).
+// Address and invoice address must be addresses of user. Status, amounts and promotions are set by caller.
+func (b *OrderService) PrepareOrder(userId string, addressId string, invoiceAddressId string, lines []OrderProductRequest, userURL string, productURL string) (models.Order, error) {
+	user, err := b.GetUser(userId, userURL)
+	if err != nil {
+		return models.Order{}, fmt.Errorf("%w: %v, %v", ErrUserNotFound, userId, err)
 	}
 
-	// Add match criteria to filter if provided
-	if len(req.Match) > 0 {
-		for _, model := range req.Match {
-			if model.MatchField == "createdAt" || model.MatchField == "updatedAt" {
-				parsedTime, _ := time.Parse("2006-01-02", model.Value.(string))
-				dateQuery := bson.M{
-					model.MatchField: bson.M{
-						config.MatchFilterParameter[model.Parameter]: parsedTime,
-					},
-				}
-				filter = bson.M{
-					"$and": []bson.M{
-						filter,
-						dateQuery,
-					},
-				}
-			} else {
-				match := bson.M{}
-				parameter := config.MatchFilterParameter[model.Parameter]
-				query := make(map[string]interface{})
-				query[parameter] = model.Value
-				match[model.MatchField] = query
-				filter = bson.M{
-					"$and": []bson.M{
-						filter,
-						match,
-					},
-				}
-			}
+	var order models.Order
+	var ok bool
+	order.UserId = userId
+	if order.Address, ok = userAddress(user, addressId); !ok {
+		return models.Order{}, fmt.Errorf("%w: %v", ErrAddressNotFound, addressId)
+	}
+	if order.InvoiceAddress, ok = userAddress(user, invoiceAddressId); !ok {
+		return models.Order{}, fmt.Errorf("%w: %v", ErrAddressNotFound, invoiceAddressId)
+	}
+
+	order.Product, order.Currency, err = b.ResolveProducts(lines, productURL)
+	if err != nil {
+		return models.Order{}, err
+	}
+
+	return order, nil
+}
+
+// userAddress => snapshot of address of user
+func userAddress(user UserResponse, addressId string) (models.Address, bool) {
+	for _, address := range user.Addresses {
+		if address.ID == addressId {
+			return models.Address{
+				ID:       address.ID,
+				Address:  address.Address,
+				City:     address.City,
+				District: address.District,
+				Type:     address.Type,
+				Default:  address.Default,
+			}, true
 		}
 	}
+	return models.Address{}, false
+}
+
+// ResolveProducts => order lines with name, price and VAT rate of product catalog (product-api), prices of client are
+// never used. Lines are snapshot of product at order time. Currency of products is the currency of order, products with
+// different currencies return ErrCurrencyMismatch. Unknown or inactive sku returns ErrProductNotFound.
+func (b *OrderService) ResolveProducts(lines []OrderProductRequest, productURL string) ([]models.OrderProduct, string, error) {
+	// => HTTP.CLIENT FIND PRODUCTS
+	client := http.Client{
+		Timeout: time.Second * 20,
+	}
 
-	// Create options for the find operation, including the requested fields and sort order
-	findOptions := options.Find()
+	query := url.Values{}
+	for _, line := range lines {
+		query.Add("sku", line.Sku)
+	}
 
-	// Add projection criteria to find options if provided
-	if len(req.Fields) > 0 {
-		projection := bson.M{}
-		findOptions.SetProjection(projection)
-		for _, field := range req.Fields {
-			projection[field] = 1
+	respProducts, err := client.Get(productURL + "?" + query.Encode())
+	if err != nil {
+		return nil, "", err
+	}
+	defer func() {
+		if err := respProducts.Body.Close(); err != nil {
+			log.Errorf("Something went wrong: %v", err)
 		}
+	}()
+
+	if respProducts.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("products cannot get from product-api, status code: %v", respProducts.StatusCode)
+	}
+
+	var productsResponse struct {
+		Data []ProductResponse `json:"data"`
+	}
+	if err := json.NewDecoder(respProducts.Body).Decode(&productsResponse); err != nil {
+		return nil, "", err
+	}
+
+	products := map[string]ProductResponse{}
+	for _, product := range productsResponse.Data {
+		products[product.Sku] = product
 	}
 
-	// Add sort criteria to find options if provided
-	if len(req.Sort) > 0 {
-		sortFields := bson.D{}
-		for key, value := range req.Sort {
-			// We can use multiple sorts with bson.E (=> bson.D {"total":-1,"createdAt":-1})
-			sortFields = append(sortFields, bson.E{Key: key, Value: value})
+	var orderProducts []models.OrderProduct
+	var currency string
+	for _, line := range lines {
+		product, ok := products[line.Sku]
+		if !ok || !product.Active {
+			return nil, "", fmt.Errorf("%w: %v", ErrProductNotFound, line.Sku)
 		}
-		findOptions.SetSort(sortFields)
+
+		if currency == "" {
+			currency = product.Currency
+		} else if product.Currency != currency {
+			return nil, "", fmt.Errorf("%w: %v is in %v, order is in %v", ErrCurrencyMismatch, product.Sku, product.Currency, currency)
+		}
+
+		orderProducts = append(orderProducts, models.OrderProduct{
+			Sku:      product.Sku,
+			Name:     product.Name,
+			Quantity: line.Quantity,
+			Price:    product.Price,
+			TaxRate:  product.TaxRate,
+		})
+	}
+
+	return orderProducts, currency, nil
+}
+
+func (b *OrderService) FromModelConvertToFilter(req OrderGetRequest) (bson.M, *options.FindOptions, error) {
+	// Create query of exact filters, matches, filter, fields and sort provided in the request
+	orderQuery, err := NewOrderQuery(req)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return filter, findOptions
+	// Create filter and options for the find operation with config of generic endpoint
+	filter, findOptions := query.Mongo(orderQuery, configs.GetGenericEndpointConfig("mongoDB"))
+	return filter, findOptions, nil
 }
 
+// GetOrdersWithFilter => orders with selected fields, documents have the json shape of order (amounts and rates are
+// numbers, not Decimal128 strings)
 func (b *OrderService) GetOrdersWithFilter(filter bson.M, opt *options.FindOptions) ([]interface{}, error) {
 	result, err := b.OrderRepository.GetOrdersWithFilter(filter, opt)
 
@@ -225,5 +399,78 @@ func (b *OrderService) GetOrdersWithFilter(filter bson.M, opt *options.FindOptio
 		return nil, err
 	}
 
+	for i, document := range result {
+		result[i] = jsonDocument(document)
+	}
+
 	return result, nil
 }
+
+// jsonDocument => document of MongoDB with json values, Decimal128 becomes a json number like money.Amount and
+// money.Rate. Other values are not changed.
+func jsonDocument(value interface{}) interface{} {
+	switch value := value.(type) {
+	case primitive.Decimal128:
+		return json.Number(value.String())
+	case map[string]interface{}:
+		document := make(map[string]interface{}, len(value))
+		for key, field := range value {
+			document[key] = jsonDocument(field)
+		}
+		return document
+	case primitive.M:
+		return jsonDocument(map[string]interface{}(value))
+	case primitive.D:
+		return jsonDocument(map[string]interface{}(value.Map()))
+	case primitive.A:
+		array := make([]interface{}, len(value))
+		for i, item := range value {
+			array[i] = jsonDocument(item)
+		}
+		return array
+	}
+	return value
+}
+
+// FindOrders => a page of orders which match the filter, newest first. Limit is DefaultOrderPageSize if it isn't set and
+// it is never more than MaxOrderPageSize.
+func (b *OrderService) FindOrders(filter OrderFilter) (OrderPage, error) {
+	query := bson.M{}
+	if filter.Status != "" {
+		query["status"] = filter.Status
+	}
+	if filter.UserId != "" {
+		query["userId"] = filter.UserId
+	}
+	if filter.Sku != "" {
+		query["product.sku"] = filter.Sku
+	}
+
+	page := OrderPage{Limit: filter.Limit, Offset: filter.Offset}
+	if page.Limit <= 0 {
+		page.Limit = DefaultOrderPageSize
+	}
+	if page.Limit > MaxOrderPageSize {
+		page.Limit = MaxOrderPageSize
+	}
+	if page.Offset < 0 {
+		page.Offset = 0
+	}
+
+	opt := options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: 1}}).
+		SetSkip(int64(page.Offset)).
+		SetLimit(int64(page.Limit))
+
+	orders, count, err := b.OrderRepository.FindOrders(query, opt)
+	if err != nil {
+		return OrderPage{}, err
+	}
+
+	page.TotalCount = count
+	page.Orders = orders
+	if page.Orders == nil {
+		page.Orders = []models.Order{}
+	}
+	return page, nil
+}
@@ -1,12 +1,25 @@
 package order_api
 
 import (
+	"OrderUserProject/internal/configs"
+	"OrderUserProject/internal/events"
 	"OrderUserProject/internal/models"
+	"OrderUserProject/internal/repository"
+	"OrderUserProject/pkg/money"
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/go-playground/assert/v2"
 	"github.com/stretchr/testify/mock"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 )
@@ -48,23 +61,19 @@ var ordersList = []models.Order{
 				IsDefaultRegularAddress: false,
 			},
 		},
-		Product: []struct {
-			Name     string  `json:"name" bson:"name"`
-			Quantity int     `json:"quantity" bson:"quantity"`
-			Price    float64 `json:"price" bson:"price"`
-		}{
+		Product: []models.OrderProduct{
 			{
 				Name:     "Asus Notebook",
 				Quantity: 1,
-				Price:    20000.0,
+				Price:    money.MustParse("20000"),
 			},
 			{
 				Name:     "Airpods",
 				Quantity: 1,
-				Price:    4000.0,
+				Price:    money.MustParse("4000"),
 			},
 		},
-		Total:     24000.0,
+		Total:     money.MustParse("24000"),
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	},
@@ -104,18 +113,14 @@ var ordersList = []models.Order{
 				IsDefaultRegularAddress: true,
 			},
 		},
-		Product: []struct {
-			Name     string  `json:"name" bson:"name"`
-			Quantity int     `json:"quantity" bson:"quantity"`
-			Price    float64 `json:"price" bson:"price"`
-		}{
+		Product: []models.OrderProduct{
 			{
 				Name:     "Iphone 12",
 				Quantity: 1,
-				Price:    24000.0,
+				Price:    money.MustParse("24000"),
 			},
 		},
-		Total:     24000.0,
+		Total:     money.MustParse("24000"),
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	},
@@ -180,23 +185,19 @@ var createOrderTestValues = map[string]struct {
 				IsDefaultRegularAddress: false,
 			},
 		},
-		Product: []struct {
-			Name     string  `json:"name" bson:"name"`
-			Quantity int     `json:"quantity" bson:"quantity"`
-			Price    float64 `json:"price" bson:"price"`
-		}{
+		Product: []models.OrderProduct{
 			{
 				Name:     "LG Smart Tv",
 				Quantity: 1,
-				Price:    20000.0,
+				Price:    money.MustParse("20000"),
 			},
 			{
 				Name:     "Bosch Filter Coffee Machine",
 				Quantity: 1,
-				Price:    2500.0,
+				Price:    money.MustParse("2500"),
 			},
 		},
-		Total:     0,
+		Total:     money.Amount{},
 		CreatedAt: time.Time{},
 		UpdatedAt: time.Time{},
 	}, true, nil},
@@ -236,23 +237,19 @@ var createOrderTestValues = map[string]struct {
 				IsDefaultRegularAddress: false,
 			},
 		},
-		Product: []struct {
-			Name     string  `json:"name" bson:"name"`
-			Quantity int     `json:"quantity" bson:"quantity"`
-			Price    float64 `json:"price" bson:"price"`
-		}{
+		Product: []models.OrderProduct{
 			{
 				Name:     "LG Smart Tv",
 				Quantity: 1,
-				Price:    20000.0,
+				Price:    money.MustParse("20000"),
 			},
 			{
 				Name:     "Bosch Filter Coffee Machine",
 				Quantity: 1,
-				Price:    2500.0,
+				Price:    money.MustParse("2500"),
 			},
 		},
-		Total:     0,
+		Total:     money.Amount{},
 		CreatedAt: time.Time{},
 		UpdatedAt: time.Time{},
 	}, false, errors.New("something went wrong")},
@@ -292,6 +289,14 @@ func (m *MockOrderRepository) GetAll() ([]models.Order, error) {
 	return args.Get(0).([]models.Order), nil
 }
 
+func (m *MockOrderRepository) GetOrdersAfter(afterId string, limit int64) ([]models.Order, error) {
+	args := m.Called(afterId, limit)
+	if args.Error(1) != nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Order), nil
+}
+
 func (m *MockOrderRepository) GetOrderById(id string) (models.Order, error) {
 	args := m.Called(id)
 	if args.Error(1) != nil {
@@ -332,6 +337,91 @@ func (m *MockOrderRepository) GetOrdersWithFilter(filter bson.M, opt *options.Fi
 	return args.Get(0).([]interface{}), nil
 }
 
+func (m *MockOrderRepository) FindOrders(filter bson.M, opt *options.FindOptions) ([]models.Order, int64, error) {
+	args := m.Called(filter, opt)
+	if args.Error(2) != nil {
+		return nil, 0, args.Error(2)
+	}
+	return args.Get(0).([]models.Order), args.Get(1).(int64), nil
+}
+
+func (m *MockOrderRepository) GetOpenOrdersByAddress(userId string, addressId string, closedStatuses []string) ([]models.Order, error) {
+	args := m.Called(userId, addressId, closedStatuses)
+	if args.Error(1) != nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Order), nil
+}
+
+func (m *MockOrderRepository) AddAddressChange(id string, change models.AddressChange, set bson.M) (bool, error) {
+	args := m.Called(id, change, set)
+	if args.Error(1) != nil {
+		return false, args.Error(1)
+	}
+	return args.Bool(0), nil
+}
+
+func (m *MockOrderRepository) GetOpenOrdersByUser(userId string, closedStatuses []string) ([]models.Order, error) {
+	args := m.Called(userId, closedStatuses)
+	if args.Error(1) != nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Order), nil
+}
+
+func (m *MockOrderRepository) SoftDelete(id string, deletedAt time.Time) (bool, error) {
+	args := m.Called(id, deletedAt)
+	if args.Error(1) != nil {
+		return false, args.Error(1)
+	}
+	return args.Bool(0), nil
+}
+
+func (m *MockOrderRepository) Restore(id string) (bool, error) {
+	args := m.Called(id)
+	if args.Error(1) != nil {
+		return false, args.Error(1)
+	}
+	return args.Bool(0), nil
+}
+
+func (m *MockOrderRepository) PurgeDeleted(deletedBefore time.Time) (int64, error) {
+	args := m.Called(deletedBefore)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockOrderRepository) UpdateTotals(order models.Order) (bool, error) {
+	args := m.Called(order)
+	if args.Error(1) != nil {
+		return false, args.Error(1)
+	}
+	return args.Bool(0), nil
+}
+
+func (m *MockOrderRepository) Cancel(order models.Order, previousUpdatedAt time.Time, notCancelableStatuses []string) (bool, error) {
+	args := m.Called(order, previousUpdatedAt, notCancelableStatuses)
+	if args.Error(1) != nil {
+		return false, args.Error(1)
+	}
+	return args.Bool(0), nil
+}
+
+func (m *MockOrderRepository) UpdateRefundStatus(id string, refundId string, fromStatus string, status string, updatedAt time.Time) (bool, error) {
+	args := m.Called(id, refundId, fromStatus, status, updatedAt)
+	if args.Error(1) != nil {
+		return false, args.Error(1)
+	}
+	return args.Bool(0), nil
+}
+
+func (m *MockOrderRepository) UpdateShipments(order models.Order, previousUpdatedAt time.Time, notShippableStatuses []string) (bool, error) {
+	args := m.Called(order, previousUpdatedAt, notShippableStatuses)
+	if args.Error(1) != nil {
+		return false, args.Error(1)
+	}
+	return args.Bool(0), nil
+}
+
 func TestOrderService_GetAll_SuccessAndFail(t *testing.T) {
 	for _, result := range getOrdersTestValues {
 		// Create a mock instance
@@ -340,7 +430,7 @@ func TestOrderService_GetAll_SuccessAndFail(t *testing.T) {
 		mockRepo.On("GetAll").Return(result.data, result.err)
 
 		// Create an instance of OrderService with the mock repository
-		orderService := NewOrderService(mockRepo)
+		orderService := NewOrderService(mockRepo, noPromotions())
 
 		// Call the GetAll method
 		orders, err := orderService.GetAll()
@@ -372,7 +462,7 @@ func TestOrderService_GetOrderById_SuccessAndFail(t *testing.T) {
 		mockRepo.On("GetOrderById", result.param).Return(result.data, result.err)
 
 		// Create an instance of OrderService with the mock repository
-		orderService := NewOrderService(mockRepo)
+		orderService := NewOrderService(mockRepo, noPromotions())
 
 		// Call the GetOrderById method
 		order, err := orderService.GetOrderById(result.param)
@@ -404,7 +494,7 @@ func TestOrderService_Insert_SuccessAndFail(t *testing.T) {
 		mockRepo.On("Insert", mock.AnythingOfType("models.Order")).Return(result.data, result.err)
 
 		// Create an instance of OrderService with the mock repository
-		orderService := NewOrderService(mockRepo)
+		orderService := NewOrderService(mockRepo, noPromotions())
 
 		// Call the Insert method
 		response, err := orderService.Insert(result.payload)
@@ -421,8 +511,14 @@ func TestOrderService_Insert_SuccessAndFail(t *testing.T) {
 			// Assert the result
 			assert.Equal(t, result.payload.UserId, response.UserId)
 			assert.Equal(t, result.payload.Status, response.Status)
-			assert.Equal(t, float64(22500), response.Total)
-			assert.Equal(t, result.payload.Product, response.Product)
+			assert.Equal(t, money.MustParse("22500"), response.Subtotal)
+			assert.Equal(t, money.MustParse("22500"), response.Total)
+			// Lines without VAT rate have total of their amount
+			assert.Equal(t, len(result.payload.Product), len(response.Product))
+			for i, line := range response.Product {
+				assert.Equal(t, result.payload.Product[i].Name, line.Name)
+				assert.Equal(t, line.Price.Mul(line.Quantity), line.Total)
+			}
 			assert.Equal(t, result.payload.Address, response.Address)
 			assert.Equal(t, result.payload.InvoiceAddress, response.InvoiceAddress)
 		}
@@ -441,7 +537,7 @@ func TestOrderService_Update_SuccessAndFail(t *testing.T) {
 		mockRepo.On("Update", mock.AnythingOfType("models.Order")).Return(result.data, result.err)
 
 		// Create an instance of OrderService with the mock repository
-		orderService := NewOrderService(mockRepo)
+		orderService := NewOrderService(mockRepo, noPromotions())
 
 		// Call the Insert method
 		response, err := orderService.Update(result.payload)
@@ -464,6 +560,26 @@ func TestOrderService_Update_SuccessAndFail(t *testing.T) {
 	}
 }
 
+func TestOrderService_Update_TotalIsNotDoubled(t *testing.T) {
+	// Create a mock instance
+	mockRepo := new(MockOrderRepository)
+	mockRepo.On("Update", mock.AnythingOfType("models.Order")).Return(true, nil)
+
+	orderService := NewOrderService(mockRepo, noPromotions())
+
+	// Existing order has total of its lines already
+	_, err := orderService.Update(ordersList[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	saved := mockRepo.Calls[0].Arguments.Get(0).(models.Order)
+	assert.Equal(t, money.MustParse("24000"), saved.Subtotal)
+	assert.Equal(t, money.MustParse("24000"), saved.Total)
+	// Order of caller is not changed
+	assert.Equal(t, money.Amount{}, ordersList[0].Product[0].Total)
+}
+
 func TestOrderService_Delete_SuccessAndFail(t *testing.T) {
 	for _, result := range deleteOrderTestValues {
 		// Create a mock instance
@@ -473,7 +589,7 @@ func TestOrderService_Delete_SuccessAndFail(t *testing.T) {
 		mockRepo.On("Delete", result.paramId).Return(result.data, result.err)
 
 		// Create an instance of OrderService with the mock repository
-		orderService := NewOrderService(mockRepo)
+		orderService := NewOrderService(mockRepo, noPromotions())
 
 		// Call the Insert method
 		response, err := orderService.Delete(result.paramId)
@@ -520,14 +636,14 @@ func TestOrderService_GetOrdersWithFilter_Success(t *testing.T) {
 	}
 
 	// Create an instance of OrderService with the mock repository
-	orderService := NewOrderService(mockRepo)
+	orderService := NewOrderService(mockRepo, noPromotions())
 
 	selectedOrder := ordersList[0]
 	filteredOrder := struct {
 		id     string
 		userId string
 		status string
-		total  float64
+		total  money.Amount
 	}{
 		id:     selectedOrder.ID,
 		userId: selectedOrder.UserId,
@@ -540,12 +656,15 @@ func TestOrderService_GetOrdersWithFilter_Success(t *testing.T) {
 	var orders []interface{}
 	orders = append(orders, orderAsInterface)
 
-	filter, opt := orderService.FromModelConvertToFilter(orderRequest)
+	filter, opt, err := orderService.FromModelConvertToFilter(orderRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	// We don't know exact order model because in service we have changed order model
 	mockRepo.On("GetOrdersWithFilter", filter, opt).Return(orders, nil)
 
-	orderServiceLast := NewOrderService(mockRepo)
+	orderServiceLast := NewOrderService(mockRepo, noPromotions())
 
 	// Call the Insert method
 	result, err := orderServiceLast.GetOrdersWithFilter(filter, opt)
@@ -561,3 +680,1902 @@ func TestOrderService_GetOrdersWithFilter_Success(t *testing.T) {
 	// We don't know exact order model because in service we have changed order model
 	mockRepo.AssertCalled(t, "GetOrdersWithFilter", filter, opt)
 }
+
+func TestOrderService_GetOrdersWithFilter_JSONShape(t *testing.T) {
+	total, _ := primitive.ParseDecimal128("389.90")
+	price, _ := primitive.ParseDecimal128("100.00")
+	taxRate, _ := primitive.ParseDecimal128("20")
+
+	// Documents are decoded as maps by repository, selected fields only
+	document := map[string]interface{}{
+		"_id":     ordersList[0].ID,
+		"total":   total,
+		"address": map[string]interface{}{"city": "İzmir"},
+		"product": primitive.A{map[string]interface{}{"sku": "AIRPODS-3", "price": price, "taxRate": taxRate}},
+	}
+
+	mockRepo := new(MockOrderRepository)
+	mockRepo.On("GetOrdersWithFilter", bson.M{}, mock.Anything).Return([]interface{}{document}, nil)
+
+	orderService := NewOrderService(mockRepo, noPromotions())
+	result, err := orderService.GetOrdersWithFilter(bson.M{}, options.Find())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Amounts and rates are numbers like json of order
+	data, err := json.Marshal(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, `[{"_id":"`+ordersList[0].ID+`","address":{"city":"İzmir"},"product":[{"price":100.00,"sku":"AIRPODS-3","taxRate":20}],"total":389.90}]`, string(data))
+}
+
+// orderGetRequest => request of generic endpoints as it is bound from body
+func orderGetRequest(t *testing.T, body string) OrderGetRequest {
+	var req OrderGetRequest
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+func TestOrderService_FromModelConvertToFilter_Groups(t *testing.T) {
+	req := orderGetRequest(t, `{"exact_filters": {"status": ["Shipped"]}, "filter": {"or": [
+		{"field": "address.city", "parameter": "eq", "value": "İzmir"},
+		{"and": [{"field": "total", "parameter": "greaterThan", "value": 1000},
+			{"not": {"field": "createdAt", "parameter": "lt", "value": "2024-01-02"}}]}]}}`)
+
+	filter, _, err := NewOrderService(new(MockOrderRepository), noPromotions()).FromModelConvertToFilter(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, bson.M{"$and": []bson.M{
+		{"status": bson.M{"$in": []interface{}{"Shipped"}}},
+		{"$or": []bson.M{
+			{"address.city": bson.M{"$eq": "İzmir"}},
+			{"$and": []bson.M{
+				{"total": bson.M{"$gt": float64(1000)}},
+				{"$nor": []bson.M{{"createdAt": bson.M{"$lt": time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)}}}},
+			}},
+		}},
+	}}, filter)
+
+	// Same filter on Elasticsearch, text fields are keyword fields
+	searchBody, err := (&ElasticService{}).FromModelConvertToElasticQuery(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query, _ := json.Marshal(searchBody["query"])
+	assert.Equal(t, `{"bool":{"must":[{"terms":{"status.keyword":["Shipped"]}},{"bool":{"minimum_should_match":1,"should":[`+
+		`{"term":{"address.city.keyword":"İzmir"}},{"bool":{"must":[{"range":{"total":{"gt":1000}}},`+
+		`{"bool":{"must_not":[{"range":{"createdAt":{"lt":"2024-01-02T00:00:00Z"}}}]}}]}}]}}]}}`, string(query))
+
+	// Request without filters matches every order
+	filter, _, _ = NewOrderService(new(MockOrderRepository), noPromotions()).FromModelConvertToFilter(OrderGetRequest{})
+	assert.Equal(t, bson.M{}, filter)
+}
+
+func TestOrderService_FromModelConvertToFilter_SortOrder(t *testing.T) {
+	// First field of body has the highest priority on both MongoDB and Elasticsearch
+	req := orderGetRequest(t, `{"sort": {"total": -1, "createdAt": 1, "address.city": 1}}`)
+
+	_, findOptions, err := NewOrderService(new(MockOrderRepository), noPromotions()).FromModelConvertToFilter(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, bson.D{{Key: "total", Value: -1}, {Key: "createdAt", Value: 1}, {Key: "address.city", Value: 1}}, findOptions.Sort)
+
+	searchBody, err := (&ElasticService{}).FromModelConvertToElasticQuery(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sortBody, _ := json.Marshal(searchBody["sort"])
+	assert.Equal(t, `[{"total":"desc"},{"createdAt":"asc"},{"address.city.keyword":"asc"}]`, string(sortBody))
+}
+
+var invalidFilterTestValues = map[string]struct {
+	body string
+	err  string
+}{
+	"unknown-field":     {`{"exact_filters": {"password": ["x"]}}`, "exact_filters.password: field {password} cannot be filtered"},
+	"unknown-parameter": {`{"match": [{"match_field": "status", "parameter": "like", "value": "Ship"}]}`, "match[0]: parameter {like} is not an operator"},
+	"invalid-date":      {`{"match": [{"match_field": "createdAt", "parameter": "eq", "value": "02.01.2024"}]}`, "match[0]: value {02.01.2024} is not a date"},
+	"range-of-string":   {`{"filter": {"field": "status", "parameter": "gt", "value": "A"}}`, "filter: operator {gt} cannot be used with string field {status}"},
+	"number-as-string": {`{"filter": {"or": [{"field": "status", "parameter": "eq", "value": "Shipped"},
+		{"field": "total", "parameter": "eq", "value": "100"}]}}`, "filter.or[1]: value {100} is not a number"},
+	"empty-group":        {`{"filter": {"and": []}}`, "filter.and: group has no clauses"},
+	"group-and-field":    {`{"filter": {"field": "status", "parameter": "eq", "value": "Shipped", "not": {"field": "status", "parameter": "eq", "value": "Shipped"}}}`, "filter: clause must have exactly one of and, or, not and field"},
+	"nested-not":         {`{"filter": {"not": {"not": {"field": "userId", "parameter": "in", "value": "user"}}}}`, "filter.not.not: value {user} is not a list of values"},
+	"invalid-regex":      {`{"filter": {"field": "product.name", "parameter": "regex", "value": "(shoe"}}`, "filter: value {(shoe} is not a regular expression"},
+	"regex-shorthand":    {`{"filter": {"field": "product.name", "parameter": "regex", "value": "\\d+"}}`, "filter: escape {\\d} of regular expression is not supported, use a character class (e.g. [0-9])"},
+	"regex-class-name":   {`{"filter": {"field": "product.name", "parameter": "regex", "value": "[[:alpha:]]"}}`, "filter: named character class of regular expression is not supported, use a character class (e.g. [a-z])"},
+	"regex-flags":        {`{"filter": {"field": "product.name", "parameter": "regex", "value": "(?i)shoe"}}`, "filter: flags and non-capturing groups of regular expression are not supported"},
+	"regex-anchor-in-or": {`{"filter": {"and": [{"field": "product.name", "parameter": "regex", "value": "^Sh|Ha"}]}}`, "filter.and[0]: anchors of regular expression must be at its start and end and out of alternation"},
+	"regex-inner-anchor": {`{"filter": {"field": "product.name", "parameter": "regex", "value": "(Sh$|Ha)"}}`, "filter: anchors of regular expression must be at its start and end and out of alternation"},
+	"unknown-sort-field": {`{"sort": {"total": -1, "password": 1}}`, "sort.password: field {password} cannot be sorted"},
+	"sort-direction":     {`{"sort": {"total": 2}}`, "sort.total: direction {2} is not 1 or -1"},
+	"unknown-selected":   {`{"fields": ["status", "password"]}`, "fields[1]: field {password} cannot be selected"},
+	"exists-as-string":   {`{"filter": {"field": "couponCode", "parameter": "exists", "value": "yes"}}`, "filter: value {yes} is not a bool"},
+}
+
+func TestOrderService_FromModelConvertToFilter_InvalidClauses(t *testing.T) {
+	orderService := NewOrderService(new(MockOrderRepository), noPromotions())
+
+	for name, test := range invalidFilterTestValues {
+		t.Run(name, func(t *testing.T) {
+			req := orderGetRequest(t, test.body)
+
+			_, _, err := orderService.FromModelConvertToFilter(req)
+			if !errors.Is(err, ErrInvalidFilter) {
+				t.Fatalf("Expected error: %v, but got: %v", ErrInvalidFilter, err)
+			}
+			assert.Equal(t, "invalid filter: "+test.err, err.Error())
+
+			// Elasticsearch query of request is not valid either
+			_, elasticErr := (&ElasticService{}).FromModelConvertToElasticQuery(req)
+			assert.Equal(t, err, elasticErr)
+		})
+	}
+}
+
+var applyAddressChangeTestValues = map[string]struct {
+	policy          string
+	recorded        bool
+	decision        string
+	updatedOrderLen int
+	err             error
+}{
+	"update-policy":          {AddressChangePolicyUpdate, true, AddressChangeUpdated, 1, nil},
+	"keep-policy":            {AddressChangePolicyKeep, true, AddressChangeKept, 0, nil},
+	"already-recorded-event": {AddressChangePolicyUpdate, false, AddressChangeUpdated, 0, nil},
+	"repository-error":       {AddressChangePolicyUpdate, false, AddressChangeUpdated, 0, errors.New("order cannot update")},
+}
+
+func TestOrderService_ApplyAddressChange_SuccessAndFail(t *testing.T) {
+	order := ordersList[0]
+	newAddress := order.Address
+	newAddress.Address = "Maslak"
+
+	change := models.AddressChange{
+		EventID:   "8d0ae8a8-25f5-4d04-a6a4-f09e4a9cbc0f",
+		EventType: "AddressChanged",
+		AddressID: order.Address.ID,
+		Address:   newAddress,
+	}
+
+	for name, result := range applyAddressChangeTestValues {
+		// Create a mock instance
+		mockRepo := new(MockOrderRepository)
+		mockRepo.On("GetOpenOrdersByAddress", order.UserId, order.Address.ID, ClosedOrderStatuses).Return([]models.Order{order}, nil)
+
+		// Decision and changed fields are checked with matcher because decidedAt is set in service
+		mockRepo.On("AddAddressChange", order.ID, mock.MatchedBy(func(recorded models.AddressChange) bool {
+			return recorded.EventID == change.EventID && recorded.Decision == result.decision
+		}), mock.MatchedBy(func(set bson.M) bool {
+			_, addressChanged := set["address"]
+			_, invoiceAddressChanged := set["invoiceAddress"]
+			return addressChanged == (result.decision == AddressChangeUpdated) && !invoiceAddressChanged
+		})).Return(result.recorded, result.err)
+
+		// Create an instance of OrderService with the mock repository
+		orderService := NewOrderService(mockRepo, noPromotions())
+
+		updatedOrders, err := orderService.ApplyAddressChange(order.UserId, change, result.policy)
+
+		if !errors.Is(err, result.err) {
+			t.Errorf("%v: Expected error: %v, but got: %v", name, result.err, err)
+		}
+
+		// Assert the result
+		assert.Equal(t, result.updatedOrderLen, len(updatedOrders))
+		if len(updatedOrders) > 0 {
+			assert.Equal(t, "Maslak", updatedOrders[0].Address.Address)
+			assert.Equal(t, order.InvoiceAddress, updatedOrders[0].InvoiceAddress)
+		}
+
+		mockRepo.AssertExpectations(t)
+	}
+}
+
+func TestOrderService_CancelOpenOrdersOfUser_Success(t *testing.T) {
+	// Create mock instances
+	mockRepo := new(MockOrderRepository)
+	mockPromotionRepo := new(MockPromotionRepository)
+
+	openOrder := cancelOrder("Not Shipped")
+	openOrder.Promotions = []models.AppliedPromotion{{PromotionID: promotionsList["fixed"].ID, Code: "MINUS50", Amount: money.MustParse("50")}}
+	closedOrder := ordersList[1]
+	closedOrder.Status = "Not Shipped"
+	closedNow := closedOrder
+	closedNow.Status = CanceledStatus
+
+	mockRepo.On("GetOpenOrdersByUser", openOrder.UserId, ClosedOrderStatuses).Return([]models.Order{openOrder, closedOrder}, nil)
+	mockRepo.On("Cancel", mock.MatchedBy(func(order models.Order) bool { return order.ID == openOrder.ID }), openOrder.UpdatedAt, ClosedOrderStatuses).Return(true, nil)
+	// Order is closed after it is listed, so repository doesn't change it and it is skipped after it is read again
+	mockRepo.On("Cancel", mock.MatchedBy(func(order models.Order) bool { return order.ID == closedOrder.ID }), closedOrder.UpdatedAt, ClosedOrderStatuses).Return(false, nil)
+	mockRepo.On("GetOrderById", closedOrder.ID).Return(closedNow, nil)
+	mockPromotionRepo.On("Release", promotionsList["fixed"].ID, openOrder.UserId).Return(true, nil)
+
+	// Create an instance of OrderService with the mock repository
+	orderService := NewOrderService(mockRepo, NewPromotionService(mockPromotionRepo))
+
+	results, err := orderService.CancelOpenOrdersOfUser(openOrder.UserId)
+	if err != nil {
+		t.Error(err)
+	}
+
+	// Canceled order has its cancellation and refund of whole total like a cancel request
+	assert.Equal(t, 1, len(results))
+	assert.Equal(t, openOrder.Status, results[0].Before.Status)
+	assert.Equal(t, CanceledStatus, results[0].After.Status)
+	assert.Equal(t, CancelReasonOther, results[0].Cancellation.Reason)
+	assert.Equal(t, len(openOrder.Product), len(results[0].Cancellation.Lines))
+	assert.Equal(t, []models.OrderCancellation{results[0].Cancellation}, results[0].After.Cancellations)
+	assert.Equal(t, openOrder.Total, results[0].Refund.Amount)
+	assert.Equal(t, RefundPending, results[0].Refund.Status)
+	assert.Equal(t, []models.Refund{*results[0].Refund}, results[0].After.Refunds)
+
+	mockRepo.AssertExpectations(t)
+	mockPromotionRepo.AssertExpectations(t)
+}
+
+func TestOrderService_Restore_SuccessAndFail(t *testing.T) {
+	order := ordersList[0]
+
+	results := map[string]struct {
+		restored bool
+		err      error
+	}{
+		"success":  {true, nil},
+		"fail-404": {false, mongo.ErrNoDocuments},
+		"fail-500": {false, errors.New("something went wrong")},
+	}
+
+	for name, result := range results {
+		// Create a mock instance
+		mockRepo := new(MockOrderRepository)
+
+		mockRepo.On("Restore", order.ID).Return(result.restored, result.err)
+		mockRepo.On("GetOrderById", order.ID).Return(order, nil)
+
+		// Create an instance of OrderService with the mock repository
+		orderService := NewOrderService(mockRepo, noPromotions())
+
+		response, err := orderService.Restore(order.ID)
+
+		if !errors.Is(err, result.err) {
+			t.Errorf("%v: expected error: %v, but got: %v", name, result.err, err)
+		}
+
+		if result.restored {
+			// Restored order is read again
+			assert.Equal(t, order.ID, response.ID)
+			mockRepo.AssertCalled(t, "GetOrderById", order.ID)
+		} else {
+			mockRepo.AssertNotCalled(t, "GetOrderById", order.ID)
+		}
+	}
+}
+
+func TestOrderService_PurgeDeleted_Success(t *testing.T) {
+	// Create a mock instance
+	mockRepo := new(MockOrderRepository)
+
+	mockRepo.On("PurgeDeleted", mock.AnythingOfType("time.Time")).Return(int64(2), nil)
+
+	// Create an instance of OrderService with the mock repository
+	orderService := NewOrderService(mockRepo, noPromotions())
+
+	retention := 30 * 24 * time.Hour
+	purged, err := orderService.PurgeDeleted(retention)
+
+	if err != nil {
+		t.Error(err)
+	}
+	assert.Equal(t, int64(2), purged)
+
+	// Orders which are deleted before retention period are purged
+	deletedBefore := mockRepo.Calls[0].Arguments.Get(0).(time.Time)
+	if time.Since(deletedBefore) < retention {
+		t.Errorf("Expected deleted before %v ago, but got: %v", retention, deletedBefore)
+	}
+}
+
+func TestOrderService_ResolveProducts_SuccessAndFail(t *testing.T) {
+	// Fake product-api, inactive product cannot be ordered
+	productAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var products []ProductResponse
+		for _, sku := range r.URL.Query()["sku"] {
+			switch sku {
+			case "ASUS-NB-15":
+				products = append(products, ProductResponse{Sku: sku, Name: "Asus Notebook", Price: money.MustParse("20000"),
+					Currency: "TRY", TaxRate: money.MustParseRate("20"), Active: true})
+			case "OLD-PHONE":
+				products = append(products, ProductResponse{Sku: sku, Name: "Old Phone", Price: money.MustParse("100"), Currency: "TRY", Active: false})
+			case "KINDLE-US":
+				products = append(products, ProductResponse{Sku: sku, Name: "Kindle", Price: money.MustParse("99.99"), Currency: "USD", Active: true})
+			}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"totalItemCount": len(products), "data": products})
+	}))
+	defer productAPI.Close()
+
+	orderService := NewOrderService(new(MockOrderRepository), noPromotions())
+
+	// Price, currency and VAT rate come from catalog
+	orderProducts, currency, err := orderService.ResolveProducts([]OrderProductRequest{{Sku: "ASUS-NB-15", Quantity: 2}}, productAPI.URL)
+	if err != nil {
+		t.Error(err)
+	}
+	assert.Equal(t, []models.OrderProduct{{Sku: "ASUS-NB-15", Name: "Asus Notebook", Quantity: 2, Price: money.MustParse("20000"),
+		TaxRate: money.MustParseRate("20")}}, orderProducts)
+	assert.Equal(t, "TRY", currency)
+
+	for _, sku := range []string{"UNKNOWN", "OLD-PHONE"} {
+		_, _, err = orderService.ResolveProducts([]OrderProductRequest{{Sku: "ASUS-NB-15", Quantity: 1}, {Sku: sku, Quantity: 1}}, productAPI.URL)
+		if !errors.Is(err, ErrProductNotFound) {
+			t.Errorf("Expected error: %v, but got: %v", ErrProductNotFound, err)
+		}
+	}
+
+	// Lines of an order cannot be in different currencies
+	_, _, err = orderService.ResolveProducts([]OrderProductRequest{{Sku: "ASUS-NB-15", Quantity: 1}, {Sku: "KINDLE-US", Quantity: 1}}, productAPI.URL)
+	if !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Expected error: %v, but got: %v", ErrCurrencyMismatch, err)
+	}
+}
+
+func TestCalculateTotals(t *testing.T) {
+	tests := map[string]struct {
+		lines      []models.OrderProduct
+		promotions []models.AppliedPromotion
+		discount   money.Amount
+		shipping   money.Amount
+		subtotal   money.Amount
+		tax        money.Amount
+		total      money.Amount
+		lineTax    []money.Amount
+		err        error
+	}{
+		"vat-per-line": {
+			lines: []models.OrderProduct{
+				{Quantity: 2, Price: money.MustParse("199.90"), TaxRate: money.MustParseRate("20")},
+				{Quantity: 3, Price: money.MustParse("0.35"), TaxRate: money.MustParseRate("1")},
+			},
+			subtotal: money.MustParse("400.85"), tax: money.MustParse("79.97"), total: money.MustParse("480.82"),
+			// 0.0105 is rounded to 0.01
+			lineTax: []money.Amount{money.MustParse("79.96"), money.MustParse("0.01")},
+		},
+		"discount-before-vat": {
+			lines: []models.OrderProduct{
+				{Quantity: 1, Price: money.MustParse("200"), TaxRate: money.MustParseRate("20")},
+				{Quantity: 1, Price: money.MustParse("100"), TaxRate: money.MustParseRate("10")},
+			},
+			// 30 is allocated as 20 and 10
+			discount: money.MustParse("30"),
+			subtotal: money.MustParse("300"), tax: money.MustParse("45"), total: money.MustParse("315"),
+			lineTax: []money.Amount{money.MustParse("36"), money.MustParse("9")},
+		},
+		"buy-x-get-y-discount-to-sku": {
+			lines: []models.OrderProduct{
+				{Sku: "ASUS-NB-15", Quantity: 1, Price: money.MustParse("1000"), TaxRate: money.MustParseRate("20")},
+				{Sku: "AIRPODS-3", Quantity: 3, Price: money.MustParse("100"), TaxRate: money.MustParseRate("10")},
+			},
+			promotions: []models.AppliedPromotion{{Type: PromotionBuyXGetY, Sku: "AIRPODS-3", Amount: money.MustParse("100")}},
+			discount:   money.MustParse("100"),
+			shipping:   money.MustParse("29.90"),
+			subtotal:   money.MustParse("1300"), tax: money.MustParse("220"), total: money.MustParse("1449.90"),
+			lineTax: []money.Amount{money.MustParse("200"), money.MustParse("20")},
+		},
+		"discount-more-than-subtotal": {
+			lines:    []models.OrderProduct{{Quantity: 1, Price: money.MustParse("10"), TaxRate: money.MustParseRate("20")}},
+			discount: money.MustParse("10.01"),
+			err:      ErrInvalidDiscount,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			order := models.Order{Product: test.lines, Promotions: test.promotions, Discount: test.discount,
+				Shipping: test.shipping, Total: money.MustParse("999")}
+
+			err := CalculateTotals(&order)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("Expected error: %v, but got: %v", test.err, err)
+			}
+			if err != nil {
+				return
+			}
+
+			assert.Equal(t, test.subtotal, order.Subtotal)
+			assert.Equal(t, test.tax, order.Tax)
+			assert.Equal(t, test.total, order.Total)
+
+			// Lines and shipping add up to the grand total
+			linesTotal := order.Shipping
+			for i, line := range order.Product {
+				assert.Equal(t, test.lineTax[i], line.Tax)
+				linesTotal = linesTotal.Add(line.Total)
+			}
+			assert.Equal(t, order.Total, linesTotal)
+
+			// Lines of caller are not changed
+			assert.Equal(t, money.Amount{}, test.lines[0].Total)
+		})
+	}
+}
+
+func TestOrderService_RecalculateTotals_DryRunAndRepair(t *testing.T) {
+	correct := ordersList[1]
+	correct.Currency = "TRY"
+	if err := CalculateTotals(&correct); err != nil {
+		t.Fatal(err)
+	}
+
+	// Total of legacy order is doubled by an update and it has no currency
+	doubled := ordersList[0]
+	doubled.Total = money.MustParse("48000")
+
+	invalid := ordersList[1]
+	invalid.ID = "8a1c5c5e-8f3c-4b43-9a3a-4a0f1f3e2d11"
+	invalid.Discount = money.MustParse("30000")
+
+	for _, dryRun := range []bool{true, false} {
+		mockRepo := new(MockOrderRepository)
+		// Orders are read in batches until a batch is empty
+		mockRepo.On("GetOrdersAfter", "", int64(recalculateBatchSize)).Return([]models.Order{correct, doubled}, nil)
+		mockRepo.On("GetOrdersAfter", doubled.ID, int64(recalculateBatchSize)).Return([]models.Order{invalid}, nil)
+		mockRepo.On("GetOrdersAfter", invalid.ID, int64(recalculateBatchSize)).Return([]models.Order{}, nil)
+		mockRepo.On("UpdateTotals", mock.AnythingOfType("models.Order")).Return(true, nil)
+
+		orderService := NewOrderService(mockRepo, noPromotions())
+
+		report, repaired, err := orderService.RecalculateTotals("TRY", dryRun)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, dryRun, report.DryRun)
+		assert.Equal(t, 3, report.Checked)
+		mockRepo.AssertNumberOfCalls(t, "GetOrdersAfter", 3)
+		assert.Equal(t, 1, report.Changed)
+		assert.Equal(t, 2, len(report.Diffs))
+
+		diff := report.Diffs[0]
+		assert.Equal(t, doubled.ID, diff.OrderID)
+		assert.Equal(t, money.MustParse("48000"), diff.Before.Total)
+		assert.Equal(t, money.MustParse("24000"), diff.After.Total)
+		assert.Equal(t, "TRY", diff.After.Currency)
+		assert.Equal(t, true, diff.LinesChanged)
+
+		// Order which cannot be calculated is reported, it is not changed
+		assert.Equal(t, invalid.ID, report.Diffs[1].OrderID)
+		assert.NotEqual(t, "", report.Diffs[1].Error)
+
+		if dryRun {
+			mockRepo.AssertNotCalled(t, "UpdateTotals", mock.Anything)
+			assert.Equal(t, 0, len(repaired))
+			continue
+		}
+
+		mockRepo.AssertNumberOfCalls(t, "UpdateTotals", 1)
+		assert.Equal(t, 1, report.Repaired)
+		assert.Equal(t, true, report.Diffs[0].Repaired)
+		assert.Equal(t, money.MustParse("24000"), repaired[0].Total)
+	}
+}
+
+func TestOrderCreateRequest_ClientTotals(t *testing.T) {
+	var request OrderCreateRequest
+	err := json.Unmarshal([]byte(`{"userId": "fcd20a19-6171-4737-a2ed-23e293cae7b5", "total": 1,
+		"product": [{"sku": "ASUS-NB-15", "quantity": 1}, {"sku": "AIRPODS-3", "quantity": 1, "tax": null, "discount": 5}]}`), &request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []string{"total", "product[1].discount", "product[1].tax"}, request.ClientTotals())
+	assert.Equal(t, 0, len(OrderCreateRequest{Product: []OrderProductRequest{{Sku: "ASUS-NB-15", Quantity: 1}}}.ClientTotals()))
+}
+
+// MockPromotionRepository is a mock implementation of IPromotionRepository
+type MockPromotionRepository struct {
+	mock.Mock
+}
+
+func (m *MockPromotionRepository) GetAll() ([]models.Promotion, error) {
+	args := m.Called()
+	if args.Error(1) != nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Promotion), nil
+}
+
+func (m *MockPromotionRepository) GetPromotionById(id string) (models.Promotion, error) {
+	args := m.Called(id)
+	if args.Error(1) != nil {
+		return models.Promotion{}, args.Error(1)
+	}
+	return args.Get(0).(models.Promotion), nil
+}
+
+func (m *MockPromotionRepository) GetPromotionByCode(code string) (models.Promotion, error) {
+	args := m.Called(code)
+	if args.Error(1) != nil {
+		return models.Promotion{}, args.Error(1)
+	}
+	return args.Get(0).(models.Promotion), nil
+}
+
+func (m *MockPromotionRepository) GetAutomatic(now time.Time) ([]models.Promotion, error) {
+	args := m.Called(now)
+	if args.Error(1) != nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Promotion), nil
+}
+
+func (m *MockPromotionRepository) Insert(promotion models.Promotion) (bool, error) {
+	args := m.Called(promotion)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockPromotionRepository) Update(promotion models.Promotion) (bool, error) {
+	args := m.Called(promotion)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockPromotionRepository) Redeem(id string, userId string, usageLimit int, perUserLimit int) (bool, error) {
+	args := m.Called(id, userId, usageLimit, perUserLimit)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockPromotionRepository) Release(id string, userId string) (bool, error) {
+	args := m.Called(id, userId)
+	return args.Bool(0), args.Error(1)
+}
+
+// noPromotions => promotion service without any promotion
+func noPromotions() IPromotionService {
+	promotionRepo := new(MockPromotionRepository)
+	promotionRepo.On("GetAutomatic", mock.AnythingOfType("time.Time")).Return([]models.Promotion{}, nil)
+	return NewPromotionService(promotionRepo)
+}
+
+var promotionsList = map[string]models.Promotion{
+	"percent": {ID: "b6c5f5a4-1f0e-4f61-9a8e-6a1d0f1c2a01", Code: "WELCOME10", Name: "Welcome", Type: PromotionPercentage,
+		Percent: money.MustParseRate("10"), MaxDiscount: money.MustParse("100"), Currency: "TRY", Stackable: true, Active: true},
+	"fixed": {ID: "b6c5f5a4-1f0e-4f61-9a8e-6a1d0f1c2a02", Code: "MINUS50", Name: "Minus 50", Type: PromotionFixedAmount,
+		Amount: money.MustParse("50"), Currency: "TRY", Stackable: true, Active: true},
+	"shipping": {ID: "b6c5f5a4-1f0e-4f61-9a8e-6a1d0f1c2a03", Name: "Free shipping", Type: PromotionFreeShipping,
+		MinSubtotal: money.MustParse("500"), Currency: "TRY", Stackable: true, Active: true},
+	"buy2get1": {ID: "b6c5f5a4-1f0e-4f61-9a8e-6a1d0f1c2a04", Name: "Buy 2 get 1", Type: PromotionBuyXGetY, Sku: "AIRPODS-3",
+		BuyQuantity: 2, GetQuantity: 1, Active: true},
+	"exclusive": {ID: "b6c5f5a4-1f0e-4f61-9a8e-6a1d0f1c2a05", Code: "HALF", Name: "Half price", Type: PromotionPercentage,
+		Percent: money.MustParseRate("50"), Active: true},
+}
+
+func promotionOrder() models.Order {
+	return models.Order{
+		UserId:   "fcd20a19-6171-4737-a2ed-23e293cae7b5",
+		Currency: "TRY",
+		Shipping: money.MustParse("29.90"),
+		Product: []models.OrderProduct{
+			{Sku: "ASUS-NB-15", Quantity: 1, Price: money.MustParse("1000"), TaxRate: money.MustParseRate("20")},
+			{Sku: "AIRPODS-3", Quantity: 3, Price: money.MustParse("100"), TaxRate: money.MustParseRate("20")},
+		},
+	}
+}
+
+func TestEvaluatePromotions(t *testing.T) {
+	tests := map[string]struct {
+		candidates []string
+		applied    []string
+		discount   money.Amount
+		shipping   money.Amount
+	}{
+		// 10% of 1300 is capped at 100
+		"percentage-with-cap": {candidates: []string{"percent"}, applied: []string{"percent"},
+			discount: money.MustParse("100"), shipping: money.MustParse("29.90")},
+		"free-shipping": {candidates: []string{"shipping"}, applied: []string{"shipping"},
+			shipping: money.MustParse("0")},
+		// 3 airpods => 1 is free
+		"buy-x-get-y": {candidates: []string{"buy2get1"}, applied: []string{"buy2get1"},
+			discount: money.MustParse("100"), shipping: money.MustParse("29.90")},
+		"stackable": {candidates: []string{"fixed", "percent", "shipping"}, applied: []string{"fixed", "percent", "shipping"},
+			discount: money.MustParse("150"), shipping: money.MustParse("0")},
+		"exclusive-first": {candidates: []string{"exclusive", "fixed", "shipping"}, applied: []string{"exclusive"},
+			discount: money.MustParse("650"), shipping: money.MustParse("29.90")},
+		"exclusive-after-stackable": {candidates: []string{"fixed", "exclusive", "shipping"}, applied: []string{"fixed", "shipping"},
+			discount: money.MustParse("50"), shipping: money.MustParse("0")},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var candidates []models.Promotion
+			for _, key := range test.candidates {
+				candidates = append(candidates, promotionsList[key])
+			}
+			order := promotionOrder()
+
+			applied := EvaluatePromotions(&order, candidates)
+
+			var names []string
+			for _, promotion := range applied {
+				for key, candidate := range promotionsList {
+					if candidate.ID == promotion.ID {
+						names = append(names, key)
+					}
+				}
+			}
+			assert.Equal(t, test.applied, names)
+			assert.Equal(t, len(applied), len(order.Promotions))
+			assert.Equal(t, test.discount, order.Discount)
+			assert.Equal(t, test.shipping, order.Shipping)
+		})
+	}
+}
+
+func TestPromotionService_Apply_CouponFail(t *testing.T) {
+	now := time.Now()
+	expired := promotionsList["fixed"]
+	expired.EndsAt = now.Add(-time.Hour)
+	usedByUser := promotionsList["fixed"]
+	usedByUser.PerUserLimit = 1
+	usedByUser.UserUsage = map[string]int{"fcd20a19-6171-4737-a2ed-23e293cae7b5": 1}
+	otherCurrency := promotionsList["fixed"]
+	otherCurrency.Currency = "USD"
+	// 3 units are needed for a free unit
+	buy3get1 := promotionsList["buy2get1"]
+	buy3get1.Code, buy3get1.BuyQuantity = "AIRPODS", 3
+
+	tests := map[string]struct {
+		coupon models.Promotion
+		err    error
+	}{
+		"not-found":      {err: mongo.ErrNoDocuments},
+		"expired":        {coupon: expired},
+		"user-limit":     {coupon: usedByUser},
+		"other-currency": {coupon: otherCurrency},
+		"no-discount":    {coupon: buy3get1},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			promotionRepo := new(MockPromotionRepository)
+			promotionRepo.On("GetPromotionByCode", "MINUS50").Return(test.coupon, test.err)
+			promotionRepo.On("GetPromotionByCode", "AIRPODS").Return(test.coupon, test.err)
+			promotionRepo.On("GetAutomatic", mock.AnythingOfType("time.Time")).Return([]models.Promotion{}, nil)
+
+			order := promotionOrder()
+			order.CouponCode = " minus50"
+			if name == "no-discount" {
+				order.CouponCode = "airpods"
+			}
+
+			_, err := NewPromotionService(promotionRepo).Apply(&order, now)
+
+			expected := ErrCouponNotApplicable
+			if test.err != nil {
+				expected = ErrCouponNotFound
+			}
+			if !errors.Is(err, expected) {
+				t.Errorf("Expected error: %v, but got: %v", expected, err)
+			}
+		})
+	}
+}
+
+func TestOrderService_Insert_WithCoupon(t *testing.T) {
+	for _, redeemed := range []bool{true, false} {
+		promotionRepo := new(MockPromotionRepository)
+		promotionRepo.On("GetPromotionByCode", "MINUS50").Return(promotionsList["fixed"], nil)
+		// Free shipping is automatic, it is stacked with coupon
+		promotionRepo.On("GetAutomatic", mock.AnythingOfType("time.Time")).Return([]models.Promotion{promotionsList["shipping"]}, nil)
+		promotionRepo.On("Redeem", promotionsList["fixed"].ID, mock.Anything, 0, 0).Return(true, nil)
+		promotionRepo.On("Redeem", promotionsList["shipping"].ID, mock.Anything, 0, 0).Return(redeemed, nil)
+		promotionRepo.On("Release", mock.Anything, mock.Anything).Return(true, nil)
+
+		mockRepo := new(MockOrderRepository)
+		mockRepo.On("Insert", mock.AnythingOfType("models.Order")).Return(true, nil)
+
+		orderService := NewOrderService(mockRepo, NewPromotionService(promotionRepo))
+
+		order := promotionOrder()
+		order.CouponCode = "minus50"
+		response, err := orderService.Insert(order)
+
+		if !redeemed {
+			// Limit of free shipping is reached in the meantime => usage of coupon is given back
+			if !errors.Is(err, ErrPromotionLimitReached) {
+				t.Errorf("Expected error: %v, but got: %v", ErrPromotionLimitReached, err)
+			}
+			promotionRepo.AssertCalled(t, "Release", promotionsList["fixed"].ID, order.UserId)
+			mockRepo.AssertNotCalled(t, "Insert", mock.Anything)
+			continue
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "MINUS50", response.CouponCode)
+		assert.Equal(t, 2, len(response.Promotions))
+		assert.Equal(t, money.MustParse("50"), response.Discount)
+		assert.Equal(t, money.Amount{}, response.Shipping)
+		// (1300 - 50) + 20% VAT
+		assert.Equal(t, money.MustParse("1500"), response.Total)
+	}
+}
+
+func TestOrderService_Update_EvaluatesPromotionsAgain(t *testing.T) {
+	// Coupon is at its limit with the usage of this order
+	coupon := promotionsList["fixed"]
+	coupon.UsageLimit, coupon.UsedCount = 1, 1
+	buy2get1 := promotionsList["buy2get1"]
+	buy2get1.Stackable = true
+	shipping := promotionsList["shipping"]
+	withoutFree := []models.OrderProduct{
+		{Sku: "ASUS-NB-15", Quantity: 1, Price: money.MustParse("1000"), TaxRate: money.MustParseRate("20")},
+		{Sku: "AIRPODS-3", Quantity: 1, Price: money.MustParse("100"), TaxRate: money.MustParseRate("20")},
+	}
+
+	tests := map[string]struct {
+		minSubtotal money.Amount
+		stored      []models.OrderProduct
+		lines       []models.OrderProduct
+		promotions  []string
+		discount    money.Amount
+		redeemed    []string
+		released    []string
+		err         error
+	}{
+		// Free unit of buy 2 get 1 doesn't survive the removed airpods
+		"lines-removed": {stored: promotionOrder().Product, lines: withoutFree, promotions: []string{coupon.ID, shipping.ID},
+			discount: money.MustParse("50"), released: []string{buy2get1.ID}},
+		"lines-added": {stored: withoutFree, lines: promotionOrder().Product, promotions: []string{coupon.ID, buy2get1.ID, shipping.ID},
+			discount: money.MustParse("150"), redeemed: []string{buy2get1.ID}},
+		"coupon-doesnt-fit": {minSubtotal: money.MustParse("1200"), stored: promotionOrder().Product, lines: withoutFree,
+			err: ErrCouponNotApplicable},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			coupon := coupon
+			coupon.MinSubtotal = test.minSubtotal
+
+			// Stored order got its promotions on create
+			stored := promotionOrder()
+			stored.ID = "2b45ac31-6906-4e1e-82db-d9bcdbdb2143"
+			stored.Product = test.stored
+			stored.CouponCode = coupon.Code
+			EvaluatePromotions(&stored, []models.Promotion{coupon, buy2get1, shipping})
+
+			promotionRepo := new(MockPromotionRepository)
+			promotionRepo.On("GetPromotionByCode", coupon.Code).Return(coupon, nil)
+			promotionRepo.On("GetAutomatic", mock.AnythingOfType("time.Time")).Return([]models.Promotion{buy2get1, shipping}, nil)
+			promotionRepo.On("Redeem", mock.Anything, stored.UserId, 0, 0).Return(true, nil)
+			promotionRepo.On("Release", mock.Anything, stored.UserId).Return(true, nil)
+			mockRepo := new(MockOrderRepository)
+			mockRepo.On("Update", mock.AnythingOfType("models.Order")).Return(true, nil)
+
+			orderService := NewOrderService(mockRepo, NewPromotionService(promotionRepo))
+
+			// Handler keeps coupon, discount breakdown and shipping of stored order
+			order := stored
+			order.Product = test.lines
+			_, err := orderService.Update(order)
+
+			if test.err != nil {
+				if !errors.Is(err, test.err) {
+					t.Errorf("Expected error: %v, but got: %v", test.err, err)
+				}
+				mockRepo.AssertNotCalled(t, "Update", mock.Anything)
+				promotionRepo.AssertNotCalled(t, "Release", mock.Anything, mock.Anything)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			saved := mockRepo.Calls[0].Arguments.Get(0).(models.Order)
+			var promotions []string
+			for _, promotion := range saved.Promotions {
+				promotions = append(promotions, promotion.PromotionID)
+			}
+			assert.Equal(t, test.promotions, promotions)
+			assert.Equal(t, test.discount, saved.Discount)
+			assert.Equal(t, money.Amount{}, saved.Shipping)
+
+			var redeemed, released []string
+			for _, call := range promotionRepo.Calls {
+				switch call.Method {
+				case "Redeem":
+					redeemed = append(redeemed, call.Arguments.String(0))
+				case "Release":
+					released = append(released, call.Arguments.String(0))
+				}
+			}
+			assert.Equal(t, test.redeemed, redeemed)
+			assert.Equal(t, test.released, released)
+		})
+	}
+}
+
+func TestOrderService_Update_TwiceInARow(t *testing.T) {
+	coupon := promotionsList["fixed"]
+	buy2get1 := promotionsList["buy2get1"]
+	buy2get1.Stackable = true
+	shipping := promotionsList["shipping"]
+
+	stored := promotionOrder()
+	stored.ID = "2b45ac31-6906-4e1e-82db-d9bcdbdb2143"
+	stored.CouponCode = coupon.Code
+	EvaluatePromotions(&stored, []models.Promotion{coupon, buy2get1, shipping})
+	if err := CalculateTotals(&stored); err != nil {
+		t.Fatal(err)
+	}
+	createdTotal := stored.Total
+
+	promotionRepo := new(MockPromotionRepository)
+	promotionRepo.On("GetPromotionByCode", coupon.Code).Return(coupon, nil)
+	promotionRepo.On("GetAutomatic", mock.AnythingOfType("time.Time")).Return([]models.Promotion{buy2get1, shipping}, nil)
+	promotionRepo.On("Redeem", mock.Anything, stored.UserId, 0, 0).Return(true, nil)
+	promotionRepo.On("Release", mock.Anything, stored.UserId).Return(true, nil)
+	// Stored order gets the fields which are set by repository
+	mockRepo := new(MockOrderRepository)
+	mockRepo.On("Update", mock.AnythingOfType("models.Order")).Run(func(args mock.Arguments) {
+		set, err := bson.Marshal(repository.OrderUpdateFields(args.Get(0).(models.Order)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := bson.Unmarshal(set, &stored); err != nil {
+			t.Fatal(err)
+		}
+	}).Return(true, nil)
+
+	orderService := NewOrderService(mockRepo, NewPromotionService(promotionRepo))
+
+	// Handler keeps coupon, discount breakdown and shipping of stored order, free airpods is removed and added again
+	for _, lines := range [][]models.OrderProduct{
+		{stored.Product[0], {Sku: "AIRPODS-3", Quantity: 1, Price: money.MustParse("100"), TaxRate: money.MustParseRate("20")}},
+		promotionOrder().Product,
+	} {
+		order := stored
+		order.Product = lines
+		if _, err := orderService.Update(order); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var promotions []string
+	for _, promotion := range stored.Promotions {
+		promotions = append(promotions, promotion.PromotionID)
+	}
+	assert.Equal(t, []string{coupon.ID, buy2get1.ID, shipping.ID}, promotions)
+	assert.Equal(t, coupon.Code, stored.CouponCode)
+	assert.Equal(t, money.Amount{}, stored.Shipping)
+	assert.Equal(t, createdTotal, stored.Total)
+
+	var redeemed, released []string
+	for _, call := range promotionRepo.Calls {
+		switch call.Method {
+		case "Redeem":
+			redeemed = append(redeemed, call.Arguments.String(0))
+		case "Release":
+			released = append(released, call.Arguments.String(0))
+		}
+	}
+	assert.Equal(t, []string{buy2get1.ID}, redeemed)
+	assert.Equal(t, []string{buy2get1.ID}, released)
+}
+
+func TestCheckUpdatable(t *testing.T) {
+	tests := map[string]struct {
+		status    string
+		shipments []models.Shipment
+		err       error
+	}{
+		"open":              {status: NotShippedStatus},
+		"failed-shipment":   {status: NotShippedStatus, shipments: []models.Shipment{{ID: "shipment", Status: ShipmentFailed}}},
+		"prepared-shipment": {status: NotShippedStatus, shipments: []models.Shipment{{ID: "shipment", Status: ShipmentPreparing}}, err: ErrOrderNotShippable},
+		"canceled":          {status: CanceledStatus, err: ErrOrderClosed},
+		"delivered":         {status: DeliveredStatus, err: ErrOrderClosed},
+		"closed":            {status: ClosedStatus, err: ErrOrderClosed},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			order := cancelOrder(test.status)
+			order.Shipments = test.shipments
+
+			err := CheckUpdatable(order)
+			if !errors.Is(err, test.err) || (test.err == nil && err != nil) {
+				t.Errorf("Expected error: %v, but got: %v", test.err, err)
+			}
+		})
+	}
+}
+
+// cancelOrder => order of 1300 with 10% discount, 20% VAT and 29.90 shipping
+func cancelOrder(status string) models.Order {
+	order := promotionOrder()
+	order.ID = "2b45ac31-6906-4e1e-82db-d9bcdbdb2143"
+	order.Status = status
+	order.Discount = money.MustParse("130")
+	order.UpdatedAt = time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+	if err := CalculateTotals(&order); err != nil {
+		panic(err)
+	}
+	return order
+}
+
+func TestOrderService_Cancel(t *testing.T) {
+	tests := map[string]struct {
+		status   string
+		request  OrderCancelRequest
+		saved    bool
+		err      error
+		partial  bool
+		total    money.Amount
+		refund   money.Amount
+		refunded string
+	}{
+		"full": {status: "Not Shipped", request: OrderCancelRequest{Reason: CancelReasonCustomerRequest}, saved: true,
+			total: money.MustParse("1433.90"), refund: money.MustParse("1433.90"), refunded: RefundPending},
+		// 1 of 3 airpods => discount share is 10, (1200 - 120) + 20% VAT + 29.90 shipping
+		"partial": {status: "Not Shipped", saved: true, partial: true, total: money.MustParse("1325.90"),
+			request: OrderCancelRequest{Reason: CancelReasonOutOfStock, Lines: []OrderCancelLineRequest{{Sku: "AIRPODS-3", Quantity: 1}},
+				RefundMethod: RefundMethodStoreCredit},
+			refund: money.MustParse("108"), refunded: RefundIssued},
+		"every-unit-with-lines": {status: "Not Shipped", saved: true, total: money.MustParse("1433.90"), refund: money.MustParse("1433.90"),
+			request: OrderCancelRequest{Reason: CancelReasonFraud, Lines: []OrderCancelLineRequest{
+				{Sku: "ASUS-NB-15", Quantity: 1}, {Sku: "AIRPODS-3", Quantity: 2}, {Sku: "AIRPODS-3", Quantity: 1}}},
+			refunded: RefundPending},
+		"shipped": {status: ShippedStatus, request: OrderCancelRequest{Reason: CancelReasonCustomerRequest}, err: ErrOrderNotCancelable},
+		"too-many-units": {status: "Not Shipped", err: ErrInvalidCancelLines,
+			request: OrderCancelRequest{Reason: CancelReasonOther, Lines: []OrderCancelLineRequest{{Sku: "AIRPODS-3", Quantity: 4}}}},
+		"changed-in-meantime": {status: "Not Shipped", request: OrderCancelRequest{Reason: CancelReasonCustomerRequest}, err: ErrOrderNotCancelable},
+		// 2 airpods are in a prepared shipment
+		"shipment-prepared": {status: "Not Shipped", request: OrderCancelRequest{Reason: CancelReasonCustomerRequest}, err: ErrOrderNotCancelable},
+		"shipped-units": {status: "Not Shipped", err: ErrInvalidCancelLines,
+			request: OrderCancelRequest{Reason: CancelReasonOutOfStock, Lines: []OrderCancelLineRequest{{Sku: "AIRPODS-3", Quantity: 2}}}},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			order := cancelOrder(test.status)
+			if name == "shipment-prepared" || name == "shipped-units" {
+				order.Shipments = []models.Shipment{{ID: "shipment", Status: ShipmentPreparing, Items: []models.ShipmentItem{{Sku: "AIRPODS-3", Quantity: 2}}}}
+			}
+
+			order.Promotions = []models.AppliedPromotion{{PromotionID: promotionsList["fixed"].ID, Code: "MINUS50", Amount: money.MustParse("50")}}
+
+			mockRepo := new(MockOrderRepository)
+			mockRepo.On("GetOrderById", order.ID).Return(order, nil)
+			mockRepo.On("Cancel", mock.AnythingOfType("models.Order"), order.UpdatedAt, NotCancelableStatuses).Return(test.saved, nil)
+			mockPromotionRepo := new(MockPromotionRepository)
+			mockPromotionRepo.On("Release", promotionsList["fixed"].ID, order.UserId).Return(true, nil)
+
+			orderService := NewOrderService(mockRepo, NewPromotionService(mockPromotionRepo))
+			result, err := orderService.Cancel(order.ID, test.request)
+
+			if test.err != nil {
+				if !errors.Is(err, test.err) {
+					t.Errorf("Expected error: %v, but got: %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, test.partial, result.Cancellation.Partial)
+			assert.Equal(t, test.total, result.After.Total)
+			assert.Equal(t, test.refund, result.Refund.Amount)
+			assert.Equal(t, test.refunded, result.Refund.Status)
+			assert.Equal(t, result.Refund.ID, result.Cancellation.RefundID)
+			assert.Equal(t, []models.Refund{*result.Refund}, result.After.Refunds)
+			assert.Equal(t, []models.OrderCancellation{result.Cancellation}, result.After.Cancellations)
+			// Order of caller is not changed
+			assert.Equal(t, 3, order.Product[1].Quantity)
+
+			// Coupon of canceled order can be used again, partially canceled order keeps it
+			if test.partial {
+				assert.Equal(t, "Not Shipped", result.After.Status)
+				assert.Equal(t, 2, result.After.Product[1].Quantity)
+				assert.Equal(t, money.MustParse("120"), result.After.Discount)
+				mockPromotionRepo.AssertNotCalled(t, "Release", mock.Anything, mock.Anything)
+			} else {
+				assert.Equal(t, CanceledStatus, result.After.Status)
+				assert.Equal(t, order.Product, result.After.Product)
+				mockPromotionRepo.AssertCalled(t, "Release", promotionsList["fixed"].ID, order.UserId)
+			}
+		})
+	}
+}
+
+func TestOrderService_Cancel_PartialDerivesStatus(t *testing.T) {
+	// 1 airpods couldn't be delivered, the rest is delivered
+	order := cancelOrder(NotDeliveredStatus)
+	order.Shipments = []models.Shipment{
+		{ID: "delivered", Status: ShipmentDelivered, Items: []models.ShipmentItem{{Sku: "ASUS-NB-15", Quantity: 1}, {Sku: "AIRPODS-3", Quantity: 2}}},
+		{ID: "failed", Status: ShipmentFailed, Items: []models.ShipmentItem{{Sku: "AIRPODS-3", Quantity: 1}}},
+	}
+
+	mockRepo := new(MockOrderRepository)
+	mockRepo.On("GetOrderById", order.ID).Return(order, nil)
+	mockRepo.On("Cancel", mock.AnythingOfType("models.Order"), order.UpdatedAt, NotCancelableStatuses).Return(true, nil)
+
+	orderService := NewOrderService(mockRepo, noPromotions())
+	result, err := orderService.Cancel(order.ID, OrderCancelRequest{Reason: CancelReasonOutOfStock,
+		Lines: []OrderCancelLineRequest{{Sku: "AIRPODS-3", Quantity: 1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every unit which is left is delivered
+	assert.Equal(t, true, result.Cancellation.Partial)
+	assert.Equal(t, DeliveredStatus, result.After.Status)
+	assert.Equal(t, DeliveredStatus, mockRepo.Calls[1].Arguments.Get(0).(models.Order).Status)
+}
+
+func TestOrderService_UpdateRefundStatus(t *testing.T) {
+	order := cancelOrder(CanceledStatus)
+	order.Refunds = []models.Refund{
+		{ID: "pending", Amount: order.Total, Currency: "TRY", Status: RefundPending},
+		{ID: "issued", Amount: order.Total, Currency: "TRY", Status: RefundIssued},
+	}
+
+	tests := map[string]struct {
+		refundId string
+		updated  bool
+		err      error
+	}{
+		"issued":              {refundId: "pending", updated: true},
+		"not-found":           {refundId: "unknown", err: ErrRefundNotFound},
+		"not-pending":         {refundId: "issued", err: ErrRefundNotPending},
+		"updated-in-meantime": {refundId: "pending", updated: false, err: ErrRefundNotPending},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockRepo := new(MockOrderRepository)
+			mockRepo.On("GetOrderById", order.ID).Return(order, nil)
+			mockRepo.On("UpdateRefundStatus", order.ID, test.refundId, RefundPending, RefundIssued, mock.AnythingOfType("time.Time")).Return(test.updated, nil)
+
+			orderService := NewOrderService(mockRepo, noPromotions())
+			updatedOrder, refund, err := orderService.UpdateRefundStatus(order.ID, test.refundId, RefundIssued)
+
+			if test.err != nil {
+				if !errors.Is(err, test.err) {
+					t.Errorf("Expected error: %v, but got: %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, RefundIssued, refund.Status)
+			assert.Equal(t, refund, updatedOrder.Refunds[0])
+			assert.Equal(t, RefundPending, order.Refunds[0].Status)
+		})
+	}
+}
+
+func TestDeriveOrderStatus(t *testing.T) {
+	// ASUS-NB-15 x 1, AIRPODS-3 x 3
+	shipment := func(status string, sku string, quantity int) models.Shipment {
+		return models.Shipment{Status: status, Items: []models.ShipmentItem{{Sku: sku, Quantity: quantity}}}
+	}
+
+	tests := map[string]struct {
+		status    string
+		shipments []models.Shipment
+		expected  string
+	}{
+		"no-shipment":  {status: NotShippedStatus, expected: NotShippedStatus},
+		"preparing":    {status: NotShippedStatus, shipments: []models.Shipment{shipment(ShipmentPreparing, "AIRPODS-3", 3)}, expected: NotShippedStatus},
+		"split":        {status: NotShippedStatus, shipments: []models.Shipment{shipment(ShipmentShipped, "AIRPODS-3", 3)}, expected: PartiallyShippedStatus},
+		"all-shipped":  {status: NotShippedStatus, shipments: []models.Shipment{shipment(ShipmentDelivered, "AIRPODS-3", 3), shipment(ShipmentShipped, "ASUS-NB-15", 1)}, expected: ShippedStatus},
+		"delivered":    {status: ShippedStatus, shipments: []models.Shipment{shipment(ShipmentDelivered, "AIRPODS-3", 3), shipment(ShipmentDelivered, "ASUS-NB-15", 1)}, expected: DeliveredStatus},
+		"failed":       {status: ShippedStatus, shipments: []models.Shipment{shipment(ShipmentShipped, "AIRPODS-3", 3), shipment(ShipmentFailed, "ASUS-NB-15", 1)}, expected: NotDeliveredStatus},
+		"failed-again": {status: NotDeliveredStatus, shipments: []models.Shipment{shipment(ShipmentShipped, "AIRPODS-3", 3), shipment(ShipmentFailed, "ASUS-NB-15", 1), shipment(ShipmentPreparing, "ASUS-NB-15", 1)}, expected: PartiallyShippedStatus},
+		"canceled":     {status: CanceledStatus, shipments: []models.Shipment{shipment(ShipmentShipped, "AIRPODS-3", 3)}, expected: CanceledStatus},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			order := promotionOrder()
+			order.Status = test.status
+			order.Shipments = test.shipments
+
+			assert.Equal(t, test.expected, DeriveOrderStatus(order))
+		})
+	}
+}
+
+func TestOrderService_CreateShipment(t *testing.T) {
+	tests := map[string]struct {
+		status   string
+		existing []models.Shipment
+		items    []ShipmentItemRequest
+		expected []models.ShipmentItem
+		err      error
+	}{
+		"every-unit": {status: NotShippedStatus,
+			expected: []models.ShipmentItem{{Sku: "ASUS-NB-15", Quantity: 1}, {Sku: "AIRPODS-3", Quantity: 3}}},
+		"split": {status: NotShippedStatus, items: []ShipmentItemRequest{{Sku: "AIRPODS-3", Quantity: 1}, {Sku: "AIRPODS-3", Quantity: 1}},
+			expected: []models.ShipmentItem{{Sku: "AIRPODS-3", Quantity: 2}}},
+		// Items of failed shipment are shipped again
+		"rest-of-order": {status: PartiallyShippedStatus, existing: []models.Shipment{
+			{Status: ShipmentShipped, Items: []models.ShipmentItem{{Sku: "AIRPODS-3", Quantity: 2}}},
+			{Status: ShipmentFailed, Items: []models.ShipmentItem{{Sku: "ASUS-NB-15", Quantity: 1}}}},
+			expected: []models.ShipmentItem{{Sku: "ASUS-NB-15", Quantity: 1}, {Sku: "AIRPODS-3", Quantity: 1}}},
+		"too-many-units": {status: PartiallyShippedStatus, existing: []models.Shipment{
+			{Status: ShipmentShipped, Items: []models.ShipmentItem{{Sku: "AIRPODS-3", Quantity: 2}}}},
+			items: []ShipmentItemRequest{{Sku: "AIRPODS-3", Quantity: 2}}, err: ErrInvalidShipmentItems},
+		"unknown-sku": {status: NotShippedStatus, items: []ShipmentItemRequest{{Sku: "IPHONE-15", Quantity: 1}}, err: ErrInvalidShipmentItems},
+		"canceled":    {status: CanceledStatus, err: ErrOrderNotShippable},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			order := cancelOrder(test.status)
+			order.Shipments = test.existing
+
+			mockRepo := new(MockOrderRepository)
+			mockRepo.On("GetOrderById", order.ID).Return(order, nil)
+			mockRepo.On("UpdateShipments", mock.AnythingOfType("models.Order"), order.UpdatedAt, notShippableStatuses).Return(true, nil)
+
+			orderService := NewOrderService(mockRepo, noPromotions())
+			change, err := orderService.CreateShipment(order.ID, ShipmentCreateRequest{Carrier: "Yurtiçi Kargo", Items: test.items})
+
+			if test.err != nil {
+				if !errors.Is(err, test.err) {
+					t.Errorf("Expected error: %v, but got: %v", test.err, err)
+				}
+				mockRepo.AssertNotCalled(t, "UpdateShipments", mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, ShipmentPreparing, change.Shipment.Status)
+			assert.Equal(t, test.expected, change.Shipment.Items)
+			assert.Equal(t, len(test.existing)+1, len(change.After.Shipments))
+			assert.Equal(t, len(test.existing), len(order.Shipments))
+		})
+	}
+}
+
+func TestOrderService_UpdateShipment(t *testing.T) {
+	tests := map[string]struct {
+		from     string
+		request  ShipmentUpdateRequest
+		tracking string
+		status   string
+		err      error
+	}{
+		"ship":         {from: ShipmentPreparing, request: ShipmentUpdateRequest{Status: ShipmentShipped, TrackingNumber: "YK123456"}, status: ShippedStatus},
+		"ship-tracked": {from: ShipmentPreparing, tracking: "YK123456", request: ShipmentUpdateRequest{Status: ShipmentShipped}, status: ShippedStatus},
+		"deliver":      {from: ShipmentShipped, tracking: "YK123456", request: ShipmentUpdateRequest{Status: ShipmentDelivered}, status: DeliveredStatus},
+		"fail":         {from: ShipmentShipped, tracking: "YK123456", request: ShipmentUpdateRequest{Status: ShipmentFailed}, status: NotDeliveredStatus},
+		"no-tracking":  {from: ShipmentPreparing, request: ShipmentUpdateRequest{Status: ShipmentShipped}, err: ErrInvalidShipmentTransition},
+		"deliver-before-ship": {from: ShipmentPreparing, request: ShipmentUpdateRequest{Status: ShipmentDelivered},
+			err: ErrInvalidShipmentTransition},
+		"delivered-is-final": {from: ShipmentDelivered, tracking: "YK123456", request: ShipmentUpdateRequest{Status: ShipmentFailed},
+			err: ErrInvalidShipmentTransition},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			order := cancelOrder(NotShippedStatus)
+			order.Shipments = []models.Shipment{{ID: "shipment", Carrier: "Aras Kargo", TrackingNumber: test.tracking, Status: test.from,
+				Items: []models.ShipmentItem{{Sku: "ASUS-NB-15", Quantity: 1}, {Sku: "AIRPODS-3", Quantity: 3}}}}
+			order.Status = DeriveOrderStatus(order)
+
+			mockRepo := new(MockOrderRepository)
+			mockRepo.On("GetOrderById", order.ID).Return(order, nil)
+			mockRepo.On("UpdateShipments", mock.AnythingOfType("models.Order"), order.UpdatedAt, notShippableStatuses).Return(true, nil)
+
+			orderService := NewOrderService(mockRepo, noPromotions())
+			change, err := orderService.UpdateShipment(order.ID, "shipment", test.request)
+
+			if test.err != nil {
+				if !errors.Is(err, test.err) {
+					t.Errorf("Expected error: %v, but got: %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, test.request.Status, change.Shipment.Status)
+			assert.Equal(t, "YK123456", change.Shipment.TrackingNumber)
+			assert.Equal(t, test.status, change.After.Status)
+			assert.Equal(t, test.request.Status == ShipmentShipped, change.Shipment.ShippedAt != nil)
+			// Order of caller is not changed
+			assert.Equal(t, test.from, order.Shipments[0].Status)
+		})
+	}
+
+	mockRepo := new(MockOrderRepository)
+	mockRepo.On("GetOrderById", "2b45ac31-6906-4e1e-82db-d9bcdbdb2143").Return(cancelOrder(NotShippedStatus), nil)
+	_, err := NewOrderService(mockRepo, noPromotions()).UpdateShipment("2b45ac31-6906-4e1e-82db-d9bcdbdb2143", "unknown",
+		ShipmentUpdateRequest{Status: ShipmentShipped})
+	if !errors.Is(err, ErrShipmentNotFound) {
+		t.Errorf("Expected error: %v, but got: %v", ErrShipmentNotFound, err)
+	}
+}
+
+func TestOrderService_FindOrders(t *testing.T) {
+	tests := map[string]struct {
+		filter OrderFilter
+		query  bson.M
+		limit  int64
+		skip   int64
+	}{
+		"default-page": {filter: OrderFilter{}, query: bson.M{}, limit: DefaultOrderPageSize},
+		"filtered": {filter: OrderFilter{Status: ShippedStatus, UserId: "fcd20a19-6171-4737-a2ed-23e293cae7b5", Sku: "AIRPODS-3", Limit: 5, Offset: 10},
+			query: bson.M{"status": ShippedStatus, "userId": "fcd20a19-6171-4737-a2ed-23e293cae7b5", "product.sku": "AIRPODS-3"}, limit: 5, skip: 10},
+		"max-page": {filter: OrderFilter{Limit: 1000, Offset: -1}, query: bson.M{}, limit: MaxOrderPageSize},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockRepo := new(MockOrderRepository)
+			mockRepo.On("FindOrders", test.query, mock.AnythingOfType("*options.FindOptions")).Return(ordersList[:1], int64(42), nil)
+
+			orderService := NewOrderService(mockRepo, noPromotions())
+			page, err := orderService.FindOrders(test.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, int64(42), page.TotalCount)
+			assert.Equal(t, ordersList[:1], page.Orders)
+			opt := mockRepo.Calls[0].Arguments.Get(1).(*options.FindOptions)
+			assert.Equal(t, test.limit, *opt.Limit)
+			assert.Equal(t, test.skip, *opt.Skip)
+		})
+	}
+}
+
+func TestOrderService_PrepareOrder(t *testing.T) {
+	userAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/fcd20a19-6171-4737-a2ed-23e293cae7b5" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(UserResponse{ID: "fcd20a19-6171-4737-a2ed-23e293cae7b5", Name: "Ayşe", Addresses: []AddressResponse{
+			{ID: "130beada-8339-4ee6-a754-725f43b8da98", Address: "Levent", City: "İstanbul", District: "Beşiktaş"},
+			{ID: "3b1a2a6e-47f4-4d3b-9a43-1c2d6f1e5a10", Address: "Kızılay", City: "Ankara", District: "Çankaya"},
+		}})
+	}))
+	defer userAPI.Close()
+	productAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		products := []ProductResponse{{Sku: "AIRPODS-3", Name: "AirPods", Price: money.MustParse("100"), Currency: "TRY",
+			TaxRate: money.MustParseRate("20"), Active: true}}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"totalItemCount": len(products), "data": products})
+	}))
+	defer productAPI.Close()
+
+	tests := map[string]struct {
+		userId         string
+		address        string
+		invoiceAddress string
+		err            error
+	}{
+		"success": {userId: "fcd20a19-6171-4737-a2ed-23e293cae7b5", address: "130beada-8339-4ee6-a754-725f43b8da98",
+			invoiceAddress: "3b1a2a6e-47f4-4d3b-9a43-1c2d6f1e5a10"},
+		"unknown-user": {userId: "c1d3f1a4-9a7b-4f0c-8d0e-2b3a4c5d6e7f", address: "130beada-8339-4ee6-a754-725f43b8da98",
+			invoiceAddress: "130beada-8339-4ee6-a754-725f43b8da98", err: ErrUserNotFound},
+		"address-of-another-user": {userId: "fcd20a19-6171-4737-a2ed-23e293cae7b5", address: "130beada-8339-4ee6-a754-725f43b8da98",
+			invoiceAddress: "9a8b7c6d-5e4f-4a3b-8c2d-1e0f9a8b7c6d", err: ErrAddressNotFound},
+	}
+
+	orderService := NewOrderService(new(MockOrderRepository), noPromotions())
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			order, err := orderService.PrepareOrder(test.userId, test.address, test.invoiceAddress,
+				[]OrderProductRequest{{Sku: "AIRPODS-3", Quantity: 2}}, userAPI.URL, productAPI.URL)
+
+			if test.err != nil {
+				if !errors.Is(err, test.err) {
+					t.Errorf("Expected error: %v, but got: %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, test.userId, order.UserId)
+			assert.Equal(t, "Beşiktaş", order.Address.District)
+			assert.Equal(t, "Çankaya", order.InvoiceAddress.District)
+			assert.Equal(t, "TRY", order.Currency)
+			assert.Equal(t, 2, order.Product[0].Quantity)
+		})
+	}
+}
+
+func TestOrderHistory(t *testing.T) {
+	order := cancelOrder(NotShippedStatus)
+	order.CreatedAt = time.Date(2023, 5, 1, 9, 0, 0, 0, time.UTC)
+	shippedAt := time.Date(2023, 5, 2, 9, 0, 0, 0, time.UTC)
+	order.Shipments = []models.Shipment{
+		{ID: "shipment", Carrier: "Aras Kargo", TrackingNumber: "AR1", Status: ShipmentShipped, ShippedAt: &shippedAt,
+			CreatedAt: time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)},
+	}
+	canceledAt := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+	order.Cancellations = []models.OrderCancellation{{ID: "cancel", Reason: CancelReasonOutOfStock, Partial: true, CanceledAt: canceledAt}}
+	order.Refunds = []models.Refund{{ID: "refund", Amount: money.MustParse("108"), Currency: "TRY", Method: RefundMethodStoreCredit,
+		Status: RefundIssued, CreatedAt: canceledAt, UpdatedAt: canceledAt}}
+
+	var events []string
+	for _, entry := range OrderHistory(order) {
+		events = append(events, entry.Event)
+	}
+
+	assert.Equal(t, []string{HistoryCreated, HistoryPartiallyCanceled, HistoryRefundCreated, HistoryRefundIssued,
+		HistoryShipmentPrepared, HistoryShipmentShipped}, events)
+	assert.Equal(t, "shipment Aras Kargo AR1", OrderHistory(order)[5].Detail)
+}
+
+// MockInvoiceRepository is a mock implementation of IInvoiceRepository
+type MockInvoiceRepository struct {
+	mock.Mock
+}
+
+func (m *MockInvoiceRepository) GetInvoiceByOrderId(orderId string) (models.Invoice, error) {
+	args := m.Called(orderId)
+	if args.Error(1) != nil {
+		return models.Invoice{}, args.Error(1)
+	}
+	return args.Get(0).(models.Invoice), nil
+}
+
+func (m *MockInvoiceRepository) Claim(invoice models.Invoice, staleBefore time.Time) (bool, error) {
+	args := m.Called(invoice, staleBefore)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockInvoiceRepository) AssignNumber(id string, number string) (bool, error) {
+	args := m.Called(id, number)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockInvoiceRepository) Issue(invoice models.Invoice, claimedStatus string) (bool, error) {
+	args := m.Called(invoice, claimedStatus)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockInvoiceRepository) NextSequence(name string, holderId string) (int64, error) {
+	args := m.Called(name, holderId)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockInvoiceRepository) ReleaseSequence(name string, holderId string) error {
+	args := m.Called(name, holderId)
+	return args.Error(0)
+}
+
+// memoryInvoiceStorage keeps rendered invoices in memory
+type memoryInvoiceStorage map[string][]byte
+
+func (s memoryInvoiceStorage) Save(name string, content []byte) error {
+	s[name] = content
+	return nil
+}
+
+func (s memoryInvoiceStorage) Open(name string) ([]byte, error) {
+	content, ok := s[name]
+	if !ok {
+		return nil, errors.New("file not found")
+	}
+	return content, nil
+}
+
+func TestInvoiceService_Generate(t *testing.T) {
+	year := time.Now().Year()
+	number := fmt.Sprintf("INV-%d-000007", year)
+	issued := models.Invoice{ID: "2b45ac31-6906-4e1e-82db-d9bcdbdb2143", Number: "INV-2023-000001", Status: InvoiceIssued}
+
+	tests := map[string]struct {
+		status   string
+		stored   []models.Invoice
+		claimed  bool
+		number   string
+		sequence bool
+		err      error
+	}{
+		"first-invoice": {status: ShippedStatus, claimed: true, number: number, sequence: true},
+		// Generation is interrupted after number is given, invoice is claimed again with its number
+		"claimed-again": {status: DeliveredStatus, claimed: true, number: "INV-2023-000005",
+			stored: []models.Invoice{{Number: "INV-2023-000005", Status: InvoiceGenerating}}},
+		"already-issued": {status: DeliveredStatus, number: issued.Number, stored: []models.Invoice{issued}},
+		"issued-by-another": {status: ShippedStatus, number: issued.Number,
+			stored: []models.Invoice{{Status: InvoiceGenerating}, issued}},
+		"in-progress":  {status: ShippedStatus, stored: []models.Invoice{{Status: InvoiceGenerating}}, err: ErrInvoiceInProgress},
+		"not-billable": {status: PartiallyShippedStatus, err: ErrInvoiceNotBillable},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			order := cancelOrder(test.status)
+			order.InvoiceAddress = models.Address{Address: "Levent", City: "İstanbul", District: "Beşiktaş"}
+
+			mockRepo := new(MockInvoiceRepository)
+			if len(test.stored) == 0 {
+				mockRepo.On("GetInvoiceByOrderId", order.ID).Return(models.Invoice{}, mongo.ErrNoDocuments).Once()
+				mockRepo.On("GetInvoiceByOrderId", order.ID).Return(models.Invoice{ID: order.ID, Status: InvoiceGenerating, CreatedAt: time.Now()}, nil)
+			}
+			for _, stored := range test.stored {
+				mockRepo.On("GetInvoiceByOrderId", order.ID).Return(stored, nil).Once()
+			}
+			if len(test.stored) == 1 {
+				mockRepo.On("GetInvoiceByOrderId", order.ID).Return(test.stored[0], nil)
+			}
+			mockRepo.On("Claim", mock.AnythingOfType("models.Invoice"), mock.AnythingOfType("time.Time")).Return(test.claimed, nil)
+			mockRepo.On("NextSequence", fmt.Sprintf("invoice-%d", year), order.ID).Return(int64(7), nil)
+			mockRepo.On("ReleaseSequence", fmt.Sprintf("invoice-%d", year), order.ID).Return(nil)
+			mockRepo.On("AssignNumber", order.ID, number).Return(true, nil)
+			mockRepo.On("Issue", mock.AnythingOfType("models.Invoice"), InvoiceGenerating).Return(true, nil)
+
+			storage := memoryInvoiceStorage{}
+			invoiceService := NewInvoiceService(mockRepo, storage, "INV", []string{ShippedStatus, DeliveredStatus}, InvoiceSeller{Name: "OrderUserProject A.Ş."})
+			invoice, err := invoiceService.Generate(order)
+
+			if test.err != nil {
+				if !errors.Is(err, test.err) {
+					t.Errorf("Expected error: %v, but got: %v", test.err, err)
+				}
+				mockRepo.AssertNotCalled(t, "Issue", mock.Anything, mock.Anything)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, test.number, invoice.Number)
+			assert.Equal(t, InvoiceIssued, invoice.Status)
+			if test.sequence {
+				mockRepo.AssertCalled(t, "AssignNumber", order.ID, number)
+				mockRepo.AssertCalled(t, "ReleaseSequence", fmt.Sprintf("invoice-%d", year), order.ID)
+			} else {
+				mockRepo.AssertNotCalled(t, "NextSequence", mock.Anything, mock.Anything)
+			}
+			if !test.claimed {
+				mockRepo.AssertNotCalled(t, "Issue", mock.Anything, mock.Anything)
+				return
+			}
+
+			// Snapshot of order is rendered and stored
+			assert.Equal(t, order.Total, invoice.Total)
+			assert.Equal(t, order.Product, invoice.Lines)
+			html, _ := invoiceService.Document(invoice, InvoiceHTML)
+			assert.Equal(t, true, strings.Contains(string(html), "Invoice "+test.number))
+			assert.Equal(t, true, strings.Contains(string(html), "Beşiktaş/İstanbul"))
+			assert.Equal(t, true, strings.Contains(string(html), order.Total.String()+" "+order.Currency))
+			document, _ := invoiceService.Document(invoice, InvoicePDF)
+			assert.Equal(t, true, bytes.HasPrefix(document, []byte("%PDF-")))
+			assert.Equal(t, true, bytes.Contains(document, []byte("Besiktas/Istanbul")))
+		})
+	}
+}
+
+// MockInventorySagaRepository is a mock implementation of IInventorySagaRepository
+type MockInventorySagaRepository struct {
+	mock.Mock
+}
+
+func (m *MockInventorySagaRepository) GetSagaById(id string) (models.InventorySaga, error) {
+	args := m.Called(id)
+	if args.Error(1) != nil {
+		return models.InventorySaga{}, args.Error(1)
+	}
+	return args.Get(0).(models.InventorySaga), nil
+}
+
+func (m *MockInventorySagaRepository) GetSagasByState(states []string, updatedBefore time.Time) ([]models.InventorySaga, error) {
+	args := m.Called(states, updatedBefore)
+	if args.Error(1) != nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.InventorySaga), nil
+}
+
+func (m *MockInventorySagaRepository) Insert(saga models.InventorySaga) (bool, error) {
+	args := m.Called(saga)
+	if args.Error(1) != nil {
+		return false, args.Error(1)
+	}
+	return true, nil
+}
+
+func (m *MockInventorySagaRepository) UpdateState(id string, fromStates []string, step models.SagaStep) (bool, error) {
+	args := m.Called(id, fromStates, step)
+	if args.Error(1) != nil {
+		return false, args.Error(1)
+	}
+	return args.Bool(0), nil
+}
+
+func (m *MockInventorySagaRepository) AddAttempt(id string, state string, updatedAt time.Time) (bool, error) {
+	args := m.Called(id, state, updatedAt)
+	if args.Error(1) != nil {
+		return false, args.Error(1)
+	}
+	return args.Bool(0), nil
+}
+
+func (m *MockInventorySagaRepository) AddAdjustment(id string, fromStates []string, lines []models.StockLine, adjustment models.StockAdjustment) (bool, error) {
+	args := m.Called(id, fromStates, lines, adjustment)
+	if args.Error(1) != nil {
+		return false, args.Error(1)
+	}
+	return args.Bool(0), nil
+}
+
+func (m *MockInventorySagaRepository) RemoveAdjustment(id string, adjustmentId string) error {
+	args := m.Called(id, adjustmentId)
+	return args.Error(0)
+}
+
+func (m *MockInventorySagaRepository) GetSagasWithPendingAdjustments(addedBefore time.Time) ([]models.InventorySaga, error) {
+	args := m.Called(addedBefore)
+	if args.Error(1) != nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.InventorySaga), nil
+}
+
+// sentCommands => Send function of saga service which records commands instead of Kafka
+func sentCommands(commands *[]events.DomainEvent) func(command events.DomainEvent) error {
+	return func(command events.DomainEvent) error {
+		*commands = append(*commands, command)
+		return nil
+	}
+}
+
+// sagaStep => matcher of saga step with state
+func sagaStep(state string) interface{} {
+	return mock.MatchedBy(func(step models.SagaStep) bool { return step.State == state })
+}
+
+func TestInventorySagaService_Start_MergesLinesAndSendsReserve(t *testing.T) {
+	order := ordersList[0]
+	order.Product = []models.OrderProduct{{Sku: "ASUS-NB-15", Quantity: 1}, {Sku: "APPLE-AIRPODS-2", Quantity: 1}, {Sku: "ASUS-NB-15", Quantity: 2}}
+
+	// Create a mock instance
+	mockSagaRepo := new(MockInventorySagaRepository)
+	mockSagaRepo.On("Insert", mock.AnythingOfType("models.InventorySaga")).Return(true, nil)
+
+	var commands []events.DomainEvent
+	sagaService := NewInventorySagaService(mockSagaRepo, new(MockOrderRepository), noPromotions(), sentCommands(&commands))
+
+	if err := sagaService.Start(order); err != nil {
+		t.Error(err)
+	}
+
+	// Saga id is the order id, lines with the same sku are reserved together
+	saga := mockSagaRepo.Calls[0].Arguments.Get(0).(models.InventorySaga)
+	assert.Equal(t, order.ID, saga.ID)
+	assert.Equal(t, SagaReserving, saga.State)
+	assert.Equal(t, []models.StockLine{{Sku: "ASUS-NB-15", Quantity: 3}, {Sku: "APPLE-AIRPODS-2", Quantity: 1}}, saga.Lines)
+
+	assert.Equal(t, 1, len(commands))
+	assert.Equal(t, events.ReserveStockType, commands[0].Type)
+	assert.Equal(t, order.ID, commands[0].Key)
+}
+
+func TestInventorySagaService_HandleReply_ReservedAndFailed(t *testing.T) {
+	order := ordersList[0]
+	saga := models.InventorySaga{ID: order.ID, OrderID: order.ID, State: SagaReserving}
+
+	results := map[string]struct {
+		reply    events.DomainEvent
+		state    string
+		next     string
+		canceled bool
+		commands int
+	}{
+		"reserved":              {events.NewStockReserved(saga.ID, order.ID), SagaReserving, SagaReserved, false, 0},
+		"failed-compensation":   {events.NewStockReservationFailed(saga.ID, order.ID, "not enough stock", []string{"ASUS-NB-15"}), SagaReserving, SagaFailed, true, 0},
+		"failed-after-release":  {events.NewStockReservationFailed(saga.ID, order.ID, "not enough stock", nil), SagaReleasing, SagaReleased, false, 0},
+		"reserved-after-cancel": {events.NewStockReserved(saga.ID, order.ID), SagaReleasing, "", false, 1},
+	}
+
+	for name, result := range results {
+		// Create mock instances
+		mockSagaRepo := new(MockInventorySagaRepository)
+		mockOrderRepo := new(MockOrderRepository)
+
+		currentSaga := saga
+		currentSaga.State = result.state
+		mockSagaRepo.On("GetSagaById", saga.ID).Return(currentSaga, nil)
+		mockSagaRepo.On("UpdateState", saga.ID, mock.Anything, mock.Anything).Return(true, nil)
+		mockOrderRepo.On("GetOrderById", order.ID).Return(order, nil)
+		mockOrderRepo.On("Cancel", mock.AnythingOfType("models.Order"), order.UpdatedAt, ClosedOrderStatuses).Return(true, nil)
+
+		var commands []events.DomainEvent
+		sagaService := NewInventorySagaService(mockSagaRepo, mockOrderRepo, noPromotions(), sentCommands(&commands))
+
+		envelope, err := result.reply.Envelope()
+		if err != nil {
+			t.Fatal(err)
+		}
+		change, err := sagaService.HandleReply(envelope)
+		if err != nil {
+			t.Errorf("%v: %v", name, err)
+		}
+
+		if result.next != "" {
+			mockSagaRepo.AssertCalled(t, "UpdateState", saga.ID, []string{result.state}, sagaStep(result.next))
+		} else {
+			mockSagaRepo.AssertNotCalled(t, "UpdateState", saga.ID, mock.Anything, mock.Anything)
+		}
+
+		// Failed reservation cancels the order (compensation)
+		assert.Equal(t, result.canceled, change != nil)
+		if result.canceled {
+			assert.Equal(t, CanceledStatus, change.After.Status)
+			assert.Equal(t, CancelReasonOutOfStock, change.Cancellation.Reason)
+			assert.Equal(t, "not enough stock", change.Cancellation.Note)
+			assert.Equal(t, order.Total, change.Refund.Amount)
+		}
+		assert.Equal(t, result.commands, len(commands))
+	}
+}
+
+func TestInventorySagaService_RetryWaiting_ResendAndGiveUp(t *testing.T) {
+	order := ordersList[0]
+	waitingSagas := []models.InventorySaga{
+		{ID: "c8a5e1f2-0d7b-4b3e-9f4a-1e2d3c4b5a69", OrderID: "c8a5e1f2-0d7b-4b3e-9f4a-1e2d3c4b5a69", State: SagaCommitting, Attempts: 10},
+		{ID: order.ID, OrderID: order.ID, State: SagaReserving, Attempts: 5,
+			Lines: []models.StockLine{{Sku: "ASUS-NB-15", Quantity: 1}}},
+	}
+
+	// Create mock instances
+	mockSagaRepo := new(MockInventorySagaRepository)
+	mockOrderRepo := new(MockOrderRepository)
+
+	mockSagaRepo.On("GetSagasWithPendingAdjustments", mock.AnythingOfType("time.Time")).Return([]models.InventorySaga{}, nil)
+	mockSagaRepo.On("GetSagasByState", WaitingSagaStates, mock.AnythingOfType("time.Time")).Return(waitingSagas, nil)
+	mockSagaRepo.On("AddAttempt", waitingSagas[0].ID, SagaCommitting, mock.AnythingOfType("time.Time")).Return(true, nil)
+	mockSagaRepo.On("UpdateState", order.ID, []string{SagaReserving}, sagaStep(SagaReleasing)).Return(true, nil)
+	mockOrderRepo.On("GetOrderById", order.ID).Return(order, nil)
+	mockOrderRepo.On("Cancel", mock.AnythingOfType("models.Order"), order.UpdatedAt, ClosedOrderStatuses).Return(true, nil)
+
+	var commands []events.DomainEvent
+	sagaService := NewInventorySagaService(mockSagaRepo, mockOrderRepo, noPromotions(), sentCommands(&commands))
+
+	changes, err := sagaService.RetryWaiting(30*time.Second, 5)
+	if err != nil {
+		t.Error(err)
+	}
+
+	// Commit is sent again until product-api replies, reservation is given up after max attempts
+	assert.Equal(t, 2, len(commands))
+	assert.Equal(t, events.CommitStockType, commands[0].Type)
+	assert.Equal(t, events.ReleaseStockType, commands[1].Type)
+	assert.Equal(t, 1, len(changes))
+	assert.Equal(t, order.ID, changes[0].After.ID)
+
+	mockSagaRepo.AssertExpectations(t)
+	mockOrderRepo.AssertExpectations(t)
+}
+
+func TestInventorySagaService_Adjust_PartialCancelThenShip(t *testing.T) {
+	order := ordersList[0]
+	saga := models.InventorySaga{ID: order.ID, OrderID: order.ID, State: SagaReserved,
+		Lines: []models.StockLine{{Sku: "ASUS-NB-15", Quantity: 1}, {Sku: "APPLE-AIRPODS-2", Quantity: 3}}}
+	canceled := []models.CanceledLine{{Sku: "APPLE-AIRPODS-2", Quantity: 2}, {Sku: "ASUS-NB-15", Quantity: 1}}
+	adjustmentId := "5d0b1c8e-3f2a-4e6b-9c7d-8a1b2c3d4e5f"
+
+	// Create a mock instance
+	mockSagaRepo := new(MockInventorySagaRepository)
+	mockSagaRepo.On("GetSagaById", saga.ID).Return(saga, nil)
+	mockSagaRepo.On("AddAdjustment", saga.ID, AdjustableSagaStates, mock.Anything, mock.AnythingOfType("models.StockAdjustment")).Return(true, nil)
+	mockSagaRepo.On("UpdateState", saga.ID, []string{SagaReserved}, sagaStep(SagaCommitting)).Return(true, nil)
+	mockSagaRepo.On("RemoveAdjustment", saga.ID, adjustmentId).Return(nil)
+
+	var commands []events.DomainEvent
+	sagaService := NewInventorySagaService(mockSagaRepo, new(MockOrderRepository), noPromotions(), sentCommands(&commands))
+
+	if err := sagaService.Adjust(order.ID, adjustmentId, canceled); err != nil {
+		t.Error(err)
+	}
+
+	// Canceled quantities are given back, saga keeps the remaining lines
+	lines := mockSagaRepo.Calls[1].Arguments.Get(2).([]models.StockLine)
+	assert.Equal(t, []models.StockLine{{Sku: "APPLE-AIRPODS-2", Quantity: 1}}, lines)
+	assert.Equal(t, 1, len(commands))
+	assert.Equal(t, events.AdjustStockType, commands[0].Type)
+	adjust := commands[0].Payload.(events.AdjustStock)
+	assert.Equal(t, adjustmentId, adjust.AdjustmentID)
+	assert.Equal(t, []events.StockLine{{Sku: "APPLE-AIRPODS-2", Quantity: 2}, {Sku: "ASUS-NB-15", Quantity: 1}}, adjust.Lines)
+
+	// Shipping the rest commits the reservation
+	if err := sagaService.Commit(order.ID); err != nil {
+		t.Error(err)
+	}
+	assert.Equal(t, 2, len(commands))
+	assert.Equal(t, events.CommitStockType, commands[1].Type)
+
+	// Reply removes the pending adjustment
+	envelope, err := events.NewStockAdjusted(saga.ID, order.ID, adjustmentId).Envelope()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sagaService.HandleReply(envelope); err != nil {
+		t.Error(err)
+	}
+
+	mockSagaRepo.AssertExpectations(t)
+}
+
+func TestInventorySagaService_CheckLines(t *testing.T) {
+	order := ordersList[0]
+	order.Product = []models.OrderProduct{{Sku: "APPLE-AIRPODS-2", Quantity: 1}, {Sku: "ASUS-NB-15", Quantity: 1}, {Sku: "APPLE-AIRPODS-2", Quantity: 2}}
+
+	tests := map[string]struct {
+		state string
+		lines []models.StockLine
+		err   error
+	}{
+		"same-lines":     {state: SagaReserved, lines: []models.StockLine{{Sku: "ASUS-NB-15", Quantity: 1}, {Sku: "APPLE-AIRPODS-2", Quantity: 3}}},
+		"reserving":      {state: SagaReserving, lines: []models.StockLine{{Sku: "ASUS-NB-15", Quantity: 1}}, err: ErrStockLinesReserved},
+		"reserved":       {state: SagaReserved, lines: []models.StockLine{{Sku: "ASUS-NB-15", Quantity: 1}, {Sku: "APPLE-AIRPODS-2", Quantity: 1}}, err: ErrStockLinesReserved},
+		"released":       {state: SagaReleased, lines: []models.StockLine{{Sku: "ASUS-NB-15", Quantity: 1}}},
+		"without-saga":   {},
+		"saga-read-fail": {err: mongo.ErrClientDisconnected},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockSagaRepo := new(MockInventorySagaRepository)
+			switch name {
+			case "without-saga":
+				mockSagaRepo.On("GetSagaById", order.ID).Return(models.InventorySaga{}, mongo.ErrNoDocuments)
+			case "saga-read-fail":
+				mockSagaRepo.On("GetSagaById", order.ID).Return(models.InventorySaga{}, test.err)
+			default:
+				mockSagaRepo.On("GetSagaById", order.ID).Return(models.InventorySaga{ID: order.ID, State: test.state, Lines: test.lines}, nil)
+			}
+			sagaService := NewInventorySagaService(mockSagaRepo, new(MockOrderRepository), noPromotions(), sentCommands(&[]events.DomainEvent{}))
+
+			err := sagaService.CheckLines(order)
+			if !errors.Is(err, test.err) {
+				t.Errorf("Expected error: %v, but got: %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestOrderChangeLog(t *testing.T) {
+	changeLog := NewOrderChangeLog(3)
+	order := ordersList[0]
+	userId := order.UserId
+
+	// Changes before stream are not sent to it
+	changeLog.Append(OrderChange{Type: OrderChangeCreated, OrderID: order.ID, UserId: userId, Status: NotShippedStatus, Order: &order})
+	ctx, cancel := context.WithCancel(context.Background())
+	missed, changes, complete := changeLog.Subscribe(ctx, OrderChangeFilter{UserId: userId}, "")
+	assert.Equal(t, 0, len(missed))
+	assert.Equal(t, true, complete)
+
+	// Changes of other users are filtered, deleted order gets user and status of its previous change
+	changeLog.Append(OrderChange{Type: OrderChangeUpdated, OrderID: "other", UserId: "other-user", Status: NotShippedStatus})
+	changeLog.Append(OrderChange{Type: OrderChangeDeleted, OrderID: order.ID})
+	deleted := <-changes
+	assert.Equal(t, OrderChange{ID: 3, Epoch: changeLog.epoch, Type: OrderChangeDeleted, OrderID: order.ID, UserId: userId, Status: NotShippedStatus, At: deleted.At}, deleted)
+	assert.Equal(t, changeLog.epoch+"-3", deleted.EventID())
+
+	// Channel is closed when stream is closed
+	cancel()
+	_, ok := <-changes
+	assert.Equal(t, false, ok)
+
+	// Log keeps changes 3, 4 and 5 after the fifth change
+	changeLog.Append(OrderChange{Type: OrderChangeCreated, OrderID: "another", UserId: "other-user"})
+	changeLog.Append(OrderChange{Type: OrderChangeUpdated, OrderID: "another", UserId: "other-user"})
+
+	tests := []struct {
+		name        string
+		lastEventID string
+		missed      []int64
+		complete    bool
+	}{
+		{name: "resume", lastEventID: changeLog.epoch + "-2", missed: []int64{3}, complete: true},
+		{name: "up to date", lastEventID: changeLog.epoch + "-5", missed: nil, complete: true},
+		{name: "change is not in log anymore", lastEventID: changeLog.epoch + "-1", missed: []int64{3}, complete: false},
+		{name: "id after last change", lastEventID: changeLog.epoch + "-99", missed: []int64{3}, complete: false},
+		// Same sequence of another instance (or of this instance before restart) is not the same change
+		{name: "id of another epoch", lastEventID: "kx2f9a0c1-2", missed: []int64{3}, complete: false},
+		{name: "id without epoch", lastEventID: "2", missed: []int64{3}, complete: false},
+	}
+
+	// Malformed event id is rejected by stream
+	if _, _, err := ParseOrderEventID(changeLog.epoch + "-x"); err == nil {
+		t.Error("Expected error of malformed event id")
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			missed, _, complete := changeLog.Subscribe(context.Background(), OrderChangeFilter{UserId: userId}, test.lastEventID)
+			var ids []int64
+			for _, change := range missed {
+				ids = append(ids, change.ID)
+			}
+			assert.Equal(t, test.missed, ids)
+			assert.Equal(t, test.complete, complete)
+		})
+	}
+}
+
+func TestOrderChangeLog_DeletedOrderNotInLog(t *testing.T) {
+	order := ordersList[0]
+	changeLog := NewOrderChangeLog(3)
+	_, changes, _ := changeLog.Subscribe(context.Background(), OrderChangeFilter{UserId: order.UserId, Status: order.Status}, "")
+
+	// Order is created before log (e.g. before restart), 'OrderChanged' event of thin mode carries deleted order
+	envelope, err := NewOrderChangedEnvelope(order.ID, OrderChangeDeleted, &order, OrderEventModeThin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := events.Validate(envelope); err != nil {
+		t.Fatal(err)
+	}
+	var orderChanged events.OrderChanged
+	if err := envelope.DecodePayload(&orderChanged); err != nil {
+		t.Fatal(err)
+	}
+	changeLog.Append(NewDeletedOrderChange(orderChanged))
+
+	select {
+	case deleted := <-changes:
+		assert.Equal(t, OrderChangeDeleted, deleted.Type)
+		assert.Equal(t, order.ID, deleted.OrderID)
+		assert.Equal(t, order.UserId, deleted.UserId)
+		assert.Equal(t, order.Status, deleted.Status)
+	default:
+		t.Error("Expected deleted order in stream of its user and status")
+	}
+}
+
+func TestElasticService_SearchOrders(t *testing.T) {
+	// Fake Elasticsearch, request body of search is recorded
+	var searchBody map[string]interface{}
+	elastic := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		_ = json.NewDecoder(r.Body).Decode(&searchBody)
+		_, _ = w.Write([]byte(`{"hits": {"total": {"value": 1, "relation": "eq"}, "hits": [{"_id": "o1", "_score": 2.5,
+			"_source": {"id": "o1", "status": "Shipped"}, "highlight": {"address.city": ["<em>İzmir</em>"]}}]}}`))
+	}))
+	defer elastic.Close()
+
+	config := configs.GetConfig("test")
+	config.Elasticsearch.Addresses = map[string]string{"Address 1": elastic.URL}
+	elasticService := NewElasticService(&config)
+
+	// Page size is never more than MaxOrderPageSize
+	result, err := elasticService.SearchOrders("izmir ayakabı", 500, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, OrderSearchResult{TotalCount: 1, Limit: MaxOrderPageSize, Offset: 0, Hits: []OrderSearchHit{{Score: 2.5,
+		Highlight: map[string][]string{"address.city": {"<em>İzmir</em>"}},
+		Order:     map[string]interface{}{"id": "o1", "status": "Shipped"}}}}, result)
+
+	// Words with typos match, every search field is highlighted
+	multiMatch := searchBody["query"].(map[string]interface{})["multi_match"].(map[string]interface{})
+	assert.Equal(t, "izmir ayakabı", multiMatch["query"])
+	assert.Equal(t, "AUTO", multiMatch["fuzziness"])
+	assert.Equal(t, len(orderSearchFields), len(searchBody["highlight"].(map[string]interface{})["fields"].(map[string]interface{})))
+	assert.Equal(t, float64(MaxOrderPageSize), searchBody["size"])
+	assert.Equal(t, float64(0), searchBody["from"])
+}
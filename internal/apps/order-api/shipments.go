@@ -0,0 +1,243 @@
+package order_api
+
+import (
+	"OrderUserProject/internal/models"
+	"fmt"
+	"github.com/google/uuid"
+	"time"
+)
+
+// notShippableStatuses => shipments of canceled or closed orders cannot be created or changed
+var notShippableStatuses = []string{CanceledStatus, ClosedStatus}
+
+// shipmentTransitions => next statuses of shipment, Delivered and Failed are final
+var shipmentTransitions = map[string][]string{
+	ShipmentPreparing: {ShipmentShipped, ShipmentFailed},
+	ShipmentShipped:   {ShipmentDelivered, ShipmentFailed},
+}
+
+// CreateShipment => a shipment is prepared with items of order which are not in another shipment (items of failed
+// shipments can be shipped again). Without items every unit which is left is added. Status of order is derived again.
+func (b *OrderService) CreateShipment(orderId string, request ShipmentCreateRequest) (ShipmentChange, error) {
+	before, err := b.OrderRepository.GetOrderById(orderId)
+	if err != nil {
+		return ShipmentChange{}, err
+	}
+	if isOneOf(before.Status, notShippableStatuses) {
+		return ShipmentChange{}, fmt.Errorf("%w: order is %v", ErrOrderNotShippable, before.Status)
+	}
+
+	items, err := shipmentItems(UnshippedQuantities(before), before.Product, request.Items)
+	if err != nil {
+		return ShipmentChange{}, err
+	}
+
+	now := time.Now()
+	shipment := models.Shipment{
+		ID:             uuid.New().String(),
+		Carrier:        request.Carrier,
+		TrackingNumber: request.TrackingNumber,
+		Status:         ShipmentPreparing,
+		Items:          items,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+
+	after := before
+	after.Shipments = append(append([]models.Shipment(nil), before.Shipments...), shipment)
+	return b.saveShipments(before, after, shipment, now)
+}
+
+// UpdateShipment => status (and carrier or tracking number) of shipment is changed, status of order is derived again.
+// Shipment cannot ship without tracking number.
+func (b *OrderService) UpdateShipment(orderId string, shipmentId string, request ShipmentUpdateRequest) (ShipmentChange, error) {
+	before, err := b.OrderRepository.GetOrderById(orderId)
+	if err != nil {
+		return ShipmentChange{}, err
+	}
+	if isOneOf(before.Status, notShippableStatuses) {
+		return ShipmentChange{}, fmt.Errorf("%w: order is %v", ErrOrderNotShippable, before.Status)
+	}
+
+	index := -1
+	for i, shipment := range before.Shipments {
+		if shipment.ID == shipmentId {
+			index = i
+		}
+	}
+	if index < 0 {
+		return ShipmentChange{}, fmt.Errorf("%w: %v", ErrShipmentNotFound, shipmentId)
+	}
+
+	after := before
+	after.Shipments = append([]models.Shipment(nil), before.Shipments...)
+	shipment := &after.Shipments[index]
+
+	if !isOneOf(request.Status, shipmentTransitions[shipment.Status]) {
+		return ShipmentChange{}, fmt.Errorf("%w: %v shipment cannot be %v", ErrInvalidShipmentTransition, shipment.Status, request.Status)
+	}
+	if request.Carrier != "" {
+		shipment.Carrier = request.Carrier
+	}
+	if request.TrackingNumber != "" {
+		shipment.TrackingNumber = request.TrackingNumber
+	}
+	if request.Status == ShipmentShipped && shipment.TrackingNumber == "" {
+		return ShipmentChange{}, fmt.Errorf("%w: tracking number is required to ship", ErrInvalidShipmentTransition)
+	}
+
+	now := time.Now()
+	shipment.Status = request.Status
+	shipment.UpdatedAt = now
+	switch request.Status {
+	case ShipmentShipped:
+		shipment.ShippedAt = &now
+	case ShipmentDelivered:
+		shipment.DeliveredAt = &now
+	}
+
+	return b.saveShipments(before, after, *shipment, now)
+}
+
+// saveShipments => shipments and derived status of order are saved if order isn't changed after it is read
+func (b *OrderService) saveShipments(before models.Order, after models.Order, shipment models.Shipment, now time.Time) (ShipmentChange, error) {
+	after.Status = DeriveOrderStatus(after)
+	after.UpdatedAt = now
+
+	result, err := b.OrderRepository.UpdateShipments(after, before.UpdatedAt, notShippableStatuses)
+	if err != nil {
+		return ShipmentChange{}, err
+	}
+	if result == false {
+		return ShipmentChange{}, fmt.Errorf("%w: order is changed, please try again", ErrOrderNotShippable)
+	}
+
+	return ShipmentChange{Before: before, After: after, Shipment: shipment}, nil
+}
+
+// DeriveOrderStatus => status of order from its shipments. Every unit delivered => Delivered, every unit shipped =>
+// Shipped, a failed shipment whose items are not shipped again => Not Delivered, some units shipped => Partially
+// Shipped, otherwise Not Shipped. Status of canceled or closed order is not changed.
+func DeriveOrderStatus(order models.Order) string {
+	if isOneOf(order.Status, notShippableStatuses) {
+		return order.Status
+	}
+
+	var units, shipped, delivered, covered int
+	failed := false
+	for _, line := range order.Product {
+		units += line.Quantity
+	}
+	for _, shipment := range order.Shipments {
+		quantity := 0
+		for _, item := range shipment.Items {
+			quantity += item.Quantity
+		}
+
+		switch shipment.Status {
+		case ShipmentFailed:
+			failed = true
+			continue
+		case ShipmentDelivered:
+			delivered += quantity
+			shipped += quantity
+		case ShipmentShipped:
+			shipped += quantity
+		}
+		covered += quantity
+	}
+
+	switch {
+	case units > 0 && delivered >= units:
+		return DeliveredStatus
+	case units > 0 && shipped >= units:
+		return ShippedStatus
+	case failed && covered < units:
+		return NotDeliveredStatus
+	case shipped > 0:
+		return PartiallyShippedStatus
+	}
+	return NotShippedStatus
+}
+
+// UnshippedQuantities => quantities of skus which are not in a shipment (items of failed shipments are not shipped)
+func UnshippedQuantities(order models.Order) map[string]int {
+	quantities := map[string]int{}
+	for _, line := range order.Product {
+		quantities[line.Sku] += line.Quantity
+	}
+	for _, shipment := range order.Shipments {
+		if shipment.Status == ShipmentFailed {
+			continue
+		}
+		for _, item := range shipment.Items {
+			quantities[item.Sku] -= item.Quantity
+		}
+	}
+	return quantities
+}
+
+// CheckUpdatable => lines of closed orders (ClosedOrderStatuses) cannot change, their refund or invoice is issued
+// already. Contents of order cannot change after its shipment is prepared either. REST API and GraphQL share it.
+func CheckUpdatable(order models.Order) error {
+	if isOneOf(order.Status, ClosedOrderStatuses) {
+		return fmt.Errorf("%w: %v order cannot be updated", ErrOrderClosed, order.Status)
+	}
+	if HasActiveShipments(order) {
+		return fmt.Errorf("%w: order with shipments cannot be updated", ErrOrderNotShippable)
+	}
+	return nil
+}
+
+// HasActiveShipments => order has a shipment which is not failed, contents of such orders cannot change anymore
+func HasActiveShipments(order models.Order) bool {
+	for _, shipment := range order.Shipments {
+		if shipment.Status != ShipmentFailed {
+			return true
+		}
+	}
+	return false
+}
+
+// shipmentItems => requested items (merged by sku) or every unit which is left in the order of lines
+func shipmentItems(unshipped map[string]int, lines []models.OrderProduct, requested []ShipmentItemRequest) ([]models.ShipmentItem, error) {
+	var items []models.ShipmentItem
+
+	if len(requested) == 0 {
+		for _, line := range lines {
+			if unshipped[line.Sku] > 0 {
+				items = append(items, models.ShipmentItem{Sku: line.Sku, Quantity: unshipped[line.Sku]})
+				unshipped[line.Sku] = 0
+			}
+		}
+		if len(items) == 0 {
+			return nil, fmt.Errorf("%w: every unit of order is already in a shipment", ErrInvalidShipmentItems)
+		}
+		return items, nil
+	}
+
+	indexes := map[string]int{}
+	for _, item := range requested {
+		if i, ok := indexes[item.Sku]; ok {
+			items[i].Quantity += item.Quantity
+			continue
+		}
+		indexes[item.Sku] = len(items)
+		items = append(items, models.ShipmentItem{Sku: item.Sku, Quantity: item.Quantity})
+	}
+	for _, item := range items {
+		if item.Quantity > unshipped[item.Sku] {
+			return nil, fmt.Errorf("%w: %v units of %v cannot be shipped, %v units are left", ErrInvalidShipmentItems, item.Quantity, item.Sku, unshipped[item.Sku])
+		}
+	}
+	return items, nil
+}
+
+func isOneOf(value string, list []string) bool {
+	for _, item := range list {
+		if value == item {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,167 @@
+package order_api
+
+import (
+	"OrderUserProject/internal/models"
+	"OrderUserProject/pkg/money"
+	"fmt"
+)
+
+// CalculateTotals => amounts of order lines and order from price, quantity and VAT rate of lines. Order discount is
+// allocated to lines by their amount (discount of buy X get Y promotions to lines of their sku), VAT of every line is
+// calculated after discount and rounded, so order tax is the sum of line VATs and lines add up to the grand total
+// without shipping. Every amount is calculated again, amounts on order (except discount and shipping) are not used.
+func CalculateTotals(order *models.Order) error {
+	// Lines are copied, lines of caller's order are not changed
+	order.Product = append([]models.OrderProduct(nil), order.Product...)
+
+	lineAmounts := make([]money.Amount, len(order.Product))
+	for i, line := range order.Product {
+		lineAmounts[i] = line.Price.Mul(line.Quantity)
+	}
+
+	subtotal := money.Sum(lineAmounts...)
+	if order.Discount.IsNegative() || order.Discount.Cmp(subtotal) > 0 {
+		return fmt.Errorf("%w: discount is %v, subtotal is %v", ErrInvalidDiscount, order.Discount, subtotal)
+	}
+
+	discounts := allocateDiscount(*order, lineAmounts)
+
+	var tax money.Amount
+	for i := range order.Product {
+		line := &order.Product[i]
+		taxable := lineAmounts[i].Sub(discounts[i])
+
+		line.Discount = discounts[i]
+		line.Tax = line.TaxRate.Of(taxable)
+		line.Total = taxable.Add(line.Tax)
+		tax = tax.Add(line.Tax)
+	}
+
+	order.Subtotal = subtotal
+	order.Tax = tax
+	order.Total = subtotal.Sub(order.Discount).Add(tax).Add(order.Shipping)
+	return nil
+}
+
+// allocateDiscount => discount of promotions with sku is allocated to lines of sku (if they are still on order), rest
+// of order discount is allocated to lines by their amount which is left
+func allocateDiscount(order models.Order, lineAmounts []money.Amount) []money.Amount {
+	discounts := make([]money.Amount, len(lineAmounts))
+	rest := order.Discount
+
+	for _, promotion := range order.Promotions {
+		if promotion.Sku == "" {
+			continue
+		}
+		weights := make([]money.Amount, len(lineAmounts))
+		for i, line := range order.Product {
+			if line.Sku == promotion.Sku {
+				weights[i] = lineAmounts[i]
+			}
+		}
+		if money.Sum(weights...).Cmp(promotion.Amount) < 0 || rest.Cmp(promotion.Amount) < 0 {
+			continue
+		}
+		for i, part := range promotion.Amount.Allocate(weights) {
+			discounts[i] = discounts[i].Add(part)
+		}
+		rest = rest.Sub(promotion.Amount)
+	}
+
+	left := make([]money.Amount, len(lineAmounts))
+	for i := range lineAmounts {
+		left[i] = lineAmounts[i].Sub(discounts[i])
+	}
+	for i, part := range rest.Allocate(left) {
+		discounts[i] = discounts[i].Add(part)
+	}
+	return discounts
+}
+
+// RecalculateTotals => amounts of every order are calculated again from its lines and orders with different amounts
+// are repaired, unless it is a dry run. Orders without currency (before pricing) get the default currency. Returns
+// report of differences and the repaired orders. Orders are read in batches of recalculateBatchSize.
+func (b *OrderService) RecalculateTotals(defaultCurrency string, dryRun bool) (RecalculationReport, []models.Order, error) {
+	report := RecalculationReport{DryRun: dryRun, Diffs: []TotalsDiff{}}
+
+	var repaired []models.Order
+	lastId := ""
+	for {
+		orders, err := b.OrderRepository.GetOrdersAfter(lastId, recalculateBatchSize)
+		if err != nil {
+			return report, repaired, err
+		}
+		if len(orders) == 0 {
+			return report, repaired, nil
+		}
+		lastId = orders[len(orders)-1].ID
+
+		for _, order := range orders {
+			if err := b.recalculateOrder(&report, &repaired, order, defaultCurrency, dryRun); err != nil {
+				return report, repaired, err
+			}
+		}
+	}
+}
+
+// recalculateOrder => amounts of order are calculated again, difference is added to report and repaired order to
+// repaired orders
+func (b *OrderService) recalculateOrder(report *RecalculationReport, repaired *[]models.Order, order models.Order, defaultCurrency string, dryRun bool) error {
+	report.Checked++
+
+	calculated := order
+	if calculated.Currency == "" {
+		calculated.Currency = defaultCurrency
+	}
+	if err := CalculateTotals(&calculated); err != nil {
+		report.Diffs = append(report.Diffs, TotalsDiff{OrderID: order.ID, Before: totalsOf(order), Error: err.Error()})
+		return nil
+	}
+
+	linesChanged := !sameLines(order.Product, calculated.Product)
+	if totalsOf(order) == totalsOf(calculated) && !linesChanged {
+		return nil
+	}
+
+	diff := TotalsDiff{OrderID: order.ID, Before: totalsOf(order), After: totalsOf(calculated), LinesChanged: linesChanged}
+	report.Changed++
+
+	if !dryRun {
+		// Order which is updated after it is read is skipped, its totals are calculated by that update
+		result, err := b.OrderRepository.UpdateTotals(calculated)
+		if err != nil {
+			return err
+		}
+		if result {
+			diff.Repaired = true
+			report.Repaired++
+			*repaired = append(*repaired, calculated)
+		}
+	}
+
+	report.Diffs = append(report.Diffs, diff)
+	return nil
+}
+
+func totalsOf(order models.Order) OrderTotals {
+	return OrderTotals{
+		Currency: order.Currency,
+		Subtotal: order.Subtotal,
+		Discount: order.Discount,
+		Tax:      order.Tax,
+		Shipping: order.Shipping,
+		Total:    order.Total,
+	}
+}
+
+func sameLines(a []models.OrderProduct, b []models.OrderProduct) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
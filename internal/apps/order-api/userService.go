@@ -0,0 +1,87 @@
+package order_api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/labstack/gommon/log"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// UserService => client of user-api, GraphQL resolves users of orders with it
+type UserService struct {
+	UserURL string
+}
+
+// IUserService to use for test or
+type IUserService interface {
+	GetUser(userId string) (UserResponse, error)
+	GetUsersByIds(ids []string) ([]UserResponse, error)
+}
+
+func NewUserService(userURL string) IUserService {
+	return &UserService{UserURL: userURL}
+}
+
+// GetUser => user of id, deleted user cannot be found
+func (b *UserService) GetUser(userId string) (UserResponse, error) {
+	// Create a new HTTP client with a timeout (to check user)
+	client := http.Client{
+		Timeout: time.Second * 20,
+	}
+
+	// Send a GET request to the User service to retrieve user information
+	respUser, err := client.Get(b.UserURL + "/" + userId)
+	if err != nil || respUser.StatusCode != http.StatusOK {
+		return UserResponse{}, errors.New("user cannot find")
+	}
+	defer func() {
+		if err := respUser.Body.Close(); err != nil {
+			log.Errorf("Something went wrong: %v", err)
+		}
+	}()
+
+	var userResponse UserResponse
+	if err := json.NewDecoder(respUser.Body).Decode(&userResponse); err != nil {
+		return UserResponse{}, err
+	}
+
+	return userResponse, nil
+}
+
+// GetUsersByIds => users of ids with one request, unknown or deleted users are not in result
+func (b *UserService) GetUsersByIds(ids []string) ([]UserResponse, error) {
+	client := http.Client{
+		Timeout: time.Second * 20,
+	}
+
+	query := url.Values{}
+	for _, id := range ids {
+		query.Add("id", id)
+	}
+
+	respUsers, err := client.Get(b.UserURL + "?" + query.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := respUsers.Body.Close(); err != nil {
+			log.Errorf("Something went wrong: %v", err)
+		}
+	}()
+
+	if respUsers.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("users cannot get from user-api, status code: %v", respUsers.StatusCode)
+	}
+
+	var usersResponse struct {
+		Data []UserResponse `json:"data"`
+	}
+	if err := json.NewDecoder(respUsers.Body).Decode(&usersResponse); err != nil {
+		return nil, err
+	}
+
+	return usersResponse.Data, nil
+}
@@ -2,23 +2,45 @@ package order_elastic
 
 import (
 	"OrderUserProject/internal/configs"
+	"OrderUserProject/internal/events"
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"github.com/elastic/go-elasticsearch/v7"
 	"github.com/elastic/go-elasticsearch/v7/esapi"
 	"github.com/neko-neko/echo-logrus/v2/log"
+	"net/http"
+	"time"
+)
+
+// Sync sources of order-elastic (configs.Config.OrderSync.Source)
+const (
+	SyncSourceKafka        = "kafka"
+	SyncSourceChangeStream = "changestream"
 )
 
 type OrderElasticService struct {
 }
 
-func NewOrderElasticService() *OrderElasticService {
+func NewOrderElasticService() IOrderElasticService {
 	orderElasticService := &OrderElasticService{}
 	return orderElasticService
 }
 
-func (b *OrderElasticService) SaveOrderToElasticsearch(order OrderResponse, config configs.Config) error {
+type IOrderElasticService interface {
+	EnsureOrderIndex(config configs.Config) error
+	SaveOrderToElasticsearch(order events.Order, config configs.Config) error
+	DeleteOrderFromElasticsearch(orderID string, config configs.Config) error
+}
+
+// orderDocument => order on es, name of user is indexed for full-text search of order-api
+type orderDocument struct {
+	events.Order
+	UserName string `json:"userName,omitempty"`
+}
+
+func (b *OrderElasticService) SaveOrderToElasticsearch(order events.Order, config configs.Config) error {
 	// client with default config
 	cfg := elasticsearch.Config{
 		Addresses: []string{
@@ -28,12 +50,19 @@ func (b *OrderElasticService) SaveOrderToElasticsearch(order OrderResponse, conf
 
 	esClient, err := elasticsearch.NewClient(cfg)
 	if err != nil {
-		log.Errorf("Error creating the client: ", err)
+		log.Errorf("Error creating the client: %v", err)
 		return err
 	}
 
+	// Order is saved without user name if user cannot get, it is added when order changes again
+	document := orderDocument{Order: order}
+	document.UserName, err = b.getUserName(order.UserId, config.HttpClient.UserAPI)
+	if err != nil {
+		log.Warnf("User name of order (ID:%v) cannot get: %v", order.ID, err)
+	}
+
 	// Build the request body.
-	data, err := json.Marshal(order)
+	data, err := json.Marshal(document)
 	if err != nil {
 		log.Errorf("Error marshaling document: %s", err)
 		return err
@@ -83,7 +112,7 @@ func (b *OrderElasticService) DeleteOrderFromElasticsearch(orderID string, confi
 
 	esClient, err := elasticsearch.NewClient(cfg)
 	if err != nil {
-		log.Errorf("Error creating the client: ", err)
+		log.Errorf("Error creating the client: %v", err)
 		return err
 	}
 
@@ -101,20 +130,39 @@ func (b *OrderElasticService) DeleteOrderFromElasticsearch(orderID string, confi
 	}
 	defer res.Body.Close()
 
+	// Order which is not on es is already deleted, so delete of the same event can be handled again
+	if res.StatusCode == http.StatusNotFound {
+		return nil
+	}
 	if res.IsError() {
-		var e map[string]interface{}
-		if err := json.NewDecoder(res.Body).Decode(&e); err != nil {
-			log.Errorf("Error parsing the response body: %s", err)
-			return err
-		} else {
-			// Print the error information.
-			log.Errorf("[%s] %s: %s",
-				res.Status(),
-				e["error"].(map[string]interface{})["type"],
-				e["error"].(map[string]interface{})["reason"],
-			)
-		}
+		log.Errorf("Order (%v) cannot delete from es: %s", orderID, res.String())
+		return fmt.Errorf("order cannot delete from es: %s", res.Status())
 	}
 
 	return nil
 }
+
+// getUserName => name of user from user-api
+func (b *OrderElasticService) getUserName(userId string, userURL string) (string, error) {
+	client := http.Client{
+		Timeout: time.Second * 20,
+	}
+
+	respUser, err := client.Get(userURL + "/" + userId)
+	if err != nil {
+		return "", err
+	}
+	defer respUser.Body.Close()
+
+	if respUser.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("user cannot get from user-api, status code: %v", respUser.StatusCode)
+	}
+
+	var user struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(respUser.Body).Decode(&user); err != nil {
+		return "", err
+	}
+	return user.Name, nil
+}
@@ -1,6 +1,7 @@
 package order_elastic
 
 import (
+	"OrderUserProject/internal/events"
 	"encoding/json"
 	"github.com/sirupsen/logrus"
 	"io"
@@ -17,9 +18,9 @@ func NewOrderEventService(logger *logrus.Logger) *OrderEventService {
 	return orderEventService
 }
 
-func (o *OrderEventService) GetOrderWithHttpClient(ordersID []string, orderURL string) ([]OrderResponse, error) {
+func (o *OrderEventService) GetOrderWithHttpClient(ordersID []string, orderURL string) ([]events.Order, error) {
 
-	var orders []OrderResponse
+	var orders []events.Order
 
 	for _, orderID := range ordersID {
 		// => HTTP.CLIENT FIND ORDER
@@ -32,22 +33,22 @@ func (o *OrderEventService) GetOrderWithHttpClient(ordersID []string, orderURL s
 		respOrder, err := client.Get(orderURL + "/" + orderID)
 		if err != nil || respOrder.StatusCode != http.StatusOK {
 			o.Logger.Errorf("Order with id {%v} cannot find!", orderID)
-			return []OrderResponse{}, err
+			return []events.Order{}, err
 		}
 
 		// Read the response body
 		respOrderBody, err := io.ReadAll(respOrder.Body)
 		if err != nil {
 			o.Logger.Errorf("StatusInternalServerError: %v", err.Error())
-			return []OrderResponse{}, err
+			return []events.Order{}, err
 		}
 
 		// Unmarshal the response body into an Order struct
-		var orderResponse OrderResponse
+		var orderResponse events.Order
 		err = json.Unmarshal(respOrderBody, &orderResponse)
 		if err != nil {
 			o.Logger.Errorf("StatusInternalServerError: %v", err.Error())
-			return []OrderResponse{}, err
+			return []events.Order{}, err
 		}
 
 		orders = append(orders, orderResponse)
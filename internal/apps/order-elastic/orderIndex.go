@@ -0,0 +1,173 @@
+package order_elastic
+
+import (
+	"OrderUserProject/internal/configs"
+	"context"
+	"fmt"
+	"github.com/elastic/go-elasticsearch/v7"
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"github.com/neko-neko/echo-logrus/v2/log"
+	"net/http"
+	"strings"
+)
+
+// orderIndexSettings => "order_text" analyzer of Turkish texts, lowercase and stemmer of Turkish (İ => i, I => ı) and
+// ascii folding after them, so "Izmir", "izmir" and "İzmir" are the same term
+const orderIndexSettings = `{
+  "analysis": {
+    "filter": {
+      "turkish_lowercase": {"type": "lowercase", "language": "turkish"},
+      "turkish_stop": {"type": "stop", "stopwords": "_turkish_"},
+      "turkish_stemmer": {"type": "stemmer", "language": "turkish"}
+    },
+    "analyzer": {
+      "order_text": {
+        "tokenizer": "standard",
+        "filter": ["apostrophe", "turkish_lowercase", "turkish_stop", "turkish_stemmer", "asciifolding"]
+      }
+    }
+  }
+}`
+
+// orderIndexMapping => amounts are scaled_float with scaling factor 100 (minor units), so they are kept exactly like
+// MongoDB decimals. Currency, coupon code and promotion codes are keyword for exact filters. Product names, addresses
+// and user name are analyzed with "order_text" for full-text search, their keyword fields are for exact filters of
+// generic endpoints. Other fields of order are mapped dynamically.
+const orderIndexMapping = `{
+  "properties": {
+    "userName": {"type": "text", "analyzer": "order_text", "fields": {"keyword": {"type": "keyword", "ignore_above": 256}}},
+    "address": {
+      "properties": {
+        "address": {"type": "text", "analyzer": "order_text", "fields": {"keyword": {"type": "keyword", "ignore_above": 256}}},
+        "city": {"type": "text", "analyzer": "order_text", "fields": {"keyword": {"type": "keyword", "ignore_above": 256}}},
+        "district": {"type": "text", "analyzer": "order_text", "fields": {"keyword": {"type": "keyword", "ignore_above": 256}}}
+      }
+    },
+    "invoiceAddress": {
+      "properties": {
+        "address": {"type": "text", "analyzer": "order_text", "fields": {"keyword": {"type": "keyword", "ignore_above": 256}}},
+        "city": {"type": "text", "analyzer": "order_text", "fields": {"keyword": {"type": "keyword", "ignore_above": 256}}},
+        "district": {"type": "text", "analyzer": "order_text", "fields": {"keyword": {"type": "keyword", "ignore_above": 256}}}
+      }
+    },
+    "currency": {"type": "keyword"},
+    "subtotal": {"type": "scaled_float", "scaling_factor": 100},
+    "discount": {"type": "scaled_float", "scaling_factor": 100},
+    "tax": {"type": "scaled_float", "scaling_factor": 100},
+    "shipping": {"type": "scaled_float", "scaling_factor": 100},
+    "total": {"type": "scaled_float", "scaling_factor": 100},
+    "couponCode": {"type": "keyword"},
+    "promotions": {
+      "properties": {
+        "promotionId": {"type": "keyword"},
+        "code": {"type": "keyword"},
+        "type": {"type": "keyword"},
+        "sku": {"type": "keyword"},
+        "amount": {"type": "scaled_float", "scaling_factor": 100}
+      }
+    },
+    "product": {
+      "properties": {
+        "name": {"type": "text", "analyzer": "order_text", "fields": {"keyword": {"type": "keyword", "ignore_above": 256}}},
+        "price": {"type": "scaled_float", "scaling_factor": 100},
+        "taxRate": {"type": "scaled_float", "scaling_factor": 100},
+        "discount": {"type": "scaled_float", "scaling_factor": 100},
+        "tax": {"type": "scaled_float", "scaling_factor": 100},
+        "total": {"type": "scaled_float", "scaling_factor": 100}
+      }
+    }
+  }
+}`
+
+// EnsureOrderIndex => create order index with analyzers and mapping, or add mapping of new fields to existing index.
+// When index is created, orders of previous index (amounts were mapped dynamically) are copied to it in background.
+// Orders which are saved before the copy are not overwritten.
+func (b *OrderElasticService) EnsureOrderIndex(config configs.Config) error {
+	cfg := elasticsearch.Config{
+		Addresses: []string{
+			config.Elasticsearch.Addresses["Address 1"],
+		},
+	}
+
+	esClient, err := elasticsearch.NewClient(cfg)
+	if err != nil {
+		log.Errorf("Error creating the client: %v", err)
+		return err
+	}
+
+	index := config.Elasticsearch.IndexName["OrderSave"]
+
+	exists, err := indexExists(esClient, index)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		// Types of existing fields cannot change, only mapping of new fields is added
+		res, err := esapi.IndicesPutMappingRequest{
+			Index: []string{index},
+			Body:  strings.NewReader(orderIndexMapping),
+		}.Do(context.Background(), esClient)
+		return responseError(res, err, "mapping of index "+index+" cannot put")
+	}
+
+	res, err := esapi.IndicesCreateRequest{
+		Index: index,
+		Body:  strings.NewReader(`{"settings": ` + orderIndexSettings + `, "mappings": ` + orderIndexMapping + `}`),
+	}.Do(context.Background(), esClient)
+	if err := responseError(res, err, "index "+index+" cannot create"); err != nil {
+		return err
+	}
+	log.Infof("Index (%v) is created with order mapping.", index)
+
+	previous := config.Elasticsearch.IndexName["OrderSavePrevious"]
+	if previous == "" {
+		return nil
+	}
+	exists, err = indexExists(esClient, previous)
+	if err != nil || !exists {
+		return err
+	}
+
+	waitForCompletion := false
+	res, err = esapi.ReindexRequest{
+		Body: strings.NewReader(fmt.Sprintf(
+			`{"conflicts": "proceed", "source": {"index": %q}, "dest": {"index": %q, "op_type": "create"}}`, previous, index)),
+		WaitForCompletion: &waitForCompletion,
+	}.Do(context.Background(), esClient)
+	if err := responseError(res, err, "index "+previous+" cannot reindex"); err != nil {
+		return err
+	}
+	log.Infof("Orders of index (%v) are copied to index (%v) in background.", previous, index)
+
+	return nil
+}
+
+func indexExists(esClient *elasticsearch.Client, index string) (bool, error) {
+	res, err := esapi.IndicesExistsRequest{Index: []string{index}}.Do(context.Background(), esClient)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	}
+	return false, fmt.Errorf("index %v cannot check: %v", index, res.Status())
+}
+
+// responseError => error of request or error response of elasticsearch, response body is closed
+func responseError(res *esapi.Response, err error, message string) error {
+	if err != nil {
+		return fmt.Errorf("%v: %w", message, err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("%v: %v", message, res.String())
+	}
+	return nil
+}
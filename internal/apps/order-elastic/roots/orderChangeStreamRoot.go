@@ -0,0 +1,166 @@
+package roots
+
+import (
+	"OrderUserProject/internal/apps/order-elastic"
+	"OrderUserProject/internal/configs"
+	"OrderUserProject/internal/events"
+	"OrderUserProject/internal/models"
+	"OrderUserProject/internal/repository"
+	"context"
+	"errors"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"time"
+)
+
+// changeStreamHistoryLost => error code of change stream which cannot resume, its token is not in oplog anymore
+const changeStreamHistoryLost = 286
+
+// Watcher of change stream and its retries
+const (
+	changeStreamWatcherName = "order-elastic"
+	changeStreamRetryDelay  = 5 * time.Second
+	maxChangeAttempt        = 3
+)
+
+// orderChangeEvent => change event of orders collection, full document is nil for deleted orders
+type orderChangeEvent struct {
+	OperationType string `bson:"operationType"`
+	DocumentKey   struct {
+		ID string `bson:"_id"`
+	} `bson:"documentKey"`
+	FullDocument *models.Order `bson:"fullDocument"`
+}
+
+// OrderChangeStreamRoot => tail orders collection with MongoDB change stream and save changed orders on es. Unlike
+// Kafka source every write reaches es, also writes which don't publish 'OrderChanged' (e.g. manual fixes in db).
+// Resume token is saved after each handled change, so watcher continues where it stopped after restart. A change which
+// cannot handle is never skipped, stream is opened again before it until it is handled.
+type OrderChangeStreamRoot struct {
+	Service    order_elastic.IOrderElasticService
+	Repository repository.IOrderChangeStreamRepository
+	Config     *configs.Config
+	Logger     *logrus.Logger
+}
+
+func NewOrderChangeStreamRoot(service order_elastic.IOrderElasticService, repository repository.IOrderChangeStreamRepository, config *configs.Config, logger *logrus.Logger) *OrderChangeStreamRoot {
+	return &OrderChangeStreamRoot{
+		Service:    service,
+		Repository: repository,
+		Config:     config,
+		Logger:     logger,
+	}
+}
+
+// StartWatchAndSaveOrder => watch changes of orders, stream is opened again with last resume token after errors
+func (o *OrderChangeStreamRoot) StartWatchAndSaveOrder() error {
+	o.Logger.Info("OrderSyncService starting to watch 'Orders' collection.")
+
+	resumeToken, err := o.Repository.GetResumeToken(changeStreamWatcherName)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return err
+	}
+	if resumeToken == nil {
+		o.Logger.Warn("There is no resume token, orders are watched from now on. Orders before are not synced.")
+	}
+
+	for {
+		resumeToken = o.watch(resumeToken)
+		time.Sleep(changeStreamRetryDelay)
+	}
+}
+
+// watch => handle changes until stream fails or a change cannot handle, returns resume token of last handled change
+func (o *OrderChangeStreamRoot) watch(resumeToken bson.Raw) bson.Raw {
+	ctx := context.Background()
+
+	stream, err := o.Repository.Watch(ctx, resumeToken)
+	if err != nil {
+		o.handleStreamError(err, &resumeToken)
+		return resumeToken
+	}
+	defer func() {
+		if err := stream.Close(ctx); err != nil {
+			o.Logger.Errorf("Change stream cannot close. | Error: %v\n", err)
+		}
+	}()
+
+	for stream.Next(ctx) {
+		var change orderChangeEvent
+		if err := stream.Decode(&change); err != nil {
+			o.Logger.Errorf("An error when decode order change, stream starts again before it. | Error: %v\n", err)
+			return resumeToken
+		}
+
+		// Token is not saved for a change which cannot save on es, stream starts again before it after retry delay
+		var err error
+		for attempt := 1; attempt <= maxChangeAttempt; attempt++ {
+			if err = o.handleChange(change); err == nil {
+				break
+			}
+			o.Logger.Errorf("An error when handle order change (attempt %v). | Error: %v\n", attempt, err)
+		}
+		if err != nil {
+			o.Logger.Errorf("Order change cannot handle, stream starts again before it.")
+			return resumeToken
+		}
+
+		resumeToken = stream.ResumeToken()
+		if err := o.Repository.SaveResumeToken(changeStreamWatcherName, resumeToken); err != nil {
+			o.Logger.Errorf("Resume token cannot save. | Error: %v\n", err)
+		}
+	}
+
+	o.handleStreamError(stream.Err(), &resumeToken)
+	return resumeToken
+}
+
+// handleChange => soft deleted orders are removed from es like deleted orders
+func (o *OrderChangeStreamRoot) handleChange(change orderChangeEvent) error {
+	switch change.OperationType {
+	case "insert", "update", "replace":
+		// Order is deleted after change, its delete event comes later
+		if change.FullDocument == nil {
+			return nil
+		}
+		if change.FullDocument.DeletedAt != nil {
+			return o.deleteOrder(change.FullDocument.ID)
+		}
+		if err := o.Service.SaveOrderToElasticsearch(events.NewOrder(*change.FullDocument), *o.Config); err != nil {
+			return err
+		}
+		o.Logger.Infof("Order (ID:%v) saved on es from change stream.", change.FullDocument.ID)
+	case "delete":
+		return o.deleteOrder(change.DocumentKey.ID)
+	case "invalidate":
+		o.Logger.Warn("Orders collection is dropped or renamed, change stream starts again after it.")
+	}
+	return nil
+}
+
+func (o *OrderChangeStreamRoot) deleteOrder(orderID string) error {
+	if err := o.Service.DeleteOrderFromElasticsearch(orderID, *o.Config); err != nil {
+		return err
+	}
+	o.Logger.Infof("Order (ID:%v) deleted from es from change stream.", orderID)
+	return nil
+}
+
+// handleStreamError => stream whose resume token is not in oplog anymore starts from now, orders which changed
+// between are not synced until they change again
+func (o *OrderChangeStreamRoot) handleStreamError(err error, resumeToken *bson.Raw) {
+	if err == nil {
+		return
+	}
+	o.Logger.Errorf("Change stream of orders failed. | Error: %v\n", err)
+
+	var serverError mongo.ServerError
+	if errors.As(err, &serverError) && serverError.HasErrorCode(changeStreamHistoryLost) {
+		o.Logger.Warn("Resume token is not in oplog anymore, orders are watched from now on.")
+		if err := o.Repository.DeleteResumeToken(changeStreamWatcherName); err != nil {
+			o.Logger.Errorf("Resume token cannot delete. | Error: %v\n", err)
+		}
+		*resumeToken = nil
+	}
+}
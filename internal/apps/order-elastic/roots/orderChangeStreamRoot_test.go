@@ -0,0 +1,212 @@
+package roots
+
+import (
+	"OrderUserProject/internal/configs"
+	"OrderUserProject/internal/events"
+	"OrderUserProject/internal/models"
+	"context"
+	"errors"
+	"github.com/go-playground/assert/v2"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"io"
+	"testing"
+	"time"
+)
+
+// MockOrderElasticService is a mock implementation of IOrderElasticService
+type MockOrderElasticService struct {
+	mock.Mock
+}
+
+func (m *MockOrderElasticService) EnsureOrderIndex(config configs.Config) error {
+	args := m.Called(config)
+	return args.Error(0)
+}
+
+func (m *MockOrderElasticService) SaveOrderToElasticsearch(order events.Order, config configs.Config) error {
+	args := m.Called(order, config)
+	return args.Error(0)
+}
+
+func (m *MockOrderElasticService) DeleteOrderFromElasticsearch(orderID string, config configs.Config) error {
+	args := m.Called(orderID, config)
+	return args.Error(0)
+}
+
+// MockOrderChangeStreamRepository is a mock implementation of IOrderChangeStreamRepository
+type MockOrderChangeStreamRepository struct {
+	mock.Mock
+}
+
+func (m *MockOrderChangeStreamRepository) Watch(ctx context.Context, resumeToken bson.Raw) (*mongo.ChangeStream, error) {
+	args := m.Called(ctx, resumeToken)
+	if args.Error(1) != nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*mongo.ChangeStream), nil
+}
+
+func (m *MockOrderChangeStreamRepository) GetResumeToken(name string) (bson.Raw, error) {
+	args := m.Called(name)
+	if args.Error(1) != nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(bson.Raw), nil
+}
+
+func (m *MockOrderChangeStreamRepository) SaveResumeToken(name string, token bson.Raw) error {
+	args := m.Called(name, token)
+	return args.Error(0)
+}
+
+func (m *MockOrderChangeStreamRepository) DeleteResumeToken(name string) error {
+	args := m.Called(name)
+	return args.Error(0)
+}
+
+func newTestChangeStreamRoot(service *MockOrderElasticService, repository *MockOrderChangeStreamRepository) *OrderChangeStreamRoot {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return NewOrderChangeStreamRoot(service, repository, &configs.Config{}, logger)
+}
+
+func newOrderChange(operationType string, order *models.Order) orderChangeEvent {
+	change := orderChangeEvent{OperationType: operationType, FullDocument: order}
+	if order != nil {
+		change.DocumentKey.ID = order.ID
+	}
+	return change
+}
+
+func TestOrderChangeStreamRoot_HandleChange(t *testing.T) {
+	deletedAt := time.Now()
+	order := models.Order{ID: "ea1b6d4a-7fbb-4b43-bbd1-bd1a6d59f7f3", UserId: "8d1b6e5c-2f4e-4d9c-9a3e-1c6b2d7f4a10"}
+	softDeleted := order
+	softDeleted.DeletedAt = &deletedAt
+	esErr := errors.New("es is not reachable")
+
+	deleteChange := orderChangeEvent{OperationType: "delete"}
+	deleteChange.DocumentKey.ID = order.ID
+
+	tests := map[string]struct {
+		change  orderChangeEvent
+		saved   bool
+		deleted bool
+		esErr   error
+	}{
+		"inserted order is saved": {
+			change: newOrderChange("insert", &order),
+			saved:  true,
+		},
+		"updated order is saved": {
+			change: newOrderChange("update", &order),
+			saved:  true,
+		},
+		"replaced order is saved": {
+			change: newOrderChange("replace", &order),
+			saved:  true,
+		},
+		"order which cannot save returns error": {
+			change: newOrderChange("update", &order),
+			saved:  true,
+			esErr:  esErr,
+		},
+		"soft deleted order is deleted": {
+			change:  newOrderChange("update", &softDeleted),
+			deleted: true,
+		},
+		"order deleted after update is skipped": {
+			change: orderChangeEvent{OperationType: "update"},
+		},
+		"deleted order is deleted": {
+			change:  deleteChange,
+			deleted: true,
+		},
+		"order which cannot delete returns error": {
+			change:  deleteChange,
+			deleted: true,
+			esErr:   esErr,
+		},
+		"invalidate is skipped": {
+			change: orderChangeEvent{OperationType: "invalidate"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			service := new(MockOrderElasticService)
+			repository := new(MockOrderChangeStreamRepository)
+			if test.saved {
+				service.On("SaveOrderToElasticsearch", events.NewOrder(order), configs.Config{}).Return(test.esErr)
+			}
+			if test.deleted {
+				service.On("DeleteOrderFromElasticsearch", order.ID, configs.Config{}).Return(test.esErr)
+			}
+
+			err := newTestChangeStreamRoot(service, repository).handleChange(test.change)
+
+			assert.Equal(t, test.esErr, err)
+			service.AssertExpectations(t)
+			if !test.saved {
+				service.AssertNotCalled(t, "SaveOrderToElasticsearch", mock.Anything, mock.Anything)
+			}
+			if !test.deleted {
+				service.AssertNotCalled(t, "DeleteOrderFromElasticsearch", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestOrderChangeStreamRoot_HandleStreamError(t *testing.T) {
+	token := bson.Raw{0x05, 0x00, 0x00, 0x00, 0x00}
+
+	tests := map[string]struct {
+		err           error
+		tokenCleared  bool
+		deleteErr     error
+		expectedToken bson.Raw
+	}{
+		"no error keeps token": {
+			expectedToken: token,
+		},
+		"history lost clears token": {
+			err:          mongo.CommandError{Code: changeStreamHistoryLost, Message: "resume point may no longer be in the oplog"},
+			tokenCleared: true,
+		},
+		"history lost clears token although it cannot delete": {
+			err:          mongo.CommandError{Code: changeStreamHistoryLost},
+			tokenCleared: true,
+			deleteErr:    errors.New("mongo is not reachable"),
+		},
+		"other server error keeps token": {
+			err:           mongo.CommandError{Code: 11601, Message: "operation was interrupted"},
+			expectedToken: token,
+		},
+		"network error keeps token": {
+			err:           errors.New("connection reset by peer"),
+			expectedToken: token,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			service := new(MockOrderElasticService)
+			repository := new(MockOrderChangeStreamRepository)
+			if test.tokenCleared {
+				repository.On("DeleteResumeToken", changeStreamWatcherName).Return(test.deleteErr)
+			}
+
+			resumeToken := token
+			newTestChangeStreamRoot(service, repository).handleStreamError(test.err, &resumeToken)
+
+			assert.Equal(t, test.expectedToken, resumeToken)
+			repository.AssertExpectations(t)
+			if !test.tokenCleared {
+				repository.AssertNotCalled(t, "DeleteResumeToken", mock.Anything)
+			}
+		})
+	}
+}
@@ -3,20 +3,23 @@ package roots
 import (
 	"OrderUserProject/internal/apps/order-elastic"
 	"OrderUserProject/internal/configs"
+	"OrderUserProject/internal/events"
 	kafkaPackage "OrderUserProject/pkg/kafka"
 	"encoding/json"
+	"fmt"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
 	"github.com/sirupsen/logrus"
 )
 
 type OrderElasticRoot struct {
-	Service  *order_elastic.OrderElasticService
+	Service  order_elastic.IOrderElasticService
 	Consumer *kafkaPackage.ConsumerKafka
 	Producer *kafkaPackage.ProducerKafka
 	Config   *configs.Config
 	Logger   *logrus.Logger
 }
 
-func NewOrderElasticRoot(service *order_elastic.OrderElasticService, consumer *kafkaPackage.ConsumerKafka, producer *kafkaPackage.ProducerKafka, config *configs.Config, logger *logrus.Logger) *OrderElasticRoot {
+func NewOrderElasticRoot(service order_elastic.IOrderElasticService, consumer *kafkaPackage.ConsumerKafka, producer *kafkaPackage.ProducerKafka, config *configs.Config, logger *logrus.Logger) *OrderElasticRoot {
 	return &OrderElasticRoot{
 		Service:  service,
 		Consumer: consumer,
@@ -41,8 +44,7 @@ func (o *OrderElasticRoot) StartConsumeAndSaveOrder() error {
 		}
 
 		for _, message := range fromTopics {
-			var orderResponse order_elastic.OrderResponse
-			jsonErr := json.Unmarshal(message.Value, &orderResponse)
+			orderResponse, jsonErr := o.decodeOrderSnapshot(message)
 			if jsonErr == nil {
 				err = o.Service.SaveOrderToElasticsearch(orderResponse, *o.Config)
 				if err != nil {
@@ -56,3 +58,30 @@ func (o *OrderElasticRoot) StartConsumeAndSaveOrder() error {
 		}
 	}
 }
+
+// decodeOrderSnapshot => read 'OrderSnapshot' event with serializer of content-type header (json or avro).
+// Messages produced before event envelopes are json, they are decoded in old way.
+func (o *OrderElasticRoot) decodeOrderSnapshot(message kafka.Message) (events.Order, error) {
+	var order events.Order
+
+	var envelope events.Envelope
+	if err := o.Consumer.Deserialize(message, &envelope); err != nil {
+		return order, err
+	}
+
+	err := events.Validate(envelope)
+	if err == events.ErrNotEnvelope {
+		err = json.Unmarshal(message.Value, &order)
+		return order, err
+	}
+	if err != nil {
+		return order, err
+	}
+
+	if envelope.Type != events.OrderSnapshotType {
+		return order, fmt.Errorf("unexpected event type: %v", envelope.Type)
+	}
+
+	err = envelope.DecodePayload(&order)
+	return order, err
+}
@@ -3,21 +3,31 @@ package roots
 import (
 	"OrderUserProject/internal/apps/order-elastic"
 	"OrderUserProject/internal/configs"
+	"OrderUserProject/internal/events"
 	kafkaPackage "OrderUserProject/pkg/kafka"
 	"encoding/json"
+	"fmt"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
 	"github.com/sirupsen/logrus"
+	"time"
 )
 
+// batchRetryDelay => wait before a batch which is neither handled nor sent to dead letter topic is read again
+const batchRetryDelay = 5 * time.Second
+
+// maxHandleAttempt => an order event is tried this many times, after that it is sent to dead letter topic
+const maxHandleAttempt = 3
+
 type OrderEventRoot struct {
 	ServiceEvent   *order_elastic.OrderEventService
-	ServiceElastic *order_elastic.OrderElasticService
+	ServiceElastic order_elastic.IOrderElasticService
 	Consumer       *kafkaPackage.ConsumerKafka
 	Producer       *kafkaPackage.ProducerKafka
 	Config         *configs.Config
 	Logger         *logrus.Logger
 }
 
-func NewOrderEventRoot(serviceEvent *order_elastic.OrderEventService, serviceElastic *order_elastic.OrderElasticService, consumer *kafkaPackage.ConsumerKafka, producer *kafkaPackage.ProducerKafka, config *configs.Config, logger *logrus.Logger) *OrderEventRoot {
+func NewOrderEventRoot(serviceEvent *order_elastic.OrderEventService, serviceElastic order_elastic.IOrderElasticService, consumer *kafkaPackage.ConsumerKafka, producer *kafkaPackage.ProducerKafka, config *configs.Config, logger *logrus.Logger) *OrderEventRoot {
 	return &OrderEventRoot{
 		ServiceEvent:   serviceEvent,
 		ServiceElastic: serviceElastic,
@@ -28,7 +38,8 @@ func NewOrderEventRoot(serviceEvent *order_elastic.OrderEventService, serviceEla
 	}
 }
 
-// StartGetOrderAndPushOrder => Get message from Kafka to consume OrderID, get order with http.client and push order with Kafka
+// StartGetOrderAndPushOrder => Get message from Kafka to consume OrderID. Full events (schema version 2) carry the order,
+// so they are saved on es directly. For thin events we get order with http.client and push order with Kafka.
 func (o *OrderEventRoot) StartGetOrderAndPushOrder() error {
 	o.Logger.Info("OrderSyncService starting for consume 'OrderID'.")
 	err := o.Consumer.SubscribeToTopics([]string{o.Config.Kafka.TopicName["OrderID"]})
@@ -36,63 +47,130 @@ func (o *OrderEventRoot) StartGetOrderAndPushOrder() error {
 		o.Logger.Errorf("Kafka connection failed. | Error: %v\n", err)
 	}
 	for {
-		ordersID := make([]string, 0)
-		ordersDeletedID := make([]string, 0)
 		fromTopics, err := o.Consumer.ConsumeFromTopics(1, 5, 2)
 		if err != nil {
 			o.Logger.Errorf("An error when consume from topic. | Error: %v\n", err)
 		}
 
-		for _, message := range fromTopics {
-			o.Logger.Infof("Message received from kafka: %v\n", string(message.Value))
+		o.handleBatch(fromTopics)
+	}
+}
 
-			var orderResponse order_elastic.OrderResponseForElastic
-			jsonErr := json.Unmarshal(message.Value, &orderResponse)
-			if jsonErr != nil {
-				o.Logger.Errorf(jsonErr.Error())
-			}
+// handleBatch => every message is tried maxHandleAttempt times, a message which still cannot handle is sent to dead
+// letter topic. Batch is acked only when every message is handled or dead lettered, otherwise it is read again from its
+// first message (saves and deletes of es are idempotent, so handled messages of batch can be handled again).
+func (o *OrderEventRoot) handleBatch(messages []kafka.Message) {
+	deadLetterTopic := o.Config.Kafka.TopicName["OrderIDDeadLetter"]
+	for _, message := range messages {
+		o.Logger.Infof("Message received from kafka: %v\n", string(message.Value))
 
-			switch orderResponse.Status {
-			case "Created", "Updated":
-				ordersID = append(ordersID, orderResponse.OrderID)
-			case "Deleted":
-				ordersDeletedID = append(ordersDeletedID, orderResponse.OrderID)
-			default:
-				o.Logger.Errorf("Unknown order response status. | Error: %v\n", orderResponse.Status)
+		var err error
+		for attempt := 1; attempt <= maxHandleAttempt; attempt++ {
+			if err = o.handleMessage(message); err == nil {
+				break
 			}
+			o.Logger.Errorf("An error when handle order event (attempt %v). | Error: %v\n", attempt, err)
+		}
+		if err == nil {
+			continue
 		}
 
-		if len(ordersID) > 0 {
-			ordersModel, err := o.ServiceEvent.GetOrderWithHttpClient(ordersID, o.Config.HttpClient.OrderAPI)
-			if err != nil || ordersModel == nil {
-				o.Logger.Errorf("Orders cannot find. | Error: %v\n", err)
-			} else {
-				o.Consumer.AckLastMessage()
-			}
+		deadLetterErr := o.Producer.SendToDeadLetter(message, deadLetterTopic, err)
+		if deadLetterErr == nil {
+			o.Logger.Errorf("Order event (offset %v) cannot handle, it is sent to %v.", message.TopicPartition.Offset, deadLetterTopic)
+			continue
+		}
 
-			for _, orderForPush := range ordersModel {
-				// => SEND MESSAGE (Order Model)
-				orderJSON, err := json.Marshal(orderForPush)
-				if err != nil {
-					o.Logger.Errorf("An error when convert from json. | Error: %v\n", err)
-				}
-
-				err = o.Producer.SendToKafkaWithMessage(orderJSON, o.Config.Kafka.TopicName["OrderModel"])
-				if err != nil {
-					o.Logger.Errorf("An error when send a message... | Error: %v\n", err)
-				} else {
-					o.Logger.Infof("Order successfully pushed with id: %v", orderForPush.ID)
-				}
-			}
+		o.Logger.Errorf("Batch of %v messages cannot handle, it is read again. | Error: %v\n", len(messages), deadLetterErr)
+		if err := o.Consumer.Rewind(messages); err != nil {
+			o.Logger.Errorf("Batch cannot rewind. | Error: %v\n", err)
 		}
+		time.Sleep(batchRetryDelay)
+		return
+	}
 
-		if len(ordersDeletedID) > 0 {
-			for _, orderForDelete := range ordersDeletedID {
-				if err := o.ServiceElastic.DeleteOrderFromElasticsearch(orderForDelete, *o.Config); err != nil {
-					o.Logger.Errorf("An error deleting order from es. | Error: %v\n", err)
-				}
-				o.Logger.Infof("Order (ID:%v) successfully deleted from es.", orderForDelete)
+	if len(messages) > 0 {
+		o.Consumer.AckLastMessage()
+	}
+}
+
+// handleMessage => full events are saved on es, order of thin events is taken from order-api and pushed to 'OrderModel'
+// and deleted orders are deleted from es
+func (o *OrderEventRoot) handleMessage(message kafka.Message) error {
+	orderResponse, err := o.decodeOrderChanged(message)
+	if err != nil {
+		return err
+	}
+
+	switch orderResponse.Status {
+	case "Created", "Updated":
+		if orderResponse.Order != nil {
+			if err := o.ServiceElastic.SaveOrderToElasticsearch(*orderResponse.Order, *o.Config); err != nil {
+				return err
 			}
+			o.Logger.Infof("Order (ID:%v) saved on es from full event.", orderResponse.Order.ID)
+			return nil
+		}
+		return o.pushOrder(orderResponse.OrderID)
+	case "Deleted":
+		if err := o.ServiceElastic.DeleteOrderFromElasticsearch(orderResponse.OrderID, *o.Config); err != nil {
+			return err
 		}
+		o.Logger.Infof("Order (ID:%v) successfully deleted from es.", orderResponse.OrderID)
+		return nil
+	default:
+		return fmt.Errorf("unknown order response status: %v", orderResponse.Status)
 	}
 }
+
+// pushOrder => get order of thin event with http.client and push order with Kafka
+func (o *OrderEventRoot) pushOrder(orderID string) error {
+	ordersModel, err := o.ServiceEvent.GetOrderWithHttpClient([]string{orderID}, o.Config.HttpClient.OrderAPI)
+	if err != nil {
+		return err
+	}
+	if len(ordersModel) == 0 {
+		return fmt.Errorf("order (ID:%v) cannot find", orderID)
+	}
+
+	for _, orderForPush := range ordersModel {
+		// => SEND MESSAGE (Order Model)
+		envelope, err := events.New(events.OrderSnapshotType, events.OrderSnapshotVersion, orderForPush)
+		if err != nil {
+			return err
+		}
+
+		if err := o.Producer.SendToKafkaWithValue(envelope, o.Config.Kafka.TopicName["OrderModel"]); err != nil {
+			return err
+		}
+		o.Logger.Infof("Order successfully pushed with id: %v", orderForPush.ID)
+	}
+	return nil
+}
+
+// decodeOrderChanged => read 'OrderChanged' event with serializer of content-type header (json or avro).
+// Messages produced before event envelopes are json, they are decoded in old way.
+func (o *OrderEventRoot) decodeOrderChanged(message kafka.Message) (events.OrderChanged, error) {
+	var orderChanged events.OrderChanged
+
+	var envelope events.Envelope
+	if err := o.Consumer.Deserialize(message, &envelope); err != nil {
+		return orderChanged, err
+	}
+
+	err := events.Validate(envelope)
+	if err == events.ErrNotEnvelope {
+		err = json.Unmarshal(message.Value, &orderChanged)
+		return orderChanged, err
+	}
+	if err != nil {
+		return orderChanged, err
+	}
+
+	if envelope.Type != events.OrderChangedType {
+		return orderChanged, fmt.Errorf("unexpected event type: %v", envelope.Type)
+	}
+
+	err = envelope.DecodePayload(&orderChanged)
+	return orderChanged, err
+}
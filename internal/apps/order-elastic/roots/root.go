@@ -5,9 +5,12 @@ import (
 	"sync"
 )
 
+// OrderSyncService => roots of sync source, Kafka source has event and elastic roots, change stream source has
+// change stream root only
 type OrderSyncService struct {
-	OrderElasticRoot *OrderElasticRoot
-	OrderEventRoot   *OrderEventRoot
+	OrderElasticRoot      *OrderElasticRoot
+	OrderEventRoot        *OrderEventRoot
+	OrderChangeStreamRoot *OrderChangeStreamRoot
 }
 
 func NewOrderSyncService(orderElasticRoot *OrderElasticRoot, orderEventRoot *OrderEventRoot) *OrderSyncService {
@@ -17,9 +20,23 @@ func NewOrderSyncService(orderElasticRoot *OrderElasticRoot, orderEventRoot *Ord
 	}
 }
 
+// NewOrderChangeStreamSyncService => orders are synced from change stream of orders collection instead of Kafka
+func NewOrderChangeStreamSyncService(orderChangeStreamRoot *OrderChangeStreamRoot) *OrderSyncService {
+	return &OrderSyncService{
+		OrderChangeStreamRoot: orderChangeStreamRoot,
+	}
+}
+
 func (o OrderSyncService) Start() {
 	group := sync.WaitGroup{}
 
+	if o.OrderChangeStreamRoot != nil {
+		if err := o.OrderChangeStreamRoot.StartWatchAndSaveOrder(); err != nil {
+			log.Fatalf("OrderChangeStreamRoot failed, shutting down the server. | Error: %v\n", err)
+		}
+		return
+	}
+
 	group.Add(2)
 	go func() {
 		defer group.Done()
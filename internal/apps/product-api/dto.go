@@ -0,0 +1,66 @@
+package product_api
+
+import (
+	"OrderUserProject/pkg/money"
+	"errors"
+)
+
+// ErrSkuExists => sku is unique in catalog, orders refer to products with sku
+var ErrSkuExists = errors.New("sku already exists")
+
+// ErrStockBelowReserved => stock cannot be less than quantity which is reserved for orders
+var ErrStockBelowReserved = errors.New("stock is less than reserved quantity")
+
+// States of stock reservation. "Reserving" is a reservation which is not finished yet, others are final for a command.
+const (
+	ReservationReserving = "Reserving"
+	ReservationReserved  = "Reserved"
+	ReservationRejected  = "Rejected"
+	ReservationReleased  = "Released"
+	ReservationCommitted = "Committed"
+)
+
+// ProductCreateRequest => price is without VAT with at most 2 fraction digits. Currency and tax class are defaults of
+// config (configs.Config.Pricing) if they are empty.
+type ProductCreateRequest struct {
+	Sku         string       `json:"sku" validate:"required,min=1,max=64"`
+	Name        string       `json:"name" validate:"required,min=1,max=100"`
+	Description string       `json:"description" validate:"max=1000"`
+	Price       money.Amount `json:"price" swaggertype:"number" validate:"required,gt=0"`
+	Currency    string       `json:"currency" validate:"omitempty,iso4217"`
+	TaxClass    string       `json:"taxClass" validate:"omitempty,max=32"`
+	Active      bool         `json:"active"`
+	Stock       int          `json:"stock" validate:"min=0"`
+}
+
+type ProductUpdateRequest struct {
+	ID          string       `json:"id" validate:"required,uuid4"`
+	Name        string       `json:"name" validate:"required,min=1,max=100"`
+	Description string       `json:"description" validate:"max=1000"`
+	Price       money.Amount `json:"price" swaggertype:"number" validate:"required,gt=0"`
+	Currency    string       `json:"currency" validate:"omitempty,iso4217"`
+	TaxClass    string       `json:"taxClass" validate:"omitempty,max=32"`
+	Active      bool         `json:"active"`
+}
+
+// ProductStockRequest => quantity on hand of product, reserved quantity is changed only by orders
+type ProductStockRequest struct {
+	Stock int `json:"stock" validate:"min=0"`
+}
+
+type ProductResponse struct {
+	ID          string `json:"id"`
+	Sku         string `json:"sku"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Active      bool   `json:"active"`
+	Stock       int    `json:"stock"`
+	Reserved    int    `json:"reserved"`
+	// Price => unit price without VAT, TaxRate => VAT percent of tax class which is applied to order lines
+	Price    money.Amount `json:"price" swaggertype:"number"`
+	Currency string       `json:"currency"`
+	TaxClass string       `json:"taxClass"`
+	TaxRate  money.Rate   `json:"taxRate" swaggertype:"number"`
+	// Available => stock which can be reserved by new orders
+	Available int `json:"available"`
+}
@@ -0,0 +1,457 @@
+package handler
+
+import (
+	"OrderUserProject/internal/apps/product-api"
+	"OrderUserProject/internal/configs"
+	"OrderUserProject/internal/events"
+	"OrderUserProject/internal/models"
+	"OrderUserProject/pkg"
+	"fmt"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/mongo"
+	"net/http"
+)
+
+type ProductHandler struct {
+	Service          product_api.IProductService
+	InventoryService product_api.IInventoryService
+	Config           *configs.Config
+	Validator        *validator.Validate
+}
+
+func NewProductHandler(e *echo.Echo, service product_api.IProductService, inventoryService product_api.IInventoryService, config *configs.Config, v *validator.Validate) *ProductHandler {
+	router := e.Group("api/products")
+	b := &ProductHandler{Service: service, InventoryService: inventoryService, Config: config, Validator: v}
+
+	e.Use(pkg.CustomErrorMiddleware)
+
+	//Routes
+	router.GET("", b.GetAllProducts)
+	router.GET("/asyncapi.json", b.AsyncAPI)
+	router.GET("/:id", b.GetProductById)
+	// Catalog and stock are changed only by admin
+	router.POST("", b.CreateProduct, pkg.AdminOnly(config.Server.AdminToken))
+	router.PUT("", b.UpdateProduct, pkg.AdminOnly(config.Server.AdminToken))
+	router.PUT("/:id/stock", b.SetProductStock, pkg.AdminOnly(config.Server.AdminToken))
+	router.DELETE("/:id", b.DeleteProduct, pkg.AdminOnly(config.Server.AdminToken))
+
+	return b
+}
+
+// GetAllProducts godoc
+// @Summary get all items in the product catalog, 'sku' query filters products (order-api resolves order lines with it)
+// @ID get-all-products
+// @Produce json
+// @Param sku query []string false "product skus" collectionFormat(multi)
+// @Success 200 {array} models.JSONSuccessResultData
+// @Success 500 {object} pkg.CustomError
+// @Router /products [get]
+func (h *ProductHandler) GetAllProducts(c echo.Context) error {
+	var productList []models.Product
+	var err error
+
+	if skus := c.QueryParams()["sku"]; len(skus) > 0 {
+		productList, err = h.Service.GetProductsBySkus(skus)
+	} else {
+		productList, err = h.Service.GetAll()
+	}
+
+	if err != nil {
+		internalServerError := pkg.CustomError{
+			Message:    fmt.Sprintf("StatusInternalServerError: %v", err),
+			StatusCode: http.StatusInternalServerError,
+		}
+		return internalServerError
+	}
+
+	// We can use automapper, but it will cause performance loss.
+	productsResponse := []product_api.ProductResponse{}
+	for _, product := range productList {
+		productsResponse = append(productsResponse, h.toProductResponse(product))
+	}
+
+	// Response success result data
+	jsonSuccessResultData := models.JSONSuccessResultData{
+		TotalItemCount: len(productsResponse),
+		Data:           productsResponse,
+	}
+
+	c.Logger().Info("All products are listed.")
+	return c.JSON(http.StatusOK, jsonSuccessResultData)
+}
+
+// GetProductById godoc
+// @Summary get a product item by ID
+// @ID get-product-by-id
+// @Produce json
+// @Param id path string true "product ID"
+// @Success 200 {object} product_api.ProductResponse
+// @Success 404 {object} pkg.CustomError
+// @Success 500 {object} pkg.CustomError
+// @Router /products/{id} [get]
+func (h *ProductHandler) GetProductById(c echo.Context) error {
+	query := c.Param("id")
+
+	product, err := h.Service.GetProductById(query)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			notFoundError := pkg.CustomError{
+				Message:    fmt.Sprintf("Not found exception: {%v} with id not found!", query),
+				StatusCode: http.StatusNotFound,
+			}
+			return notFoundError
+		}
+		internalServerError := pkg.CustomError{
+			Message:    fmt.Sprintf("StatusInternalServerError: %v", err),
+			StatusCode: http.StatusInternalServerError,
+		}
+		return internalServerError
+	}
+
+	c.Logger().Infof("{%v} with id is listed.", product.ID)
+	return c.JSON(http.StatusOK, h.toProductResponse(product))
+}
+
+// CreateProduct godoc
+// @Summary add a new item to the product catalog
+// @ID create-product
+// @Produce json
+// @Param data body product_api.ProductCreateRequest true "product data"
+// @Param X-Admin-Token header string true "admin token"
+// @Success 201 {object} models.JSONSuccessResultId
+// @Success 400 {object} pkg.CustomError
+// @Success 403 {object} pkg.CustomError
+// @Success 409 {object} pkg.CustomError
+// @Success 500 {object} pkg.CustomError
+// @Router /products [post]
+func (h *ProductHandler) CreateProduct(c echo.Context) error {
+	var productRequest product_api.ProductCreateRequest
+
+	// We parse the data as json into the struct
+	if err := c.Bind(&productRequest); err != nil {
+		badRequestError := pkg.CustomError{
+			Message:    fmt.Sprintf("Bad Request. It cannot be binding! %v", err),
+			StatusCode: http.StatusBadRequest,
+		}
+		return badRequestError
+	}
+
+	// Validate product input using the validator instance
+	if err := h.Validator.Struct(productRequest); err != nil {
+		badRequestError := pkg.CustomError{
+			Message:    fmt.Sprintf("Bad Request. Please put valid product model! %v", err),
+			StatusCode: http.StatusBadRequest,
+		}
+		return badRequestError
+	}
+
+	// We can use automapper, but it will cause performance loss.
+	var product models.Product
+	product.Sku = productRequest.Sku
+	product.Name = productRequest.Name
+	product.Description = productRequest.Description
+	product.Price = productRequest.Price
+	product.Active = productRequest.Active
+	product.Stock = productRequest.Stock
+	currency, taxClass, err := h.pricing(productRequest.Currency, productRequest.TaxClass)
+	if err != nil {
+		return err
+	}
+	product.Currency = currency
+	product.TaxClass = taxClass
+
+	result, err := h.Service.Insert(product)
+
+	if err != nil {
+		if err == product_api.ErrSkuExists {
+			conflictError := pkg.CustomError{
+				Message:    fmt.Sprintf("Conflict. Product with {%v} sku already exists!", product.Sku),
+				StatusCode: http.StatusConflict,
+			}
+			return conflictError
+		}
+		internalServerError := pkg.CustomError{
+			Message:    fmt.Sprintf("StatusInternalServerError: %v", err),
+			StatusCode: http.StatusInternalServerError,
+		}
+		return internalServerError
+	}
+
+	// Response id and success boolean
+	jsonSuccessResultId := models.JSONSuccessResultId{
+		ID:      result.ID,
+		Success: true,
+	}
+
+	c.Logger().Infof("{%v} with id is created.", jsonSuccessResultId.ID)
+	return c.JSON(http.StatusCreated, jsonSuccessResultId)
+}
+
+// UpdateProduct godoc
+// @Summary update a product item, sku cannot be changed. Existing orders keep their price
+// @ID update-product
+// @Produce json
+// @Param data body product_api.ProductUpdateRequest true "product data"
+// @Param X-Admin-Token header string true "admin token"
+// @Success 200 {object} models.JSONSuccessResultId
+// @Success 400 {object} pkg.CustomError
+// @Success 403 {object} pkg.CustomError
+// @Success 404 {object} pkg.CustomError
+// @Success 500 {object} pkg.CustomError
+// @Router /products [put]
+func (h *ProductHandler) UpdateProduct(c echo.Context) error {
+	var productUpdateRequest product_api.ProductUpdateRequest
+
+	// We parse the data as json into the struct
+	if err := c.Bind(&productUpdateRequest); err != nil {
+		badRequestError := pkg.CustomError{
+			Message:    fmt.Sprintf("Bad Request. It cannot be binding! %v", err),
+			StatusCode: http.StatusBadRequest,
+		}
+		return badRequestError
+	}
+
+	// Validate product input using the validator instance
+	if err := h.Validator.Struct(productUpdateRequest); err != nil {
+		badRequestError := pkg.CustomError{
+			Message:    fmt.Sprintf("Bad Request. Please put valid product model! %v", err),
+			StatusCode: http.StatusBadRequest,
+		}
+		return badRequestError
+	}
+
+	product, err := h.Service.GetProductById(productUpdateRequest.ID)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			notFoundError := pkg.CustomError{
+				Message:    fmt.Sprintf("Not found exception: {%v} with id not found!", productUpdateRequest.ID),
+				StatusCode: http.StatusNotFound,
+			}
+			return notFoundError
+		}
+		internalServerError := pkg.CustomError{
+			Message:    fmt.Sprintf("StatusInternalServerError: %v", err),
+			StatusCode: http.StatusInternalServerError,
+		}
+		return internalServerError
+	}
+
+	product.Name = productUpdateRequest.Name
+	product.Description = productUpdateRequest.Description
+	product.Price = productUpdateRequest.Price
+	product.Active = productUpdateRequest.Active
+	// Currency and tax class which are not sent are kept
+	if productUpdateRequest.Currency == "" {
+		productUpdateRequest.Currency = product.Currency
+	}
+	if productUpdateRequest.TaxClass == "" {
+		productUpdateRequest.TaxClass = product.TaxClass
+	}
+	currency, taxClass, err := h.pricing(productUpdateRequest.Currency, productUpdateRequest.TaxClass)
+	if err != nil {
+		return err
+	}
+	product.Currency = currency
+	product.TaxClass = taxClass
+
+	result, err := h.Service.Update(product)
+
+	if err != nil || result == false {
+		internalServerError := pkg.CustomError{
+			Message:    fmt.Sprintf("StatusInternalServerError: {%v} ", err),
+			StatusCode: http.StatusInternalServerError,
+		}
+		return internalServerError
+	}
+
+	// Response id and success boolean
+	jsonSuccessResultId := models.JSONSuccessResultId{
+		ID:      product.ID,
+		Success: result,
+	}
+
+	c.Logger().Infof("{%v} with id is updated.", jsonSuccessResultId.ID)
+	return c.JSON(http.StatusOK, jsonSuccessResultId)
+}
+
+// SetProductStock godoc
+// @Summary set quantity on hand of a product, reserved quantity of open orders is not changed
+// @ID set-product-stock
+// @Produce json
+// @Param id path string true "product ID"
+// @Param data body product_api.ProductStockRequest true "stock data"
+// @Param X-Admin-Token header string true "admin token"
+// @Success 200 {object} models.JSONSuccessResultId
+// @Success 400 {object} pkg.CustomError
+// @Success 403 {object} pkg.CustomError
+// @Success 404 {object} pkg.CustomError
+// @Success 409 {object} pkg.CustomError
+// @Success 500 {object} pkg.CustomError
+// @Router /products/{id}/stock [put]
+func (h *ProductHandler) SetProductStock(c echo.Context) error {
+	query := c.Param("id")
+	var stockRequest product_api.ProductStockRequest
+
+	// We parse the data as json into the struct
+	if err := c.Bind(&stockRequest); err != nil {
+		badRequestError := pkg.CustomError{
+			Message:    fmt.Sprintf("Bad Request. It cannot be binding! %v", err),
+			StatusCode: http.StatusBadRequest,
+		}
+		return badRequestError
+	}
+
+	// Validate stock input using the validator instance
+	if err := h.Validator.Struct(stockRequest); err != nil {
+		badRequestError := pkg.CustomError{
+			Message:    fmt.Sprintf("Bad Request. Please put valid stock! %v", err),
+			StatusCode: http.StatusBadRequest,
+		}
+		return badRequestError
+	}
+
+	product, err := h.Service.GetProductById(query)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			notFoundError := pkg.CustomError{
+				Message:    fmt.Sprintf("Not found exception: {%v} with id not found!", query),
+				StatusCode: http.StatusNotFound,
+			}
+			return notFoundError
+		}
+		internalServerError := pkg.CustomError{
+			Message:    fmt.Sprintf("StatusInternalServerError: %v", err),
+			StatusCode: http.StatusInternalServerError,
+		}
+		return internalServerError
+	}
+
+	if err := h.InventoryService.SetStock(product.ID, stockRequest.Stock); err != nil {
+		if err == product_api.ErrStockBelowReserved {
+			conflictError := pkg.CustomError{
+				Message:    fmt.Sprintf("Conflict. %v reserved items of {%v} sku are waiting for shipment!", product.Reserved, product.Sku),
+				StatusCode: http.StatusConflict,
+			}
+			return conflictError
+		}
+		internalServerError := pkg.CustomError{
+			Message:    fmt.Sprintf("StatusInternalServerError: %v", err),
+			StatusCode: http.StatusInternalServerError,
+		}
+		return internalServerError
+	}
+
+	// Response id and success boolean
+	jsonSuccessResultId := models.JSONSuccessResultId{
+		ID:      product.ID,
+		Success: true,
+	}
+
+	c.Logger().Infof("Stock of {%v} with id is set to %v.", product.ID, stockRequest.Stock)
+	return c.JSON(http.StatusOK, jsonSuccessResultId)
+}
+
+// DeleteProduct godoc
+// @Summary delete a product item by ID, existing orders keep their snapshot of product
+// @ID delete-product-by-id
+// @Produce json
+// @Param id path string true "product ID"
+// @Param X-Admin-Token header string true "admin token"
+// @Success 200 {object} models.JSONSuccessResultId
+// @Success 403 {object} pkg.CustomError
+// @Success 404 {object} pkg.CustomError
+// @Router /products/{id} [delete]
+func (h *ProductHandler) DeleteProduct(c echo.Context) error {
+	query := c.Param("id")
+
+	result, err := h.Service.Delete(query)
+
+	if err != nil || result == false {
+		notFoundError := pkg.CustomError{
+			Message:    fmt.Sprintf("Not found exception: {%v} with id not found!", query),
+			StatusCode: http.StatusNotFound,
+		}
+		return notFoundError
+	}
+
+	// Response id and success boolean
+	jsonSuccessResultId := models.JSONSuccessResultId{
+		ID:      query,
+		Success: result,
+	}
+
+	c.Logger().Infof("{%v} with id is deleted.", jsonSuccessResultId.ID)
+	return c.JSON(http.StatusOK, jsonSuccessResultId)
+}
+
+// AsyncAPI godoc
+// @Summary asyncapi document of kafka events which are published by product-api
+// @ID get-product-asyncapi
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Success 500 {object} pkg.CustomError
+// @Router /products/asyncapi.json [get]
+func (h *ProductHandler) AsyncAPI(c echo.Context) error {
+	document, err := events.NewAsyncAPI("Product Microservice Events", "1.0.0", h.Config.Kafka.Address,
+		events.InventoryEventsChannel(h.Config.Kafka.TopicName["InventoryEvents"]).WithFormat(h.Config.Kafka.Serialization["InventoryEvents"]))
+	if err != nil {
+		internalServerErr := pkg.CustomError{
+			Message:    fmt.Sprintf("InternalServerError. AsyncAPI document cannot generate: %v", err),
+			StatusCode: http.StatusInternalServerError,
+		}
+		return internalServerErr
+	}
+
+	return c.JSON(http.StatusOK, document)
+}
+
+// pricing => currency and tax class of product, defaults of config are used if they are empty. Tax class has to have
+// a VAT rate in config.
+func (h *ProductHandler) pricing(currency string, taxClass string) (string, string, error) {
+	if currency == "" {
+		currency = h.Config.Pricing.Currency
+	}
+	if taxClass == "" {
+		taxClass = h.Config.Pricing.DefaultTaxClass
+	}
+
+	if _, ok := h.Config.Pricing.VATRates[taxClass]; !ok {
+		badRequestError := pkg.CustomError{
+			Message:    fmt.Sprintf("Bad Request. Tax class {%v} has no VAT rate!", taxClass),
+			StatusCode: http.StatusBadRequest,
+		}
+		return "", "", badRequestError
+	}
+
+	return currency, taxClass, nil
+}
+
+// toProductResponse => mapping from product model to response. Products which are created before pricing have default
+// currency and tax class.
+func (h *ProductHandler) toProductResponse(product models.Product) product_api.ProductResponse {
+	currency, taxClass := product.Currency, product.TaxClass
+	if currency == "" {
+		currency = h.Config.Pricing.Currency
+	}
+	if taxClass == "" {
+		taxClass = h.Config.Pricing.DefaultTaxClass
+	}
+
+	return product_api.ProductResponse{
+		ID:          product.ID,
+		Sku:         product.Sku,
+		Name:        product.Name,
+		Description: product.Description,
+		Active:      product.Active,
+		Stock:       product.Stock,
+		Reserved:    product.Reserved,
+		Price:       product.Price,
+		Currency:    currency,
+		TaxClass:    taxClass,
+		TaxRate:     h.Config.Pricing.VATRates[taxClass],
+		Available:   product.Stock - product.Reserved,
+	}
+}
@@ -0,0 +1,268 @@
+package product_api
+
+import (
+	"OrderUserProject/internal/events"
+	"OrderUserProject/internal/models"
+	"OrderUserProject/internal/repository"
+	"fmt"
+	"go.mongodb.org/mongo-driver/mongo"
+	"strings"
+	"time"
+)
+
+type InventoryService struct {
+	Repository repository.IInventoryRepository
+}
+
+func NewInventoryService(repository repository.IInventoryRepository) IInventoryService {
+	inventoryService := &InventoryService{
+		Repository: repository,
+	}
+	return inventoryService
+}
+
+// IInventoryService => participant of inventory saga. Every command returns its reply, a command which is handled before
+// returns the same reply again, so order-api can repeat commands safely.
+type IInventoryService interface {
+	Reserve(command events.ReserveStock) (events.DomainEvent, error)
+	Release(command events.ReleaseStock) (events.DomainEvent, error)
+	Commit(command events.CommitStock) (events.DomainEvent, error)
+	Adjust(command events.AdjustStock) (events.DomainEvent, error)
+	SetStock(id string, stock int) error
+}
+
+// Reserve => reserve stock of every line or nothing. If a line cannot be reserved, lines which are reserved are released
+// again (compensation) and reservation is rejected. Error means the command can be tried again.
+func (b *InventoryService) Reserve(command events.ReserveStock) (events.DomainEvent, error) {
+	reservation, err := b.Repository.GetReservationById(command.SagaID)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return events.DomainEvent{}, err
+	}
+	// Reservation which is not finished (e.g. service stopped) is continued, lines are not reserved twice
+	if err == nil && reservation.State != ReservationReserving {
+		return reservationReply(reservation), nil
+	}
+
+	if err == mongo.ErrNoDocuments {
+		reservation = models.StockReservation{
+			ID:        command.SagaID,
+			OrderID:   command.OrderID,
+			Lines:     command.Models(),
+			State:     ReservationReserving,
+			CreatedAt: time.Now(),
+		}
+		reservation.UpdatedAt = reservation.CreatedAt
+		if err := b.Repository.UpsertReservation(reservation); err != nil {
+			return events.DomainEvent{}, err
+		}
+	}
+
+	var failedSkus []string
+	for _, line := range reservation.Lines {
+		ok, err := b.Repository.ReserveLine(reservation.ID, line)
+		if err != nil {
+			return events.DomainEvent{}, err
+		}
+		if !ok {
+			failedSkus = append(failedSkus, line.Sku)
+		}
+	}
+
+	reservation.State = ReservationReserved
+	if len(failedSkus) > 0 {
+		if err := b.releaseLines(reservation); err != nil {
+			return events.DomainEvent{}, err
+		}
+		reservation.State = ReservationRejected
+		reservation.Reason = fmt.Sprintf("not enough stock: %v", strings.Join(failedSkus, ", "))
+	}
+
+	reservation.UpdatedAt = time.Now()
+	if err := b.Repository.UpsertReservation(reservation); err != nil {
+		return events.DomainEvent{}, err
+	}
+
+	if len(failedSkus) > 0 {
+		return events.NewStockReservationFailed(reservation.ID, reservation.OrderID, reservation.Reason, failedSkus), nil
+	}
+	return reservationReply(reservation), nil
+}
+
+// Release => give reserved stock back. If reservation comes after release (e.g. commands are sent again), it is rejected
+// because released reservation is recorded. Committed stock cannot be released.
+func (b *InventoryService) Release(command events.ReleaseStock) (events.DomainEvent, error) {
+	reservation, err := b.Repository.GetReservationById(command.SagaID)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return events.DomainEvent{}, err
+	}
+
+	if err == mongo.ErrNoDocuments {
+		reservation = models.StockReservation{
+			ID:        command.SagaID,
+			OrderID:   command.OrderID,
+			State:     ReservationReleased,
+			Reason:    "released before reservation",
+			CreatedAt: time.Now(),
+		}
+		reservation.UpdatedAt = reservation.CreatedAt
+		if err := b.Repository.UpsertReservation(reservation); err != nil {
+			return events.DomainEvent{}, err
+		}
+		return reservationReply(reservation), nil
+	}
+
+	if reservation.State != ReservationReserving && reservation.State != ReservationReserved {
+		return reservationReply(reservation), nil
+	}
+
+	if err := b.releaseLines(reservation); err != nil {
+		return events.DomainEvent{}, err
+	}
+
+	reservation.State = ReservationReleased
+	reservation.UpdatedAt = time.Now()
+	if err := b.Repository.UpsertReservation(reservation); err != nil {
+		return events.DomainEvent{}, err
+	}
+
+	return reservationReply(reservation), nil
+}
+
+// Commit => reserved stock of shipped order is removed from stock. Only completed reservation can be committed.
+func (b *InventoryService) Commit(command events.CommitStock) (events.DomainEvent, error) {
+	reservation, err := b.Repository.GetReservationById(command.SagaID)
+	if err == mongo.ErrNoDocuments {
+		return events.NewStockReservationFailed(command.SagaID, command.OrderID, "there is no reservation to commit", nil), nil
+	}
+	if err != nil {
+		return events.DomainEvent{}, err
+	}
+
+	if reservation.State != ReservationReserved {
+		if reservation.State == ReservationCommitted {
+			return reservationReply(reservation), nil
+		}
+		reason := fmt.Sprintf("reservation is %v, it cannot commit", strings.ToLower(reservation.State))
+		return events.NewStockReservationFailed(reservation.ID, reservation.OrderID, reason, nil), nil
+	}
+
+	for _, line := range reservation.Lines {
+		if err := b.Repository.CommitLine(reservation.ID, line.Sku); err != nil {
+			return events.DomainEvent{}, err
+		}
+	}
+
+	reservation.State = ReservationCommitted
+	reservation.UpdatedAt = time.Now()
+	if err := b.Repository.UpsertReservation(reservation); err != nil {
+		return events.DomainEvent{}, err
+	}
+
+	return reservationReply(reservation), nil
+}
+
+// Adjust => canceled quantities of a partially canceled order are given back. Reserved quantities are released and
+// committed quantities (first shipment commits the order) are added to stock again, lines of reservation become the
+// remaining lines. Adjustment before reservation only changes lines which are reserved later.
+func (b *InventoryService) Adjust(command events.AdjustStock) (events.DomainEvent, error) {
+	reply := events.NewStockAdjusted(command.SagaID, command.OrderID, command.AdjustmentID)
+
+	reservation, err := b.Repository.GetReservationById(command.SagaID)
+	if err == mongo.ErrNoDocuments {
+		return reply, nil
+	}
+	if err != nil {
+		return events.DomainEvent{}, err
+	}
+	for _, adjustmentId := range reservation.Adjustments {
+		if adjustmentId == command.AdjustmentID {
+			return reply, nil
+		}
+	}
+
+	canceled := command.Models()
+	switch reservation.State {
+	case ReservationReserving, ReservationReserved:
+		for _, line := range canceled {
+			if err := b.Repository.ReduceLine(reservation.ID, line, command.AdjustmentID); err != nil {
+				return events.DomainEvent{}, err
+			}
+		}
+	case ReservationCommitted:
+		for _, line := range canceled {
+			if err := b.Repository.RestockLine(line); err != nil {
+				return events.DomainEvent{}, err
+			}
+		}
+	default:
+		// Released or rejected reservation has nothing to give back
+		return reply, nil
+	}
+
+	reservation.Lines = reduceLines(reservation.Lines, canceled)
+	reservation.Adjustments = append(reservation.Adjustments, command.AdjustmentID)
+	reservation.UpdatedAt = time.Now()
+	if err := b.Repository.UpsertReservation(reservation); err != nil {
+		return events.DomainEvent{}, err
+	}
+
+	return reply, nil
+}
+
+// SetStock => change quantity on hand of product, it cannot be less than reserved quantity
+func (b *InventoryService) SetStock(id string, stock int) error {
+	result, err := b.Repository.SetStock(id, stock, time.Now())
+	if err != nil {
+		return err
+	}
+	if result == false {
+		return ErrStockBelowReserved
+	}
+
+	return nil
+}
+
+func (b *InventoryService) releaseLines(reservation models.StockReservation) error {
+	for _, line := range reservation.Lines {
+		if err := b.Repository.ReleaseLine(reservation.ID, line.Sku); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reduceLines => lines after canceled quantities, lines without quantity are removed
+func reduceLines(lines []models.StockLine, canceled []models.StockLine) []models.StockLine {
+	quantities := map[string]int{}
+	for _, line := range canceled {
+		quantities[line.Sku] += line.Quantity
+	}
+
+	left := []models.StockLine{}
+	for _, line := range lines {
+		quantity := quantities[line.Sku]
+		if quantity > line.Quantity {
+			quantity = line.Quantity
+		}
+		quantities[line.Sku] -= quantity
+		if line.Quantity > quantity {
+			line.Quantity -= quantity
+			left = append(left, line)
+		}
+	}
+	return left
+}
+
+// reservationReply => reply of finished reservation, it is sent again when a command is repeated
+func reservationReply(reservation models.StockReservation) events.DomainEvent {
+	switch reservation.State {
+	case ReservationReserved:
+		return events.NewStockReserved(reservation.ID, reservation.OrderID)
+	case ReservationReleased:
+		return events.NewStockReleased(reservation.ID, reservation.OrderID)
+	case ReservationCommitted:
+		return events.NewStockCommitted(reservation.ID, reservation.OrderID)
+	default:
+		return events.NewStockReservationFailed(reservation.ID, reservation.OrderID, reservation.Reason, nil)
+	}
+}
@@ -0,0 +1,121 @@
+package roots
+
+import (
+	"OrderUserProject/internal/apps/product-api"
+	"OrderUserProject/internal/configs"
+	"OrderUserProject/internal/events"
+	kafkaPackage "OrderUserProject/pkg/kafka"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/labstack/echo/v4"
+)
+
+// maxHandleAttempt => a command is tried this many times, after that it is skipped (order-api sends it again later)
+const maxHandleAttempt = 3
+
+// InventoryCommandRoot => consume commands of inventory saga and send the reply of every command to 'InventoryEvents'
+type InventoryCommandRoot struct {
+	Service  product_api.IInventoryService
+	Consumer *kafkaPackage.ConsumerKafka
+	Producer *kafkaPackage.ProducerKafka
+	Config   *configs.Config
+	Logger   echo.Logger
+}
+
+func NewInventoryCommandRoot(service product_api.IInventoryService, consumer *kafkaPackage.ConsumerKafka, producer *kafkaPackage.ProducerKafka, config *configs.Config, logger echo.Logger) *InventoryCommandRoot {
+	return &InventoryCommandRoot{
+		Service:  service,
+		Consumer: consumer,
+		Producer: producer,
+		Config:   config,
+		Logger:   logger,
+	}
+}
+
+// StartConsumeInventoryCommands => Get message from Kafka to consume 'InventoryCommands'. Commands of an order come
+// with order id as key, so they are handled in the order they are sent.
+func (i *InventoryCommandRoot) StartConsumeInventoryCommands() {
+	i.Logger.Info("InventoryCommandRoot starting for consume 'InventoryCommands'.")
+	err := i.Consumer.SubscribeToTopics([]string{i.Config.Kafka.TopicName["InventoryCommands"]})
+	if err != nil {
+		i.Logger.Errorf("Kafka connection failed. | Error: %v\n", err)
+	}
+
+	for {
+		fromTopics, err := i.Consumer.ConsumeFromTopics(1, 5, 10)
+		if err != nil {
+			i.Logger.Errorf("An error when consume from topic. | Error: %v\n", err)
+		}
+
+		for _, message := range fromTopics {
+			for attempt := 1; attempt <= maxHandleAttempt; attempt++ {
+				err := i.handleMessage(message)
+				if err == nil {
+					break
+				}
+				i.Logger.Errorf("An error when handle inventory command (attempt %v). | Error: %v\n", attempt, err)
+			}
+		}
+
+		if len(fromTopics) > 0 {
+			i.Consumer.AckLastMessage()
+		}
+	}
+}
+
+func (i *InventoryCommandRoot) handleMessage(message kafka.Message) error {
+	var envelope events.Envelope
+	if err := i.Consumer.Deserialize(message, &envelope); err != nil {
+		return err
+	}
+	if err := events.Validate(envelope); err != nil {
+		return err
+	}
+
+	var reply events.DomainEvent
+	var err error
+
+	switch envelope.Type {
+	case events.ReserveStockType:
+		var command events.ReserveStock
+		if err := envelope.DecodePayload(&command); err != nil {
+			return err
+		}
+		reply, err = i.Service.Reserve(command)
+	case events.ReleaseStockType:
+		var command events.ReleaseStock
+		if err := envelope.DecodePayload(&command); err != nil {
+			return err
+		}
+		reply, err = i.Service.Release(command)
+	case events.CommitStockType:
+		var command events.CommitStock
+		if err := envelope.DecodePayload(&command); err != nil {
+			return err
+		}
+		reply, err = i.Service.Commit(command)
+	case events.AdjustStockType:
+		var command events.AdjustStock
+		if err := envelope.DecodePayload(&command); err != nil {
+			return err
+		}
+		reply, err = i.Service.Adjust(command)
+	default:
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	// => SEND MESSAGE (InventoryEvents) => reply is sent again if command is repeated, order-api ignores duplicates
+	replyEnvelope, err := reply.Envelope()
+	if err != nil {
+		return err
+	}
+	if err := i.Producer.SendToKafkaWithKey(reply.Key, replyEnvelope, i.Config.Kafka.TopicName["InventoryEvents"]); err != nil {
+		return err
+	}
+
+	i.Logger.Infof("%v command of order (%v) is handled, %v is sent.", envelope.Type, reply.Key, reply.Type)
+	return nil
+}
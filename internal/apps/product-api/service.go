@@ -0,0 +1,111 @@
+package product_api
+
+import (
+	"OrderUserProject/internal/models"
+	"OrderUserProject/internal/repository"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/mongo"
+	"strings"
+	"time"
+)
+
+type ProductService struct {
+	Repository repository.IProductRepository
+}
+
+func NewProductService(repository repository.IProductRepository) IProductService {
+	productService := &ProductService{
+		Repository: repository,
+	}
+	return productService
+}
+
+type IProductService interface {
+	GetAll() ([]models.Product, error)
+	GetProductById(id string) (models.Product, error)
+	GetProductsBySkus(skus []string) ([]models.Product, error)
+	Insert(product models.Product) (models.Product, error)
+	Update(product models.Product) (bool, error)
+	Delete(id string) (bool, error)
+}
+
+func (b *ProductService) GetAll() ([]models.Product, error) {
+	result, err := b.Repository.GetAll()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (b *ProductService) GetProductById(id string) (models.Product, error) {
+	result, err := b.Repository.GetProductById(id)
+
+	if err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// GetProductsBySkus => products of skus, order-api resolves order lines with it
+func (b *ProductService) GetProductsBySkus(skus []string) ([]models.Product, error) {
+	for i := range skus {
+		skus[i] = strings.TrimSpace(skus[i])
+	}
+
+	return b.Repository.GetProductsBySkus(skus)
+}
+
+func (b *ProductService) Insert(product models.Product) (models.Product, error) {
+	product.Sku = strings.TrimSpace(product.Sku)
+
+	// Sku is unique in catalog, unique index of sku rejects a product which is inserted at the same time
+	existing, err := b.Repository.GetProductsBySkus([]string{product.Sku})
+	if err != nil {
+		return product, err
+	}
+	if len(existing) > 0 {
+		return product, ErrSkuExists
+	}
+
+	// Create id and created date value
+	product.ID = uuid.New().String()
+	product.CreatedAt = time.Now()
+	product.UpdatedAt = product.CreatedAt
+
+	result, err := b.Repository.Insert(product)
+	if mongo.IsDuplicateKeyError(err) {
+		return product, ErrSkuExists
+	}
+
+	if err != nil || result == false {
+		return product, err
+	}
+
+	return product, nil
+}
+
+func (b *ProductService) Update(product models.Product) (bool, error) {
+	// to create updated date value
+	product.UpdatedAt = time.Now()
+
+	result, err := b.Repository.Update(product)
+
+	if err != nil || result == false {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (b *ProductService) Delete(id string) (bool, error) {
+	result, err := b.Repository.Delete(id)
+
+	if err != nil || result == false {
+		return false, err
+	}
+
+	return true, nil
+}
@@ -0,0 +1,408 @@
+package product_api
+
+import (
+	"OrderUserProject/internal/events"
+	"OrderUserProject/internal/models"
+	"OrderUserProject/pkg/money"
+	"errors"
+	"github.com/go-playground/assert/v2"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/mongo"
+	"testing"
+	"time"
+)
+
+var productList = []models.Product{
+	{
+		ID:        "6c3d3e0b-5d4c-4a3b-9c5e-0f6b0d2c2a11",
+		Sku:       "ASUS-NB-15",
+		Name:      "Asus Notebook",
+		Price:     money.MustParse("20000"),
+		Active:    true,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	},
+	{
+		ID:        "0b8f6a7e-3a4d-4c55-8a3e-7f1e2d9c4b22",
+		Sku:       "APPLE-AIRPODS-2",
+		Name:      "Airpods",
+		Price:     money.MustParse("4000"),
+		Active:    true,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	},
+}
+
+// MockProductRepository is a mock implementation of IProductRepository
+type MockProductRepository struct {
+	mock.Mock
+}
+
+func (m *MockProductRepository) GetAll() ([]models.Product, error) {
+	args := m.Called()
+	if args.Error(1) != nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Product), nil
+}
+
+func (m *MockProductRepository) GetProductById(id string) (models.Product, error) {
+	args := m.Called(id)
+	if args.Error(1) != nil {
+		return models.Product{}, args.Error(1)
+	}
+	return args.Get(0).(models.Product), nil
+}
+
+func (m *MockProductRepository) GetProductsBySkus(skus []string) ([]models.Product, error) {
+	args := m.Called(skus)
+	if args.Error(1) != nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Product), nil
+}
+
+func (m *MockProductRepository) Insert(product models.Product) (bool, error) {
+	args := m.Called(product)
+	if args.Error(1) != nil {
+		return false, args.Error(1)
+	}
+	return true, nil
+}
+
+func (m *MockProductRepository) CreateIndexes() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func (m *MockProductRepository) Update(product models.Product) (bool, error) {
+	args := m.Called(product)
+	if args.Error(1) != nil {
+		return false, args.Error(1)
+	}
+	return args.Bool(0), nil
+}
+
+func (m *MockProductRepository) Delete(id string) (bool, error) {
+	args := m.Called(id)
+	if args.Error(1) != nil {
+		return false, args.Error(1)
+	}
+	return args.Bool(0), nil
+}
+
+func TestProductService_GetAll_SuccessAndFail(t *testing.T) {
+	results := map[string]struct {
+		data []models.Product
+		err  error
+	}{
+		"success":  {productList, nil},
+		"fail-500": {nil, errors.New("something went wrong")},
+	}
+
+	for name, result := range results {
+		// Create a mock instance
+		mockRepo := new(MockProductRepository)
+		mockRepo.On("GetAll").Return(result.data, result.err)
+
+		// Create an instance of ProductService with the mock repository
+		productService := NewProductService(mockRepo)
+
+		products, err := productService.GetAll()
+		if !errors.Is(err, result.err) {
+			t.Errorf("%v: expected error: %v, but got: %v", name, result.err, err)
+		}
+		assert.Equal(t, len(result.data), len(products))
+	}
+}
+
+func TestProductService_Insert_SuccessAndSkuConflict(t *testing.T) {
+	product := models.Product{Sku: " IPHONE-12 ", Name: "Iphone 12", Price: money.MustParse("24000"), Active: true}
+
+	// Product with the same sku is inserted after check => unique index of sku rejects it
+	duplicateKeyErr := mongo.WriteException{WriteErrors: mongo.WriteErrors{{Code: 11000, Message: "E11000 duplicate key error"}}}
+
+	results := map[string]struct {
+		existing  []models.Product
+		insertErr error
+		inserted  bool
+		err       error
+	}{
+		"success":                {[]models.Product{}, nil, true, nil},
+		"sku-conflict":           {[]models.Product{{ID: "6c3d3e0b-5d4c-4a3b-9c5e-0f6b0d2c2a11", Sku: "IPHONE-12"}}, nil, false, ErrSkuExists},
+		"sku-conflict-on-insert": {[]models.Product{}, duplicateKeyErr, true, ErrSkuExists},
+	}
+
+	for name, result := range results {
+		// Create a mock instance
+		mockRepo := new(MockProductRepository)
+
+		// Sku is trimmed before uniqueness check
+		mockRepo.On("GetProductsBySkus", []string{"IPHONE-12"}).Return(result.existing, nil)
+		mockRepo.On("Insert", mock.AnythingOfType("models.Product")).Return(result.insertErr == nil, result.insertErr)
+
+		// Create an instance of ProductService with the mock repository
+		productService := NewProductService(mockRepo)
+
+		inserted, err := productService.Insert(product)
+		if !errors.Is(err, result.err) {
+			t.Errorf("%v: expected error: %v, but got: %v", name, result.err, err)
+		}
+
+		if result.err == nil {
+			assert.Equal(t, "IPHONE-12", inserted.Sku)
+			assert.NotEqual(t, "", inserted.ID)
+		}
+		if result.inserted {
+			mockRepo.AssertCalled(t, "Insert", mock.AnythingOfType("models.Product"))
+		} else {
+			mockRepo.AssertNotCalled(t, "Insert", mock.AnythingOfType("models.Product"))
+		}
+	}
+}
+
+func TestProductService_UpdateAndDelete_Success(t *testing.T) {
+	// Create a mock instance
+	mockRepo := new(MockProductRepository)
+	mockRepo.On("Update", mock.AnythingOfType("models.Product")).Return(true, nil)
+	mockRepo.On("Delete", productList[0].ID).Return(true, nil)
+
+	// Create an instance of ProductService with the mock repository
+	productService := NewProductService(mockRepo)
+
+	updated, err := productService.Update(productList[0])
+	if err != nil {
+		t.Error(err)
+	}
+	assert.Equal(t, true, updated)
+
+	deleted, err := productService.Delete(productList[0].ID)
+	if err != nil {
+		t.Error(err)
+	}
+	assert.Equal(t, true, deleted)
+}
+
+// MockInventoryRepository is a mock implementation of IInventoryRepository
+type MockInventoryRepository struct {
+	mock.Mock
+}
+
+func (m *MockInventoryRepository) GetReservationById(id string) (models.StockReservation, error) {
+	args := m.Called(id)
+	if args.Error(1) != nil {
+		return models.StockReservation{}, args.Error(1)
+	}
+	return args.Get(0).(models.StockReservation), nil
+}
+
+func (m *MockInventoryRepository) UpsertReservation(reservation models.StockReservation) error {
+	args := m.Called(reservation)
+	return args.Error(0)
+}
+
+func (m *MockInventoryRepository) ReserveLine(sagaId string, line models.StockLine) (bool, error) {
+	args := m.Called(sagaId, line)
+	if args.Error(1) != nil {
+		return false, args.Error(1)
+	}
+	return args.Bool(0), nil
+}
+
+func (m *MockInventoryRepository) ReleaseLine(sagaId string, sku string) error {
+	args := m.Called(sagaId, sku)
+	return args.Error(0)
+}
+
+func (m *MockInventoryRepository) CommitLine(sagaId string, sku string) error {
+	args := m.Called(sagaId, sku)
+	return args.Error(0)
+}
+
+func (m *MockInventoryRepository) ReduceLine(sagaId string, line models.StockLine, adjustmentId string) error {
+	args := m.Called(sagaId, line, adjustmentId)
+	return args.Error(0)
+}
+
+func (m *MockInventoryRepository) RestockLine(line models.StockLine) error {
+	args := m.Called(line)
+	return args.Error(0)
+}
+
+func (m *MockInventoryRepository) SetStock(id string, stock int, updatedAt time.Time) (bool, error) {
+	args := m.Called(id, stock, updatedAt)
+	if args.Error(1) != nil {
+		return false, args.Error(1)
+	}
+	return args.Bool(0), nil
+}
+
+var reserveStockCommand = events.ReserveStock{
+	SagaID:  "2b45ac31-6906-4e1e-82db-d9bcdbdb2143",
+	OrderID: "2b45ac31-6906-4e1e-82db-d9bcdbdb2143",
+	Lines:   []events.StockLine{{Sku: "ASUS-NB-15", Quantity: 1}, {Sku: "APPLE-AIRPODS-2", Quantity: 3}},
+}
+
+func TestInventoryService_Reserve_SuccessAndNotEnoughStock(t *testing.T) {
+	results := map[string]struct {
+		airpodsReserved bool
+		reply           string
+		state           string
+	}{
+		"success":          {true, events.StockReservedType, ReservationReserved},
+		"not-enough-stock": {false, events.StockReservationFailedType, ReservationRejected},
+	}
+
+	for name, result := range results {
+		// Create a mock instance
+		mockRepo := new(MockInventoryRepository)
+
+		mockRepo.On("GetReservationById", reserveStockCommand.SagaID).Return(models.StockReservation{}, mongo.ErrNoDocuments)
+		mockRepo.On("UpsertReservation", mock.AnythingOfType("models.StockReservation")).Return(nil)
+		mockRepo.On("ReserveLine", reserveStockCommand.SagaID, models.StockLine{Sku: "ASUS-NB-15", Quantity: 1}).Return(true, nil)
+		mockRepo.On("ReserveLine", reserveStockCommand.SagaID, models.StockLine{Sku: "APPLE-AIRPODS-2", Quantity: 3}).Return(result.airpodsReserved, nil)
+		mockRepo.On("ReleaseLine", reserveStockCommand.SagaID, mock.AnythingOfType("string")).Return(nil)
+
+		// Create an instance of InventoryService with the mock repository
+		inventoryService := NewInventoryService(mockRepo)
+
+		reply, err := inventoryService.Reserve(reserveStockCommand)
+		if err != nil {
+			t.Errorf("%v: %v", name, err)
+		}
+		assert.Equal(t, result.reply, reply.Type)
+		assert.Equal(t, reserveStockCommand.OrderID, reply.Key)
+
+		// Last state of reservation is saved
+		lastCall := mockRepo.Calls[len(mockRepo.Calls)-1]
+		assert.Equal(t, "UpsertReservation", lastCall.Method)
+		assert.Equal(t, result.state, lastCall.Arguments.Get(0).(models.StockReservation).State)
+
+		// Reserved lines are released again if a line cannot be reserved
+		if result.airpodsReserved {
+			mockRepo.AssertNotCalled(t, "ReleaseLine", reserveStockCommand.SagaID, "ASUS-NB-15")
+		} else {
+			mockRepo.AssertCalled(t, "ReleaseLine", reserveStockCommand.SagaID, "ASUS-NB-15")
+			assert.Equal(t, []string{"APPLE-AIRPODS-2"}, reply.Payload.(events.StockReservationFailed).Skus)
+		}
+	}
+}
+
+func TestInventoryService_RepeatedCommands_ReplyAgain(t *testing.T) {
+	reservation := models.StockReservation{ID: reserveStockCommand.SagaID, OrderID: reserveStockCommand.OrderID,
+		Lines: reserveStockCommand.Models(), State: ReservationReserved}
+
+	// Create a mock instance
+	mockRepo := new(MockInventoryRepository)
+	mockRepo.On("GetReservationById", reservation.ID).Return(reservation, nil)
+
+	// Create an instance of InventoryService with the mock repository
+	inventoryService := NewInventoryService(mockRepo)
+
+	// Reservation is finished, stock is not reserved twice
+	reply, err := inventoryService.Reserve(reserveStockCommand)
+	if err != nil {
+		t.Error(err)
+	}
+	assert.Equal(t, events.StockReservedType, reply.Type)
+	mockRepo.AssertNotCalled(t, "ReserveLine", mock.Anything, mock.Anything)
+
+	// Released reservation cannot be committed
+	reservation.State = ReservationReleased
+	mockRepo = new(MockInventoryRepository)
+	mockRepo.On("GetReservationById", reservation.ID).Return(reservation, nil)
+	inventoryService = NewInventoryService(mockRepo)
+
+	reply, err = inventoryService.Commit(events.CommitStock{SagaID: reservation.ID, OrderID: reservation.OrderID})
+	if err != nil {
+		t.Error(err)
+	}
+	assert.Equal(t, events.StockReservationFailedType, reply.Type)
+	mockRepo.AssertNotCalled(t, "CommitLine", mock.Anything, mock.Anything)
+}
+
+func TestInventoryService_Release_BeforeReserve(t *testing.T) {
+	// Create a mock instance
+	mockRepo := new(MockInventoryRepository)
+
+	mockRepo.On("GetReservationById", reserveStockCommand.SagaID).Return(models.StockReservation{}, mongo.ErrNoDocuments)
+	mockRepo.On("UpsertReservation", mock.AnythingOfType("models.StockReservation")).Return(nil)
+
+	// Create an instance of InventoryService with the mock repository
+	inventoryService := NewInventoryService(mockRepo)
+
+	reply, err := inventoryService.Release(events.ReleaseStock{SagaID: reserveStockCommand.SagaID, OrderID: reserveStockCommand.OrderID})
+	if err != nil {
+		t.Error(err)
+	}
+	assert.Equal(t, events.StockReleasedType, reply.Type)
+
+	// Released reservation is recorded, so reservation which comes later is not done
+	reservation := mockRepo.Calls[1].Arguments.Get(0).(models.StockReservation)
+	assert.Equal(t, ReservationReleased, reservation.State)
+	mockRepo.AssertNotCalled(t, "ReleaseLine", mock.Anything, mock.Anything)
+}
+
+func TestInventoryService_Adjust_PartialCancelThenCommit(t *testing.T) {
+	adjustCommand := events.AdjustStock{SagaID: reserveStockCommand.SagaID, OrderID: reserveStockCommand.OrderID,
+		AdjustmentID: "5d0b1c8e-3f2a-4e6b-9c7d-8a1b2c3d4e5f", Lines: []events.StockLine{{Sku: "APPLE-AIRPODS-2", Quantity: 2}}}
+
+	results := map[string]struct {
+		state       string
+		adjustments []string
+		reduced     bool
+		restocked   bool
+	}{
+		"reserved":          {ReservationReserved, nil, true, false},
+		"committed":         {ReservationCommitted, nil, false, true},
+		"released":          {ReservationReleased, nil, false, false},
+		"repeated-adjusted": {ReservationReserved, []string{adjustCommand.AdjustmentID}, false, false},
+	}
+
+	for name, result := range results {
+		t.Run(name, func(t *testing.T) {
+			reservation := models.StockReservation{ID: reserveStockCommand.SagaID, OrderID: reserveStockCommand.OrderID,
+				Lines: reserveStockCommand.Models(), State: result.state, Adjustments: result.adjustments}
+
+			// Create a mock instance
+			mockRepo := new(MockInventoryRepository)
+			mockRepo.On("GetReservationById", reservation.ID).Return(reservation, nil)
+			mockRepo.On("ReduceLine", reservation.ID, models.StockLine{Sku: "APPLE-AIRPODS-2", Quantity: 2}, adjustCommand.AdjustmentID).Return(nil)
+			mockRepo.On("RestockLine", models.StockLine{Sku: "APPLE-AIRPODS-2", Quantity: 2}).Return(nil)
+			mockRepo.On("UpsertReservation", mock.AnythingOfType("models.StockReservation")).Return(nil)
+
+			// Create an instance of InventoryService with the mock repository
+			inventoryService := NewInventoryService(mockRepo)
+
+			reply, err := inventoryService.Adjust(adjustCommand)
+			if err != nil {
+				t.Error(err)
+			}
+			assert.Equal(t, events.StockAdjustedType, reply.Type)
+			assert.Equal(t, adjustCommand.AdjustmentID, reply.Payload.(events.StockAdjusted).AdjustmentID)
+			assert.Equal(t, result.reduced, len(mockRepo.Calls) > 1 && mockRepo.Calls[1].Method == "ReduceLine")
+			assert.Equal(t, result.restocked, len(mockRepo.Calls) > 1 && mockRepo.Calls[1].Method == "RestockLine")
+			if !result.reduced {
+				return
+			}
+
+			// Remaining lines are committed when order is shipped
+			adjusted := mockRepo.Calls[len(mockRepo.Calls)-1].Arguments.Get(0).(models.StockReservation)
+			assert.Equal(t, []models.StockLine{{Sku: "ASUS-NB-15", Quantity: 1}, {Sku: "APPLE-AIRPODS-2", Quantity: 1}}, adjusted.Lines)
+			assert.Equal(t, []string{adjustCommand.AdjustmentID}, adjusted.Adjustments)
+
+			mockRepo = new(MockInventoryRepository)
+			mockRepo.On("GetReservationById", reservation.ID).Return(adjusted, nil)
+			mockRepo.On("CommitLine", reservation.ID, mock.AnythingOfType("string")).Return(nil)
+			mockRepo.On("UpsertReservation", mock.AnythingOfType("models.StockReservation")).Return(nil)
+			inventoryService = NewInventoryService(mockRepo)
+
+			reply, err = inventoryService.Commit(events.CommitStock{SagaID: reservation.ID, OrderID: reservation.OrderID})
+			if err != nil {
+				t.Error(err)
+			}
+			assert.Equal(t, events.StockCommittedType, reply.Type)
+			mockRepo.AssertNumberOfCalls(t, "CommitLine", 2)
+		})
+	}
+}
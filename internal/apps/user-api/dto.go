@@ -1,5 +1,16 @@
 package user_api
 
+import "errors"
+
+// ErrAddressNotFound => address id is not in address list of user
+var ErrAddressNotFound = errors.New("address not found")
+
+// Default address types of AddressDefaultRequest
+const (
+	DefaultInvoiceAddress = "invoice"
+	DefaultRegularAddress = "regular"
+)
+
 type UserCreateRequest struct {
 	Name      string                 `json:"name" validate:"required,min=1,max=100"`
 	Email     string                 `json:"email" validate:"required,email"`
@@ -55,3 +66,28 @@ type AddressResponse struct {
 		IsDefaultRegularAddress bool `json:"isDefaultRegularAddress" bson:"isDefaultRegularAddress"`
 	} `json:"default" bson:"default"`
 }
+
+// AddressDefaultRequest => address becomes default of these types ("invoice", "regular")
+type AddressDefaultRequest struct {
+	Type []string `json:"type" validate:"required,min=1,max=2,dive,oneof=invoice regular"`
+}
+
+// OpenOrdersResponse => open orders of user (or of an address of user) from order-api
+type OpenOrdersResponse struct {
+	UserId    string   `json:"userId"`
+	AddressId string   `json:"addressId,omitempty"`
+	Count     int      `json:"count"`
+	OrderIds  []string `json:"orderIds"`
+}
+
+// UserDeleteConflictResponse => user cannot delete because of open orders
+type UserDeleteConflictResponse struct {
+	Message  string   `json:"message"`
+	OrderIds []string `json:"orderIds"`
+}
+
+// AddressDeleteConflictResponse => address cannot delete because open orders are placed against it
+type AddressDeleteConflictResponse struct {
+	Message  string   `json:"message"`
+	OrderIds []string `json:"orderIds"`
+}
@@ -1,9 +1,13 @@
 package handler
 
 import (
+	"OrderUserProject/internal/address"
 	"OrderUserProject/internal/apps/user-api"
+	"OrderUserProject/internal/configs"
+	"OrderUserProject/internal/events"
 	"OrderUserProject/internal/models"
 	"OrderUserProject/pkg"
+	"OrderUserProject/pkg/kafka"
 	"fmt"
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
@@ -14,13 +18,17 @@ import (
 )
 
 type UserHandler struct {
-	Service   user_api.IUserService
-	Validator *validator.Validate
+	Service    user_api.IUserService
+	Validator  *validator.Validate
+	Producer   *kafka.ProducerKafka
+	Config     *configs.Config
+	Normalizer address.Normalizer
 }
 
-func NewUserHandler(e *echo.Echo, service user_api.IUserService, v *validator.Validate) *UserHandler {
+func NewUserHandler(e *echo.Echo, service user_api.IUserService, v *validator.Validate, producer *kafka.ProducerKafka, config *configs.Config,
+	normalizer address.Normalizer) *UserHandler {
 	router := e.Group("api/users")
-	b := &UserHandler{Service: service, Validator: v}
+	b := &UserHandler{Service: service, Validator: v, Producer: producer, Config: config, Normalizer: normalizer}
 
 	e.Use(pkg.CustomErrorMiddleware)
 
@@ -32,20 +40,31 @@ func NewUserHandler(e *echo.Echo, service user_api.IUserService, v *validator.Va
 	router.PUT("/add-address/:id", b.AddAddress)
 	router.PUT("/change-address/:id", b.ChangeAddress)
 	router.PUT("/delete-address/:id/:address_id", b.DeleteAddress)
+	router.PUT("/:id/addresses/:addressId/default", b.SetDefaultAddress)
 	router.DELETE("/:id", b.DeleteUser)
+	router.POST("/:id/restore", b.RestoreUser, pkg.AdminOnly(config.Server.AdminToken))
+	router.GET("/asyncapi.json", b.AsyncAPI)
 
 	return b
 }
 
 // GetAllUsers godoc
-// @Summary get all items in the user list
+// @Summary get all items in the user list, 'id' query filters users (order-api resolves users of orders with it)
 // @ID get-all-users
 // @Produce json
+// @Param id query []string false "user ids" collectionFormat(multi)
 // @Success 200 {array} models.JSONSuccessResultData
 // @Success 500 {object} pkg.CustomError
 // @Router /users [get]
 func (h *UserHandler) GetAllUsers(c echo.Context) error {
-	userList, err := h.Service.GetAll()
+	var userList []models.User
+	var err error
+
+	if ids := c.QueryParams()["id"]; len(ids) > 0 {
+		userList, err = h.Service.GetUsersByIds(ids)
+	} else {
+		userList, err = h.Service.GetAll()
+	}
 
 	if err != nil {
 		internalServerError := pkg.CustomError{
@@ -187,7 +206,13 @@ func (h *UserHandler) CreateUser(c echo.Context) error {
 		address.District = addressRequest.District
 		address.Type = addressRequest.Type
 		address.Default = addressRequest.Default
-		user.Addresses = append(user.Addresses, address)
+
+		// Canonical city and district names, unknown city-district pair is rejected
+		normalizedAddress, err := h.normalizeAddress(address)
+		if err != nil {
+			return err
+		}
+		user.Addresses = append(user.Addresses, normalizedAddress)
 	}
 
 	// Using 'bcrypt' to hash password
@@ -222,6 +247,9 @@ func (h *UserHandler) CreateUser(c echo.Context) error {
 		return internalServerError
 	}
 
+	// => SEND MESSAGE (UserEvents)
+	h.pushUserEvent(c, events.NewUserCreated(events.NewUser(result)))
+
 	// Response id and success boolean
 	jsonSuccessResultId := models.JSONSuccessResultId{
 		ID:      result.ID,
@@ -300,6 +328,9 @@ func (h *UserHandler) UpdateUser(c echo.Context) error {
 		return internalServerError
 	}
 
+	// => SEND MESSAGE (UserEvents)
+	h.pushUserUpdated(c, userExist)
+
 	// Response id and success boolean
 	jsonSuccessResultId := models.JSONSuccessResultId{
 		ID:      user.ID,
@@ -311,17 +342,81 @@ func (h *UserHandler) UpdateUser(c echo.Context) error {
 }
 
 // DeleteUser godoc
-// @Summary delete a user item by ID
+// @Summary delete a user item by ID. User with open orders cannot delete, admin can force it (open orders are canceled)
 // @ID delete-user-by-id
 // @Produce json
 // @Param id path string true "user ID"
+// @Param force query bool false "admin only, delete user and cancel open orders"
+// @Param X-Admin-Token header string false "admin token, required for force"
 // @Success 200 {object} models.JSONSuccessResultId
+// @Success 403 {object} pkg.CustomError
 // @Success 404 {object} pkg.CustomError
+// @Success 409 {object} user_api.UserDeleteConflictResponse
+// @Success 500 {object} pkg.CustomError
 // @Router /users/{id} [delete]
 func (h *UserHandler) DeleteUser(c echo.Context) error {
 	query := c.Param("id")
+	force := c.QueryParam("force") == "true"
+
+	if force && !pkg.IsAdmin(c, h.Config.Server.AdminToken) {
+		forbiddenError := pkg.CustomError{
+			Message:    "Forbidden. Only admin can force delete a user!",
+			StatusCode: http.StatusForbidden,
+		}
+		return forbiddenError
+	}
+
+	// User before delete is used for domain event
+	deletedUser, err := h.Service.GetUserById(query)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			notFoundError := pkg.CustomError{
+				Message:    fmt.Sprintf("Not found exception: {%v} with id not found!", query),
+				StatusCode: http.StatusNotFound,
+			}
+			return notFoundError
+		}
+		internalServerError := pkg.CustomError{
+			Message:    fmt.Sprintf("StatusInternalServerError: %v", err),
+			StatusCode: http.StatusInternalServerError,
+		}
+		return internalServerError
+	}
 
-	result, err := h.Service.Delete(query)
+	var result bool
+	if force {
+		// Admin => open orders are not checked, order-api cancels them when it consumes 'UserDeleted' event
+		if h.Config.SoftDelete.Enabled {
+			result, err = h.Service.SoftDelete(query)
+		} else {
+			result, err = h.Service.Delete(query)
+		}
+	} else {
+		// Check open orders with order-api, user cannot delete if we don't know
+		openOrders, checkErr := h.Service.GetOpenOrders(query, h.Config.HttpClient.OrderAPI, h.Config.Server.AdminToken)
+		if checkErr != nil {
+			internalServerError := pkg.CustomError{
+				Message:    fmt.Sprintf("StatusInternalServerError: open orders of user cannot check: %v", checkErr),
+				StatusCode: http.StatusInternalServerError,
+			}
+			return internalServerError
+		}
+
+		if openOrders.Count > 0 {
+			c.Logger().Infof("{%v} with id cannot delete, user has %v open orders.", query, openOrders.Count)
+			return c.JSON(http.StatusConflict, user_api.UserDeleteConflictResponse{
+				Message:  fmt.Sprintf("Conflict. User has %v open orders, they have to be delivered, canceled or closed before delete!", openOrders.Count),
+				OrderIds: openOrders.OrderIds,
+			})
+		}
+
+		// In soft delete mode user is kept until retention period, admin can restore it
+		if h.Config.SoftDelete.Enabled {
+			result, err = h.Service.SoftDelete(query)
+		} else {
+			result, err = h.Service.Delete(query)
+		}
+	}
 
 	if err != nil || result == false {
 		notFoundError := pkg.CustomError{
@@ -331,13 +426,59 @@ func (h *UserHandler) DeleteUser(c echo.Context) error {
 		return notFoundError
 	}
 
+	// => SEND MESSAGE (UserEvents)
+	h.pushUserEvent(c, events.NewUserDeleted(events.NewUser(deletedUser)))
+
 	// Response id and success boolean
 	jsonSuccessResultId := models.JSONSuccessResultId{
 		ID:      query,
 		Success: result,
 	}
 
-	c.Logger().Infof("{%v} with id is deleted.", jsonSuccessResultId.ID)
+	c.Logger().Infof("{%v} with id is deleted (force: %v).", jsonSuccessResultId.ID, force)
+	return c.JSON(http.StatusOK, jsonSuccessResultId)
+}
+
+// RestoreUser godoc
+// @Summary restore a soft deleted user by ID (admin only)
+// @ID restore-user-by-id
+// @Produce json
+// @Param id path string true "user ID"
+// @Param X-Admin-Token header string true "admin token"
+// @Success 200 {object} models.JSONSuccessResultId
+// @Success 403 {object} pkg.CustomError
+// @Success 404 {object} pkg.CustomError
+// @Success 500 {object} pkg.CustomError
+// @Router /users/{id}/restore [post]
+func (h *UserHandler) RestoreUser(c echo.Context) error {
+	query := c.Param("id")
+
+	user, err := h.Service.Restore(query)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			notFoundError := pkg.CustomError{
+				Message:    fmt.Sprintf("Not found exception: deleted user with {%v} id not found!", query),
+				StatusCode: http.StatusNotFound,
+			}
+			return notFoundError
+		}
+		internalServerError := pkg.CustomError{
+			Message:    fmt.Sprintf("StatusInternalServerError: %v", err),
+			StatusCode: http.StatusInternalServerError,
+		}
+		return internalServerError
+	}
+
+	// => SEND MESSAGE (UserEvents) => restored user is created again for consumers
+	h.pushUserEvent(c, events.NewUserCreated(events.NewUser(user)))
+
+	// Response id and success boolean
+	jsonSuccessResultId := models.JSONSuccessResultId{
+		ID:      user.ID,
+		Success: true,
+	}
+
+	c.Logger().Infof("{%v} with id is restored.", jsonSuccessResultId.ID)
 	return c.JSON(http.StatusOK, jsonSuccessResultId)
 }
 
@@ -392,6 +533,9 @@ func (h *UserHandler) AddAddress(c echo.Context) error {
 		return badRequestError
 	}
 
+	// User before change is used for domain event
+	userBefore := copyUser(user)
+
 	var userAddressModel models.Address
 	userAddressModel.ID = uuid.New().String()
 	userAddressModel.Address = userAddress.Address
@@ -400,8 +544,25 @@ func (h *UserHandler) AddAddress(c echo.Context) error {
 	userAddressModel.Type = userAddress.Type
 	userAddressModel.Default = userAddress.Default
 
+	// Canonical city and district names, unknown city-district pair is rejected
+	userAddressModel, err = h.normalizeAddress(userAddressModel)
+	if err != nil {
+		return err
+	}
+
 	user.Addresses = append(user.Addresses, userAddressModel)
 
+	// New default address demotes the previous default
+	user, err = h.Service.SetDefaultAddress(user, userAddressModel.ID,
+		userAddressModel.Default.IsDefaultInvoiceAddress, userAddressModel.Default.IsDefaultRegularAddress)
+	if err != nil {
+		internalServerError := pkg.CustomError{
+			Message:    fmt.Sprintf("StatusInternalServerError: {%v} ", err),
+			StatusCode: http.StatusInternalServerError,
+		}
+		return internalServerError
+	}
+
 	userAddressCheck, err := h.Service.InvoiceRegularAddressCheck(user)
 	if err != nil {
 		badRequestError := pkg.CustomError{
@@ -421,6 +582,9 @@ func (h *UserHandler) AddAddress(c echo.Context) error {
 		return internalServerError
 	}
 
+	// => SEND MESSAGE (UserEvents)
+	h.pushUserUpdated(c, userBefore)
+
 	// Response id and success boolean
 	jsonSuccessResultId := models.JSONSuccessResultId{
 		ID:      userAddressModel.ID,
@@ -490,12 +654,42 @@ func (h *UserHandler) ChangeAddress(c echo.Context) error {
 	userAddressModel.Type = userAddress.Type
 	userAddressModel.Default = userAddress.Default
 
+	// Canonical city and district names, unknown city-district pair is rejected
+	userAddressModel, err = h.normalizeAddress(userAddressModel)
+	if err != nil {
+		return err
+	}
+
+	// Address before change is used for domain event
+	var oldAddress models.Address
+	found := false
 	for i, address := range user.Addresses {
 		if address.ID == userAddressModel.ID {
+			oldAddress = address
+			found = true
 			user.Addresses[i] = userAddressModel
 		}
 	}
 
+	if !found {
+		notFoundError := pkg.CustomError{
+			Message:    fmt.Sprintf("Not found exception: address {%v} of user {%v} not found!", userAddressModel.ID, query),
+			StatusCode: http.StatusNotFound,
+		}
+		return notFoundError
+	}
+
+	// Changed address can become default, previous default is demoted
+	user, err = h.Service.SetDefaultAddress(user, userAddressModel.ID,
+		userAddressModel.Default.IsDefaultInvoiceAddress, userAddressModel.Default.IsDefaultRegularAddress)
+	if err != nil {
+		internalServerError := pkg.CustomError{
+			Message:    fmt.Sprintf("StatusInternalServerError: {%v} ", err),
+			StatusCode: http.StatusInternalServerError,
+		}
+		return internalServerError
+	}
+
 	userAddressCheck, err := h.Service.InvoiceRegularAddressCheck(user)
 	if err != nil {
 		badRequestError := pkg.CustomError{
@@ -515,6 +709,13 @@ func (h *UserHandler) ChangeAddress(c echo.Context) error {
 		return internalServerError
 	}
 
+	// => SEND MESSAGE (UserEvents)
+	for _, address := range userAddressCheck.Addresses {
+		if address.ID == userAddressModel.ID {
+			h.pushUserEvent(c, events.NewAddressChanged(user.ID, events.NewAddress(oldAddress), events.NewAddress(address)))
+		}
+	}
+
 	// Response id and success boolean
 	jsonSuccessResultId := models.JSONSuccessResultId{
 		ID:      userAddressModel.ID,
@@ -526,7 +727,7 @@ func (h *UserHandler) ChangeAddress(c echo.Context) error {
 }
 
 // DeleteAddress godoc
-// @Summary delete a user's address by userID
+// @Summary delete a user's address by userID. Address which is used by open orders cannot be deleted
 // @ID delete-address-with-userID
 // @Produce json
 // @Param id path string true "user ID"
@@ -534,6 +735,7 @@ func (h *UserHandler) ChangeAddress(c echo.Context) error {
 // @Success 200 {object} models.JSONSuccessResultId
 // @Success 400 {object} pkg.CustomError
 // @Success 404 {object} pkg.CustomError
+// @Success 409 {object} user_api.AddressDeleteConflictResponse
 // @Success 500 {object} pkg.CustomError
 // @Router /users/delete-address/{id}/{address_id} [put]
 func (h *UserHandler) DeleteAddress(c echo.Context) error {
@@ -565,12 +767,44 @@ func (h *UserHandler) DeleteAddress(c echo.Context) error {
 		return badRequestError
 	}
 
+	// Deleted address is used for domain event
+	var deletedAddress models.Address
+	found := false
 	for i, address := range user.Addresses {
 		if address.ID == queryAddressID {
+			deletedAddress = address
+			found = true
 			user.Addresses = append(user.Addresses[:i], user.Addresses[i+1:]...)
+			break
 		}
 	}
 
+	if !found {
+		notFoundError := pkg.CustomError{
+			Message:    fmt.Sprintf("Not found exception: address {%v} of user {%v} not found!", queryAddressID, queryID),
+			StatusCode: http.StatusNotFound,
+		}
+		return notFoundError
+	}
+
+	// Check open orders of address with order-api, address cannot delete if we don't know
+	openOrders, err := h.Service.GetOpenOrdersOfAddress(queryID, queryAddressID, h.Config.HttpClient.OrderAPI, h.Config.Server.AdminToken)
+	if err != nil {
+		internalServerError := pkg.CustomError{
+			Message:    fmt.Sprintf("StatusInternalServerError: open orders of address cannot check: %v", err),
+			StatusCode: http.StatusInternalServerError,
+		}
+		return internalServerError
+	}
+
+	if openOrders.Count > 0 {
+		c.Logger().Infof("Address {%v} cannot delete, it is used by %v open orders.", queryAddressID, openOrders.Count)
+		return c.JSON(http.StatusConflict, user_api.AddressDeleteConflictResponse{
+			Message:  fmt.Sprintf("Conflict. Address is used by %v open orders, they have to be delivered, canceled or closed before delete!", openOrders.Count),
+			OrderIds: openOrders.OrderIds,
+		})
+	}
+
 	userAddressCheck, err := h.Service.InvoiceRegularAddressCheck(user)
 	if err != nil {
 		badRequestError := pkg.CustomError{
@@ -590,6 +824,9 @@ func (h *UserHandler) DeleteAddress(c echo.Context) error {
 		return internalServerError
 	}
 
+	// => SEND MESSAGE (UserEvents)
+	h.pushUserEvent(c, events.NewAddressDeleted(user.ID, events.NewAddress(deletedAddress)))
+
 	// Response id and success boolean
 	jsonSuccessResultId := models.JSONSuccessResultId{
 		ID:      queryID,
@@ -599,3 +836,174 @@ func (h *UserHandler) DeleteAddress(c echo.Context) error {
 	c.Logger().Infof("{%v} with id is updated.", jsonSuccessResultId.ID)
 	return c.JSON(http.StatusOK, jsonSuccessResultId)
 }
+
+// SetDefaultAddress godoc
+// @Summary address becomes the default invoice and/or regular address of user, previous default is demoted
+// @ID set-default-address-with-userID
+// @Produce json
+// @Param id path string true "user ID"
+// @Param addressId path string true "address ID"
+// @Param data body user_api.AddressDefaultRequest true "default types (invoice, regular)"
+// @Success 200 {object} models.JSONSuccessResultId
+// @Success 400 {object} pkg.CustomError
+// @Success 404 {object} pkg.CustomError
+// @Success 500 {object} pkg.CustomError
+// @Router /users/{id}/addresses/{addressId}/default [put]
+func (h *UserHandler) SetDefaultAddress(c echo.Context) error {
+	queryID := c.Param("id")
+	queryAddressID := c.Param("addressId")
+
+	var defaultRequest user_api.AddressDefaultRequest
+
+	// We parse the data as json into the struct
+	if err := c.Bind(&defaultRequest); err != nil {
+		badRequestError := pkg.CustomError{
+			Message:    fmt.Sprintf("Bad Request. It cannot be binding! %v", err),
+			StatusCode: http.StatusBadRequest,
+		}
+		return badRequestError
+	}
+
+	// Validate user input using the validator instance
+	if err := h.Validator.Struct(defaultRequest); err != nil {
+		badRequestError := pkg.CustomError{
+			Message:    fmt.Sprintf("Bad Request. Please put valid default types (invoice, regular) ! %v", err),
+			StatusCode: http.StatusBadRequest,
+		}
+		return badRequestError
+	}
+
+	user, err := h.Service.GetUserById(queryID)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			notFoundError := pkg.CustomError{
+				Message:    fmt.Sprintf("Not found exception: {%v} with id not found!", queryID),
+				StatusCode: http.StatusNotFound,
+			}
+			return notFoundError
+		}
+		internalServerError := pkg.CustomError{
+			Message:    fmt.Sprintf("StatusInternalServerError: {%v} ", err),
+			StatusCode: http.StatusInternalServerError,
+		}
+		return internalServerError
+	}
+
+	// User before change is used for domain event
+	userBefore := copyUser(user)
+
+	invoice, regular := false, false
+	for _, defaultType := range defaultRequest.Type {
+		switch defaultType {
+		case user_api.DefaultInvoiceAddress:
+			invoice = true
+		case user_api.DefaultRegularAddress:
+			regular = true
+		}
+	}
+
+	user, err = h.Service.SetDefaultAddress(user, queryAddressID, invoice, regular)
+	if err != nil {
+		notFoundError := pkg.CustomError{
+			Message:    fmt.Sprintf("Not found exception: address {%v} of user {%v} not found!", queryAddressID, queryID),
+			StatusCode: http.StatusNotFound,
+		}
+		return notFoundError
+	}
+
+	result, err := h.Service.Update(user)
+
+	if err != nil || result == false {
+		internalServerError := pkg.CustomError{
+			Message:    fmt.Sprintf("StatusInternalServerError: {%v} ", err),
+			StatusCode: http.StatusInternalServerError,
+		}
+		return internalServerError
+	}
+
+	// => SEND MESSAGE (UserEvents)
+	h.pushUserUpdated(c, userBefore)
+
+	// Response id and success boolean
+	jsonSuccessResultId := models.JSONSuccessResultId{
+		ID:      queryAddressID,
+		Success: result,
+	}
+
+	c.Logger().Infof("{%v} with id is default address now.", jsonSuccessResultId.ID)
+	return c.JSON(http.StatusOK, jsonSuccessResultId)
+}
+
+// AsyncAPI godoc
+// @Summary asyncapi document of kafka events which are published by user-api
+// @ID get-user-asyncapi
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Success 500 {object} pkg.CustomError
+// @Router /users/asyncapi.json [get]
+func (h *UserHandler) AsyncAPI(c echo.Context) error {
+	document, err := events.NewAsyncAPI("User Microservice Events", "1.0.0", h.Config.Kafka.Address,
+		events.UserEventsChannel(h.Config.Kafka.TopicName["UserEvents"]).WithFormat(h.Config.Kafka.Serialization["UserEvents"]))
+	if err != nil {
+		internalServerErr := pkg.CustomError{
+			Message:    fmt.Sprintf("InternalServerError. AsyncAPI document cannot generate: %v", err),
+			StatusCode: http.StatusInternalServerError,
+		}
+		return internalServerErr
+	}
+
+	return c.JSON(http.StatusOK, document)
+}
+
+// pushUserEvent => send domain event to public 'UserEvents' topic. User id is the message key, so events of a user are
+// consumed in order. Errors are only logged, the request is already done.
+func (h *UserHandler) pushUserEvent(c echo.Context, domainEvent events.DomainEvent) {
+	envelope, err := domainEvent.Envelope()
+	if err != nil {
+		c.Logger().Errorf("Something went wrong convert to %v event: %v", domainEvent.Type, err)
+		return
+	}
+
+	err = h.Producer.SendToKafkaWithKey(domainEvent.Key, envelope, h.Config.Kafka.TopicName["UserEvents"])
+	if err != nil {
+		c.Logger().Errorf("Something went wrong %v event cannot pushed: %v", domainEvent.Type, err)
+	} else {
+		c.Logger().Infof("%v event of user (%v) pushed successfully.", domainEvent.Type, domainEvent.Key)
+	}
+}
+
+// pushUserUpdated => send 'UserUpdated' event, user after update is read again because service sets updatedAt
+func (h *UserHandler) pushUserUpdated(c echo.Context, before models.User) {
+	after, err := h.Service.GetUserById(before.ID)
+	if err != nil {
+		c.Logger().Errorf("Updated user (%v) cannot read for event: %v", before.ID, err)
+		return
+	}
+
+	h.pushUserEvent(c, events.NewUserUpdated(events.NewUser(before), events.NewUser(after)))
+}
+
+// normalizeAddress => address with canonical spellings from normalizer, bad request if it is rejected
+func (h *UserHandler) normalizeAddress(address models.Address) (models.Address, error) {
+	if h.Normalizer == nil {
+		return address, nil
+	}
+
+	normalizedAddress, err := h.Normalizer.Normalize(address)
+	if err != nil {
+		badRequestError := pkg.CustomError{
+			Message:    fmt.Sprintf("Bad Request. Please put valid city and district! %v", err),
+			StatusCode: http.StatusBadRequest,
+		}
+		return address, badRequestError
+	}
+	return normalizedAddress, nil
+}
+
+// copyUser => copy of user with its own address list, handlers change address list in place
+func copyUser(user models.User) models.User {
+	userCopy := user
+	userCopy.Addresses = append([]models.Address(nil), user.Addresses...)
+	return userCopy
+}
@@ -3,8 +3,15 @@ package user_api
 import (
 	"OrderUserProject/internal/models"
 	"OrderUserProject/internal/repository"
+	"OrderUserProject/pkg"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/google/uuid"
+	"github.com/labstack/gommon/log"
+	"go.mongodb.org/mongo-driver/mongo"
+	"net/http"
+	"strings"
 	"time"
 )
 
@@ -22,10 +29,17 @@ func NewUserService(repository repository.IUserRepository) IUserService {
 type IUserService interface {
 	GetAll() ([]models.User, error)
 	GetUserById(id string) (models.User, error)
+	GetUsersByIds(ids []string) ([]models.User, error)
 	Insert(user models.User) (models.User, error)
 	Update(user models.User) (bool, error)
 	Delete(id string) (bool, error)
 	InvoiceRegularAddressCheck(user models.User) (models.User, error)
+	SoftDelete(id string) (bool, error)
+	GetOpenOrders(userId string, orderURL string, adminToken string) (OpenOrdersResponse, error)
+	GetOpenOrdersOfAddress(userId string, addressId string, orderURL string, adminToken string) (OpenOrdersResponse, error)
+	SetDefaultAddress(user models.User, addressId string, invoice bool, regular bool) (models.User, error)
+	Restore(id string) (models.User, error)
+	PurgeDeleted(retention time.Duration) (int64, error)
 }
 
 func (b *UserService) GetAll() ([]models.User, error) {
@@ -49,6 +63,15 @@ func (b *UserService) GetUserById(id string) (models.User, error) {
 	return result, nil
 }
 
+// GetUsersByIds => users of ids, order-api resolves users of many orders with one request
+func (b *UserService) GetUsersByIds(ids []string) ([]models.User, error) {
+	for i := range ids {
+		ids[i] = strings.TrimSpace(ids[i])
+	}
+
+	return b.Repository.GetUsersByIds(ids)
+}
+
 func (b *UserService) Insert(user models.User) (models.User, error) {
 
 	// Create id and created date value
@@ -88,26 +111,134 @@ func (b *UserService) Delete(id string) (bool, error) {
 	return true, nil
 }
 
+// SoftDelete => user is marked as deleted instead of removing
+func (b *UserService) SoftDelete(id string) (bool, error) {
+	result, err := b.Repository.SoftDelete(id, time.Now())
+
+	if err != nil || result == false {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Restore => soft deleted user is restored, restored user is returned
+func (b *UserService) Restore(id string) (models.User, error) {
+	result, err := b.Repository.Restore(id)
+	if err != nil {
+		return models.User{}, err
+	}
+	if result == false {
+		return models.User{}, mongo.ErrNoDocuments
+	}
+
+	return b.Repository.GetUserById(id)
+}
+
+// PurgeDeleted => hard delete users which are soft deleted before retention period
+func (b *UserService) PurgeDeleted(retention time.Duration) (int64, error) {
+	return b.Repository.PurgeDeleted(time.Now().Add(-retention))
+}
+
+// GetOpenOrders => open orders of user from internal endpoint of order-api
+func (b *UserService) GetOpenOrders(userId string, orderURL string, adminToken string) (OpenOrdersResponse, error) {
+	return getOpenOrders(orderURL+"/internal/users/"+userId+"/open-orders", adminToken)
+}
+
+// GetOpenOrdersOfAddress => open orders of user which use the address as regular or invoice address
+func (b *UserService) GetOpenOrdersOfAddress(userId string, addressId string, orderURL string, adminToken string) (OpenOrdersResponse, error) {
+	return getOpenOrders(orderURL+"/internal/users/"+userId+"/addresses/"+addressId+"/open-orders", adminToken)
+}
+
+// getOpenOrders => call internal open orders endpoint of order-api, internal endpoints are only for admin token
+func getOpenOrders(url string, adminToken string) (OpenOrdersResponse, error) {
+	// Create a new HTTP client with a timeout
+	client := http.Client{
+		Timeout: time.Second * 20,
+	}
+
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return OpenOrdersResponse{}, err
+	}
+	request.Header.Set(pkg.AdminTokenHeader, adminToken)
+
+	respOrders, err := client.Do(request)
+	if err != nil {
+		return OpenOrdersResponse{}, err
+	}
+	defer func() {
+		if err := respOrders.Body.Close(); err != nil {
+			log.Errorf("Something went wrong: %v", err)
+		}
+	}()
+
+	if respOrders.StatusCode != http.StatusOK {
+		return OpenOrdersResponse{}, fmt.Errorf("open orders cannot get from order-api, status code: %v", respOrders.StatusCode)
+	}
+
+	var openOrdersResponse OpenOrdersResponse
+	if err := json.NewDecoder(respOrders.Body).Decode(&openOrdersResponse); err != nil {
+		return OpenOrdersResponse{}, err
+	}
+
+	return openOrdersResponse, nil
+}
+
+// SetDefaultAddress => address becomes the only default invoice and/or regular address of user, previous default
+// is demoted. False flags don't change anything.
+func (b *UserService) SetDefaultAddress(user models.User, addressId string, invoice bool, regular bool) (models.User, error) {
+	found := false
+	for _, address := range user.Addresses {
+		if address.ID == addressId {
+			found = true
+		}
+	}
+	if !found {
+		return user, ErrAddressNotFound
+	}
+
+	for i := range user.Addresses {
+		isAddress := user.Addresses[i].ID == addressId
+		if invoice {
+			user.Addresses[i].Default.IsDefaultInvoiceAddress = isAddress
+		}
+		if regular {
+			user.Addresses[i].Default.IsDefaultRegularAddress = isAddress
+		}
+	}
+
+	return user, nil
+}
+
 func (b *UserService) InvoiceRegularAddressCheck(user models.User) (models.User, error) {
-	// Invoice and regular addresses check
-	hasDefaultInvoice := false
-	hasDefaultRegular := false
+	// Invoice and regular addresses check, user has exactly one default of each
+	defaultInvoiceCount := 0
+	defaultRegularCount := 0
 	for _, addressRequest := range user.Addresses {
 		if addressRequest.Default.IsDefaultRegularAddress {
-			hasDefaultRegular = true
+			defaultRegularCount++
 		}
 		if addressRequest.Default.IsDefaultInvoiceAddress {
-			hasDefaultInvoice = true
+			defaultInvoiceCount++
 		}
 	}
 
-	if !hasDefaultInvoice {
+	if defaultInvoiceCount == 0 {
 		return user, errors.New("At least one address chosen as a default invoice address!")
 	}
 
-	if !hasDefaultRegular {
+	if defaultRegularCount == 0 {
 		return user, errors.New("At least one address chosen as a default regular address!")
 	}
 
+	if defaultInvoiceCount > 1 {
+		return user, errors.New("Only one address can be chosen as a default invoice address!")
+	}
+
+	if defaultRegularCount > 1 {
+		return user, errors.New("Only one address can be chosen as a default regular address!")
+	}
+
 	return user, nil
 }
@@ -2,9 +2,14 @@ package user_api
 
 import (
 	"OrderUserProject/internal/models"
+	"OrderUserProject/pkg"
+	"encoding/json"
 	"errors"
 	"github.com/go-playground/assert/v2"
 	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/mongo"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 )
@@ -102,6 +107,14 @@ func (m *MockUserRepository) GetUserById(id string) (models.User, error) {
 	return args.Get(0).(models.User), nil
 }
 
+func (m *MockUserRepository) GetUsersByIds(ids []string) ([]models.User, error) {
+	args := m.Called(ids)
+	if args.Error(1) != nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.User), nil
+}
+
 func (m *MockUserRepository) Insert(user models.User) (bool, error) {
 	args := m.Called(user)
 	if args.Error(1) != nil {
@@ -126,6 +139,27 @@ func (m *MockUserRepository) Delete(id string) (bool, error) {
 	return true, nil
 }
 
+func (m *MockUserRepository) SoftDelete(id string, deletedAt time.Time) (bool, error) {
+	args := m.Called(id, deletedAt)
+	if args.Error(1) != nil {
+		return false, args.Error(1)
+	}
+	return true, nil
+}
+
+func (m *MockUserRepository) Restore(id string) (bool, error) {
+	args := m.Called(id)
+	if args.Error(1) != nil {
+		return false, args.Error(1)
+	}
+	return args.Bool(0), nil
+}
+
+func (m *MockUserRepository) PurgeDeleted(deletedBefore time.Time) (int64, error) {
+	args := m.Called(deletedBefore)
+	return args.Get(0).(int64), args.Error(1)
+}
+
 func TestUserService_GetAll_Success(t *testing.T) {
 	// Create a mock instance
 	mockRepo := new(MockUserRepository)
@@ -203,6 +237,21 @@ func TestUserService_GetUserById_NotFoundFail(t *testing.T) {
 	}
 }
 
+func TestUserService_GetUsersByIds_TrimsIds(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	ids := []string{"4f6f687e-522a-4203-810b-827bc6c09180", "unknown"}
+	mockRepo.On("GetUsersByIds", ids).Return(userList[:1], nil)
+
+	userService := NewUserService(mockRepo)
+
+	// Ids of query string are trimmed, unknown ids are skipped by repository
+	users, err := userService.GetUsersByIds([]string{" 4f6f687e-522a-4203-810b-827bc6c09180", "unknown "})
+
+	assert.Equal(t, nil, err)
+	assert.Equal(t, userList[:1], users)
+	mockRepo.AssertCalled(t, "GetUsersByIds", ids)
+}
+
 func TestUserService_Insert_Success(t *testing.T) {
 	// Create a mock instance
 	mockRepo := new(MockUserRepository)
@@ -342,3 +391,168 @@ func TestUserService_Delete_NotFoundFail(t *testing.T) {
 	// We don't know exact user model because in service we have changed user model
 	mockRepo.AssertCalled(t, "Delete", id)
 }
+
+func TestUserService_SoftDelete_Success(t *testing.T) {
+	// Create a mock instance
+	mockRepo := new(MockUserRepository)
+
+	id := "4f6f687e-522a-4203-810b-827bc6c09180"
+
+	// Deleted date is set in service
+	mockRepo.On("SoftDelete", id, mock.AnythingOfType("time.Time")).Return(true, nil)
+
+	// Create an instance of UserService with the mock repository
+	userService := NewUserService(mockRepo)
+
+	result, err := userService.SoftDelete(id)
+
+	// Assert the result
+	if err != nil {
+		t.Error(err)
+	}
+	assert.Equal(t, true, result)
+
+	mockRepo.AssertCalled(t, "SoftDelete", id, mock.AnythingOfType("time.Time"))
+}
+
+func TestUserService_GetOpenOrders_SuccessAndFail(t *testing.T) {
+	userId := "4f6f687e-522a-4203-810b-827bc6c09180"
+
+	// Fake order-api
+	orderAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/orders/internal/users/"+userId+"/open-orders" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Header.Get(pkg.AdminTokenHeader) != "test-admin-token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(OpenOrdersResponse{UserId: userId, Count: 1, OrderIds: []string{"2b45ac31-6906-4e1e-82db-d9bcdbdb2143"}})
+	}))
+	defer orderAPI.Close()
+
+	userService := NewUserService(new(MockUserRepository))
+
+	openOrders, err := userService.GetOpenOrders(userId, orderAPI.URL+"/api/orders", "test-admin-token")
+	if err != nil {
+		t.Error(err)
+	}
+	assert.Equal(t, 1, openOrders.Count)
+	assert.Equal(t, []string{"2b45ac31-6906-4e1e-82db-d9bcdbdb2143"}, openOrders.OrderIds)
+
+	// order-api error => user cannot delete, so error is returned
+	_, err = userService.GetOpenOrders(userId, orderAPI.URL+"/wrong", "test-admin-token")
+	if err == nil {
+		t.Error("Expected error for wrong order-api url")
+	}
+
+	// Internal endpoints are only for admin token
+	_, err = userService.GetOpenOrders(userId, orderAPI.URL+"/api/orders", "wrong-token")
+	if err == nil {
+		t.Error("Expected error for wrong admin token")
+	}
+}
+
+func TestUserService_Restore_SuccessAndNotFoundFail(t *testing.T) {
+	id := "4f6f687e-522a-4203-810b-827bc6c09180"
+
+	for _, restored := range []bool{true, false} {
+		// Create a mock instance
+		mockRepo := new(MockUserRepository)
+
+		mockRepo.On("Restore", id).Return(restored, nil)
+		mockRepo.On("GetUserById", id).Return(models.User{ID: id}, nil)
+
+		// Create an instance of UserService with the mock repository
+		userService := NewUserService(mockRepo)
+
+		user, err := userService.Restore(id)
+
+		if restored {
+			if err != nil {
+				t.Error(err)
+			}
+			assert.Equal(t, id, user.ID)
+		} else {
+			// Nothing is restored => user is not deleted or it is purged
+			assert.Equal(t, mongo.ErrNoDocuments, err)
+			mockRepo.AssertNotCalled(t, "GetUserById", id)
+		}
+	}
+}
+
+func TestUserService_SetDefaultAddress_DemotesPreviousDefault(t *testing.T) {
+	userService := NewUserService(new(MockUserRepository))
+
+	// Kadıköy is default regular, Üsküdar is default invoice address
+	user := userList[1]
+	user.Addresses = append([]models.Address(nil), userList[1].Addresses...)
+
+	user, err := userService.SetDefaultAddress(user, "cc05be98-25af-4b22-b95c-bda2401bf6bc", true, false)
+	if err != nil {
+		t.Error(err)
+	}
+
+	// Kadıköy is default of both now, previous default invoice address is demoted
+	assert.Equal(t, true, user.Addresses[0].Default.IsDefaultInvoiceAddress)
+	assert.Equal(t, true, user.Addresses[0].Default.IsDefaultRegularAddress)
+	assert.Equal(t, false, user.Addresses[1].Default.IsDefaultInvoiceAddress)
+
+	_, err = userService.InvoiceRegularAddressCheck(user)
+	if err != nil {
+		t.Error(err)
+	}
+
+	// Unknown address
+	_, err = userService.SetDefaultAddress(user, "130beada-8339-4ee6-a754-725f43b8da98", true, true)
+	assert.Equal(t, ErrAddressNotFound, err)
+}
+
+func TestUserService_InvoiceRegularAddressCheck_SingleDefaults(t *testing.T) {
+	userService := NewUserService(new(MockUserRepository))
+
+	// Two default invoice addresses
+	user := userList[1]
+	user.Addresses = append([]models.Address(nil), userList[1].Addresses...)
+	user.Addresses[0].Default.IsDefaultInvoiceAddress = true
+
+	_, err := userService.InvoiceRegularAddressCheck(user)
+	if err == nil {
+		t.Error("Expected error for two default invoice addresses")
+	}
+
+	// No default regular address
+	user.Addresses[0].Default.IsDefaultInvoiceAddress = false
+	user.Addresses[0].Default.IsDefaultRegularAddress = false
+
+	_, err = userService.InvoiceRegularAddressCheck(user)
+	if err == nil {
+		t.Error("Expected error without default regular address")
+	}
+}
+
+func TestUserService_GetOpenOrdersOfAddress_Success(t *testing.T) {
+	userId := "4f6f687e-522a-4203-810b-827bc6c09180"
+	addressId := "130beada-8339-4ee6-a754-725f43b8da98"
+
+	// Fake order-api
+	orderAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/orders/internal/users/"+userId+"/addresses/"+addressId+"/open-orders" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(OpenOrdersResponse{UserId: userId, AddressId: addressId, Count: 1,
+			OrderIds: []string{"2b45ac31-6906-4e1e-82db-d9bcdbdb2143"}})
+	}))
+	defer orderAPI.Close()
+
+	userService := NewUserService(new(MockUserRepository))
+
+	openOrders, err := userService.GetOpenOrdersOfAddress(userId, addressId, orderAPI.URL+"/api/orders", "test-admin-token")
+	if err != nil {
+		t.Error(err)
+	}
+	assert.Equal(t, addressId, openOrders.AddressId)
+	assert.Equal(t, []string{"2b45ac31-6906-4e1e-82db-d9bcdbdb2143"}, openOrders.OrderIds)
+}
@@ -1,15 +1,34 @@
 package configs
 
+import (
+	"OrderUserProject/pkg/money"
+	"os"
+	"time"
+)
+
 type Config struct {
 	Server struct {
 		Port map[string]string
 		Host string
+		// AdminToken => value of 'X-Admin-Token' header for admin endpoints, admin endpoints are closed if it is empty
+		AdminToken string
 	}
 	Database struct {
-		Connection          string
-		DatabaseName        string
-		UserCollectionName  string
-		OrderCollectionName string
+		Connection            string
+		DatabaseName          string
+		UserCollectionName    string
+		OrderCollectionName   string
+		ProductCollectionName string
+		// SagaCollectionName => inventory sagas of order-api, ReservationCollectionName => stock reservations of product-api
+		SagaCollectionName        string
+		ReservationCollectionName string
+		// PromotionCollectionName => promotions and coupons of order-api
+		PromotionCollectionName string
+		// InvoiceCollectionName => invoices of orders, CounterCollectionName => sequences (e.g. invoice numbers)
+		InvoiceCollectionName string
+		CounterCollectionName string
+		// ResumeTokenCollectionName => resume tokens of change stream watchers (order-elastic)
+		ResumeTokenCollectionName string
 	}
 	Elasticsearch struct {
 		Addresses map[string]string
@@ -18,35 +37,122 @@ type Config struct {
 	Kafka struct {
 		Address   string
 		TopicName map[string]string
+		// OrderEventMode => "thin" sends only {orderID,status}, "full" sends the order snapshot too
+		OrderEventMode string
+		// Serialization => wire format of topic (key of TopicName): "json" or "avro".
+		// "OrderEvents", "UserEvents" and inventory topics carry several event types, so they cannot be "avro".
+		Serialization map[string]string
 	}
 	HttpClient struct {
-		UserAPI  string
-		OrderAPI string
+		UserAPI    string
+		OrderAPI   string
+		ProductAPI string
+	}
+	Order struct {
+		// AddressChangePolicy => "update" changes address snapshot of open orders when user changes the address,
+		// "keep" leaves orders untouched. Decision is recorded on order in both cases.
+		AddressChangePolicy string
+	}
+	SoftDelete struct {
+		// Enabled => delete endpoints of users and orders set deletedAt instead of removing the document
+		Enabled bool
+		// Retention => soft deleted documents are purged (hard deleted) after this period
+		Retention time.Duration
+		// PurgeInterval => period of purge job
+		PurgeInterval time.Duration
+	}
+	Address struct {
+		// DatasetPath => json file of cities and districts for address normalizer, built-in Turkish dataset if empty
+		DatasetPath string
+	}
+	Inventory struct {
+		// Enabled => orders reserve stock with inventory saga, orders are not checked with stock if it is false
+		Enabled bool
+		// ReplyTimeout => command of saga is sent again if product-api doesn't reply in this period
+		ReplyTimeout time.Duration
+		// MaxReserveAttempts => reserve command is sent again at most this many times, then order is canceled
+		MaxReserveAttempts int
+		// RetryInterval => period of job which sends commands of waiting sagas again
+		RetryInterval time.Duration
+	}
+	Pricing struct {
+		// Currency => currency of products which are created without currency (and of legacy products)
+		Currency string
+		// DefaultTaxClass => tax class of products which are created without tax class (and of legacy products)
+		DefaultTaxClass string
+		// VATRates => VAT percent of every tax class, prices are without VAT and VAT of order lines is calculated
+		// with rate of product tax class at order time
+		VATRates map[string]money.Rate
+		// ShippingFee => shipping fee of an order with VAT, free shipping promotions remove it
+		ShippingFee money.Amount
+	}
+	Invoice struct {
+		// Storage => "filesystem" keeps rendered invoices in Directory, "gridfs" in GridFS Bucket of database
+		Storage   string
+		Directory string
+		Bucket    string
+		// NumberPrefix => invoice numbers are "<prefix>-<year>-<sequence>", sequence starts from 1 every year
+		NumberPrefix string
+		// BillableStatuses => invoice of order is issued when order reaches one of these statuses
+		BillableStatuses []string
+		// Seller => issuer of invoices
+		SellerName      string
+		SellerAddress   string
+		SellerTaxNumber string
+	}
+	Stream struct {
+		// ChangeLogSize => last order changes which are kept in memory, clients resume GET /api/orders/stream with
+		// Last-Event-ID if it is still in log
+		ChangeLogSize int
+		// KeepAlive => period of comment lines which keep idle streams open behind proxies
+		KeepAlive time.Duration
+	}
+	OrderSync struct {
+		// Source => source of order-elastic, "kafka" reads 'OrderChanged' events of order-api, "changestream" tails
+		// orders collection with MongoDB change stream (needs replica set), so every write of orders reaches es
+		Source string
 	}
 }
 
 var Configs = map[string]Config{
 	"test": {
 		Server: struct {
-			Port map[string]string
-			Host string
+			Port       map[string]string
+			Host       string
+			AdminToken string
 		}{
 			Port: map[string]string{
-				"orderAPI": ":30011",
-				"userAPI":  ":30012",
+				"orderAPI":   ":30011",
+				"userAPI":    ":30012",
+				"productAPI": ":30013",
 			},
-			Host: "localhost",
+			Host:       "localhost",
+			AdminToken: "test-admin-token",
 		},
 		Database: struct {
-			Connection          string
-			DatabaseName        string
-			UserCollectionName  string
-			OrderCollectionName string
+			Connection                string
+			DatabaseName              string
+			UserCollectionName        string
+			OrderCollectionName       string
+			ProductCollectionName     string
+			SagaCollectionName        string
+			ReservationCollectionName string
+			PromotionCollectionName   string
+			InvoiceCollectionName     string
+			CounterCollectionName     string
+			ResumeTokenCollectionName string
 		}{
-			Connection:          "mongodb://localhost:27017",
-			DatabaseName:        "ProjectDB",
-			UserCollectionName:  "Users",
-			OrderCollectionName: "Orders",
+			Connection:                "mongodb://localhost:27017",
+			DatabaseName:              "ProjectDB",
+			UserCollectionName:        "Users",
+			OrderCollectionName:       "Orders",
+			ProductCollectionName:     "Products",
+			SagaCollectionName:        "InventorySagas",
+			ReservationCollectionName: "StockReservations",
+			PromotionCollectionName:   "Promotions",
+			InvoiceCollectionName:     "Invoices",
+			CounterCollectionName:     "Counters",
+			ResumeTokenCollectionName: "ResumeTokens",
 		},
 		Elasticsearch: struct {
 			Addresses map[string]string
@@ -56,48 +162,171 @@ var Configs = map[string]Config{
 				"Address 1": "http://localhost:9200",
 			},
 			IndexName: map[string]string{
-				"OrderSave": "order_duplicate_v01",
+				"OrderSave": "order_duplicate_v03",
+				// OrderSavePrevious => index before texts are analyzed as Turkish for full-text search, it is reindexed
+				// to "OrderSave"
+				"OrderSavePrevious": "order_duplicate_v02",
 			},
 		},
 		Kafka: struct {
-			Address   string
-			TopicName map[string]string
+			Address        string
+			TopicName      map[string]string
+			OrderEventMode string
+			Serialization  map[string]string
 		}{
 			Address: "localhost:9092",
 			TopicName: map[string]string{
 				"OrderID":    "orderID-created-v01",
 				"OrderModel": "orderDuplicate-created-v01",
+				// OrderEvents => public topic of order domain events
+				"OrderEvents": "order-events",
+				// UserEvents => public topic of user domain events
+				"UserEvents": "user-events",
+				// InventoryCommands and InventoryEvents => internal topics of inventory saga (order-api <=> product-api)
+				"InventoryCommands": "inventory-commands",
+				"InventoryEvents":   "inventory-events",
+				// OrderIDDeadLetter => order events which order-elastic cannot handle after attempts
+				"OrderIDDeadLetter": "orderID-dead-letter",
+				// UserEventsDeadLetter => user events which order-api cannot handle after attempts
+				"UserEventsDeadLetter": "user-events-dead-letter",
+				// InventoryEventsDeadLetter => inventory events which order-api cannot handle after attempts
+				"InventoryEventsDeadLetter": "inventory-events-dead-letter",
+			},
+			OrderEventMode: "full",
+			Serialization: map[string]string{
+				"OrderID":           "json",
+				"OrderModel":        "json",
+				"OrderEvents":       "json",
+				"UserEvents":        "json",
+				"InventoryCommands": "json",
+				"InventoryEvents":   "json",
 			},
 		},
 		HttpClient: struct {
-			UserAPI  string
-			OrderAPI string
+			UserAPI    string
+			OrderAPI   string
+			ProductAPI string
+		}{
+			UserAPI:    "http://localhost:30012/api/users",
+			OrderAPI:   "http://localhost:30011/api/orders",
+			ProductAPI: "http://localhost:30013/api/products",
+		},
+		Order: struct {
+			AddressChangePolicy string
+		}{
+			AddressChangePolicy: "update",
+		},
+		SoftDelete: struct {
+			Enabled       bool
+			Retention     time.Duration
+			PurgeInterval time.Duration
+		}{
+			Enabled:       true,
+			Retention:     30 * 24 * time.Hour,
+			PurgeInterval: time.Hour,
+		},
+		Address: struct {
+			DatasetPath string
+		}{
+			DatasetPath: "",
+		},
+		Inventory: struct {
+			Enabled            bool
+			ReplyTimeout       time.Duration
+			MaxReserveAttempts int
+			RetryInterval      time.Duration
+		}{
+			Enabled:            true,
+			ReplyTimeout:       30 * time.Second,
+			MaxReserveAttempts: 5,
+			RetryInterval:      15 * time.Second,
+		},
+		Pricing: struct {
+			Currency        string
+			DefaultTaxClass string
+			VATRates        map[string]money.Rate
+			ShippingFee     money.Amount
+		}{
+			Currency:        "TRY",
+			DefaultTaxClass: "standard",
+			VATRates: map[string]money.Rate{
+				"standard": money.MustParseRate("20"),
+				"reduced":  money.MustParseRate("10"),
+				"basic":    money.MustParseRate("1"),
+				"exempt":   money.MustParseRate("0"),
+			},
+			ShippingFee: money.MustParse("29.90"),
+		},
+		Invoice: struct {
+			Storage          string
+			Directory        string
+			Bucket           string
+			NumberPrefix     string
+			BillableStatuses []string
+			SellerName       string
+			SellerAddress    string
+			SellerTaxNumber  string
+		}{
+			Storage:          "filesystem",
+			Directory:        "invoices",
+			Bucket:           "invoices",
+			NumberPrefix:     "INV",
+			BillableStatuses: []string{"Shipped", "Delivered"},
+			SellerName:       "OrderUserProject A.Ş.",
+			SellerAddress:    "Maslak Mah. Büyükdere Cad. No:1, Sarıyer/İstanbul",
+			SellerTaxNumber:  "1234567890",
+		},
+		Stream: struct {
+			ChangeLogSize int
+			KeepAlive     time.Duration
+		}{
+			ChangeLogSize: 1000,
+			KeepAlive:     15 * time.Second,
+		},
+		OrderSync: struct {
+			Source string
 		}{
-			UserAPI:  "http://localhost:30012/api/users",
-			OrderAPI: "http://localhost:30011/api/orders",
+			Source: "kafka",
 		},
 	},
 	"production": {
 		Server: struct {
-			Port map[string]string
-			Host string
+			Port       map[string]string
+			Host       string
+			AdminToken string
 		}{
 			Port: map[string]string{
-				"orderAPI": ":8011",
-				"userAPI":  ":8012",
+				"orderAPI":   ":8011",
+				"userAPI":    ":8012",
+				"productAPI": ":8013",
 			},
-			Host: "",
+			Host:       "",
+			AdminToken: os.Getenv("ADMIN_TOKEN"),
 		},
 		Database: struct {
-			Connection          string
-			DatabaseName        string
-			UserCollectionName  string
-			OrderCollectionName string
+			Connection                string
+			DatabaseName              string
+			UserCollectionName        string
+			OrderCollectionName       string
+			ProductCollectionName     string
+			SagaCollectionName        string
+			ReservationCollectionName string
+			PromotionCollectionName   string
+			InvoiceCollectionName     string
+			CounterCollectionName     string
+			ResumeTokenCollectionName string
 		}{
-			Connection:          "mongodb://172.28.0.51:27017",
-			DatabaseName:        "ProjectDB",
-			UserCollectionName:  "Users",
-			OrderCollectionName: "Orders",
+			Connection:                "mongodb://172.28.0.51:27017",
+			DatabaseName:              "ProjectDB",
+			UserCollectionName:        "Users",
+			OrderCollectionName:       "Orders",
+			ProductCollectionName:     "Products",
+			SagaCollectionName:        "InventorySagas",
+			ReservationCollectionName: "StockReservations",
+			PromotionCollectionName:   "Promotions",
+			InvoiceCollectionName:     "Invoices",
+			CounterCollectionName:     "Counters",
+			ResumeTokenCollectionName: "ResumeTokens",
 		},
 		Elasticsearch: struct {
 			Addresses map[string]string
@@ -107,25 +336,131 @@ var Configs = map[string]Config{
 				"Address 1": "http://172.28.0.55:9200",
 			},
 			IndexName: map[string]string{
-				"OrderSave": "order_duplicate_v01",
+				"OrderSave": "order_duplicate_v03",
+				// OrderSavePrevious => index before texts are analyzed as Turkish for full-text search, it is reindexed
+				// to "OrderSave"
+				"OrderSavePrevious": "order_duplicate_v02",
 			},
 		},
 		Kafka: struct {
-			Address   string
-			TopicName map[string]string
+			Address        string
+			TopicName      map[string]string
+			OrderEventMode string
+			Serialization  map[string]string
 		}{
 			Address: "172.28.0.53:9092",
 			TopicName: map[string]string{
 				"OrderID":    "orderID-created-v01",
 				"OrderModel": "orderDuplicate-created-v01",
+				// OrderEvents => public topic of order domain events
+				"OrderEvents": "order-events",
+				// UserEvents => public topic of user domain events
+				"UserEvents": "user-events",
+				// InventoryCommands and InventoryEvents => internal topics of inventory saga (order-api <=> product-api)
+				"InventoryCommands": "inventory-commands",
+				"InventoryEvents":   "inventory-events",
+				// OrderIDDeadLetter => order events which order-elastic cannot handle after attempts
+				"OrderIDDeadLetter": "orderID-dead-letter",
+				// UserEventsDeadLetter => user events which order-api cannot handle after attempts
+				"UserEventsDeadLetter": "user-events-dead-letter",
+				// InventoryEventsDeadLetter => inventory events which order-api cannot handle after attempts
+				"InventoryEventsDeadLetter": "inventory-events-dead-letter",
+			},
+			OrderEventMode: "full",
+			Serialization: map[string]string{
+				"OrderID":           "json",
+				"OrderModel":        "json",
+				"OrderEvents":       "json",
+				"UserEvents":        "json",
+				"InventoryCommands": "json",
+				"InventoryEvents":   "json",
 			},
 		},
 		HttpClient: struct {
-			UserAPI  string
-			OrderAPI string
+			UserAPI    string
+			OrderAPI   string
+			ProductAPI string
+		}{
+			UserAPI:    "http://user-api:80/api/users",
+			OrderAPI:   "http://order-api:80/api/orders",
+			ProductAPI: "http://product-api:80/api/products",
+		},
+		Order: struct {
+			AddressChangePolicy string
+		}{
+			AddressChangePolicy: "update",
+		},
+		SoftDelete: struct {
+			Enabled       bool
+			Retention     time.Duration
+			PurgeInterval time.Duration
+		}{
+			Enabled:       true,
+			Retention:     30 * 24 * time.Hour,
+			PurgeInterval: time.Hour,
+		},
+		Address: struct {
+			DatasetPath string
+		}{
+			DatasetPath: "",
+		},
+		Inventory: struct {
+			Enabled            bool
+			ReplyTimeout       time.Duration
+			MaxReserveAttempts int
+			RetryInterval      time.Duration
 		}{
-			UserAPI:  "http://user-api:80/api/users",
-			OrderAPI: "http://order-api:80/api/orders",
+			Enabled:            true,
+			ReplyTimeout:       30 * time.Second,
+			MaxReserveAttempts: 5,
+			RetryInterval:      15 * time.Second,
+		},
+		Pricing: struct {
+			Currency        string
+			DefaultTaxClass string
+			VATRates        map[string]money.Rate
+			ShippingFee     money.Amount
+		}{
+			Currency:        "TRY",
+			DefaultTaxClass: "standard",
+			VATRates: map[string]money.Rate{
+				"standard": money.MustParseRate("20"),
+				"reduced":  money.MustParseRate("10"),
+				"basic":    money.MustParseRate("1"),
+				"exempt":   money.MustParseRate("0"),
+			},
+			ShippingFee: money.MustParse("29.90"),
+		},
+		Invoice: struct {
+			Storage          string
+			Directory        string
+			Bucket           string
+			NumberPrefix     string
+			BillableStatuses []string
+			SellerName       string
+			SellerAddress    string
+			SellerTaxNumber  string
+		}{
+			Storage:          "gridfs",
+			Directory:        "invoices",
+			Bucket:           "invoices",
+			NumberPrefix:     "INV",
+			BillableStatuses: []string{"Shipped", "Delivered"},
+			SellerName:       "OrderUserProject A.Ş.",
+			SellerAddress:    "Maslak Mah. Büyükdere Cad. No:1, Sarıyer/İstanbul",
+			SellerTaxNumber:  "1234567890",
+		},
+		Stream: struct {
+			ChangeLogSize int
+			KeepAlive     time.Duration
+		}{
+			ChangeLogSize: 1000,
+			KeepAlive:     15 * time.Second,
+		},
+		OrderSync: struct {
+			Source string
+		}{
+			Source: "kafka",
 		},
 	},
 	"qa": {},
@@ -156,6 +491,14 @@ var GenericEndpointConfigs = map[string]GenericEndpointConfig{
 			"product.quantity":        "product.quantity",
 			"product.price":           "product.price",
 			"total":                   "total",
+			"subtotal":                "subtotal",
+			"discount":                "discount",
+			"tax":                     "tax",
+			"shipping":                "shipping",
+			"currency":                "currency",
+			"couponCode":              "couponCode",
+			"promotions.code":         "promotions.code",
+			"promotions.type":         "promotions.type",
 			"createdAt":               "createdAt",
 			"createdAT":               "createdAt",
 			"updatedAt":               "updatedAt",
@@ -203,6 +546,14 @@ var GenericEndpointConfigs = map[string]GenericEndpointConfig{
 			"product.quantity":        "product.quantity",
 			"product.price":           "product.price",
 			"total":                   "total",
+			"subtotal":                "subtotal",
+			"discount":                "discount",
+			"tax":                     "tax",
+			"shipping":                "shipping",
+			"currency":                "currency",
+			"couponCode":              "couponCode",
+			"promotions.code":         "promotions.code",
+			"promotions.type":         "promotions.type",
 			"createdAt":               "createdAt",
 			"createdAT":               "createdAt",
 			"updatedAt":               "updatedAt",
@@ -241,6 +592,54 @@ var GenericEndpointConfigs = map[string]GenericEndpointConfig{
 		}},
 }
 
+// Types of fields of generic endpoints, operators and values of filters are checked with them
+const (
+	FieldTypeString = "string"
+	FieldTypeNumber = "number"
+	FieldTypeDate   = "date"
+	FieldTypeBool   = "bool"
+)
+
+// GenericEndpointFieldTypes => fields which can be filtered on generic endpoints (fields of GenericEndpointConfigs)
+// with their types, other fields are rejected
+var GenericEndpointFieldTypes = map[string]string{
+	"id":                      FieldTypeString,
+	"_id":                     FieldTypeString,
+	"userId":                  FieldTypeString,
+	"userID":                  FieldTypeString,
+	"status":                  FieldTypeString,
+	"product.name":            FieldTypeString,
+	"product.quantity":        FieldTypeNumber,
+	"product.price":           FieldTypeNumber,
+	"total":                   FieldTypeNumber,
+	"subtotal":                FieldTypeNumber,
+	"discount":                FieldTypeNumber,
+	"tax":                     FieldTypeNumber,
+	"shipping":                FieldTypeNumber,
+	"currency":                FieldTypeString,
+	"couponCode":              FieldTypeString,
+	"promotions.code":         FieldTypeString,
+	"promotions.type":         FieldTypeString,
+	"createdAt":               FieldTypeDate,
+	"createdAT":               FieldTypeDate,
+	"updatedAt":               FieldTypeDate,
+	"updatedAT":               FieldTypeDate,
+	"address.id":              FieldTypeString,
+	"address.address":         FieldTypeString,
+	"address.city":            FieldTypeString,
+	"address.district":        FieldTypeString,
+	"address.type":            FieldTypeString,
+	"invoiceAddress.id":       FieldTypeString,
+	"invoiceAddress.address":  FieldTypeString,
+	"invoiceAddress.city":     FieldTypeString,
+	"invoiceAddress.district": FieldTypeString,
+	"invoiceAddress.type":     FieldTypeString,
+	"address.default.isDefaultInvoiceAddress":        FieldTypeBool,
+	"address.default.isDefaultRegularAddress":        FieldTypeBool,
+	"invoiceAddress.default.isDefaultInvoiceAddress": FieldTypeBool,
+	"invoiceAddress.default.isDefaultRegularAddress": FieldTypeBool,
+}
+
 func GetGenericEndpointConfig(database string) GenericEndpointConfig {
 	return GenericEndpointConfigs[database]
 }
@@ -0,0 +1,258 @@
+package events
+
+import (
+	"OrderUserProject/pkg/kafka"
+	"OrderUserProject/pkg/money"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AsyncAPIVersion => version of AsyncAPI specification of generated documents
+const AsyncAPIVersion = "2.6.0"
+
+// AvroSchemaFormat => schema format of messages which have avro schema as payload
+const AvroSchemaFormat = "application/vnd.apache.avro+json;version=1.9.0"
+
+// AsyncAPIChannel => kafka topic and events which are published to it
+type AsyncAPIChannel struct {
+	Topic       string
+	Description string
+	// Format => serialization format of topic in config (configs.Config.Kafka.Serialization), json if it is empty
+	Format   string
+	Messages []AsyncAPIMessage
+}
+
+// WithFormat => same channel with serialization format of topic
+func (c AsyncAPIChannel) WithFormat(format string) AsyncAPIChannel {
+	c.Format = format
+	return c
+}
+
+// AsyncAPIMessage => event type of channel, payload schema is generated from Go type of payload
+type AsyncAPIMessage struct {
+	Type    string
+	Version int
+	Summary string
+	Payload interface{}
+}
+
+// OrderEventsChannel => public topic of order domain events
+func OrderEventsChannel(topic string) AsyncAPIChannel {
+	return AsyncAPIChannel{
+		Topic:       topic,
+		Description: "Public order domain events with order state before and after the change. Messages of an order have the order id as key.",
+		Messages: []AsyncAPIMessage{
+			{Type: OrderCreatedType, Version: OrderCreatedVersion, Summary: "Order is created.", Payload: OrderCreated{}},
+			{Type: OrderStatusChangedType, Version: OrderStatusChangedVersion, Summary: "Status of order is changed.", Payload: OrderStatusChanged{}},
+			{Type: OrderCanceledType, Version: OrderCanceledVersion, Summary: "Order or some of its lines are canceled.", Payload: OrderCanceled{}},
+			{Type: OrderDeletedType, Version: OrderDeletedVersion, Summary: "Order is deleted.", Payload: OrderDeleted{}},
+			{Type: RefundIssuedType, Version: RefundIssuedVersion, Summary: "Refund of a cancellation is issued.", Payload: RefundIssued{}},
+		},
+	}
+}
+
+// OrderChangedChannel => internal topic of order-elastic
+func OrderChangedChannel(topic string) AsyncAPIChannel {
+	return AsyncAPIChannel{
+		Topic:       topic,
+		Description: "Internal topic to keep elasticsearch duplicate of orders, it is not a public contract.",
+		Messages: []AsyncAPIMessage{
+			{Type: OrderChangedType, Version: OrderChangedFullVersion, Summary: "Order is created, updated or deleted.", Payload: OrderChanged{}},
+		},
+	}
+}
+
+// NewAsyncAPI => AsyncAPI document of channels which are published by the application. Every message is an envelope,
+// payload schema is generated from Go type with reflection, so document is never out of date. Messages of avro topics
+// have avro schema of envelope as payload.
+func NewAsyncAPI(title string, version string, server string, channels ...AsyncAPIChannel) (map[string]interface{}, error) {
+	messages := map[string]interface{}{}
+	channelItems := map[string]interface{}{}
+
+	for _, channel := range channels {
+		contentType, err := kafka.ContentTypeOfFormat(channel.Format)
+		if err != nil {
+			return nil, fmt.Errorf("channel %v: %w", channel.Topic, err)
+		}
+
+		var refs []interface{}
+		for _, message := range channel.Messages {
+			asyncMessage := map[string]interface{}{
+				"name":        message.Type,
+				"title":       message.Type + " v" + strconv.Itoa(message.Version),
+				"summary":     message.Summary,
+				"contentType": contentType,
+				"headers": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"content-type": map[string]interface{}{
+							"type":        "string",
+							"description": "Serializer of message value",
+							"const":       contentType,
+						},
+					},
+				},
+				"payload": envelopeSchema(message),
+			}
+
+			if contentType == kafka.ContentTypeAvro {
+				payload, err := avroPayload(message.Type)
+				if err != nil {
+					return nil, fmt.Errorf("channel %v: %w", channel.Topic, err)
+				}
+				asyncMessage["schemaFormat"] = AvroSchemaFormat
+				asyncMessage["payload"] = payload
+			}
+
+			messages[message.Type] = asyncMessage
+			refs = append(refs, map[string]interface{}{"$ref": "#/components/messages/" + message.Type})
+		}
+
+		var message interface{} = map[string]interface{}{"oneOf": refs}
+		if len(refs) == 1 {
+			message = refs[0]
+		}
+
+		// 'subscribe' => the application publishes and others subscribe
+		channelItems[channel.Topic] = map[string]interface{}{
+			"description": channel.Description,
+			"subscribe": map[string]interface{}{
+				"message": message,
+			},
+		}
+	}
+
+	return map[string]interface{}{
+		"asyncapi": AsyncAPIVersion,
+		"info": map[string]interface{}{
+			"title":   title,
+			"version": version,
+		},
+		"servers": map[string]interface{}{
+			"kafka": map[string]interface{}{
+				"url":      server,
+				"protocol": "kafka",
+			},
+		},
+		"channels": channelItems,
+		"components": map[string]interface{}{
+			"messages": messages,
+		},
+	}, nil
+}
+
+// avroPayload => avro schema of envelope of event type as payload of message
+func avroPayload(eventType string) (map[string]interface{}, error) {
+	schema, err := AvroSchema(eventType)
+	if err != nil {
+		return nil, err
+	}
+
+	var payload map[string]interface{}
+	if err := json.Unmarshal([]byte(schema), &payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// envelopeSchema => json schema of envelope which carries the message
+func envelopeSchema(message AsyncAPIMessage) map[string]interface{} {
+	return map[string]interface{}{
+		"type":     "object",
+		"required": []string{"type", "version", "id", "occurredAt", "payload"},
+		"properties": map[string]interface{}{
+			"type":       map[string]interface{}{"type": "string", "const": message.Type},
+			"version":    map[string]interface{}{"type": "integer", "const": message.Version},
+			"id":         map[string]interface{}{"type": "string", "format": "uuid"},
+			"occurredAt": map[string]interface{}{"type": "string", "format": "date-time"},
+			"payload":    JSONSchemaOf(reflect.TypeOf(message.Payload)),
+		},
+	}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Money types are json numbers
+var amountType, rateType = reflect.TypeOf(money.Amount{}), reflect.TypeOf(money.Rate{})
+
+// JSONSchemaOf => json schema of Go type, properties come from json tags and descriptions from description tags.
+// Fields without 'omitempty' are required.
+func JSONSchemaOf(t reflect.Type) map[string]interface{} {
+	if t == timeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+	if t == amountType || t == rateType {
+		return map[string]interface{}{"type": "number"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return JSONSchemaOf(t.Elem())
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": JSONSchemaOf(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": JSONSchemaOf(t.Elem())}
+	case reflect.Struct:
+		properties := map[string]interface{}{}
+		required := []string{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+
+			name, omitEmpty := jsonName(field)
+			if name == "-" {
+				continue
+			}
+
+			property := JSONSchemaOf(field.Type)
+			if description := field.Tag.Get("description"); description != "" {
+				property["description"] = description
+			}
+			properties[name] = property
+
+			if !omitEmpty {
+				required = append(required, name)
+			}
+		}
+		return map[string]interface{}{"type": "object", "required": required, "properties": properties}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// jsonName => name of field in json and 'omitempty' option
+func jsonName(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("json")
+	if tag == "" {
+		return field.Name, false
+	}
+
+	parts := strings.Split(tag, ",")
+	name := parts[0]
+	if name == "" {
+		name = field.Name
+	}
+
+	omitEmpty := false
+	for _, option := range parts[1:] {
+		if option == "omitempty" {
+			omitEmpty = true
+		}
+	}
+	return name, omitEmpty
+}
@@ -0,0 +1,193 @@
+package events
+
+import (
+	"OrderUserProject/internal/models"
+	"OrderUserProject/pkg/kafka"
+	"OrderUserProject/pkg/money"
+	"github.com/go-playground/assert/v2"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestNewOrderUpdated_EventTypeByStatus(t *testing.T) {
+	before := NewOrder(models.Order{ID: "2b45ac31-6906-4e1e-82db-d9bcdbdb2143", UserId: "5e77f1b3-fb22-4bb4-8f4c-6a1b7d4ad2a4",
+		Status: "Not Shipped", CreatedAt: time.Now(), UpdatedAt: time.Now()})
+
+	results := map[string]struct {
+		status    string
+		ok        bool
+		eventType string
+	}{
+		"status-not-changed": {"Not Shipped", false, ""},
+		"status-changed":     {"Shipped", true, OrderStatusChangedType},
+		"canceled":           {OrderCanceledStatus, true, OrderCanceledType},
+	}
+
+	for name, result := range results {
+		after := before
+		after.Status = result.status
+
+		domainEvent, ok := NewOrderUpdated(before, after)
+		assert.Equal(t, result.ok, ok)
+		assert.Equal(t, result.eventType, domainEvent.Type)
+
+		if ok {
+			if _, err := domainEvent.Envelope(); err != nil {
+				t.Errorf("%v: %v", name, err)
+			}
+		}
+	}
+
+	for _, domainEvent := range []DomainEvent{NewOrderCreated(before), NewOrderDeleted(before)} {
+		if _, err := domainEvent.Envelope(); err != nil {
+			t.Errorf("%v: %v", domainEvent.Type, err)
+		}
+	}
+}
+
+func TestCancelEvents_AreValid(t *testing.T) {
+	order := models.Order{ID: "2b45ac31-6906-4e1e-82db-d9bcdbdb2143", UserId: "5e77f1b3-fb22-4bb4-8f4c-6a1b7d4ad2a4",
+		Status: "Not Shipped", Currency: "TRY", Total: money.MustParse("108"), CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	cancellation := models.OrderCancellation{ID: "9d1c4f0e-7f8a-4d4c-b1b5-2f7c8d0e6a11", Reason: "OutOfStock", Partial: true,
+		Lines: []models.CanceledLine{{Sku: "AIRPODS-3", Name: "AirPods", Quantity: 1}}, RefundID: "4c8e2d7a-3b1f-4e9a-8c6d-5a2b1f0e9d33"}
+	refund := models.Refund{ID: cancellation.RefundID, CancellationID: cancellation.ID, Amount: money.MustParse("108"),
+		Currency: "TRY", Method: "StoreCredit", Status: "Issued"}
+	order.Cancellations = []models.OrderCancellation{cancellation}
+
+	canceled := NewOrderCanceled(NewOrder(order), NewOrder(order), cancellation)
+	issued := NewRefundIssued(order, refund)
+	assert.Equal(t, cancellation.Reason, issued.Payload.(RefundIssued).Reason)
+
+	for _, domainEvent := range []DomainEvent{canceled, issued} {
+		assert.Equal(t, order.ID, domainEvent.Key)
+		if _, err := domainEvent.Envelope(); err != nil {
+			t.Errorf("%v: %v", domainEvent.Type, err)
+		}
+	}
+}
+
+func TestUserEvents_AreValid(t *testing.T) {
+	user := NewUser(models.User{ID: "fcd20a19-6171-4737-a2ed-23e293cae7b5", Name: "Emre", CreatedAt: time.Now(), UpdatedAt: time.Now(),
+		Addresses: []models.Address{{ID: "130beada-8339-4ee6-a754-725f43b8da98", City: "İstanbul"}}})
+
+	for _, domainEvent := range []DomainEvent{NewUserCreated(user), NewUserUpdated(user, user), NewUserDeleted(user),
+		NewAddressChanged(user.ID, user.Addresses[0], user.Addresses[0]), NewAddressDeleted(user.ID, user.Addresses[0])} {
+		assert.Equal(t, user.ID, domainEvent.Key)
+		if _, err := domainEvent.Envelope(); err != nil {
+			t.Errorf("%v: %v", domainEvent.Type, err)
+		}
+	}
+}
+
+func TestInventoryEvents_AreValid(t *testing.T) {
+	sagaID := "2b45ac31-6906-4e1e-82db-d9bcdbdb2143"
+	lines := []models.StockLine{{Sku: "ASUS-NB-15", Quantity: 2}}
+
+	for _, domainEvent := range []DomainEvent{NewReserveStock(sagaID, sagaID, lines), NewReleaseStock(sagaID, sagaID),
+		NewCommitStock(sagaID, sagaID), NewStockReserved(sagaID, sagaID), NewStockReservationFailed(sagaID, sagaID, "not enough stock", nil),
+		NewStockReleased(sagaID, sagaID), NewStockCommitted(sagaID, sagaID), NewAdjustStock(sagaID, sagaID, sagaID, lines), NewStockAdjusted(sagaID, sagaID, sagaID)} {
+		assert.Equal(t, sagaID, domainEvent.Key)
+		if _, err := domainEvent.Envelope(); err != nil {
+			t.Errorf("%v: %v", domainEvent.Type, err)
+		}
+	}
+
+	// Reservation without lines is not a valid command
+	if _, err := NewReserveStock(sagaID, sagaID, nil).Envelope(); err == nil {
+		t.Errorf("%v without lines is valid", ReserveStockType)
+	}
+}
+
+func TestNewAsyncAPI_PayloadsMatchRegisteredSchemas(t *testing.T) {
+	channels := []AsyncAPIChannel{OrderEventsChannel("order-events"), OrderChangedChannel("orderID"),
+		UserEventsChannel("user-events"), InventoryCommandsChannel("inventory-commands"), InventoryEventsChannel("inventory-events")}
+	document, err := NewAsyncAPI("Order API", "1.0.0", "localhost:9092", channels...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	messages := document["components"].(map[string]interface{})["messages"].(map[string]interface{})
+
+	for _, channel := range channels {
+		for _, message := range channel.Messages {
+			asyncMessage := messages[message.Type].(map[string]interface{})
+			assert.Equal(t, kafka.ContentTypeJSON, asyncMessage["contentType"])
+
+			generated := asyncMessage["payload"].(map[string]interface{})
+			assert.Equal(t, []string{"type", "version", "id", "occurredAt", "payload"}, generated["required"])
+			envelope := generated["properties"].(map[string]interface{})
+			assert.Equal(t, message.Type, envelope["type"].(map[string]interface{})["const"])
+			assert.Equal(t, message.Version, envelope["version"].(map[string]interface{})["const"])
+			payload := envelope["payload"].(map[string]interface{})
+
+			registered := DefaultRegistry.raw[message.Type][message.Version]
+			if registered == nil {
+				t.Fatalf("%v v%v is not registered", message.Type, message.Version)
+			}
+
+			assert.Equal(t, propertyNames(registered), propertyNames(payload))
+			assert.Equal(t, requiredNames(registered), requiredNames(payload))
+		}
+	}
+}
+
+func TestNewAsyncAPI_ContentTypeOfFormat(t *testing.T) {
+	results := map[string]struct {
+		channel      AsyncAPIChannel
+		contentType  string
+		schemaFormat interface{}
+		payloadName  interface{}
+		err          bool
+	}{
+		"default":           {OrderChangedChannel("orderID"), kafka.ContentTypeJSON, nil, nil, false},
+		"json":              {OrderChangedChannel("orderID").WithFormat(kafka.FormatJSON), kafka.ContentTypeJSON, nil, nil, false},
+		"avro":              {OrderChangedChannel("orderID").WithFormat(kafka.FormatAvro), kafka.ContentTypeAvro, AvroSchemaFormat, "OrderChangedEnvelope", false},
+		"avro-without-avsc": {UserEventsChannel("user-events").WithFormat(kafka.FormatAvro), "", nil, nil, true},
+		"unknown":           {OrderChangedChannel("orderID").WithFormat("xml"), "", nil, nil, true},
+	}
+
+	for name, result := range results {
+		t.Run(name, func(t *testing.T) {
+			document, err := NewAsyncAPI("Order API", "1.0.0", "localhost:9092", result.channel)
+			assert.Equal(t, result.err, err != nil)
+			if err != nil {
+				return
+			}
+
+			messages := document["components"].(map[string]interface{})["messages"].(map[string]interface{})
+			asyncMessage := messages[OrderChangedType].(map[string]interface{})
+			assert.Equal(t, result.contentType, asyncMessage["contentType"])
+			assert.Equal(t, result.schemaFormat, asyncMessage["schemaFormat"])
+
+			header := asyncMessage["headers"].(map[string]interface{})["properties"].(map[string]interface{})[kafka.ContentTypeHeader]
+			assert.Equal(t, result.contentType, header.(map[string]interface{})["const"])
+
+			if result.payloadName != nil {
+				assert.Equal(t, result.payloadName, asyncMessage["payload"].(map[string]interface{})["name"])
+			}
+		})
+	}
+}
+
+func propertyNames(schema map[string]interface{}) []string {
+	var names []string
+	for name := range schema["properties"].(map[string]interface{}) {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func requiredNames(schema map[string]interface{}) []string {
+	var names []string
+	switch required := schema["required"].(type) {
+	case []string:
+		names = append(names, required...)
+	case []interface{}:
+		for _, name := range required {
+			names = append(names, name.(string))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,40 @@
+package events
+
+import (
+	"OrderUserProject/pkg/kafka"
+	"embed"
+	"fmt"
+)
+
+// Avro schemas of event envelopes, one schema for each event type. Payload record is the union of every version
+// (fields added in later versions are nullable), so one schema can read every version of the event.
+//
+//go:embed avro
+var avroFiles embed.FS
+
+// AvroSchema => avro schema of event type for kafka avro serializer
+func AvroSchema(eventType string) (string, error) {
+	content, err := avroFiles.ReadFile("avro/" + eventType + ".avsc")
+	if err != nil {
+		return "", fmt.Errorf("avro schema of event %v is not found", eventType)
+	}
+	return string(content), nil
+}
+
+// NewSerializer => kafka serializer of topic which carries the event type, format comes from config
+func NewSerializer(format string, eventType string) (kafka.Serializer, error) {
+	schema, err := AvroSchema(eventType)
+	if err != nil && format == kafka.FormatAvro {
+		return nil, err
+	}
+	return kafka.NewSerializer(format, schema)
+}
+
+// NewAvroSerializer => avro serializer of event type, consumers register it to read avro messages
+func NewAvroSerializer(eventType string) (*kafka.AvroSerializer, error) {
+	schema, err := AvroSchema(eventType)
+	if err != nil {
+		return nil, err
+	}
+	return kafka.NewAvroSerializer(schema)
+}
@@ -0,0 +1,91 @@
+package events
+
+import (
+	"OrderUserProject/internal/models"
+	"OrderUserProject/pkg/kafka"
+	"OrderUserProject/pkg/money"
+	"github.com/go-playground/assert/v2"
+	"testing"
+	"time"
+)
+
+func TestAvroSchema_ReadsEveryVersion(t *testing.T) {
+	order := NewOrder(models.Order{ID: "2b45ac31-6906-4e1e-82db-d9bcdbdb2143", Status: "Shipped",
+		Address: models.Address{ID: "130beada-8339-4ee6-a754-725f43b8da98", City: "İstanbul", Type: []string{"Regular"}},
+		Total:   money.MustParse("24000"), CreatedAt: time.Now(), UpdatedAt: time.Now()})
+	order.Product = []Product{{Name: "Airpods", Quantity: 1, Price: money.MustParse("4000")}}
+	legacyOrder := order
+
+	// Amounts of later versions are nullable in avro schema
+	order.Currency = "TRY"
+	order.Subtotal, order.Discount, order.Tax = money.MustParse("24000"), money.MustParse("100.10"), money.MustParse("4779.98")
+	order.Total = money.MustParse("28679.88")
+	order.Product = []Product{{Name: "Airpods", Quantity: 1, Price: money.MustParse("4000"), TaxRate: money.MustParseRate("20"),
+		Discount: money.MustParse("16.68"), Tax: money.MustParse("796.66"), Total: money.MustParse("4779.98")}}
+	amountsOrder := order
+
+	// Shipping and promotions of later versions are nullable too
+	order.Shipping, order.CouponCode = money.MustParse("0"), "WELCOME10"
+	order.Promotions = []Promotion{
+		{PromotionID: "b6c5f5a4-1f0e-4f61-9a8e-6a1d0f1c2a01", Code: "WELCOME10", Name: "Welcome", Type: "FixedAmount", Amount: money.MustParse("100.10")},
+		{PromotionID: "b6c5f5a4-1f0e-4f61-9a8e-6a1d0f1c2a03", Name: "Free shipping", Type: "FreeShipping", Amount: money.MustParse("29.90")},
+	}
+
+	payloads := map[string]struct {
+		eventType string
+		version   int
+		payload   interface{}
+	}{
+		"order-changed-v1":  {OrderChangedType, OrderChangedThinVersion, OrderChanged{OrderID: order.ID, Status: "Deleted"}},
+		"order-changed-v2":  {OrderChangedType, 2, OrderChanged{OrderID: order.ID, Status: "Created", Order: &legacyOrder}},
+		"order-snapshot-v1": {OrderSnapshotType, 1, legacyOrder},
+		"order-changed-v3":  {OrderChangedType, 3, OrderChanged{OrderID: order.ID, Status: "Updated", Order: &amountsOrder}},
+		"order-snapshot-v2": {OrderSnapshotType, 2, amountsOrder},
+		"order-changed-v4":  {OrderChangedType, OrderChangedFullVersion, OrderChanged{OrderID: order.ID, Status: "Updated", Order: &order}},
+		"order-snapshot-v3": {OrderSnapshotType, OrderSnapshotVersion, order},
+	}
+
+	for name, result := range payloads {
+		schema, err := AvroSchema(result.eventType)
+		if err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+		serializer, err := kafka.NewAvroSerializer(schema)
+		if err != nil {
+			t.Fatalf("%v: avro schema cannot compile: %v", name, err)
+		}
+
+		envelope, err := New(result.eventType, result.version, result.payload)
+		if err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+
+		data, err := serializer.Marshal(envelope)
+		if err != nil {
+			t.Fatalf("%v: avro cannot encode envelope: %v", name, err)
+		}
+
+		var decoded Envelope
+		if err := serializer.Unmarshal(data, &decoded); err != nil {
+			t.Fatalf("%v: avro cannot decode envelope: %v", name, err)
+		}
+
+		// Decoded envelope has to be valid for json schema of the same version
+		if err := Validate(decoded); err != nil {
+			t.Errorf("%v: decoded envelope is invalid: %v", name, err)
+		}
+		assert.Equal(t, envelope.ID, decoded.ID)
+		assert.Equal(t, envelope.Version, decoded.Version)
+
+		// Amounts are doubles in avro, they are read back exactly
+		if result.eventType == OrderSnapshotType && result.version == OrderSnapshotVersion {
+			var decodedOrder Order
+			if err := decoded.DecodePayload(&decodedOrder); err != nil {
+				t.Fatalf("%v: %v", name, err)
+			}
+			assert.Equal(t, order.Total, decodedOrder.Total)
+			assert.Equal(t, order.Product[0].Tax, decodedOrder.Product[0].Tax)
+			assert.Equal(t, order.Promotions, decodedOrder.Promotions)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"time"
+)
+
+// Envelope => every Kafka message is wrapped with this model, so consumers know which schema (type + version) to use
+type Envelope struct {
+	Type       string          `json:"type"`
+	Version    int             `json:"version"`
+	ID         string          `json:"id"`
+	OccurredAt time.Time       `json:"occurredAt"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+// ErrNotEnvelope => message was produced before envelopes (legacy message), consumer can decode it in old way
+var ErrNotEnvelope = errors.New("message is not an event envelope")
+
+// New => create envelope for payload, payload is validated with registered schema before produce
+func New(eventType string, version int, payload interface{}) (Envelope, error) {
+	payloadJson, err := json.Marshal(payload)
+	if err != nil {
+		return Envelope{}, err
+	}
+
+	if err := DefaultRegistry.Validate(eventType, version, payloadJson); err != nil {
+		return Envelope{}, err
+	}
+
+	envelope := Envelope{
+		Type:       eventType,
+		Version:    version,
+		ID:         uuid.New().String(),
+		OccurredAt: time.Now().UTC(),
+		Payload:    payloadJson,
+	}
+
+	return envelope, nil
+}
+
+// Validate => check consumed envelope and validate payload with registered schema
+func Validate(envelope Envelope) error {
+	if envelope.Type == "" || envelope.Version == 0 {
+		return ErrNotEnvelope
+	}
+
+	if envelope.ID == "" {
+		return fmt.Errorf("event (%v v%v) has no id", envelope.Type, envelope.Version)
+	}
+
+	return DefaultRegistry.Validate(envelope.Type, envelope.Version, envelope.Payload)
+}
+
+// DecodePayload => unmarshal payload into given model
+func (e Envelope) DecodePayload(v interface{}) error {
+	return json.Unmarshal(e.Payload, v)
+}
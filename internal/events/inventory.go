@@ -0,0 +1,250 @@
+package events
+
+import "OrderUserProject/internal/models"
+
+// Commands of inventory saga, order-api sends them to 'InventoryCommands' topic and product-api handles them.
+// Message key is the order id, so commands of an order are handled in order.
+const (
+	ReserveStockType = "ReserveStock"
+	ReleaseStockType = "ReleaseStock"
+	CommitStockType  = "CommitStock"
+	AdjustStockType  = "AdjustStock"
+)
+
+// Replies of inventory saga, product-api sends them to 'InventoryEvents' topic and order-api moves the saga with them
+const (
+	StockReservedType          = "StockReserved"
+	StockReservationFailedType = "StockReservationFailed"
+	StockReleasedType          = "StockReleased"
+	StockCommittedType         = "StockCommitted"
+	StockAdjustedType          = "StockAdjusted"
+)
+
+// Latest versions of inventory event types
+const (
+	ReserveStockVersion           = 1
+	ReleaseStockVersion           = 1
+	CommitStockVersion            = 1
+	AdjustStockVersion            = 1
+	StockReservedVersion          = 1
+	StockReservationFailedVersion = 1
+	StockReleasedVersion          = 1
+	StockCommittedVersion         = 1
+	StockAdjustedVersion          = 1
+)
+
+// StockLine => sku and quantity of an order line
+type StockLine struct {
+	Sku      string `json:"sku"`
+	Quantity int    `json:"quantity"`
+}
+
+// ReserveStock => reserve stock of every line or nothing
+type ReserveStock struct {
+	SagaID  string      `json:"sagaID" description:"Id of inventory saga"`
+	OrderID string      `json:"orderID" description:"Id of order"`
+	Lines   []StockLine `json:"lines" description:"Order lines to reserve"`
+}
+
+// ReleaseStock => give reserved stock back (order is canceled or deleted), reservation which comes later is rejected
+type ReleaseStock struct {
+	SagaID  string `json:"sagaID" description:"Id of inventory saga"`
+	OrderID string `json:"orderID" description:"Id of order"`
+}
+
+// CommitStock => reserved stock leaves the warehouse (order is shipped)
+type CommitStock struct {
+	SagaID  string `json:"sagaID" description:"Id of inventory saga"`
+	OrderID string `json:"orderID" description:"Id of order"`
+}
+
+// AdjustStock => canceled quantities of a partially canceled order are given back. Reserved quantities are released,
+// committed quantities (order is shipped partly) are added to stock again. Adjustment id is the cancellation id.
+type AdjustStock struct {
+	SagaID       string      `json:"sagaID" description:"Id of inventory saga"`
+	OrderID      string      `json:"orderID" description:"Id of order"`
+	AdjustmentID string      `json:"adjustmentID" description:"Id of adjustment, a repeated adjustment is not applied again"`
+	Lines        []StockLine `json:"lines" description:"Canceled quantities of skus"`
+}
+
+// StockReserved => stock of every line is reserved
+type StockReserved struct {
+	SagaID  string `json:"sagaID" description:"Id of inventory saga"`
+	OrderID string `json:"orderID" description:"Id of order"`
+}
+
+// StockReservationFailed => nothing is reserved, skus are the lines without enough stock
+type StockReservationFailed struct {
+	SagaID  string   `json:"sagaID" description:"Id of inventory saga"`
+	OrderID string   `json:"orderID" description:"Id of order"`
+	Reason  string   `json:"reason" description:"Why reservation failed"`
+	Skus    []string `json:"skus,omitempty" description:"Skus without enough stock"`
+}
+
+// StockReleased => reserved stock is given back
+type StockReleased struct {
+	SagaID  string `json:"sagaID" description:"Id of inventory saga"`
+	OrderID string `json:"orderID" description:"Id of order"`
+}
+
+// StockCommitted => reserved stock is removed from stock
+type StockCommitted struct {
+	SagaID  string `json:"sagaID" description:"Id of inventory saga"`
+	OrderID string `json:"orderID" description:"Id of order"`
+}
+
+// StockAdjusted => canceled quantities are given back
+type StockAdjusted struct {
+	SagaID       string `json:"sagaID" description:"Id of inventory saga"`
+	OrderID      string `json:"orderID" description:"Id of order"`
+	AdjustmentID string `json:"adjustmentID" description:"Id of adjustment"`
+}
+
+// NewStockLines => mapping from stock line models to event model
+func NewStockLines(lines []models.StockLine) []StockLine {
+	stockLines := []StockLine{}
+	for _, line := range lines {
+		stockLines = append(stockLines, StockLine{Sku: line.Sku, Quantity: line.Quantity})
+	}
+	return stockLines
+}
+
+// Models => mapping from event model to stock line models
+func (r ReserveStock) Models() []models.StockLine {
+	var lines []models.StockLine
+	for _, line := range r.Lines {
+		lines = append(lines, models.StockLine{Sku: line.Sku, Quantity: line.Quantity})
+	}
+	return lines
+}
+
+// Models => mapping from event model to stock line models
+func (r AdjustStock) Models() []models.StockLine {
+	var lines []models.StockLine
+	for _, line := range r.Lines {
+		lines = append(lines, models.StockLine{Sku: line.Sku, Quantity: line.Quantity})
+	}
+	return lines
+}
+
+// NewReserveStock => command to reserve stock of order lines
+func NewReserveStock(sagaID string, orderID string, lines []models.StockLine) DomainEvent {
+	return DomainEvent{
+		Key:     orderID,
+		Type:    ReserveStockType,
+		Version: ReserveStockVersion,
+		Payload: ReserveStock{SagaID: sagaID, OrderID: orderID, Lines: NewStockLines(lines)},
+	}
+}
+
+// NewReleaseStock => command to release stock of order
+func NewReleaseStock(sagaID string, orderID string) DomainEvent {
+	return DomainEvent{
+		Key:     orderID,
+		Type:    ReleaseStockType,
+		Version: ReleaseStockVersion,
+		Payload: ReleaseStock{SagaID: sagaID, OrderID: orderID},
+	}
+}
+
+// NewCommitStock => command to commit reserved stock of order
+func NewCommitStock(sagaID string, orderID string) DomainEvent {
+	return DomainEvent{
+		Key:     orderID,
+		Type:    CommitStockType,
+		Version: CommitStockVersion,
+		Payload: CommitStock{SagaID: sagaID, OrderID: orderID},
+	}
+}
+
+// NewAdjustStock => command to give canceled quantities of order back
+func NewAdjustStock(sagaID string, orderID string, adjustmentID string, lines []models.StockLine) DomainEvent {
+	return DomainEvent{
+		Key:     orderID,
+		Type:    AdjustStockType,
+		Version: AdjustStockVersion,
+		Payload: AdjustStock{SagaID: sagaID, OrderID: orderID, AdjustmentID: adjustmentID, Lines: NewStockLines(lines)},
+	}
+}
+
+// NewStockReserved => reply of reserved stock
+func NewStockReserved(sagaID string, orderID string) DomainEvent {
+	return DomainEvent{
+		Key:     orderID,
+		Type:    StockReservedType,
+		Version: StockReservedVersion,
+		Payload: StockReserved{SagaID: sagaID, OrderID: orderID},
+	}
+}
+
+// NewStockReservationFailed => reply of failed reservation
+func NewStockReservationFailed(sagaID string, orderID string, reason string, skus []string) DomainEvent {
+	if skus == nil {
+		skus = []string{}
+	}
+	return DomainEvent{
+		Key:     orderID,
+		Type:    StockReservationFailedType,
+		Version: StockReservationFailedVersion,
+		Payload: StockReservationFailed{SagaID: sagaID, OrderID: orderID, Reason: reason, Skus: skus},
+	}
+}
+
+// NewStockReleased => reply of released stock
+func NewStockReleased(sagaID string, orderID string) DomainEvent {
+	return DomainEvent{
+		Key:     orderID,
+		Type:    StockReleasedType,
+		Version: StockReleasedVersion,
+		Payload: StockReleased{SagaID: sagaID, OrderID: orderID},
+	}
+}
+
+// NewStockCommitted => reply of committed stock
+func NewStockCommitted(sagaID string, orderID string) DomainEvent {
+	return DomainEvent{
+		Key:     orderID,
+		Type:    StockCommittedType,
+		Version: StockCommittedVersion,
+		Payload: StockCommitted{SagaID: sagaID, OrderID: orderID},
+	}
+}
+
+// NewStockAdjusted => reply of adjusted stock
+func NewStockAdjusted(sagaID string, orderID string, adjustmentID string) DomainEvent {
+	return DomainEvent{
+		Key:     orderID,
+		Type:    StockAdjustedType,
+		Version: StockAdjustedVersion,
+		Payload: StockAdjusted{SagaID: sagaID, OrderID: orderID, AdjustmentID: adjustmentID},
+	}
+}
+
+// InventoryCommandsChannel => internal topic of inventory saga commands
+func InventoryCommandsChannel(topic string) AsyncAPIChannel {
+	return AsyncAPIChannel{
+		Topic:       topic,
+		Description: "Commands of inventory saga from order-api to product-api. Messages of an order have the order id as key.",
+		Messages: []AsyncAPIMessage{
+			{Type: ReserveStockType, Version: ReserveStockVersion, Summary: "Reserve stock of order lines.", Payload: ReserveStock{}},
+			{Type: ReleaseStockType, Version: ReleaseStockVersion, Summary: "Release reserved stock of order.", Payload: ReleaseStock{}},
+			{Type: CommitStockType, Version: CommitStockVersion, Summary: "Commit reserved stock of shipped order.", Payload: CommitStock{}},
+			{Type: AdjustStockType, Version: AdjustStockVersion, Summary: "Give canceled quantities of partially canceled order back.", Payload: AdjustStock{}},
+		},
+	}
+}
+
+// InventoryEventsChannel => internal topic of inventory saga replies
+func InventoryEventsChannel(topic string) AsyncAPIChannel {
+	return AsyncAPIChannel{
+		Topic:       topic,
+		Description: "Replies of inventory saga from product-api to order-api. Messages of an order have the order id as key.",
+		Messages: []AsyncAPIMessage{
+			{Type: StockReservedType, Version: StockReservedVersion, Summary: "Stock of order is reserved.", Payload: StockReserved{}},
+			{Type: StockReservationFailedType, Version: StockReservationFailedVersion, Summary: "Stock of order cannot reserve.", Payload: StockReservationFailed{}},
+			{Type: StockReleasedType, Version: StockReleasedVersion, Summary: "Stock of order is released.", Payload: StockReleased{}},
+			{Type: StockCommittedType, Version: StockCommittedVersion, Summary: "Stock of order is committed.", Payload: StockCommitted{}},
+			{Type: StockAdjustedType, Version: StockAdjustedVersion, Summary: "Canceled quantities of order are given back.", Payload: StockAdjusted{}},
+		},
+	}
+}
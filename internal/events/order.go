@@ -0,0 +1,144 @@
+package events
+
+import (
+	"OrderUserProject/internal/models"
+	"OrderUserProject/pkg/money"
+	"time"
+)
+
+// Event types of order-api and order-elastic
+const (
+	// OrderChangedType => 'OrderID' topic. Version 1 has just id and status, version 2 carries order too ("full" event mode),
+	// version 3 adds currency, subtotal, discount and tax to order, version 4 adds shipping, coupon code and promotions
+	OrderChangedType = "OrderChanged"
+	// OrderSnapshotType => 'OrderModel' topic, order model to save on elasticsearch
+	OrderSnapshotType = "OrderSnapshot"
+)
+
+// Latest versions of event types
+const (
+	OrderChangedThinVersion = 1
+	OrderChangedFullVersion = 4
+	OrderSnapshotVersion    = 3
+)
+
+// OrderChanged => payload of 'OrderChanged' event
+type OrderChanged struct {
+	OrderID string `json:"orderID"`
+	Status  string `json:"status"`
+	Order   *Order `json:"order,omitempty"`
+}
+
+// Order => order model which is shared between order-api and order-elastic
+type Order struct {
+	ID             string    `json:"id"`
+	UserId         string    `json:"userId"`
+	Status         string    `json:"status"`
+	Address        Address   `json:"address"`
+	InvoiceAddress Address   `json:"invoiceAddress"`
+	Product        []Product `json:"product"`
+	// Amounts are json numbers with 2 fraction digits, elasticsearch maps them as scaled_float
+	Currency string       `json:"currency"`
+	Subtotal money.Amount `json:"subtotal"`
+	Discount money.Amount `json:"discount"`
+	Tax      money.Amount `json:"tax"`
+	Shipping money.Amount `json:"shipping"`
+	Total    money.Amount `json:"total"`
+	// Promotions => discount breakdown of coupon and automatic promotions
+	CouponCode string      `json:"couponCode,omitempty"`
+	Promotions []Promotion `json:"promotions,omitempty"`
+	CreatedAt  time.Time   `json:"createdAt"`
+	UpdatedAt  time.Time   `json:"updatedAt"`
+}
+
+type Address struct {
+	ID       string         `json:"id"`
+	Address  string         `json:"address"`
+	City     string         `json:"city"`
+	District string         `json:"district"`
+	Type     []string       `json:"type"`
+	Default  AddressDefault `json:"default"`
+}
+
+type AddressDefault struct {
+	IsDefaultInvoiceAddress bool `json:"isDefaultInvoiceAddress"`
+	IsDefaultRegularAddress bool `json:"isDefaultRegularAddress"`
+}
+
+type Promotion struct {
+	PromotionID string       `json:"promotionId"`
+	Code        string       `json:"code,omitempty"`
+	Name        string       `json:"name"`
+	Type        string       `json:"type"`
+	Sku         string       `json:"sku,omitempty"`
+	Amount      money.Amount `json:"amount"`
+}
+
+type Product struct {
+	Name     string       `json:"name"`
+	Quantity int          `json:"quantity"`
+	Price    money.Amount `json:"price"`
+	TaxRate  money.Rate   `json:"taxRate"`
+	Discount money.Amount `json:"discount"`
+	Tax      money.Amount `json:"tax"`
+	Total    money.Amount `json:"total"`
+}
+
+// NewOrder => mapping from order model to event model
+func NewOrder(order models.Order) Order {
+	orderEvent := Order{
+		ID:             order.ID,
+		UserId:         order.UserId,
+		Status:         order.Status,
+		Address:        newAddress(order.Address),
+		InvoiceAddress: newAddress(order.InvoiceAddress),
+		Currency:       order.Currency,
+		Subtotal:       order.Subtotal,
+		Discount:       order.Discount,
+		Tax:            order.Tax,
+		Shipping:       order.Shipping,
+		Total:          order.Total,
+		CouponCode:     order.CouponCode,
+		CreatedAt:      order.CreatedAt,
+		UpdatedAt:      order.UpdatedAt,
+	}
+
+	for _, product := range order.Product {
+		orderEvent.Product = append(orderEvent.Product, Product{
+			Name:     product.Name,
+			Quantity: product.Quantity,
+			Price:    product.Price,
+			TaxRate:  product.TaxRate,
+			Discount: product.Discount,
+			Tax:      product.Tax,
+			Total:    product.Total,
+		})
+	}
+
+	for _, promotion := range order.Promotions {
+		orderEvent.Promotions = append(orderEvent.Promotions, Promotion{
+			PromotionID: promotion.PromotionID,
+			Code:        promotion.Code,
+			Name:        promotion.Name,
+			Type:        promotion.Type,
+			Sku:         promotion.Sku,
+			Amount:      promotion.Amount,
+		})
+	}
+
+	return orderEvent
+}
+
+func newAddress(address models.Address) Address {
+	return Address{
+		ID:       address.ID,
+		Address:  address.Address,
+		City:     address.City,
+		District: address.District,
+		Type:     address.Type,
+		Default: AddressDefault{
+			IsDefaultInvoiceAddress: address.Default.IsDefaultInvoiceAddress,
+			IsDefaultRegularAddress: address.Default.IsDefaultRegularAddress,
+		},
+	}
+}
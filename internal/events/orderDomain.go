@@ -0,0 +1,179 @@
+package events
+
+import (
+	"OrderUserProject/internal/models"
+	"OrderUserProject/pkg/money"
+)
+
+// Domain events of orders, they are published to public 'OrderEvents' topic for downstream consumers
+// (finance, notification etc.). Unlike 'OrderChanged' they carry the order state before and after the change.
+const (
+	OrderCreatedType       = "OrderCreated"
+	OrderStatusChangedType = "OrderStatusChanged"
+	OrderCanceledType      = "OrderCanceled"
+	OrderDeletedType       = "OrderDeleted"
+	RefundIssuedType       = "RefundIssued"
+)
+
+// Latest versions of domain event types, version 2 adds currency, subtotal, discount and tax to orders, version 3
+// adds shipping, coupon code and promotions. Version 4 of 'OrderCanceled' adds reason and canceled lines, it is
+// published for partial cancellations too.
+const (
+	OrderCreatedVersion       = 3
+	OrderStatusChangedVersion = 3
+	OrderCanceledVersion      = 4
+	OrderDeletedVersion       = 3
+	RefundIssuedVersion       = 1
+)
+
+// OrderCanceledStatus => order status which is published as 'OrderCanceled' instead of 'OrderStatusChanged'
+const OrderCanceledStatus = "Canceled"
+
+// OrderCreated => order is created, there is no state before
+type OrderCreated struct {
+	OrderID string `json:"orderID" description:"Id of created order"`
+	UserId  string `json:"userId" description:"Owner of order"`
+	After   Order  `json:"after" description:"Order after creation"`
+}
+
+// OrderStatusChanged => status of order is changed (except cancel)
+type OrderStatusChanged struct {
+	OrderID        string `json:"orderID" description:"Id of changed order"`
+	UserId         string `json:"userId" description:"Owner of order"`
+	PreviousStatus string `json:"previousStatus" description:"Status before change"`
+	Status         string `json:"status" description:"Status after change"`
+	Before         Order  `json:"before" description:"Order before change"`
+	After          Order  `json:"after" description:"Order after change"`
+}
+
+// OrderCanceled => status of order is changed to "Canceled" or some units of its lines are canceled (partial)
+type OrderCanceled struct {
+	OrderID        string         `json:"orderID" description:"Id of canceled order"`
+	UserId         string         `json:"userId" description:"Owner of order"`
+	PreviousStatus string         `json:"previousStatus" description:"Status before cancel"`
+	Reason         string         `json:"reason,omitempty" description:"Reason code of cancel, empty if order is canceled by status update"`
+	Note           string         `json:"note,omitempty" description:"Explanation of reason"`
+	Partial        bool           `json:"partial,omitempty" description:"Only canceled lines are canceled, order is still open"`
+	Lines          []CanceledLine `json:"lines,omitempty" description:"Canceled quantities of lines"`
+	Before         Order          `json:"before" description:"Order before cancel"`
+	After          Order          `json:"after" description:"Order after cancel"`
+}
+
+// CanceledLine => canceled quantity of an order line
+type CanceledLine struct {
+	Sku      string `json:"sku" description:"Stock keeping unit of product"`
+	Name     string `json:"name" description:"Name of product"`
+	Quantity int    `json:"quantity" description:"Canceled quantity"`
+}
+
+// RefundIssued => money of a cancellation is given back to user
+type RefundIssued struct {
+	OrderID        string       `json:"orderID" description:"Id of canceled order"`
+	UserId         string       `json:"userId" description:"Owner of order"`
+	RefundID       string       `json:"refundID" description:"Id of refund"`
+	CancellationID string       `json:"cancellationID" description:"Id of cancellation which is refunded"`
+	Reason         string       `json:"reason,omitempty" description:"Reason code of cancellation"`
+	Amount         money.Amount `json:"amount" description:"Refunded amount"`
+	Currency       string       `json:"currency" description:"ISO 4217 code of amount"`
+	Method         string       `json:"method" description:"OriginalPayment, StoreCredit or BankTransfer"`
+}
+
+// OrderDeleted => order is deleted, there is no state after
+type OrderDeleted struct {
+	OrderID string `json:"orderID" description:"Id of deleted order"`
+	UserId  string `json:"userId" description:"Owner of order"`
+	Before  Order  `json:"before" description:"Order before delete"`
+}
+
+// DomainEvent => event type, version and payload of a domain event, it is ready to wrap with envelope.
+// Key is the message key of event (order id or user id), events with the same key are consumed in order.
+type DomainEvent struct {
+	Key     string
+	Type    string
+	Version int
+	Payload interface{}
+}
+
+// NewOrderCreated => domain event of created order
+func NewOrderCreated(after Order) DomainEvent {
+	return DomainEvent{
+		Key:     after.ID,
+		Type:    OrderCreatedType,
+		Version: OrderCreatedVersion,
+		Payload: OrderCreated{OrderID: after.ID, UserId: after.UserId, After: after},
+	}
+}
+
+// NewOrderUpdated => domain event of updated order. If status isn't changed there is no domain event (ok is false),
+// if order is canceled event is 'OrderCanceled' otherwise 'OrderStatusChanged'.
+func NewOrderUpdated(before Order, after Order) (DomainEvent, bool) {
+	if before.Status == after.Status {
+		return DomainEvent{}, false
+	}
+
+	if after.Status == OrderCanceledStatus {
+		return DomainEvent{
+			Key:     after.ID,
+			Type:    OrderCanceledType,
+			Version: OrderCanceledVersion,
+			Payload: OrderCanceled{OrderID: after.ID, UserId: after.UserId, PreviousStatus: before.Status, Before: before, After: after},
+		}, true
+	}
+
+	return DomainEvent{
+		Key:     after.ID,
+		Type:    OrderStatusChangedType,
+		Version: OrderStatusChangedVersion,
+		Payload: OrderStatusChanged{OrderID: after.ID, UserId: after.UserId, PreviousStatus: before.Status,
+			Status: after.Status, Before: before, After: after},
+	}, true
+}
+
+// NewOrderCanceled => domain event of order which is canceled completely or partially with cancel request
+func NewOrderCanceled(before Order, after Order, cancellation models.OrderCancellation) DomainEvent {
+	var lines []CanceledLine
+	for _, line := range cancellation.Lines {
+		lines = append(lines, CanceledLine{Sku: line.Sku, Name: line.Name, Quantity: line.Quantity})
+	}
+
+	return DomainEvent{
+		Key:     after.ID,
+		Type:    OrderCanceledType,
+		Version: OrderCanceledVersion,
+		Payload: OrderCanceled{OrderID: after.ID, UserId: after.UserId, PreviousStatus: before.Status, Reason: cancellation.Reason,
+			Note: cancellation.Note, Partial: cancellation.Partial, Lines: lines, Before: before, After: after},
+	}
+}
+
+// NewRefundIssued => domain event of issued refund, reason is the reason of its cancellation on order
+func NewRefundIssued(order models.Order, refund models.Refund) DomainEvent {
+	var reason string
+	for _, cancellation := range order.Cancellations {
+		if cancellation.ID == refund.CancellationID {
+			reason = cancellation.Reason
+		}
+	}
+
+	return DomainEvent{
+		Key:     order.ID,
+		Type:    RefundIssuedType,
+		Version: RefundIssuedVersion,
+		Payload: RefundIssued{OrderID: order.ID, UserId: order.UserId, RefundID: refund.ID, CancellationID: refund.CancellationID,
+			Reason: reason, Amount: refund.Amount, Currency: refund.Currency, Method: refund.Method},
+	}
+}
+
+// NewOrderDeleted => domain event of deleted order
+func NewOrderDeleted(before Order) DomainEvent {
+	return DomainEvent{
+		Key:     before.ID,
+		Type:    OrderDeletedType,
+		Version: OrderDeletedVersion,
+		Payload: OrderDeleted{OrderID: before.ID, UserId: before.UserId, Before: before},
+	}
+}
+
+// Envelope => wrap domain event with envelope, payload is validated with registered schema
+func (d DomainEvent) Envelope() (Envelope, error) {
+	return New(d.Type, d.Version, d.Payload)
+}
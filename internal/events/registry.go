@@ -0,0 +1,261 @@
+package events
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"io/fs"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Schema files are kept as "schemas/<event type>/v<version>.json". A schema file cannot be changed after release,
+// every change has to be a new version which is backward compatible with the previous one (checked in tests).
+//
+//go:embed schemas
+var schemaFiles embed.FS
+
+// DefaultRegistry => registry with schemas of this repository, it is used by Marshal and Unmarshal
+var DefaultRegistry = MustNewRegistry(schemaFiles, "schemas")
+
+// Registry => local (file based) schema registry
+type Registry struct {
+	schemas map[string]map[int]*jsonschema.Schema
+	raw     map[string]map[int]map[string]interface{}
+}
+
+// NewRegistry => load and compile every schema under root directory
+func NewRegistry(fsys fs.FS, root string) (*Registry, error) {
+	registry := &Registry{
+		schemas: map[string]map[int]*jsonschema.Schema{},
+		raw:     map[string]map[int]map[string]interface{}{},
+	}
+
+	compiler := jsonschema.NewCompiler()
+	compiler.Draft = jsonschema.Draft7
+	compiler.AssertFormat = true
+
+	err := fs.WalkDir(fsys, root, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		eventType := path.Base(path.Dir(filePath))
+		fileName := path.Base(filePath)
+		if !strings.HasPrefix(fileName, "v") || path.Ext(fileName) != ".json" {
+			return fmt.Errorf("schema file name (%v) must be like 'v1.json'", filePath)
+		}
+		version, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(fileName, "v"), ".json"))
+		if err != nil || version < 1 {
+			return fmt.Errorf("schema file name (%v) has invalid version", filePath)
+		}
+
+		content, err := fs.ReadFile(fsys, filePath)
+		if err != nil {
+			return err
+		}
+
+		var raw map[string]interface{}
+		if err := json.Unmarshal(content, &raw); err != nil {
+			return fmt.Errorf("schema (%v) is not valid json: %v", filePath, err)
+		}
+
+		if err := compiler.AddResource(filePath, bytes.NewReader(content)); err != nil {
+			return err
+		}
+		schema, err := compiler.Compile(filePath)
+		if err != nil {
+			return fmt.Errorf("schema (%v) cannot compile: %v", filePath, err)
+		}
+
+		if registry.schemas[eventType] == nil {
+			registry.schemas[eventType] = map[int]*jsonschema.Schema{}
+			registry.raw[eventType] = map[int]map[string]interface{}{}
+		}
+		registry.schemas[eventType][version] = schema
+		registry.raw[eventType][version] = raw
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return registry, nil
+}
+
+// MustNewRegistry => like NewRegistry but panics, embedded schemas have to be valid
+func MustNewRegistry(fsys fs.FS, root string) *Registry {
+	registry, err := NewRegistry(fsys, root)
+	if err != nil {
+		panic(err)
+	}
+	return registry
+}
+
+// Types => registered event types
+func (r *Registry) Types() []string {
+	var types []string
+	for eventType := range r.schemas {
+		types = append(types, eventType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Versions => registered versions of event type in ascending order
+func (r *Registry) Versions(eventType string) []int {
+	var versions []int
+	for version := range r.schemas[eventType] {
+		versions = append(versions, version)
+	}
+	sort.Ints(versions)
+	return versions
+}
+
+// Validate => check payload with schema of event type and version
+func (r *Registry) Validate(eventType string, version int, payload []byte) error {
+	schema, ok := r.schemas[eventType][version]
+	if !ok {
+		return fmt.Errorf("schema of event %v v%v is not registered", eventType, version)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return fmt.Errorf("payload of event %v v%v is not valid json: %v", eventType, version, err)
+	}
+
+	if err := schema.Validate(value); err != nil {
+		return fmt.Errorf("payload of event %v v%v is invalid: %v", eventType, version, err)
+	}
+
+	return nil
+}
+
+// CheckCompatibility => every version of every event type must be backward compatible with its previous version
+func (r *Registry) CheckCompatibility() error {
+	for _, eventType := range r.Types() {
+		versions := r.Versions(eventType)
+		for i, version := range versions {
+			if version != i+1 {
+				return fmt.Errorf("event %v: versions must start from 1 without gaps, found v%v", eventType, version)
+			}
+			if i == 0 {
+				continue
+			}
+			err := CheckSchemaCompatibility(r.raw[eventType][versions[i-1]], r.raw[eventType][version])
+			if err != nil {
+				return fmt.Errorf("event %v v%v is not compatible with v%v: %v", eventType, version, versions[i-1], err)
+			}
+		}
+	}
+	return nil
+}
+
+// CheckSchemaCompatibility => new schema may add optional properties, but it cannot remove properties, change types,
+// add required properties, narrow enums or close additional properties. Otherwise, old producers or consumers break.
+func CheckSchemaCompatibility(oldSchema, newSchema map[string]interface{}) error {
+	return checkCompatibility("", oldSchema, newSchema)
+}
+
+func checkCompatibility(location string, oldSchema, newSchema map[string]interface{}) error {
+	if location == "" {
+		location = "/"
+	}
+
+	if !reflect.DeepEqual(schemaTypes(oldSchema), schemaTypes(newSchema)) {
+		return fmt.Errorf("type of %v changed from %v to %v", location, schemaTypes(oldSchema), schemaTypes(newSchema))
+	}
+
+	if oldEnum, ok := oldSchema["enum"].([]interface{}); ok {
+		newEnum, _ := newSchema["enum"].([]interface{})
+		for _, value := range oldEnum {
+			if !containsValue(newEnum, value) {
+				return fmt.Errorf("enum value %v of %v is removed", value, location)
+			}
+		}
+	} else if _, ok := newSchema["enum"]; ok {
+		return fmt.Errorf("enum is added to %v", location)
+	}
+
+	if newAdditional, ok := newSchema["additionalProperties"].(bool); ok && !newAdditional {
+		if value, ok := oldSchema["additionalProperties"].(bool); !ok || value {
+			return fmt.Errorf("additional properties of %v are closed", location)
+		}
+	}
+
+	oldRequired := stringSet(oldSchema["required"])
+	for _, property := range stringList(newSchema["required"]) {
+		if !oldRequired[property] {
+			return fmt.Errorf("property %v is required now", path.Join(location, property))
+		}
+	}
+
+	oldProperties, _ := oldSchema["properties"].(map[string]interface{})
+	newProperties, _ := newSchema["properties"].(map[string]interface{})
+	for property, oldProperty := range oldProperties {
+		newProperty, ok := newProperties[property]
+		if !ok {
+			return fmt.Errorf("property %v is removed", path.Join(location, property))
+		}
+		oldPropertySchema, _ := oldProperty.(map[string]interface{})
+		newPropertySchema, _ := newProperty.(map[string]interface{})
+		if err := checkCompatibility(path.Join(location, property), oldPropertySchema, newPropertySchema); err != nil {
+			return err
+		}
+	}
+
+	if oldItems, ok := oldSchema["items"].(map[string]interface{}); ok {
+		newItems, _ := newSchema["items"].(map[string]interface{})
+		if err := checkCompatibility(path.Join(location, "[]"), oldItems, newItems); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// schemaTypes => "type" keyword can be a string or list of strings
+func schemaTypes(schema map[string]interface{}) []string {
+	types := stringList(schema["type"])
+	if typeName, ok := schema["type"].(string); ok {
+		types = []string{typeName}
+	}
+	sort.Strings(types)
+	return types
+}
+
+func stringList(value interface{}) []string {
+	var result []string
+	list, _ := value.([]interface{})
+	for _, item := range list {
+		if s, ok := item.(string); ok {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+func stringSet(value interface{}) map[string]bool {
+	result := map[string]bool{}
+	for _, s := range stringList(value) {
+		result[s] = true
+	}
+	return result
+}
+
+func containsValue(list []interface{}, value interface{}) bool {
+	for _, item := range list {
+		if reflect.DeepEqual(item, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,149 @@
+package events
+
+import (
+	"OrderUserProject/internal/models"
+	"OrderUserProject/pkg/money"
+	"encoding/json"
+	"github.com/go-playground/assert/v2"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+var schemaV1 = `{
+	"type": "object",
+	"required": ["orderID"],
+	"properties": {
+		"orderID": {"type": "string"},
+		"status": {"type": "string", "enum": ["Created", "Deleted"]}
+	}
+}`
+
+var incompatibleSchemaTestValues = map[string]struct {
+	schemaV2 string
+	valid    bool
+}{
+	"optional-property-added": {`{
+		"type": "object",
+		"required": ["orderID"],
+		"properties": {
+			"orderID": {"type": "string"},
+			"status": {"type": "string", "enum": ["Created", "Deleted", "Updated"]},
+			"order": {"type": "object"}
+		}
+	}`, true},
+	"property-removed": {`{
+		"type": "object",
+		"required": ["orderID"],
+		"properties": {"orderID": {"type": "string"}}
+	}`, false},
+	"type-changed": {`{
+		"type": "object",
+		"required": ["orderID"],
+		"properties": {
+			"orderID": {"type": "integer"},
+			"status": {"type": "string", "enum": ["Created", "Deleted"]}
+		}
+	}`, false},
+	"required-added": {`{
+		"type": "object",
+		"required": ["orderID", "status"],
+		"properties": {
+			"orderID": {"type": "string"},
+			"status": {"type": "string", "enum": ["Created", "Deleted"]}
+		}
+	}`, false},
+	"enum-narrowed": {`{
+		"type": "object",
+		"required": ["orderID"],
+		"properties": {
+			"orderID": {"type": "string"},
+			"status": {"type": "string", "enum": ["Created"]}
+		}
+	}`, false},
+}
+
+func TestRegistry_SchemasAreBackwardCompatible(t *testing.T) {
+	if err := DefaultRegistry.CheckCompatibility(); err != nil {
+		t.Error(err)
+	}
+
+	// Every event type which is produced in this repository has to be registered
+	for _, eventType := range []string{OrderChangedType, OrderSnapshotType} {
+		if len(DefaultRegistry.Versions(eventType)) == 0 {
+			t.Errorf("Schema of event %v is not registered", eventType)
+		}
+	}
+}
+
+func TestRegistry_CheckCompatibility_RejectsIncompatibleChanges(t *testing.T) {
+	for name, result := range incompatibleSchemaTestValues {
+		fsys := fstest.MapFS{
+			"schemas/TestEvent/v1.json": {Data: []byte(schemaV1)},
+			"schemas/TestEvent/v2.json": {Data: []byte(result.schemaV2)},
+		}
+
+		registry, err := NewRegistry(fsys, "schemas")
+		if err != nil {
+			t.Fatalf("%v: registry cannot load: %v", name, err)
+		}
+
+		err = registry.CheckCompatibility()
+		if result.valid && err != nil {
+			t.Errorf("%v: expected compatible, but got: %v", name, err)
+		}
+		if !result.valid && err == nil {
+			t.Errorf("%v: expected incompatible, but schema is accepted", name)
+		}
+	}
+}
+
+func TestNewValidate_SuccessAndFail(t *testing.T) {
+	order := models.Order{ID: "2b45ac31-6906-4e1e-82db-d9bcdbdb2143", Status: "Shipped", Total: money.MustParse("24000"),
+		CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	orderEvent := NewOrder(order)
+
+	envelope, err := New(OrderChangedType, OrderChangedFullVersion,
+		OrderChanged{OrderID: order.ID, Status: "Created", Order: &orderEvent})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Envelope is sent as json and read by consumer
+	message, _ := json.Marshal(envelope)
+	var consumed Envelope
+	if err := json.Unmarshal(message, &consumed); err != nil {
+		t.Fatal(err)
+	}
+	if err := Validate(consumed); err != nil {
+		t.Fatal(err)
+	}
+
+	var payload OrderChanged
+	if err := consumed.DecodePayload(&payload); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, OrderChangedType, consumed.Type)
+	assert.Equal(t, OrderChangedFullVersion, consumed.Version)
+	assert.Equal(t, order.ID, payload.Order.ID)
+	assert.Equal(t, order.Total, payload.Order.Total)
+
+	// Unknown status is not valid for schema, so it cannot be produced
+	if _, err := New(OrderChangedType, OrderChangedThinVersion, OrderChanged{OrderID: order.ID, Status: "Lost"}); err == nil {
+		t.Error("Expected schema validation error for unknown status")
+	}
+
+	// Consumer rejects invalid payload too
+	invalidEnvelope := Envelope{Type: OrderChangedType, Version: OrderChangedThinVersion, ID: "1",
+		Payload: []byte(`{"status":"Created"}`)}
+	if err := Validate(invalidEnvelope); err == nil {
+		t.Error("Expected schema validation error for missing orderID")
+	}
+
+	// Legacy messages are not envelopes
+	var legacy Envelope
+	_ = json.Unmarshal([]byte(`{"orderID":"1","status":"Created"}`), &legacy)
+	if err := Validate(legacy); err != ErrNotEnvelope {
+		t.Errorf("Expected: %v, but got: %v", ErrNotEnvelope, err)
+	}
+}
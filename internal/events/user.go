@@ -0,0 +1,166 @@
+package events
+
+import (
+	"OrderUserProject/internal/models"
+	"time"
+)
+
+// Domain events of users, user-api publishes them to 'UserEvents' topic. Message key is the user id.
+const (
+	UserCreatedType    = "UserCreated"
+	UserUpdatedType    = "UserUpdated"
+	UserDeletedType    = "UserDeleted"
+	AddressChangedType = "AddressChanged"
+	AddressDeletedType = "AddressDeleted"
+)
+
+// Latest versions of user event types
+const (
+	UserCreatedVersion    = 1
+	UserUpdatedVersion    = 1
+	UserDeletedVersion    = 1
+	AddressChangedVersion = 1
+	AddressDeletedVersion = 1
+)
+
+// User => user model of events, password is never published
+type User struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Addresses []Address `json:"addresses"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// UserCreated => user is created
+type UserCreated struct {
+	UserID string `json:"userID" description:"Id of created user"`
+	After  User   `json:"after" description:"User after creation"`
+}
+
+// UserUpdated => user (name, email or address list) is updated
+type UserUpdated struct {
+	UserID string `json:"userID" description:"Id of updated user"`
+	Before User   `json:"before" description:"User before update"`
+	After  User   `json:"after" description:"User after update"`
+}
+
+// UserDeleted => user is deleted
+type UserDeleted struct {
+	UserID string `json:"userID" description:"Id of deleted user"`
+	Before User   `json:"before" description:"User before delete"`
+}
+
+// AddressChanged => an address of user is changed, orders may have snapshot of this address
+type AddressChanged struct {
+	UserID string  `json:"userID" description:"Owner of address"`
+	Before Address `json:"before" description:"Address before change"`
+	After  Address `json:"after" description:"Address after change"`
+}
+
+// AddressDeleted => an address of user is deleted
+type AddressDeleted struct {
+	UserID string  `json:"userID" description:"Owner of address"`
+	Before Address `json:"before" description:"Address before delete"`
+}
+
+// NewUser => mapping from user model to event model
+func NewUser(user models.User) User {
+	userEvent := User{
+		ID:        user.ID,
+		Name:      user.Name,
+		Email:     user.Email,
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+	}
+
+	for _, address := range user.Addresses {
+		userEvent.Addresses = append(userEvent.Addresses, newAddress(address))
+	}
+
+	return userEvent
+}
+
+// NewAddress => mapping from address model to event model
+func NewAddress(address models.Address) Address {
+	return newAddress(address)
+}
+
+// Model => mapping from event model to address model
+func (a Address) Model() models.Address {
+	var address models.Address
+	address.ID = a.ID
+	address.Address = a.Address
+	address.City = a.City
+	address.District = a.District
+	address.Type = a.Type
+	address.Default.IsDefaultInvoiceAddress = a.Default.IsDefaultInvoiceAddress
+	address.Default.IsDefaultRegularAddress = a.Default.IsDefaultRegularAddress
+	return address
+}
+
+// NewUserCreated => domain event of created user
+func NewUserCreated(after User) DomainEvent {
+	return DomainEvent{
+		Key:     after.ID,
+		Type:    UserCreatedType,
+		Version: UserCreatedVersion,
+		Payload: UserCreated{UserID: after.ID, After: after},
+	}
+}
+
+// NewUserUpdated => domain event of updated user
+func NewUserUpdated(before User, after User) DomainEvent {
+	return DomainEvent{
+		Key:     after.ID,
+		Type:    UserUpdatedType,
+		Version: UserUpdatedVersion,
+		Payload: UserUpdated{UserID: after.ID, Before: before, After: after},
+	}
+}
+
+// NewUserDeleted => domain event of deleted user
+func NewUserDeleted(before User) DomainEvent {
+	return DomainEvent{
+		Key:     before.ID,
+		Type:    UserDeletedType,
+		Version: UserDeletedVersion,
+		Payload: UserDeleted{UserID: before.ID, Before: before},
+	}
+}
+
+// NewAddressChanged => domain event of changed address
+func NewAddressChanged(userID string, before Address, after Address) DomainEvent {
+	return DomainEvent{
+		Key:     userID,
+		Type:    AddressChangedType,
+		Version: AddressChangedVersion,
+		Payload: AddressChanged{UserID: userID, Before: before, After: after},
+	}
+}
+
+// NewAddressDeleted => domain event of deleted address
+func NewAddressDeleted(userID string, before Address) DomainEvent {
+	return DomainEvent{
+		Key:     userID,
+		Type:    AddressDeletedType,
+		Version: AddressDeletedVersion,
+		Payload: AddressDeleted{UserID: userID, Before: before},
+	}
+}
+
+// UserEventsChannel => public topic of user domain events
+func UserEventsChannel(topic string) AsyncAPIChannel {
+	return AsyncAPIChannel{
+		Topic:       topic,
+		Description: "Public user domain events. Messages of a user have the user id as key.",
+		Messages: []AsyncAPIMessage{
+			{Type: UserCreatedType, Version: UserCreatedVersion, Summary: "User is created.", Payload: UserCreated{}},
+			{Type: UserUpdatedType, Version: UserUpdatedVersion, Summary: "User is updated.", Payload: UserUpdated{}},
+			{Type: UserDeletedType, Version: UserDeletedVersion, Summary: "User is deleted.", Payload: UserDeleted{}},
+			{Type: AddressChangedType, Version: AddressChangedVersion, Summary: "Address of user is changed.", Payload: AddressChanged{}},
+			{Type: AddressDeletedType, Version: AddressDeletedVersion, Summary: "Address of user is deleted.", Payload: AddressDeleted{}},
+		},
+	}
+}
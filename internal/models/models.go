@@ -1,6 +1,7 @@
 package models
 
 import (
+	"OrderUserProject/pkg/money"
 	"time"
 )
 
@@ -12,24 +13,267 @@ type User struct {
 	Addresses []Address `json:"addresses" bson:"addresses"`
 	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt" bson:"updatedAt"`
+	// DeletedAt => user is soft deleted, it is restored or purged after retention period
+	DeletedAt *time.Time `json:"deletedAt,omitempty" bson:"deletedAt,omitempty"`
 }
 
 type Order struct {
-	ID             string  `json:"id" bson:"_id"`
-	UserId         string  `json:"userId" bson:"userId"`
-	Status         string  `json:"status" bson:"status"`
-	Address        Address `json:"address" bson:"address"`
-	InvoiceAddress Address `json:"invoiceAddress" bson:"invoiceAddress"`
-	Product        []struct {
-		Name     string  `json:"name" bson:"name"`
-		Quantity int     `json:"quantity" bson:"quantity"`
-		Price    float64 `json:"price" bson:"price"`
-	} `json:"product" bson:"product"`
-	Total     float64   `json:"total" bson:"total"`
+	ID             string         `json:"id" bson:"_id"`
+	UserId         string         `json:"userId" bson:"userId"`
+	Status         string         `json:"status" bson:"status"`
+	Address        Address        `json:"address" bson:"address"`
+	InvoiceAddress Address        `json:"invoiceAddress" bson:"invoiceAddress"`
+	Product        []OrderProduct `json:"product" bson:"product"`
+	// Currency => currency of every amount of order, lines of an order have the same currency
+	Currency string `json:"currency" bson:"currency"`
+	// Subtotal => sum of line amounts (price * quantity) without VAT, Discount => order discount which is allocated
+	// to lines before VAT, Tax => sum of line VATs, Shipping => shipping fee with VAT (after free shipping),
+	// Total => grand total (subtotal - discount + tax + shipping)
+	Subtotal money.Amount `json:"subtotal" bson:"subtotal"`
+	Discount money.Amount `json:"discount" bson:"discount"`
+	Tax      money.Amount `json:"tax" bson:"tax"`
+	Shipping money.Amount `json:"shipping" bson:"shipping"`
+	Total    money.Amount `json:"total" bson:"total"`
+	// CouponCode => coupon which is entered on order, Promotions => breakdown of discount, promotions are evaluated
+	// once when order is created
+	CouponCode string             `json:"couponCode,omitempty" bson:"couponCode,omitempty"`
+	Promotions []AppliedPromotion `json:"promotions,omitempty" bson:"promotions,omitempty"`
+	CreatedAt  time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt  time.Time          `json:"updatedAt" bson:"updatedAt"`
+	// Shipments => packages of order, status of order is derived from their statuses
+	Shipments []Shipment `json:"shipments,omitempty" bson:"shipments,omitempty"`
+	// Cancellations => canceled lines with reason, Refunds => money which is given back for cancellations
+	Cancellations []OrderCancellation `json:"cancellations,omitempty" bson:"cancellations,omitempty"`
+	Refunds       []Refund            `json:"refunds,omitempty" bson:"refunds,omitempty"`
+	// AddressChanges => decisions of order-api when user changes or deletes an address of this order
+	AddressChanges []AddressChange `json:"addressChanges,omitempty" bson:"addressChanges,omitempty"`
+	// DeletedAt => order is soft deleted, it is restored or purged after retention period
+	DeletedAt *time.Time `json:"deletedAt,omitempty" bson:"deletedAt,omitempty"`
+}
+
+// OrderProduct => order line, name, price (without VAT) and VAT rate are snapshot of product catalog at order time.
+// Discount is the share of order discount, Tax is VAT of line after discount and Total is the line amount with VAT.
+type OrderProduct struct {
+	Sku      string       `json:"sku" bson:"sku"`
+	Name     string       `json:"name" bson:"name"`
+	Quantity int          `json:"quantity" bson:"quantity"`
+	Price    money.Amount `json:"price" bson:"price"`
+	TaxRate  money.Rate   `json:"taxRate" bson:"taxRate"`
+	Discount money.Amount `json:"discount" bson:"discount"`
+	Tax      money.Amount `json:"tax" bson:"tax"`
+	Total    money.Amount `json:"total" bson:"total"`
+}
+
+// Product => product of catalog (product-api), orders are placed with sku
+type Product struct {
+	ID          string    `json:"id" bson:"_id"`
+	Sku         string    `json:"sku" bson:"sku"`
+	Name        string    `json:"name" bson:"name"`
+	Description string    `json:"description" bson:"description"`
+	Active      bool      `json:"active" bson:"active"`
+	CreatedAt   time.Time `json:"createdAt" bson:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt" bson:"updatedAt"`
+	// Price => unit price without VAT in Currency, TaxClass => key of VAT rate (configs.Config.Pricing.VATRates)
+	Price    money.Amount `json:"price" bson:"price"`
+	Currency string       `json:"currency" bson:"currency"`
+	TaxClass string       `json:"taxClass" bson:"taxClass"`
+	// Stock => quantity on hand, Reserved => part of stock which is reserved for open orders (not shipped yet)
+	Stock    int `json:"stock" bson:"stock"`
+	Reserved int `json:"reserved" bson:"reserved"`
+	// Reservations => reserved quantity of each inventory saga, a saga reserves or releases a product only once
+	Reservations []ProductReservation `json:"reservations,omitempty" bson:"reservations,omitempty"`
+}
+
+// Shipment => a package of order with carrier and tracking number, an order can be split to many shipments.
+// Items of a failed shipment can be shipped again with a new shipment.
+type Shipment struct {
+	ID             string         `json:"id" bson:"id"`
+	Carrier        string         `json:"carrier" bson:"carrier"`
+	TrackingNumber string         `json:"trackingNumber,omitempty" bson:"trackingNumber,omitempty"`
+	Status         string         `json:"status" bson:"status"`
+	Items          []ShipmentItem `json:"items" bson:"items"`
+	ShippedAt      *time.Time     `json:"shippedAt,omitempty" bson:"shippedAt,omitempty"`
+	DeliveredAt    *time.Time     `json:"deliveredAt,omitempty" bson:"deliveredAt,omitempty"`
+	CreatedAt      time.Time      `json:"createdAt" bson:"createdAt"`
+	UpdatedAt      time.Time      `json:"updatedAt" bson:"updatedAt"`
+}
+
+// ShipmentItem => quantity of an order line in shipment
+type ShipmentItem struct {
+	Sku      string `json:"sku" bson:"sku"`
+	Quantity int    `json:"quantity" bson:"quantity"`
+}
+
+// Invoice => invoice of an order, id is the order id, so an order has one invoice. Lines, amounts and invoice address
+// are snapshot of order when it is billed. Number is given when invoice is issued, PdfFile and HtmlFile are names of
+// rendered documents in invoice storage.
+type Invoice struct {
+	ID             string         `json:"id" bson:"_id"`
+	OrderID        string         `json:"orderId" bson:"orderId"`
+	Number         string         `json:"number,omitempty" bson:"number,omitempty"`
+	Status         string         `json:"status" bson:"status"`
+	UserId         string         `json:"userId,omitempty" bson:"userId,omitempty"`
+	InvoiceAddress Address        `json:"invoiceAddress" bson:"invoiceAddress"`
+	Lines          []OrderProduct `json:"lines,omitempty" bson:"lines,omitempty"`
+	Currency       string         `json:"currency,omitempty" bson:"currency,omitempty"`
+	Subtotal       money.Amount   `json:"subtotal" bson:"subtotal"`
+	Discount       money.Amount   `json:"discount" bson:"discount"`
+	Tax            money.Amount   `json:"tax" bson:"tax"`
+	Shipping       money.Amount   `json:"shipping" bson:"shipping"`
+	Total          money.Amount   `json:"total" bson:"total"`
+	PdfFile        string         `json:"pdfFile,omitempty" bson:"pdfFile,omitempty"`
+	HtmlFile       string         `json:"htmlFile,omitempty" bson:"htmlFile,omitempty"`
+	IssuedAt       *time.Time     `json:"issuedAt,omitempty" bson:"issuedAt,omitempty"`
+	CreatedAt      time.Time      `json:"createdAt" bson:"createdAt"`
+	UpdatedAt      time.Time      `json:"updatedAt" bson:"updatedAt"`
+}
+
+// OrderCancellation => order is canceled completely or some units of its lines are canceled (Partial)
+type OrderCancellation struct {
+	ID         string         `json:"id" bson:"id"`
+	Reason     string         `json:"reason" bson:"reason"`
+	Note       string         `json:"note,omitempty" bson:"note,omitempty"`
+	Partial    bool           `json:"partial" bson:"partial"`
+	Lines      []CanceledLine `json:"lines" bson:"lines"`
+	RefundID   string         `json:"refundId,omitempty" bson:"refundId,omitempty"`
+	CanceledAt time.Time      `json:"canceledAt" bson:"canceledAt"`
+}
+
+// CanceledLine => canceled quantity of an order line
+type CanceledLine struct {
+	Sku      string `json:"sku" bson:"sku"`
+	Name     string `json:"name" bson:"name"`
+	Quantity int    `json:"quantity" bson:"quantity"`
+}
+
+// Refund => money which is given back to user for a cancellation. Status is Pending until payment side gives the
+// money back (Issued) or cannot give it back (Failed).
+type Refund struct {
+	ID             string       `json:"id" bson:"id"`
+	CancellationID string       `json:"cancellationId" bson:"cancellationId"`
+	Amount         money.Amount `json:"amount" bson:"amount"`
+	Currency       string       `json:"currency" bson:"currency"`
+	Method         string       `json:"method" bson:"method"`
+	Status         string       `json:"status" bson:"status"`
+	CreatedAt      time.Time    `json:"createdAt" bson:"createdAt"`
+	UpdatedAt      time.Time    `json:"updatedAt" bson:"updatedAt"`
+}
+
+// Promotion => discount rule of order-api. Promotion with code is a coupon which is entered on order, promotion
+// without code is applied automatically to every eligible order.
+type Promotion struct {
+	ID   string `json:"id" bson:"_id"`
+	Code string `json:"code,omitempty" bson:"code,omitempty"`
+	Name string `json:"name" bson:"name"`
+	// Type => Percentage (Percent of remaining subtotal, at most MaxDiscount), FixedAmount (Amount), FreeShipping or
+	// BuyXGetY (GetQuantity of every BuyQuantity + GetQuantity units of Sku are free)
+	Type        string       `json:"type" bson:"type"`
+	Percent     money.Rate   `json:"percent" bson:"percent"`
+	Amount      money.Amount `json:"amount" bson:"amount"`
+	MaxDiscount money.Amount `json:"maxDiscount" bson:"maxDiscount"`
+	Sku         string       `json:"sku,omitempty" bson:"sku,omitempty"`
+	BuyQuantity int          `json:"buyQuantity,omitempty" bson:"buyQuantity,omitempty"`
+	GetQuantity int          `json:"getQuantity,omitempty" bson:"getQuantity,omitempty"`
+	// Currency => currency of amounts, promotion applies only to orders in this currency (empty is any currency for
+	// percentage promotions)
+	Currency    string       `json:"currency,omitempty" bson:"currency,omitempty"`
+	MinSubtotal money.Amount `json:"minSubtotal" bson:"minSubtotal"`
+	// StartsAt, EndsAt => validity window, zero EndsAt never ends
+	StartsAt time.Time `json:"startsAt" bson:"startsAt"`
+	EndsAt   time.Time `json:"endsAt" bson:"endsAt"`
+	// UsageLimit => orders which can use the promotion, PerUserLimit => orders of a user, zero is unlimited
+	UsageLimit   int `json:"usageLimit" bson:"usageLimit"`
+	PerUserLimit int `json:"perUserLimit" bson:"perUserLimit"`
+	// UsedCount => orders which used the promotion, UserUsage => used count of every user
+	UsedCount int            `json:"usedCount" bson:"usedCount"`
+	UserUsage map[string]int `json:"-" bson:"userUsage,omitempty"`
+	// Stackable => promotion can be combined with other stackable promotions, Priority => higher is applied first
+	Stackable bool      `json:"stackable" bson:"stackable"`
+	Priority  int       `json:"priority" bson:"priority"`
+	Active    bool      `json:"active" bson:"active"`
 	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt" bson:"updatedAt"`
 }
 
+// AppliedPromotion => discount of a promotion on order. Discount of promotion with sku is allocated to lines of sku,
+// free shipping discount is not a part of order discount.
+type AppliedPromotion struct {
+	PromotionID string       `json:"promotionId" bson:"promotionId"`
+	Code        string       `json:"code,omitempty" bson:"code,omitempty"`
+	Name        string       `json:"name" bson:"name"`
+	Type        string       `json:"type" bson:"type"`
+	Sku         string       `json:"sku,omitempty" bson:"sku,omitempty"`
+	Amount      money.Amount `json:"amount" bson:"amount"`
+}
+
+// ProductReservation => quantity of product which is reserved by an inventory saga (order)
+type ProductReservation struct {
+	SagaID   string `json:"sagaId" bson:"sagaId"`
+	Quantity int    `json:"quantity" bson:"quantity"`
+	// Adjustments => ids of adjustments which reduced the quantity, an adjustment is applied once
+	Adjustments []string `json:"adjustments,omitempty" bson:"adjustments,omitempty"`
+}
+
+// StockLine => sku and quantity of an order line for inventory
+type StockLine struct {
+	Sku      string `json:"sku" bson:"sku"`
+	Quantity int    `json:"quantity" bson:"quantity"`
+}
+
+// StockReservation => reservation of an inventory saga on product-api side, state answers repeated commands
+type StockReservation struct {
+	ID      string      `json:"id" bson:"_id"`
+	OrderID string      `json:"orderId" bson:"orderId"`
+	Lines   []StockLine `json:"lines" bson:"lines"`
+	State   string      `json:"state" bson:"state"`
+	Reason  string      `json:"reason,omitempty" bson:"reason,omitempty"`
+	// Adjustments => ids of applied adjustments, a repeated adjustment gets the same reply
+	Adjustments []string  `json:"adjustments,omitempty" bson:"adjustments,omitempty"`
+	CreatedAt   time.Time `json:"createdAt" bson:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt" bson:"updatedAt"`
+}
+
+// InventorySaga => stock reservation of an order on order-api side. Saga id is the order id, so an order has one saga.
+// Steps are the history of state changes.
+type InventorySaga struct {
+	ID      string      `json:"id" bson:"_id"`
+	OrderID string      `json:"orderId" bson:"orderId"`
+	Lines   []StockLine `json:"lines" bson:"lines"`
+	State   string      `json:"state" bson:"state"`
+	Reason  string      `json:"reason,omitempty" bson:"reason,omitempty"`
+	// Attempts => command of current state is sent this many times again, it is reset on every state change
+	Attempts int        `json:"attempts" bson:"attempts"`
+	Steps    []SagaStep `json:"steps" bson:"steps"`
+	// PendingAdjustments => canceled quantities which are sent to product-api and not replied yet
+	PendingAdjustments []StockAdjustment `json:"pendingAdjustments,omitempty" bson:"pendingAdjustments,omitempty"`
+	CreatedAt          time.Time         `json:"createdAt" bson:"createdAt"`
+	UpdatedAt          time.Time         `json:"updatedAt" bson:"updatedAt"`
+}
+
+// StockAdjustment => canceled quantities of a partially canceled order which are given back, id is the cancellation id
+type StockAdjustment struct {
+	ID    string      `json:"id" bson:"id"`
+	Lines []StockLine `json:"lines" bson:"lines"`
+	At    time.Time   `json:"at" bson:"at"`
+}
+
+// SagaStep => a state change of saga
+type SagaStep struct {
+	State  string    `json:"state" bson:"state"`
+	Reason string    `json:"reason,omitempty" bson:"reason,omitempty"`
+	At     time.Time `json:"at" bson:"at"`
+}
+
+// AddressChange => address of order is "Updated" with new address or "Kept" as it is (snapshot at order time)
+type AddressChange struct {
+	EventID   string    `json:"eventId" bson:"eventId"`
+	EventType string    `json:"eventType" bson:"eventType"`
+	AddressID string    `json:"addressId" bson:"addressId"`
+	Decision  string    `json:"decision" bson:"decision"`
+	Address   Address   `json:"address" bson:"address"`
+	DecidedAt time.Time `json:"decidedAt" bson:"decidedAt"`
+}
+
 type Address struct {
 	ID       string   `json:"id" bson:"_id"`
 	Address  string   `json:"address" bson:"address"`
@@ -0,0 +1,532 @@
+package query
+
+import (
+	"OrderUserProject/internal/configs"
+	"encoding/json"
+	"fmt"
+	"github.com/go-playground/assert/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func date(value string) time.Time {
+	parsed, _ := time.Parse(time.RFC3339, value)
+	return parsed
+}
+
+// conformanceOrders => orders as they are stored on MongoDB, id is '_id' on MongoDB and 'id' on Elasticsearch
+var conformanceOrders = []map[string]interface{}{
+	{
+		"_id": "o1", "userId": "u1", "status": "Shipped", "total": 1800.0, "couponCode": "SPRING",
+		"createdAt": date("2024-01-01T10:00:00Z"),
+		"address":   map[string]interface{}{"city": "İzmir", "district": "Narlıdere", "default": map[string]interface{}{"isDefaultInvoiceAddress": true}},
+		"product": []interface{}{
+			map[string]interface{}{"name": "Shoe", "quantity": 1.0, "price": 1500.0},
+			map[string]interface{}{"name": "Sock", "quantity": 2.0, "price": 150.0},
+		},
+	},
+	{
+		"_id": "o2", "userId": "u1", "status": "Not Shipped", "total": 250.0,
+		"createdAt": date("2024-01-02T00:00:00Z"),
+		"address":   map[string]interface{}{"city": "İstanbul", "district": "Kadıköy", "default": map[string]interface{}{"isDefaultInvoiceAddress": false}},
+		"product": []interface{}{
+			map[string]interface{}{"name": "T-Shirt", "quantity": 1.0, "price": 250.0},
+			map[string]interface{}{"name": "Gift & Card", "quantity": 1.0, "price": 300.0},
+		},
+	},
+	{
+		"_id": "o3", "userId": "u2", "status": "Delivered", "total": 2000.0, "couponCode": "WINTER",
+		"createdAt": date("2024-02-15T08:30:00Z"),
+		"address":   map[string]interface{}{"city": "İzmir", "district": "Bornova", "default": map[string]interface{}{"isDefaultInvoiceAddress": false}},
+		"product":   []interface{}{map[string]interface{}{"name": "Shoe", "quantity": 1.0, "price": 2000.0}},
+	},
+	{
+		"_id": "o4", "userId": "u2", "status": "Shipped", "total": 250.0,
+		"createdAt": date("2023-12-31T23:00:00Z"),
+		"address":   map[string]interface{}{"city": "Ankara", "district": "Çankaya", "default": map[string]interface{}{"isDefaultInvoiceAddress": false}},
+		"product":   []interface{}{map[string]interface{}{"name": "Hat", "quantity": 1.0, "price": 250.0}},
+	},
+}
+
+var conformanceTestValues = []struct {
+	name   string
+	filter string
+	// sort => fields with the highest priority first, descending fields start with '-'
+	sort []string
+	want []string
+}{
+	{name: "eq", filter: `{"field": "status", "parameter": "eq", "value": "Shipped"}`, want: []string{"o1", "o4"}},
+	{name: "eq-turkish", filter: `{"field": "address.city", "parameter": "equal", "value": "İzmir"}`, want: []string{"o1", "o3"}},
+	{name: "ne", filter: `{"field": "status", "parameter": "ne", "value": "Shipped"}`, want: []string{"o2", "o3"}},
+	{name: "gt", filter: `{"field": "total", "parameter": "gt", "value": 250}`, want: []string{"o1", "o3"}},
+	{name: "lte", filter: `{"field": "total", "parameter": "lessThanEqual", "value": 250}`, want: []string{"o2", "o4"}},
+	{name: "in", filter: `{"field": "userId", "parameter": "in", "value": ["u2", "u3"]}`, want: []string{"o3", "o4"}},
+	{name: "nin", filter: `{"field": "status", "parameter": "nin", "value": ["Shipped", "Delivered"]}`, want: []string{"o2"}},
+	{name: "exists", filter: `{"field": "couponCode", "parameter": "exists", "value": true}`, want: []string{"o1", "o3"}},
+	{name: "not-exists", filter: `{"field": "couponCode", "parameter": "exists", "value": false}`, want: []string{"o2", "o4"}},
+	{name: "regex-part", filter: `{"field": "product.name", "parameter": "regex", "value": "ho"}`, want: []string{"o1", "o3"}},
+	{name: "regex-start", filter: `{"field": "product.name", "parameter": "regex", "value": "^S"}`, want: []string{"o1", "o3"}},
+	{name: "regex-end", filter: `{"field": "product.name", "parameter": "regex", "value": "ck$"}`, want: []string{"o1"}},
+	{name: "regex-whole", filter: `{"field": "product.name", "parameter": "regex", "value": "^Sho$"}`, want: []string{}},
+	{name: "regex-alternation", filter: `{"field": "product.name", "parameter": "regex", "value": "oe|at"}`, want: []string{"o1", "o3", "o4"}},
+	{name: "regex-anchored-group", filter: `{"field": "product.name", "parameter": "regex", "value": "^(Hat|Sock)$"}`, want: []string{"o1", "o4"}},
+	{name: "regex-class", filter: `{"field": "product.name", "parameter": "regex", "value": "^S[a-z]+e$"}`, want: []string{"o1", "o3"}},
+	{name: "regex-escaped", filter: `{"field": "product.name", "parameter": "regex", "value": "T\\-S"}`, want: []string{"o2"}},
+	{name: "regex-lucene-operator", filter: `{"field": "product.name", "parameter": "regex", "value": " & "}`, want: []string{"o2"}},
+	{name: "date-day", filter: `{"field": "createdAt", "parameter": "gte", "value": "2024-01-01"}`, want: []string{"o1", "o2", "o3"}},
+	{name: "date-eq", filter: `{"field": "createdAt", "parameter": "eq", "value": "2024-01-02"}`, want: []string{"o2"}},
+	{name: "date-time", filter: `{"field": "createdAt", "parameter": "lt", "value": "2024-01-01T12:00:00Z"}`, want: []string{"o1", "o4"}},
+	{name: "array-eq", filter: `{"field": "product.name", "parameter": "eq", "value": "Sock"}`, want: []string{"o1"}},
+	{name: "array-ne", filter: `{"field": "product.name", "parameter": "ne", "value": "Shoe"}`, want: []string{"o2", "o4"}},
+	{name: "array-range", filter: `{"field": "product.price", "parameter": "lt", "value": 200}`, want: []string{"o1"}},
+	{name: "bool", filter: `{"field": "address.default.isDefaultInvoiceAddress", "parameter": "eq", "value": true}`, want: []string{"o1"}},
+	{name: "groups", filter: `{"or": [{"field": "status", "parameter": "eq", "value": "Delivered"},
+		{"and": [{"field": "userId", "parameter": "eq", "value": "u1"}, {"not": {"field": "total", "parameter": "gt", "value": 1000}}]}]}`,
+		want: []string{"o2", "o3"}},
+	{name: "not-or", filter: `{"not": {"or": [{"field": "address.city", "parameter": "eq", "value": "İzmir"},
+		{"field": "total", "parameter": "lt", "value": 300}]}}`, want: []string{}},
+	{name: "no-filter", want: []string{"o1", "o2", "o3", "o4"}},
+	{name: "sort-fields", sort: []string{"-total", "createdAt"}, want: []string{"o3", "o1", "o4", "o2"}},
+	{name: "sort-keyword", filter: `{"field": "total", "parameter": "gte", "value": 250}`, sort: []string{"status", "-id"},
+		want: []string{"o3", "o2", "o4", "o1"}},
+}
+
+// Conformance => same queries are emitted for MongoDB and Elasticsearch and emitted queries are evaluated on the same
+// orders with small evaluators of both query languages (only what emitters use). Both must select the same orders in
+// the same order.
+func TestConformance_MongoAndElastic(t *testing.T) {
+	mongoConfig := configs.GetGenericEndpointConfig("mongoDB")
+	elasticConfig := configs.GetGenericEndpointConfig("elasticsearch")
+
+	for _, test := range conformanceTestValues {
+		t.Run(test.name, func(t *testing.T) {
+			query := Query{Filter: And()}
+			if test.filter != "" {
+				var clause Clause
+				if err := json.Unmarshal([]byte(test.filter), &clause); err != nil {
+					t.Fatal(err)
+				}
+				filter, err := NewFilter("filter", clause)
+				if err != nil {
+					t.Fatal(err)
+				}
+				query.Filter = filter
+			}
+			for _, field := range test.sort {
+				direction := 1
+				if strings.HasPrefix(field, "-") {
+					field, direction = field[1:], -1
+				}
+				sortField, err := NewSortField("sort."+field, field, direction)
+				if err != nil {
+					t.Fatal(err)
+				}
+				query.Sort = append(query.Sort, sortField)
+			}
+
+			mongoFilter, findOptions := Mongo(query, mongoConfig)
+			mongoResult := evaluateMongo(mongoFilter, findOptions.Sort)
+
+			searchBody := Elastic(query, elasticConfig)
+			// Search body is sent as JSON, so it is evaluated after a round trip like Elasticsearch sees it
+			body, _ := json.Marshal(searchBody)
+			var elasticBody map[string]interface{}
+			_ = json.Unmarshal(body, &elasticBody)
+			elasticResult := evaluateElastic(elasticBody)
+
+			assert.Equal(t, test.want, mongoResult)
+			assert.Equal(t, test.want, elasticResult)
+		})
+	}
+}
+
+func TestConformance_Fields(t *testing.T) {
+	fields, err := NewFields("fields", []string{"status", "address.city", "id", "total"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := Query{Filter: And(), Fields: fields}
+
+	_, findOptions := Mongo(query, configs.GetGenericEndpointConfig("mongoDB"))
+	var mongoFields []string
+	for field := range findOptions.Projection.(bson.M) {
+		mongoFields = append(mongoFields, strings.Replace(field, "_id", "id", 1))
+	}
+	sort.Strings(mongoFields)
+
+	// Source fields are text fields of keyword fields and id is always in result like _id of MongoDB
+	elasticFields := append([]string(nil), Elastic(query, configs.GetGenericEndpointConfig("elasticsearch"))["_source"].([]string)...)
+	sort.Strings(elasticFields)
+
+	assert.Equal(t, []string{"address.city", "id", "status", "total"}, mongoFields)
+	assert.Equal(t, mongoFields, elasticFields)
+}
+
+// values => values of path in document, arrays are flattened like both MongoDB and Elasticsearch do
+func values(document interface{}, path string) []interface{} {
+	if path == "" {
+		if array, ok := document.([]interface{}); ok {
+			return array
+		}
+		if document == nil {
+			return nil
+		}
+		return []interface{}{document}
+	}
+
+	key, rest := path, ""
+	if i := strings.Index(path, "."); i >= 0 {
+		key, rest = path[:i], path[i+1:]
+	}
+	switch node := document.(type) {
+	case map[string]interface{}:
+		return values(node[key], rest)
+	case []interface{}:
+		var result []interface{}
+		for _, item := range node {
+			result = append(result, values(item, path)...)
+		}
+		return result
+	}
+	return nil
+}
+
+// compare => values of the same type, dates of Elasticsearch queries are RFC 3339 strings and numbers of JSON are float64
+func compare(documentValue interface{}, queryValue interface{}) (int, bool) {
+	if documentDate, ok := documentValue.(time.Time); ok {
+		if s, ok := queryValue.(string); ok {
+			queryValue = date(s)
+		}
+		if queryDate, ok := queryValue.(time.Time); ok {
+			switch {
+			case documentDate.Before(queryDate):
+				return -1, true
+			case documentDate.After(queryDate):
+				return 1, true
+			}
+			return 0, true
+		}
+		return 0, false
+	}
+
+	switch a := documentValue.(type) {
+	case float64:
+		if b, ok := queryValue.(float64); ok {
+			switch {
+			case a < b:
+				return -1, true
+			case a > b:
+				return 1, true
+			}
+			return 0, true
+		}
+	case string:
+		if b, ok := queryValue.(string); ok {
+			return strings.Compare(a, b), true
+		}
+	case bool:
+		if b, ok := queryValue.(bool); ok && a == b {
+			return 0, true
+		} else if ok {
+			return 1, true
+		}
+	}
+	return 0, false
+}
+
+func anyValue(documentValues []interface{}, match func(value interface{}) bool) bool {
+	for _, value := range documentValues {
+		if match(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func equal(a interface{}, b interface{}) bool {
+	result, ok := compare(a, b)
+	return ok && result == 0
+}
+
+func inList(value interface{}, list interface{}) bool {
+	for _, item := range toList(list) {
+		if equal(value, item) {
+			return true
+		}
+	}
+	return false
+}
+
+func toList(list interface{}) []interface{} {
+	if items, ok := list.([]interface{}); ok {
+		return items
+	}
+	panic(fmt.Sprintf("not a list: %v", list))
+}
+
+func rangeMatches(operator string, result int, ok bool) bool {
+	if !ok {
+		return false
+	}
+	switch strings.TrimPrefix(operator, "$") {
+	case OperatorGt:
+		return result > 0
+	case OperatorGte:
+		return result >= 0
+	case OperatorLt:
+		return result < 0
+	case OperatorLte:
+		return result <= 0
+	}
+	panic("unsupported range operator: " + operator)
+}
+
+func ids(documents []map[string]interface{}, idField string) []string {
+	result := []string{}
+	for _, document := range documents {
+		result = append(result, document[idField].(string))
+	}
+	return result
+}
+
+// sortDocuments => documents are sorted by first value of fields, ties keep order of collection
+func sortDocuments(documents []map[string]interface{}, fields []string, descending []bool) {
+	sort.SliceStable(documents, func(i, j int) bool {
+		for k, field := range fields {
+			result, _ := compare(values(documents[i], field)[0], values(documents[j], field)[0])
+			if result != 0 {
+				return (result < 0) != descending[k]
+			}
+		}
+		return false
+	})
+}
+
+// evaluateMongo => ids of orders which match filter, sorted by sort of find options
+func evaluateMongo(filter bson.M, sortSpec interface{}) []string {
+	var result []map[string]interface{}
+	for _, order := range conformanceOrders {
+		if mongoMatches(order, filter) {
+			result = append(result, order)
+		}
+	}
+
+	if sortSpec != nil {
+		var fields []string
+		var descending []bool
+		for _, e := range sortSpec.(bson.D) {
+			fields = append(fields, e.Key)
+			descending = append(descending, e.Value == -1)
+		}
+		sortDocuments(result, fields, descending)
+	}
+	return ids(result, "_id")
+}
+
+func mongoMatches(document map[string]interface{}, filter bson.M) bool {
+	for key, value := range filter {
+		switch key {
+		case "$and", "$or", "$nor":
+			matched := 0
+			for _, query := range value.([]bson.M) {
+				if mongoMatches(document, query) {
+					matched++
+				}
+			}
+			count := len(value.([]bson.M))
+			if (key == "$and" && matched != count) || (key == "$or" && matched == 0) || (key == "$nor" && matched != 0) {
+				return false
+			}
+		default:
+			documentValues := values(document, key)
+			for operator, operand := range value.(bson.M) {
+				if !mongoOperatorMatches(documentValues, operator, operand) {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+func mongoOperatorMatches(documentValues []interface{}, operator string, operand interface{}) bool {
+	switch operator {
+	case "$eq":
+		return anyValue(documentValues, func(value interface{}) bool { return equal(value, operand) })
+	case "$ne":
+		return !anyValue(documentValues, func(value interface{}) bool { return equal(value, operand) })
+	case "$gt", "$gte", "$lt", "$lte":
+		return anyValue(documentValues, func(value interface{}) bool {
+			result, ok := compare(value, operand)
+			return rangeMatches(operator, result, ok)
+		})
+	case "$in":
+		return anyValue(documentValues, func(value interface{}) bool { return inList(value, operand) })
+	case "$nin":
+		return !anyValue(documentValues, func(value interface{}) bool { return inList(value, operand) })
+	case "$exists":
+		return (len(documentValues) > 0) == operand.(bool)
+	case "$regex":
+		pattern := regexp.MustCompile(operand.(string))
+		return anyValue(documentValues, func(value interface{}) bool {
+			s, ok := value.(string)
+			return ok && pattern.MatchString(s)
+		})
+	}
+	panic("unsupported MongoDB operator: " + operator)
+}
+
+// elasticDocument => order as it is indexed on Elasticsearch, keyword fields are not in source
+func elasticDocument(order map[string]interface{}) map[string]interface{} {
+	document := map[string]interface{}{}
+	for key, value := range order {
+		document[key] = value
+	}
+	document["id"] = document["_id"]
+	delete(document, "_id")
+	return document
+}
+
+// evaluateElastic => ids of orders which match query of search body, sorted by its sort
+func evaluateElastic(searchBody map[string]interface{}) []string {
+	var result []map[string]interface{}
+	for _, order := range conformanceOrders {
+		document := elasticDocument(order)
+		if elasticMatches(document, searchBody["query"].(map[string]interface{})) {
+			result = append(result, document)
+		}
+	}
+
+	if sortSpec, ok := searchBody["sort"].([]interface{}); ok {
+		var fields []string
+		var descending []bool
+		for _, item := range sortSpec {
+			for field, direction := range item.(map[string]interface{}) {
+				fields = append(fields, elasticField(field))
+				descending = append(descending, direction == "desc")
+			}
+		}
+		sortDocuments(result, fields, descending)
+	}
+	return ids(result, "id")
+}
+
+// elasticField => keyword field has the value of its text field
+func elasticField(field string) string {
+	return strings.TrimSuffix(field, ".keyword")
+}
+
+// single => field and value of a query of one field e.g. {"total": 1800}
+func single(query interface{}) (string, interface{}) {
+	for field, value := range query.(map[string]interface{}) {
+		return elasticField(field), value
+	}
+	panic("empty query")
+}
+
+func elasticMatches(document map[string]interface{}, query map[string]interface{}) bool {
+	for kind, body := range query {
+		switch kind {
+		case "match_all":
+		case "bool":
+			boolQuery := body.(map[string]interface{})
+			for occur, queries := range boolQuery {
+				if occur == "minimum_should_match" {
+					continue
+				}
+				matched := 0
+				for _, child := range queries.([]interface{}) {
+					if elasticMatches(document, child.(map[string]interface{})) {
+						matched++
+					}
+				}
+				count := len(queries.([]interface{}))
+				if (occur == "must" && matched != count) || (occur == "should" && matched == 0) || (occur == "must_not" && matched != 0) {
+					return false
+				}
+			}
+		case "term":
+			field, operand := single(body)
+			if !anyValue(values(document, field), func(value interface{}) bool { return equal(value, operand) }) {
+				return false
+			}
+		case "terms":
+			field, operand := single(body)
+			if !anyValue(values(document, field), func(value interface{}) bool { return inList(value, operand) }) {
+				return false
+			}
+		case "range":
+			field, bounds := single(body)
+			for operator, operand := range bounds.(map[string]interface{}) {
+				if !anyValue(values(document, field), func(value interface{}) bool {
+					result, ok := compare(value, operand)
+					return rangeMatches(operator, result, ok)
+				}) {
+					return false
+				}
+			}
+		case "exists":
+			if len(values(document, elasticField(body.(map[string]interface{})["field"].(string)))) == 0 {
+				return false
+			}
+		case "regexp":
+			field, operand := single(body)
+			pattern := luceneRegexp(operand.(string))
+			if !anyValue(values(document, field), func(value interface{}) bool {
+				s, ok := value.(string)
+				return ok && pattern.MatchString(s)
+			}) {
+				return false
+			}
+		default:
+			panic("unsupported Elasticsearch query: " + kind)
+		}
+	}
+	return true
+}
+
+// luceneRegexp => regular expression of Lucene as Go regular expression. Lucene matches the whole value, . matches
+// every character, ^ and $ are literals and an escaped character is always a literal (e.g. \d is d). Operators of
+// optional features ("#@&<>~) and intervals are not used by emitter, so they are not supported.
+func luceneRegexp(pattern string) *regexp.Regexp {
+	var goPattern strings.Builder
+	inClass := false
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case c == '\\':
+			i++
+			if inClass {
+				goPattern.WriteString(fmt.Sprintf(`\x{%x}`, pattern[i]))
+			} else {
+				goPattern.WriteString(regexp.QuoteMeta(string(pattern[i])))
+			}
+		case inClass:
+			inClass = c != ']'
+			goPattern.WriteByte(c)
+		case c == '[':
+			inClass = true
+			goPattern.WriteByte(c)
+			// => ] after [ and [^ is a literal
+			if i+1 < len(pattern) && pattern[i+1] == '^' {
+				goPattern.WriteByte('^')
+				i++
+			}
+			if i+1 < len(pattern) && pattern[i+1] == ']' {
+				goPattern.WriteString(`\]`)
+				i++
+			}
+		case c == '^' || c == '$':
+			goPattern.WriteString(`\` + string(c))
+		case strings.IndexByte(`"#@&<>~`, c) >= 0:
+			panic("unsupported Lucene operator: " + string(c))
+		default:
+			goPattern.WriteByte(c)
+		}
+	}
+	return regexp.MustCompile(`^(?s:` + goPattern.String() + `)$`)
+}
@@ -0,0 +1,132 @@
+package query
+
+import (
+	"OrderUserProject/internal/configs"
+	"strings"
+	"time"
+)
+
+// Elastic => query as Elasticsearch search body, fields are mapped with generic endpoint config of Elasticsearch
+func Elastic(query Query, config configs.GenericEndpointConfig) map[string]interface{} {
+	searchBody := map[string]interface{}{"query": elasticFilter(query.Filter, config)}
+
+	// Creating sort area, => "sort": [{"total": "desc"}, {"createdAt": "asc"}]
+	if len(query.Sort) > 0 {
+		sortFields := make([]interface{}, 0, len(query.Sort))
+		for _, sortField := range query.Sort {
+			direction := "asc"
+			if sortField.Descending {
+				direction = "desc"
+			}
+			sortFields = append(sortFields, map[string]interface{}{config.ExactFilterArea[sortField.Field]: direction})
+		}
+		searchBody["sort"] = sortFields
+	}
+
+	// Creating fields area, id is always in result like _id of MongoDB
+	if len(query.Fields) > 0 {
+		source := []string{"id"}
+		for _, field := range query.Fields {
+			// Source of a keyword field is its text field
+			sourceField := strings.TrimSuffix(config.ExactFilterArea[field], ".keyword")
+			if sourceField != "id" {
+				source = append(source, sourceField)
+			}
+		}
+		searchBody["_source"] = source
+	}
+
+	return searchBody
+}
+
+func elasticFilter(filter Filter, config configs.GenericEndpointConfig) map[string]interface{} {
+	switch filter.Kind {
+	case FilterAnd, FilterOr, FilterNot:
+		if len(filter.Children) == 0 {
+			return map[string]interface{}{"match_all": map[string]interface{}{}}
+		}
+		queries := make([]interface{}, 0, len(filter.Children))
+		for _, child := range filter.Children {
+			queries = append(queries, elasticFilter(child, config))
+		}
+		switch filter.Kind {
+		case FilterOr:
+			// =>  "bool": {"should": [...], "minimum_should_match": 1}
+			return elasticBool("should", queries...)
+		case FilterNot:
+			return elasticBool("must_not", queries...)
+		}
+		return elasticBool("must", queries...)
+	}
+
+	field := config.ExactFilterArea[filter.Field]
+	value := elasticValue(filter.Value)
+
+	switch filter.Operator {
+	case OperatorNe:
+		// => "bool": {"must_not": [{"term": {"total": 1800}}]}
+		return elasticBool("must_not", map[string]interface{}{"term": map[string]interface{}{field: value}})
+	case OperatorGt, OperatorGte, OperatorLt, OperatorLte:
+		// => "range": {"total":{"lt": 2000}}
+		return map[string]interface{}{"range": map[string]interface{}{field: map[string]interface{}{filter.Operator: value}}}
+	case OperatorIn:
+		// => "terms":{"total":[1800,2000,2200]}
+		return map[string]interface{}{"terms": map[string]interface{}{field: value}}
+	case OperatorNin:
+		return elasticBool("must_not", map[string]interface{}{"terms": map[string]interface{}{field: value}})
+	case OperatorExists:
+		// => "exists":{"field":"total"}
+		exists := map[string]interface{}{"exists": map[string]interface{}{"field": field}}
+		if value == false {
+			return elasticBool("must_not", exists)
+		}
+		return exists
+	case OperatorRegex:
+		// => "regexp":{"product.name.keyword": ".*a.*"}
+		return map[string]interface{}{"regexp": map[string]interface{}{field: elasticRegexp(value.(string))}}
+	}
+	// => "term":{"total":1800}
+	return map[string]interface{}{"term": map[string]interface{}{field: value}}
+}
+
+func elasticBool(occur string, queries ...interface{}) map[string]interface{} {
+	boolQuery := map[string]interface{}{occur: queries}
+	if occur == "should" {
+		boolQuery["minimum_should_match"] = 1
+	}
+	return map[string]interface{}{"bool": boolQuery}
+}
+
+// elasticValue => dates are sent as RFC 3339
+func elasticValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case []interface{}:
+		values := make([]interface{}, len(v))
+		for i := range v {
+			values[i] = elasticValue(v[i])
+		}
+		return values
+	}
+	return value
+}
+
+// elasticRegexp => regular expression of Elasticsearch matches the whole value, MongoDB matches a part of it. So
+// body is wrapped with ".*" unless it is anchored with ^ or $ (anchors are not operators of Elasticsearch), alternation
+// is grouped before.
+func elasticRegexp(pattern string) string {
+	// Pattern of filter is validated with newRegex
+	r, _ := newRegex(pattern)
+	body := r.Lucene
+	if r.Alternation {
+		body = "(" + body + ")"
+	}
+	if !r.Start {
+		body = ".*" + body
+	}
+	if !r.End {
+		body = body + ".*"
+	}
+	return body
+}
@@ -0,0 +1,230 @@
+package query
+
+import (
+	"OrderUserProject/internal/configs"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// Kinds of filter nodes
+const (
+	FilterAnd       = "and"
+	FilterOr        = "or"
+	FilterNot       = "not"
+	FilterCondition = "condition"
+)
+
+// Operators of filter conditions
+const (
+	OperatorEq     = "eq"
+	OperatorNe     = "ne"
+	OperatorGt     = "gt"
+	OperatorGte    = "gte"
+	OperatorLt     = "lt"
+	OperatorLte    = "lte"
+	OperatorIn     = "in"
+	OperatorNin    = "nin"
+	OperatorExists = "exists"
+	OperatorRegex  = "regex"
+)
+
+// Operators => parameters of requests with their operators
+var Operators = map[string]string{
+	"equal":            OperatorEq,
+	"eq":               OperatorEq,
+	"notEqual":         OperatorNe,
+	"ne":               OperatorNe,
+	"greaterThan":      OperatorGt,
+	"gt":               OperatorGt,
+	"greaterThanEqual": OperatorGte,
+	"gte":              OperatorGte,
+	"lessThan":         OperatorLt,
+	"lt":               OperatorLt,
+	"lessThanEqual":    OperatorLte,
+	"lte":              OperatorLte,
+	"in":               OperatorIn,
+	"nin":              OperatorNin,
+	"exists":           OperatorExists,
+	"regex":            OperatorRegex,
+}
+
+// ErrInvalidQuery => a clause of request is not valid, error names the clause (e.g. filter.or[1])
+var ErrInvalidQuery = errors.New("invalid filter")
+
+// Clause => clause of filter of request, it is a group (one of and, or and not) or a condition (field, parameter and
+// value) e.g. {"or": [{"field": "status", "parameter": "eq", "value": "Shipped"},
+// {"not": {"field": "total", "parameter": "lt", "value": 100}}]}
+type Clause struct {
+	And       []Clause    `json:"and,omitempty"`
+	Or        []Clause    `json:"or,omitempty"`
+	Not       *Clause     `json:"not,omitempty"`
+	Field     string      `json:"field,omitempty"`
+	Parameter string      `json:"parameter,omitempty"`
+	Value     interface{} `json:"value"`
+}
+
+// Filter => validated filter which is emitted as MongoDB and Elasticsearch queries. Groups (and, or and not) have
+// Children, conditions have a whitelisted Field, an Operator and a Value of the field type (string, float64, bool or
+// time.Time, a list of them for in and nin, bool for exists). And without children matches every document.
+type Filter struct {
+	Kind     string
+	Children []Filter
+	Field    string
+	Operator string
+	Value    interface{}
+}
+
+// And => filter of every filter, a single filter is not wrapped
+func And(filters ...Filter) Filter {
+	if len(filters) == 1 {
+		return filters[0]
+	}
+	return Filter{Kind: FilterAnd, Children: filters}
+}
+
+// NewFilter => filter of clause, a clause is exactly one of a group or a condition. name is the path of clause in
+// request and it is used in errors.
+func NewFilter(name string, clause Clause) (Filter, error) {
+	parts := 0
+	for _, set := range []bool{clause.And != nil, clause.Or != nil, clause.Not != nil, clause.Field != ""} {
+		if set {
+			parts++
+		}
+	}
+	if parts != 1 {
+		return Filter{}, fmt.Errorf("%w: %v: clause must have exactly one of and, or, not and field", ErrInvalidQuery, name)
+	}
+
+	switch {
+	case clause.And != nil:
+		return newGroup(name+".and", FilterAnd, clause.And)
+	case clause.Or != nil:
+		return newGroup(name+".or", FilterOr, clause.Or)
+	case clause.Not != nil:
+		child, err := NewFilter(name+".not", *clause.Not)
+		if err != nil {
+			return Filter{}, err
+		}
+		return Filter{Kind: FilterNot, Children: []Filter{child}}, nil
+	}
+
+	return NewCondition(name, clause.Field, clause.Parameter, clause.Value)
+}
+
+func newGroup(name string, kind string, clauses []Clause) (Filter, error) {
+	if len(clauses) == 0 {
+		return Filter{}, fmt.Errorf("%w: %v: group has no clauses", ErrInvalidQuery, name)
+	}
+
+	group := Filter{Kind: kind, Children: make([]Filter, 0, len(clauses))}
+	for i, clause := range clauses {
+		child, err := NewFilter(fmt.Sprintf("%v[%d]", name, i), clause)
+		if err != nil {
+			return Filter{}, err
+		}
+		group.Children = append(group.Children, child)
+	}
+	return group, nil
+}
+
+// NewCondition => condition of a whitelisted field (configs.GenericEndpointFieldTypes), operator of parameter must fit
+// type of field and value is converted to it. Regular expressions must be in the common subset of MongoDB and
+// Elasticsearch syntaxes (newRegex).
+func NewCondition(name string, field string, parameter string, value interface{}) (Filter, error) {
+	fieldType, ok := configs.GenericEndpointFieldTypes[field]
+	if !ok {
+		return Filter{}, fmt.Errorf("%w: %v: field {%v} cannot be filtered", ErrInvalidQuery, name, field)
+	}
+	operator, ok := Operators[parameter]
+	if !ok {
+		return Filter{}, fmt.Errorf("%w: %v: parameter {%v} is not an operator", ErrInvalidQuery, name, parameter)
+	}
+	if !operatorFits(operator, fieldType) {
+		return Filter{}, fmt.Errorf("%w: %v: operator {%v} cannot be used with %v field {%v}", ErrInvalidQuery, name, operator, fieldType, field)
+	}
+
+	var err error
+	switch operator {
+	case OperatorExists:
+		if _, ok := value.(bool); !ok {
+			err = fmt.Errorf("value {%v} is not a bool", value)
+		}
+	case OperatorIn, OperatorNin:
+		values, ok := value.([]interface{})
+		if !ok || len(values) == 0 {
+			err = fmt.Errorf("value {%v} is not a list of values", value)
+			break
+		}
+		typedValues := make([]interface{}, len(values))
+		for i := range values {
+			if typedValues[i], err = typedValue(fieldType, values[i]); err != nil {
+				break
+			}
+		}
+		value = typedValues
+	case OperatorRegex:
+		pattern, ok := value.(string)
+		if !ok {
+			err = fmt.Errorf("value {%v} is not a regular expression", value)
+		} else if _, compileErr := regexp.Compile(pattern); compileErr != nil {
+			err = fmt.Errorf("value {%v} is not a regular expression", value)
+		} else {
+			_, err = newRegex(pattern)
+		}
+	default:
+		value, err = typedValue(fieldType, value)
+	}
+	if err != nil {
+		return Filter{}, fmt.Errorf("%w: %v: %v", ErrInvalidQuery, name, err)
+	}
+
+	return Filter{Kind: FilterCondition, Field: field, Operator: operator, Value: value}, nil
+}
+
+// operatorFits => ranges are for numbers and dates, regular expressions are for strings
+func operatorFits(operator string, fieldType string) bool {
+	switch operator {
+	case OperatorGt, OperatorGte, OperatorLt, OperatorLte:
+		return fieldType == configs.FieldTypeNumber || fieldType == configs.FieldTypeDate
+	case OperatorRegex:
+		return fieldType == configs.FieldTypeString
+	case OperatorIn, OperatorNin:
+		return fieldType != configs.FieldTypeBool
+	}
+	return true
+}
+
+// typedValue => value of request as value of field type, dates are 2006-01-02 or RFC 3339
+func typedValue(fieldType string, value interface{}) (interface{}, error) {
+	switch fieldType {
+	case configs.FieldTypeString:
+		if s, ok := value.(string); ok {
+			return s, nil
+		}
+	case configs.FieldTypeNumber:
+		switch number := value.(type) {
+		case float64:
+			return number, nil
+		case int:
+			return float64(number), nil
+		case int64:
+			return float64(number), nil
+		}
+	case configs.FieldTypeBool:
+		if b, ok := value.(bool); ok {
+			return b, nil
+		}
+	case configs.FieldTypeDate:
+		if s, ok := value.(string); ok {
+			if date, err := time.Parse("2006-01-02", s); err == nil {
+				return date, nil
+			}
+			if date, err := time.Parse(time.RFC3339, s); err == nil {
+				return date.UTC(), nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("value {%v} is not a %v", value, fieldType)
+}
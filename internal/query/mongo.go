@@ -0,0 +1,59 @@
+package query
+
+import (
+	"OrderUserProject/internal/configs"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Mongo => query as MongoDB filter and find options, fields and operators are mapped with generic endpoint config of
+// MongoDB
+func Mongo(query Query, config configs.GenericEndpointConfig) (bson.M, *options.FindOptions) {
+	findOptions := options.Find()
+
+	// Add projection criteria to find options if provided, _id is always in result
+	if len(query.Fields) > 0 {
+		projection := bson.M{}
+		for _, field := range query.Fields {
+			projection[config.ExactFilterArea[field]] = 1
+		}
+		findOptions.SetProjection(projection)
+	}
+
+	// Add sort criteria to find options if provided
+	if len(query.Sort) > 0 {
+		// We can use multiple sorts with bson.E (=> bson.D {"total":-1,"createdAt":-1})
+		sortFields := bson.D{}
+		for _, sortField := range query.Sort {
+			direction := 1
+			if sortField.Descending {
+				direction = -1
+			}
+			sortFields = append(sortFields, bson.E{Key: config.ExactFilterArea[sortField.Field], Value: direction})
+		}
+		findOptions.SetSort(sortFields)
+	}
+
+	return mongoFilter(query.Filter, config), findOptions
+}
+
+func mongoFilter(filter Filter, config configs.GenericEndpointConfig) bson.M {
+	switch filter.Kind {
+	case FilterAnd, FilterOr:
+		// => "$and" of no queries is not valid, it matches every document
+		if len(filter.Children) == 0 {
+			return bson.M{}
+		}
+		queries := make([]bson.M, 0, len(filter.Children))
+		for _, child := range filter.Children {
+			queries = append(queries, mongoFilter(child, config))
+		}
+		return bson.M{"$" + filter.Kind: queries}
+	case FilterNot:
+		// => "$not" is an operator of fields, "$nor" negates a query
+		return bson.M{"$nor": []bson.M{mongoFilter(filter.Children[0], config)}}
+	}
+
+	// => "total":{"$lt":2000}
+	return bson.M{config.ExactFilterArea[filter.Field]: bson.M{config.MatchFilterParameter[filter.Operator]: filter.Value}}
+}
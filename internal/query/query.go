@@ -0,0 +1,42 @@
+package query
+
+import (
+	"OrderUserProject/internal/configs"
+	"fmt"
+)
+
+// Query => backend agnostic query of generic endpoints, it is emitted as MongoDB (Mongo) and Elasticsearch (Elastic)
+// queries with the same semantics. Fields and Sort are whitelisted fields like fields of Filter.
+type Query struct {
+	Filter Filter
+	// Fields => fields of documents in result, id is always in result. Every field is in result without fields.
+	Fields []string
+	// Sort => first field has the highest priority
+	Sort []SortField
+}
+
+type SortField struct {
+	Field      string
+	Descending bool
+}
+
+// NewFields => fields of request, they must be whitelisted
+func NewFields(name string, fields []string) ([]string, error) {
+	for i, field := range fields {
+		if _, ok := configs.GenericEndpointFieldTypes[field]; !ok {
+			return nil, fmt.Errorf("%w: %v[%d]: field {%v} cannot be selected", ErrInvalidQuery, name, i, field)
+		}
+	}
+	return fields, nil
+}
+
+// NewSortField => sort of a whitelisted field, direction is 1 (ascending) or -1 (descending)
+func NewSortField(name string, field string, direction int) (SortField, error) {
+	if _, ok := configs.GenericEndpointFieldTypes[field]; !ok {
+		return SortField{}, fmt.Errorf("%w: %v: field {%v} cannot be sorted", ErrInvalidQuery, name, field)
+	}
+	if direction != 1 && direction != -1 {
+		return SortField{}, fmt.Errorf("%w: %v: direction {%v} is not 1 or -1", ErrInvalidQuery, name, direction)
+	}
+	return SortField{Field: field, Descending: direction == -1}, nil
+}
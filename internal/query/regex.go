@@ -0,0 +1,108 @@
+package query
+
+import (
+	"errors"
+	"strings"
+)
+
+// luceneOperators => characters which are operators of optional features of Lucene (Elasticsearch enables all of
+// them) and literals of MongoDB, they are escaped on Elasticsearch
+const luceneOperators = `"#@&<>~`
+
+// regex => regular expression of regex operator. Anchors are kept apart from the body, because regular expression of
+// Elasticsearch (Lucene) always matches the whole value and ^ and $ are literals of it.
+type regex struct {
+	// Lucene => body without anchors in Lucene syntax
+	Lucene      string
+	Start       bool
+	End         bool
+	Alternation bool
+}
+
+// newRegex => pattern must be in the common subset of MongoDB and Lucene syntaxes, so both match the same values:
+// literals, escaped punctuation, ., character classes in brackets, groups, alternation and repetitions. Shorthand
+// classes (e.g. \d), flags (e.g. (?i)) and anchors which are not at start and end of pattern (e.g. in alternation) are
+// not valid, Lucene has no equivalent of them.
+func newRegex(pattern string) (regex, error) {
+	var r regex
+	var lucene strings.Builder
+
+	body := pattern
+	if strings.HasPrefix(body, "^") {
+		r.Start = true
+		body = body[1:]
+	}
+
+	depth := 0
+	// classStart => index of first character of current character class, -1 out of class
+	classStart := -1
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+		if c == '\\' {
+			if i+1 == len(body) {
+				return regex{}, errors.New("trailing \\ of regular expression is not valid")
+			}
+			next := body[i+1]
+			if isAlphanumeric(next) {
+				return regex{}, errors.New("escape {\\" + string(next) + "} of regular expression is not supported, use a character class (e.g. [0-9])")
+			}
+			lucene.WriteByte(c)
+			lucene.WriteByte(next)
+			i++
+			continue
+		}
+
+		if classStart >= 0 {
+			switch {
+			case c == '[' && i+1 < len(body) && body[i+1] == ':':
+				return regex{}, errors.New("named character class of regular expression is not supported, use a character class (e.g. [a-z])")
+			case c == '^' && i == classStart:
+				// => negated class, ] after it is a literal
+				classStart++
+			case c == ']' && i > classStart:
+				classStart = -1
+			}
+			lucene.WriteByte(c)
+			continue
+		}
+
+		switch c {
+		case '[':
+			classStart = i + 1
+		case '(':
+			if i+1 < len(body) && body[i+1] == '?' {
+				return regex{}, errors.New("flags and non-capturing groups of regular expression are not supported")
+			}
+			depth++
+		case ')':
+			depth--
+		case '|':
+			if depth == 0 {
+				r.Alternation = true
+			}
+		case '^':
+			return regex{}, errors.New("anchors of regular expression must be at its start and end and out of alternation")
+		case '$':
+			if i != len(body)-1 {
+				return regex{}, errors.New("anchors of regular expression must be at its start and end and out of alternation")
+			}
+			r.End = true
+			continue
+		default:
+			if strings.IndexByte(luceneOperators, c) >= 0 {
+				lucene.WriteByte('\\')
+			}
+		}
+		lucene.WriteByte(c)
+	}
+
+	if r.Alternation && (r.Start || r.End) {
+		return regex{}, errors.New("anchors of regular expression must be at its start and end and out of alternation")
+	}
+	r.Lucene = lucene.String()
+	return r, nil
+}
+
+func isAlphanumeric(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
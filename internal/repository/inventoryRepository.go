@@ -0,0 +1,206 @@
+package repository
+
+import (
+	"OrderUserProject/internal/models"
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+// InventoryRepository => stock of products (product collection) and reservations of inventory sagas. Every stock change
+// is a single document update which is guarded with reservation list of product, so a saga changes a product only once.
+type InventoryRepository struct {
+	ProductCollection     *mongo.Collection
+	ReservationCollection *mongo.Collection
+}
+
+func NewInventoryRepository(productCollection *mongo.Collection, reservationCollection *mongo.Collection) IInventoryRepository {
+	inventoryRepository := &InventoryRepository{ProductCollection: productCollection, ReservationCollection: reservationCollection}
+	return inventoryRepository
+}
+
+// IInventoryRepository to use for test or
+type IInventoryRepository interface {
+	GetReservationById(id string) (models.StockReservation, error)
+	UpsertReservation(reservation models.StockReservation) error
+	ReserveLine(sagaId string, line models.StockLine) (bool, error)
+	ReleaseLine(sagaId string, sku string) error
+	CommitLine(sagaId string, sku string) error
+	ReduceLine(sagaId string, line models.StockLine, adjustmentId string) error
+	RestockLine(line models.StockLine) error
+	SetStock(id string, stock int, updatedAt time.Time) (bool, error)
+}
+
+// GetReservationById Method => reservation of saga
+func (b *InventoryRepository) GetReservationById(id string) (models.StockReservation, error) {
+	var reservation models.StockReservation
+
+	// to open connection
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	err := b.ReservationCollection.FindOne(ctx, bson.M{"_id": id}).Decode(&reservation)
+
+	if err != nil {
+		return reservation, err
+	}
+
+	return reservation, nil
+}
+
+// UpsertReservation Method => create or replace reservation of saga
+func (b *InventoryRepository) UpsertReservation(reservation models.StockReservation) error {
+	// to open connection
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	_, err := b.ReservationCollection.ReplaceOne(ctx, bson.M{"_id": reservation.ID}, reservation, options.Replace().SetUpsert(true))
+	return err
+}
+
+// ReserveLine Method => reserve quantity of line if product is active and has enough available (stock - reserved)
+// quantity. Line which is already reserved by the saga is not reserved twice, it returns true.
+func (b *InventoryRepository) ReserveLine(sagaId string, line models.StockLine) (bool, error) {
+	// to open connection
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"sku":                 line.Sku,
+		"active":              true,
+		"reservations.sagaId": bson.M{"$ne": sagaId},
+		"$expr":               bson.M{"$gte": bson.A{bson.M{"$subtract": bson.A{"$stock", "$reserved"}}, line.Quantity}},
+	}
+	update := bson.M{
+		"$inc":  bson.M{"reserved": line.Quantity},
+		"$push": bson.M{"reservations": models.ProductReservation{SagaID: sagaId, Quantity: line.Quantity}},
+	}
+
+	result, err := b.ProductCollection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	if result.ModifiedCount > 0 {
+		return true, nil
+	}
+
+	// Command may be handled before (e.g. reply is lost)
+	count, err := b.ProductCollection.CountDocuments(ctx, bson.M{"sku": line.Sku, "reservations.sagaId": sagaId})
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// ReleaseLine Method => give reserved quantity of saga back, nothing is changed if saga has no reservation on product
+func (b *InventoryRepository) ReleaseLine(sagaId string, sku string) error {
+	return b.removeReservation(sagaId, sku, false)
+}
+
+// CommitLine Method => remove reserved quantity of saga from stock, nothing is changed if saga has no reservation on product
+func (b *InventoryRepository) CommitLine(sagaId string, sku string) error {
+	return b.removeReservation(sagaId, sku, true)
+}
+
+// ReduceLine Method => reserved quantity of saga is reduced by quantity of line (at most by its reservation). Adjustment
+// is recorded on reservation of product in the same update, so a repeated adjustment doesn't change anything.
+func (b *InventoryRepository) ReduceLine(sagaId string, line models.StockLine, adjustmentId string) error {
+	// to open connection
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	reservationFilter := bson.M{"$elemMatch": bson.M{"sagaId": sagaId, "adjustments": bson.M{"$ne": adjustmentId}}}
+
+	var product models.Product
+	err := b.ProductCollection.FindOne(ctx, bson.M{"sku": line.Sku, "reservations": reservationFilter}).Decode(&product)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	quantity := line.Quantity
+	for _, reservation := range product.Reservations {
+		if reservation.SagaID == sagaId && reservation.Quantity < quantity {
+			quantity = reservation.Quantity
+		}
+	}
+
+	// Reservation without quantity is kept until saga is committed or released, it doesn't change stock
+	filter := bson.M{"_id": product.ID, "reservations": reservationFilter}
+	update := bson.M{
+		"$inc":  bson.M{"reserved": -quantity, "reservations.$.quantity": -quantity},
+		"$push": bson.M{"reservations.$.adjustments": adjustmentId},
+	}
+
+	_, err = b.ProductCollection.UpdateOne(ctx, filter, update)
+	return err
+}
+
+// RestockLine Method => committed quantity which is not shipped (it is canceled) is added to stock again
+func (b *InventoryRepository) RestockLine(line models.StockLine) error {
+	// to open connection
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	_, err := b.ProductCollection.UpdateOne(ctx, bson.M{"sku": line.Sku}, bson.M{"$inc": bson.M{"stock": line.Quantity}})
+	return err
+}
+
+// SetStock Method => change quantity on hand, stock cannot be less than reserved quantity
+func (b *InventoryRepository) SetStock(id string, stock int, updatedAt time.Time) (bool, error) {
+	// to open connection
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	filter := bson.M{"_id": id, "reserved": bson.M{"$lte": stock}}
+	update := bson.M{"$set": bson.M{"stock": stock, "updatedAt": updatedAt}}
+
+	result, err := b.ProductCollection.UpdateOne(ctx, filter, update)
+	if err != nil || result.MatchedCount <= 0 {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (b *InventoryRepository) removeReservation(sagaId string, sku string, commit bool) error {
+	// to open connection
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	var product models.Product
+	err := b.ProductCollection.FindOne(ctx, bson.M{"sku": sku, "reservations.sagaId": sagaId}).Decode(&product)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var quantity int
+	for _, reservation := range product.Reservations {
+		if reservation.SagaID == sagaId {
+			quantity = reservation.Quantity
+		}
+	}
+
+	inc := bson.M{"reserved": -quantity}
+	if commit {
+		inc["stock"] = -quantity
+	}
+
+	// Filter with saga id again, the reservation may be removed in the meantime
+	filter := bson.M{"_id": product.ID, "reservations.sagaId": sagaId}
+	update := bson.M{
+		"$inc":  inc,
+		"$pull": bson.M{"reservations": bson.M{"sagaId": sagaId}},
+	}
+
+	_, err = b.ProductCollection.UpdateOne(ctx, filter, update)
+	return err
+}
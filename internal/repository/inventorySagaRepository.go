@@ -0,0 +1,195 @@
+package repository
+
+import (
+	"OrderUserProject/internal/models"
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+type InventorySagaRepository struct {
+	SagaCollection *mongo.Collection
+}
+
+func NewInventorySagaRepository(mongoCollection *mongo.Collection) IInventorySagaRepository {
+	inventorySagaRepository := &InventorySagaRepository{SagaCollection: mongoCollection}
+	return inventorySagaRepository
+}
+
+// IInventorySagaRepository to use for test or
+type IInventorySagaRepository interface {
+	GetSagaById(id string) (models.InventorySaga, error)
+	GetSagasByState(states []string, updatedBefore time.Time) ([]models.InventorySaga, error)
+	Insert(saga models.InventorySaga) (bool, error)
+	UpdateState(id string, fromStates []string, step models.SagaStep) (bool, error)
+	AddAttempt(id string, state string, updatedAt time.Time) (bool, error)
+	AddAdjustment(id string, fromStates []string, lines []models.StockLine, adjustment models.StockAdjustment) (bool, error)
+	RemoveAdjustment(id string, adjustmentId string) error
+	GetSagasWithPendingAdjustments(addedBefore time.Time) ([]models.InventorySaga, error)
+}
+
+// GetSagaById Method => saga of order, saga id is the order id
+func (b *InventorySagaRepository) GetSagaById(id string) (models.InventorySaga, error) {
+	var saga models.InventorySaga
+
+	// to open connection
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	err := b.SagaCollection.FindOne(ctx, bson.M{"_id": id}).Decode(&saga)
+
+	if err != nil {
+		return saga, err
+	}
+
+	return saga, nil
+}
+
+// GetSagasByState Method => sagas in one of the states, oldest first. Sagas which are updated after updatedBefore are
+// not listed (zero time lists every saga).
+func (b *InventorySagaRepository) GetSagasByState(states []string, updatedBefore time.Time) ([]models.InventorySaga, error) {
+	var sagas []models.InventorySaga
+
+	// to open connection
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	filter := bson.M{"state": bson.M{"$in": states}}
+	if !updatedBefore.IsZero() {
+		filter["updatedAt"] = bson.M{"$lt": updatedBefore}
+	}
+
+	result, err := b.SagaCollection.Find(ctx, filter, options.Find().SetSort(bson.M{"updatedAt": 1}))
+
+	if err != nil {
+		return nil, err
+	}
+
+	for result.Next(ctx) {
+		var saga models.InventorySaga
+		if err := result.Decode(&saga); err != nil {
+			return nil, err
+		}
+		sagas = append(sagas, saga)
+	}
+
+	return sagas, nil
+}
+
+// Insert method => to create saga of order
+func (b *InventorySagaRepository) Insert(saga models.InventorySaga) (bool, error) {
+	// to open connection
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	// mongodb.driver
+	result, err := b.SagaCollection.InsertOne(ctx, saga)
+
+	if err != nil {
+		return false, err
+	}
+	if result.InsertedID == nil {
+		return false, errors.New("failed to add")
+	}
+
+	return true, nil
+}
+
+// UpdateState Method => move saga to state of step only if it is in one of fromStates, so a repeated or late reply
+// cannot move the saga back. Step is added to history and attempts are reset.
+func (b *InventorySagaRepository) UpdateState(id string, fromStates []string, step models.SagaStep) (bool, error) {
+	// open connection
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	filter := bson.M{"_id": id, "state": bson.M{"$in": fromStates}}
+	update := bson.M{
+		"$set":  bson.M{"state": step.State, "reason": step.Reason, "attempts": 0, "updatedAt": step.At},
+		"$push": bson.M{"steps": step},
+	}
+
+	result, err := b.SagaCollection.UpdateOne(ctx, filter, update)
+	if err != nil || result.ModifiedCount <= 0 {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// AddAttempt Method => command of state is sent again, saga is not changed if it left the state in the meantime
+func (b *InventorySagaRepository) AddAttempt(id string, state string, updatedAt time.Time) (bool, error) {
+	// open connection
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	filter := bson.M{"_id": id, "state": state}
+	update := bson.M{"$inc": bson.M{"attempts": 1}, "$set": bson.M{"updatedAt": updatedAt}}
+
+	result, err := b.SagaCollection.UpdateOne(ctx, filter, update)
+	if err != nil || result.ModifiedCount <= 0 {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// AddAdjustment Method => lines of saga become the remaining lines and adjustment waits for reply of product-api. Saga
+// is changed only if it is in one of fromStates.
+func (b *InventorySagaRepository) AddAdjustment(id string, fromStates []string, lines []models.StockLine, adjustment models.StockAdjustment) (bool, error) {
+	// open connection
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	filter := bson.M{"_id": id, "state": bson.M{"$in": fromStates}, "pendingAdjustments.id": bson.M{"$ne": adjustment.ID}}
+	update := bson.M{
+		"$set":  bson.M{"lines": lines, "updatedAt": adjustment.At},
+		"$push": bson.M{"pendingAdjustments": adjustment},
+	}
+
+	result, err := b.SagaCollection.UpdateOne(ctx, filter, update)
+	if err != nil || result.ModifiedCount <= 0 {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// RemoveAdjustment Method => adjustment is replied, nothing is changed if it is removed before
+func (b *InventorySagaRepository) RemoveAdjustment(id string, adjustmentId string) error {
+	// open connection
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	update := bson.M{"$pull": bson.M{"pendingAdjustments": bson.M{"id": adjustmentId}}}
+	_, err := b.SagaCollection.UpdateOne(ctx, bson.M{"_id": id}, update)
+	return err
+}
+
+// GetSagasWithPendingAdjustments Method => sagas which have an adjustment that is added before addedBefore and not
+// replied yet
+func (b *InventorySagaRepository) GetSagasWithPendingAdjustments(addedBefore time.Time) ([]models.InventorySaga, error) {
+	var sagas []models.InventorySaga
+
+	// to open connection
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	filter := bson.M{"pendingAdjustments.at": bson.M{"$lt": addedBefore}}
+	result, err := b.SagaCollection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	for result.Next(ctx) {
+		var saga models.InventorySaga
+		if err := result.Decode(&saga); err != nil {
+			return nil, err
+		}
+		sagas = append(sagas, saga)
+	}
+
+	return sagas, nil
+}
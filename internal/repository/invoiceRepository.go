@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"OrderUserProject/internal/models"
+	"context"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+type InvoiceRepository struct {
+	InvoiceCollection *mongo.Collection
+	CounterCollection *mongo.Collection
+}
+
+func NewInvoiceRepository(invoiceCollection *mongo.Collection, counterCollection *mongo.Collection) IInvoiceRepository {
+	invoiceRepository := &InvoiceRepository{InvoiceCollection: invoiceCollection, CounterCollection: counterCollection}
+	return invoiceRepository
+}
+
+// IInvoiceRepository to use for test or
+type IInvoiceRepository interface {
+	GetInvoiceByOrderId(orderId string) (models.Invoice, error)
+	Claim(invoice models.Invoice, staleBefore time.Time) (bool, error)
+	AssignNumber(id string, number string) (bool, error)
+	Issue(invoice models.Invoice, claimedStatus string) (bool, error)
+	NextSequence(name string, holderId string) (int64, error)
+	ReleaseSequence(name string, holderId string) error
+}
+
+// GetInvoiceByOrderId Method => invoice of order, id of invoice is the order id
+func (b *InvoiceRepository) GetInvoiceByOrderId(orderId string) (models.Invoice, error) {
+	var invoice models.Invoice
+
+	// to open connection
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	err := b.InvoiceCollection.FindOne(ctx, bson.M{"_id": orderId}).Decode(&invoice)
+	return invoice, err
+}
+
+// Claim Method => invoice is created with its status (e.g. generating), so only one request generates invoice of an
+// order. Invoice which is not issued and is not updated after staleBefore (generation is interrupted) is claimed again.
+// Returns false if invoice is issued or another request generates it.
+func (b *InvoiceRepository) Claim(invoice models.Invoice, staleBefore time.Time) (bool, error) {
+	// open connection
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"_id": invoice.ID, "status": invoice.Status, "updatedAt": bson.M{"$lt": staleBefore}}
+	update := bson.M{
+		"$set":         bson.M{"updatedAt": invoice.UpdatedAt},
+		"$setOnInsert": bson.M{"orderId": invoice.OrderID, "createdAt": invoice.CreatedAt},
+	}
+
+	result, err := b.InvoiceCollection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		// Invoice exists with another status or it is claimed recently
+		return false, nil
+	}
+	if err != nil || (result.ModifiedCount <= 0 && result.UpsertedCount <= 0) {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// AssignNumber Method => number is saved once to claimed invoice, so invoice which is claimed again keeps its number
+func (b *InvoiceRepository) AssignNumber(id string, number string) (bool, error) {
+	// open connection
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"_id": id, "number": bson.M{"$exists": false}}
+	result, err := b.InvoiceCollection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"number": number}})
+	if err != nil || result.ModifiedCount <= 0 {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Issue Method => status, snapshot of order and files of claimed invoice are saved
+func (b *InvoiceRepository) Issue(invoice models.Invoice, claimedStatus string) (bool, error) {
+	// open connection
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	update := bson.M{"$set": bson.M{
+		"status":         invoice.Status,
+		"userId":         invoice.UserId,
+		"invoiceAddress": invoice.InvoiceAddress,
+		"lines":          invoice.Lines,
+		"currency":       invoice.Currency,
+		"subtotal":       invoice.Subtotal,
+		"discount":       invoice.Discount,
+		"tax":            invoice.Tax,
+		"shipping":       invoice.Shipping,
+		"total":          invoice.Total,
+		"pdfFile":        invoice.PdfFile,
+		"htmlFile":       invoice.HtmlFile,
+		"issuedAt":       invoice.IssuedAt,
+		"updatedAt":      invoice.UpdatedAt,
+	}}
+
+	result, err := b.InvoiceCollection.UpdateOne(ctx, bson.M{"_id": invoice.ID, "status": claimedStatus}, update)
+	if err != nil || result.ModifiedCount <= 0 {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// NextSequence Method => next value of named sequence, it starts from 1. Value is reserved for holder in the same
+// update which increments it, so concurrent calls never get the same value and a holder which calls again (e.g. it is
+// interrupted before it saves the value) gets its value again until it is released.
+func (b *InvoiceRepository) NextSequence(name string, holderId string) (int64, error) {
+	var counter struct {
+		Value   int64            `bson:"value"`
+		Holders map[string]int64 `bson:"holders"`
+	}
+
+	// open connection
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	holder := "holders." + holderId
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"value": bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$value", 0}}, 1}}}}},
+		{{Key: "$set", Value: bson.M{holder: "$value"}}},
+	}
+	opt := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	// Counter which is created by a concurrent call is updated on second attempt
+	for attempt := 0; attempt < 2; attempt++ {
+		err := b.CounterCollection.FindOneAndUpdate(ctx, bson.M{"_id": name, holder: bson.M{"$exists": false}}, update, opt).Decode(&counter)
+		if mongo.IsDuplicateKeyError(err) {
+			// Counter exists and holder may have a value already
+			err = b.CounterCollection.FindOne(ctx, bson.M{"_id": name}).Decode(&counter)
+		}
+		if err != nil {
+			return 0, err
+		}
+		if value, ok := counter.Holders[holderId]; ok {
+			return value, nil
+		}
+	}
+
+	return 0, fmt.Errorf("value of sequence %v cannot reserve", name)
+}
+
+// ReleaseSequence Method => value of holder is saved, its reservation is removed from counter
+func (b *InvoiceRepository) ReleaseSequence(name string, holderId string) error {
+	// open connection
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := b.CounterCollection.UpdateOne(ctx, bson.M{"_id": name}, bson.M{"$unset": bson.M{"holders." + holderId: ""}})
+	return err
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+// OrderChangeStreamRepository => change stream of orders collection, resume token of a watcher is kept in token
+// collection, so watcher continues after the last change it handled when it is restarted
+type OrderChangeStreamRepository struct {
+	OrderCollection *mongo.Collection
+	TokenCollection *mongo.Collection
+}
+
+func NewOrderChangeStreamRepository(orderCollection *mongo.Collection, tokenCollection *mongo.Collection) IOrderChangeStreamRepository {
+	orderChangeStreamRepository := &OrderChangeStreamRepository{OrderCollection: orderCollection, TokenCollection: tokenCollection}
+	return orderChangeStreamRepository
+}
+
+// IOrderChangeStreamRepository to use for test or
+type IOrderChangeStreamRepository interface {
+	Watch(ctx context.Context, resumeToken bson.Raw) (*mongo.ChangeStream, error)
+	GetResumeToken(name string) (bson.Raw, error)
+	SaveResumeToken(name string, token bson.Raw) error
+	DeleteResumeToken(name string) error
+}
+
+// Watch Method => changes of orders after resume token (after now if token is nil). Inserted, updated and replaced
+// orders come with their current document (it is nil if order is deleted after change).
+func (b *OrderChangeStreamRepository) Watch(ctx context.Context, resumeToken bson.Raw) (*mongo.ChangeStream, error) {
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: bson.M{
+		"operationType": bson.M{"$in": bson.A{"insert", "update", "replace", "delete", "invalidate"}},
+	}}}}
+
+	opts := options.ChangeStream().SetFullDocument(options.UpdateLookup)
+	if resumeToken != nil {
+		// startAfter => stream continues after an 'invalidate' event too (collection is dropped or renamed)
+		opts.SetStartAfter(resumeToken)
+	}
+
+	return b.OrderCollection.Watch(ctx, pipeline, opts)
+}
+
+// GetResumeToken Method => last resume token of watcher, mongo.ErrNoDocuments if watcher has no token
+func (b *OrderChangeStreamRepository) GetResumeToken(name string) (bson.Raw, error) {
+	// to open connection
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	var token struct {
+		Token bson.Raw `bson:"token"`
+	}
+	err := b.TokenCollection.FindOne(ctx, bson.M{"_id": name}).Decode(&token)
+	return token.Token, err
+}
+
+// SaveResumeToken Method => token of last handled change of watcher
+func (b *OrderChangeStreamRepository) SaveResumeToken(name string, token bson.Raw) error {
+	// to open connection
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	update := bson.M{"$set": bson.M{"token": token, "updatedAt": time.Now()}}
+	_, err := b.TokenCollection.UpdateOne(ctx, bson.M{"_id": name}, update, options.Update().SetUpsert(true))
+	return err
+}
+
+// DeleteResumeToken Method => watcher starts from now, e.g. its token is not in oplog anymore
+func (b *OrderChangeStreamRepository) DeleteResumeToken(name string) error {
+	// to open connection
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	_, err := b.TokenCollection.DeleteOne(ctx, bson.M{"_id": name})
+	return err
+}
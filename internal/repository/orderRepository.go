@@ -25,11 +25,23 @@ func NewOrderRepository(mongoCollection *mongo.Collection) IOrderRepository {
 // IOrderRepository to use for test or
 type IOrderRepository interface {
 	GetAll() ([]models.Order, error)
+	GetOrdersAfter(afterId string, limit int64) ([]models.Order, error)
 	GetOrderById(id string) (models.Order, error)
 	Insert(order models.Order) (bool, error)
 	Update(user models.Order) (bool, error)
 	Delete(id string) (bool, error)
 	GetOrdersWithFilter(filter bson.M, opt *options.FindOptions) ([]interface{}, error)
+	FindOrders(filter bson.M, opt *options.FindOptions) ([]models.Order, int64, error)
+	GetOpenOrdersByAddress(userId string, addressId string, closedStatuses []string) ([]models.Order, error)
+	AddAddressChange(id string, change models.AddressChange, set bson.M) (bool, error)
+	GetOpenOrdersByUser(userId string, closedStatuses []string) ([]models.Order, error)
+	SoftDelete(id string, deletedAt time.Time) (bool, error)
+	Restore(id string) (bool, error)
+	PurgeDeleted(deletedBefore time.Time) (int64, error)
+	UpdateTotals(order models.Order) (bool, error)
+	Cancel(order models.Order, previousUpdatedAt time.Time, notCancelableStatuses []string) (bool, error)
+	UpdateRefundStatus(id string, refundId string, fromStatus string, status string, updatedAt time.Time) (bool, error)
+	UpdateShipments(order models.Order, previousUpdatedAt time.Time, notShippableStatuses []string) (bool, error)
 }
 
 // GetAll Method => to list every order
@@ -42,7 +54,7 @@ func (b *OrderRepository) GetAll() ([]models.Order, error) {
 	defer cancel()
 
 	//We can think of "Cursor" like a request. We pull the data from the database with the "Next" command. (C# => IQueryable)
-	result, err := b.OrderCollection.Find(ctx, bson.M{})
+	result, err := b.OrderCollection.Find(ctx, NotDeleted())
 
 	if err != nil {
 		return nil, err
@@ -60,6 +72,32 @@ func (b *OrderRepository) GetAll() ([]models.Order, error) {
 
 }
 
+// GetOrdersAfter Method => a batch of orders in id order whose id is after afterId (empty for first batch), so every
+// order is listed batch by batch without a long-running cursor
+func (b *OrderRepository) GetOrdersAfter(afterId string, limit int64) ([]models.Order, error) {
+	var orders []models.Order
+
+	// to open connection
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	opt := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(limit)
+	result, err := b.OrderCollection.Find(ctx, NotDeleted(bson.M{"_id": bson.M{"$gt": afterId}}), opt)
+	if err != nil {
+		return nil, err
+	}
+
+	for result.Next(ctx) {
+		var order models.Order
+		if err := result.Decode(&order); err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+
+	return orders, nil
+}
+
 // GetOrderById Method => to find a single order with id
 func (b *OrderRepository) GetOrderById(id string) (models.Order, error) {
 	var order models.Order
@@ -69,7 +107,7 @@ func (b *OrderRepository) GetOrderById(id string) (models.Order, error) {
 	defer cancel()
 
 	// to find book by id
-	err := b.OrderCollection.FindOne(ctx, bson.M{"_id": id}).Decode(&order)
+	err := b.OrderCollection.FindOne(ctx, NotDeleted(bson.M{"_id": id})).Decode(&order)
 
 	if err != nil {
 		return order, err
@@ -102,7 +140,7 @@ func (b *OrderRepository) Update(order models.Order) (bool, error) {
 
 	// => Update => update + insert = upsert => default value false
 	// opt := options.Update().SetUpsert(true)
-	filter := bson.D{{"_id", order.ID}}
+	filter := NotDeleted(bson.M{"_id": order.ID})
 
 	// => if we use this CreatedDate and id value will be null, so we have to use "UpdateOne"
 	//replacement := models.Book{Title: book.Title, Quantity: book.Quantity, Author: book.Author, UpdatedDate: book.UpdatedDate}
@@ -111,14 +149,7 @@ func (b *OrderRepository) Update(order models.Order) (bool, error) {
 	//update := bson.D{{"$set", bson.D{{"title", book.Title}}}}
 
 	// => if we have to chance more than one parameter we have to write like this
-	update := bson.D{{"$set", bson.D{
-		{"userId", order.UserId},
-		{"status", order.Status},
-		{"address", order.Address},
-		{"invoiceAddress", order.InvoiceAddress},
-		{"product", order.Product},
-		{"total", order.Total},
-		{"updatedAt", order.UpdatedAt}}}}
+	update := bson.D{{"$set", OrderUpdateFields(order)}}
 
 	// mongodb.driver
 	result, err := b.OrderCollection.UpdateOne(ctx, filter, update)
@@ -130,6 +161,26 @@ func (b *OrderRepository) Update(order models.Order) (bool, error) {
 	return true, nil
 }
 
+// OrderUpdateFields => fields of order which are set by Update, promotions of order are evaluated again on update, so
+// coupon and discount breakdown are saved with amounts
+func OrderUpdateFields(order models.Order) bson.D {
+	return bson.D{
+		{"userId", order.UserId},
+		{"status", order.Status},
+		{"address", order.Address},
+		{"invoiceAddress", order.InvoiceAddress},
+		{"product", order.Product},
+		{"currency", order.Currency},
+		{"couponCode", order.CouponCode},
+		{"promotions", order.Promotions},
+		{"subtotal", order.Subtotal},
+		{"discount", order.Discount},
+		{"tax", order.Tax},
+		{"shipping", order.Shipping},
+		{"total", order.Total},
+		{"updatedAt", order.UpdatedAt}}
+}
+
 // Delete Method => to delete a order from orders by id
 func (b *OrderRepository) Delete(id string) (bool, error) {
 	// to open connection
@@ -152,7 +203,7 @@ func (b *OrderRepository) GetOrdersWithFilter(filter bson.M, opt *options.FindOp
 	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
 	defer cancel()
 
-	result, err := b.OrderCollection.Find(ctx, filter, opt)
+	result, err := b.OrderCollection.Find(ctx, NotDeleted(filter), opt)
 
 	if err != nil {
 		return nil, err
@@ -174,3 +225,258 @@ func (b *OrderRepository) GetOrdersWithFilter(filter bson.M, opt *options.FindOp
 
 	return resultOrders, nil
 }
+
+// FindOrders Method => a page of orders with filter (skip, limit and sort of options) and count of every order which
+// matches the filter
+func (b *OrderRepository) FindOrders(filter bson.M, opt *options.FindOptions) ([]models.Order, int64, error) {
+	// open connection
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	count, err := b.OrderCollection.CountDocuments(ctx, NotDeleted(filter))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	result, err := b.OrderCollection.Find(ctx, NotDeleted(filter), opt)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var orders []models.Order
+	for result.Next(ctx) {
+		var order models.Order
+		if err := result.Decode(&order); err != nil {
+			return nil, 0, err
+		}
+		orders = append(orders, order)
+	}
+
+	return orders, count, nil
+}
+
+// GetOpenOrdersByAddress Method => orders of user which are not closed and have the address as regular or invoice address
+func (b *OrderRepository) GetOpenOrdersByAddress(userId string, addressId string, closedStatuses []string) ([]models.Order, error) {
+	// open connection
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"userId":    userId,
+		"status":    bson.M{"$nin": closedStatuses},
+		"deletedAt": bson.M{"$exists": false},
+		"$or": []bson.M{
+			{"address._id": addressId},
+			{"invoiceAddress._id": addressId},
+		},
+	}
+
+	result, err := b.OrderCollection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var orders []models.Order
+	for result.Next(ctx) {
+		var order models.Order
+		if err := result.Decode(&order); err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+
+	return orders, nil
+}
+
+// AddAddressChange Method => record address change decision on order and set changed fields (address, invoiceAddress).
+// The same event is recorded once, so redelivered kafka messages don't change the order again.
+func (b *OrderRepository) AddAddressChange(id string, change models.AddressChange, set bson.M) (bool, error) {
+	// open connection
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	filter := NotDeleted(bson.M{"_id": id, "addressChanges.eventId": bson.M{"$ne": change.EventID}})
+	update := bson.M{"$push": bson.M{"addressChanges": change}}
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+
+	result, err := b.OrderCollection.UpdateOne(ctx, filter, update)
+	if err != nil || result.ModifiedCount <= 0 {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// GetOpenOrdersByUser Method => orders of user which are not closed
+func (b *OrderRepository) GetOpenOrdersByUser(userId string, closedStatuses []string) ([]models.Order, error) {
+	// open connection
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	filter := NotDeleted(bson.M{
+		"userId": userId,
+		"status": bson.M{"$nin": closedStatuses},
+	})
+
+	result, err := b.OrderCollection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var orders []models.Order
+	for result.Next(ctx) {
+		var order models.Order
+		if err := result.Decode(&order); err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+
+	return orders, nil
+}
+
+// SoftDelete Method => mark order as deleted, order document is kept until purge
+func (b *OrderRepository) SoftDelete(id string, deletedAt time.Time) (bool, error) {
+	// open connection
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	update := bson.M{"$set": bson.M{"deletedAt": deletedAt, "updatedAt": deletedAt}}
+
+	result, err := b.OrderCollection.UpdateOne(ctx, NotDeleted(bson.M{"_id": id}), update)
+	if err != nil || result.ModifiedCount <= 0 {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Restore Method => remove deletedAt of soft deleted order
+func (b *OrderRepository) Restore(id string) (bool, error) {
+	// open connection
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	update := bson.M{"$unset": bson.M{"deletedAt": ""}, "$set": bson.M{"updatedAt": time.Now()}}
+
+	result, err := b.OrderCollection.UpdateOne(ctx, Deleted(id), update)
+	if err != nil || result.ModifiedCount <= 0 {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// PurgeDeleted Method => hard delete orders which are soft deleted before the date
+func (b *OrderRepository) PurgeDeleted(deletedBefore time.Time) (int64, error) {
+	// open connection
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	result, err := b.OrderCollection.DeleteMany(ctx, bson.M{"deletedAt": bson.M{"$lt": deletedBefore}})
+	if err != nil {
+		return 0, err
+	}
+
+	return result.DeletedCount, nil
+}
+
+// UpdateTotals Method => change only lines, currency and amounts of order. Order is not changed if it is updated after
+// it is read (updatedAt is different), so lines of a newer update are not overwritten.
+func (b *OrderRepository) UpdateTotals(order models.Order) (bool, error) {
+	// open connection
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := NotDeleted(bson.M{"_id": order.ID, "updatedAt": order.UpdatedAt})
+	update := bson.M{"$set": bson.M{
+		"product":  order.Product,
+		"currency": order.Currency,
+		"subtotal": order.Subtotal,
+		"discount": order.Discount,
+		"tax":      order.Tax,
+		"shipping": order.Shipping,
+		"total":    order.Total,
+	}}
+
+	result, err := b.OrderCollection.UpdateOne(ctx, filter, update)
+	if err != nil || result.ModifiedCount <= 0 {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Cancel Method => save lines, amounts, status, cancellations and refunds of canceled order. Order is not changed if it
+// is updated after it is read (updatedAt is previousUpdatedAt) or its status cannot be canceled anymore (e.g. shipped).
+func (b *OrderRepository) Cancel(order models.Order, previousUpdatedAt time.Time, notCancelableStatuses []string) (bool, error) {
+	// open connection
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := NotDeleted(bson.M{
+		"_id":       order.ID,
+		"updatedAt": previousUpdatedAt,
+		"status":    bson.M{"$nin": notCancelableStatuses},
+	})
+	update := bson.M{"$set": bson.M{
+		"product":       order.Product,
+		"status":        order.Status,
+		"subtotal":      order.Subtotal,
+		"discount":      order.Discount,
+		"tax":           order.Tax,
+		"shipping":      order.Shipping,
+		"total":         order.Total,
+		"cancellations": order.Cancellations,
+		"refunds":       order.Refunds,
+		"updatedAt":     order.UpdatedAt,
+	}}
+
+	result, err := b.OrderCollection.UpdateOne(ctx, filter, update)
+	if err != nil || result.ModifiedCount <= 0 {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// UpdateRefundStatus Method => change status of refund of order if it still has fromStatus, so a refund is issued once
+func (b *OrderRepository) UpdateRefundStatus(id string, refundId string, fromStatus string, status string, updatedAt time.Time) (bool, error) {
+	// open connection
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := NotDeleted(bson.M{"_id": id, "refunds": bson.M{"$elemMatch": bson.M{"id": refundId, "status": fromStatus}}})
+	update := bson.M{"$set": bson.M{"refunds.$.status": status, "refunds.$.updatedAt": updatedAt, "updatedAt": updatedAt}}
+
+	result, err := b.OrderCollection.UpdateOne(ctx, filter, update)
+	if err != nil || result.ModifiedCount <= 0 {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// UpdateShipments Method => save shipments and derived status of order. Order is not changed if it is updated after it
+// is read (updatedAt is previousUpdatedAt) or it is canceled or closed in the meantime.
+func (b *OrderRepository) UpdateShipments(order models.Order, previousUpdatedAt time.Time, notShippableStatuses []string) (bool, error) {
+	// open connection
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := NotDeleted(bson.M{
+		"_id":       order.ID,
+		"updatedAt": previousUpdatedAt,
+		"status":    bson.M{"$nin": notShippableStatuses},
+	})
+	update := bson.M{"$set": bson.M{"shipments": order.Shipments, "status": order.Status, "updatedAt": order.UpdatedAt}}
+
+	result, err := b.OrderCollection.UpdateOne(ctx, filter, update)
+	if err != nil || result.ModifiedCount <= 0 {
+		return false, err
+	}
+
+	return true, nil
+}
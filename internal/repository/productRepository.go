@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"OrderUserProject/internal/models"
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+type ProductRepository struct {
+	ProductCollection *mongo.Collection
+}
+
+func NewProductRepository(mongoCollection *mongo.Collection) IProductRepository {
+	productRepository := &ProductRepository{ProductCollection: mongoCollection}
+	return productRepository
+}
+
+// IProductRepository to use for test or
+type IProductRepository interface {
+	GetAll() ([]models.Product, error)
+	GetProductById(id string) (models.Product, error)
+	GetProductsBySkus(skus []string) ([]models.Product, error)
+	Insert(product models.Product) (bool, error)
+	Update(product models.Product) (bool, error)
+	Delete(id string) (bool, error)
+	CreateIndexes() error
+}
+
+// CreateIndexes Method => sku is unique in catalog, so two requests with the same sku cannot both insert a product
+func (b *ProductRepository) CreateIndexes() error {
+	// to open connection
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	_, err := b.ProductCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "sku", Value: 1}},
+		Options: options.Index().SetName("sku_unique").SetUnique(true),
+	})
+	return err
+}
+
+// GetAll Method => to list every product
+func (b *ProductRepository) GetAll() ([]models.Product, error) {
+	return b.find(bson.M{})
+}
+
+// GetProductById Method => to find a single product with id
+func (b *ProductRepository) GetProductById(id string) (models.Product, error) {
+	var product models.Product
+
+	// to open connection
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	err := b.ProductCollection.FindOne(ctx, bson.M{"_id": id}).Decode(&product)
+
+	if err != nil {
+		return product, err
+	}
+
+	return product, nil
+}
+
+// GetProductsBySkus Method => products with these skus, unknown skus are not in the list
+func (b *ProductRepository) GetProductsBySkus(skus []string) ([]models.Product, error) {
+	return b.find(bson.M{"sku": bson.M{"$in": skus}})
+}
+
+// Insert method => to create new product
+func (b *ProductRepository) Insert(product models.Product) (bool, error) {
+	// to open connection
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	// mongodb.driver
+	result, err := b.ProductCollection.InsertOne(ctx, product)
+
+	// Duplicate sku is returned as it is, so service can tell it from other errors
+	if mongo.IsDuplicateKeyError(err) {
+		return false, err
+	}
+	if err != nil || result.InsertedID == nil {
+		return false, errors.New("failed to add")
+	}
+
+	return true, nil
+}
+
+// Update method => to change exist product, sku cannot be changed because orders refer to it
+func (b *ProductRepository) Update(product models.Product) (bool, error) {
+	// to open connection
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"_id": product.ID}
+
+	update := bson.D{{"$set", bson.D{
+		{"name", product.Name},
+		{"description", product.Description},
+		{"price", product.Price},
+		{"currency", product.Currency},
+		{"taxClass", product.TaxClass},
+		{"active", product.Active},
+		{"updatedAt", product.UpdatedAt}}}}
+
+	// mongodb.driver
+	result, err := b.ProductCollection.UpdateOne(ctx, filter, update)
+
+	if result.ModifiedCount <= 0 || err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Delete Method => to delete a product from catalog by id, orders keep their snapshot of product
+func (b *ProductRepository) Delete(id string) (bool, error) {
+	// to open connection
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	// delete by id column
+	result, err := b.ProductCollection.DeleteOne(ctx, bson.M{"_id": id})
+
+	if err != nil || result.DeletedCount <= 0 {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (b *ProductRepository) find(filter bson.M) ([]models.Product, error) {
+	var products []models.Product
+
+	// to open connection
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	result, err := b.ProductCollection.Find(ctx, filter)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for result.Next(ctx) {
+		var product models.Product
+		if err := result.Decode(&product); err != nil {
+			return nil, err
+		}
+		products = append(products, product)
+	}
+
+	return products, nil
+}
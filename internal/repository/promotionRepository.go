@@ -0,0 +1,191 @@
+package repository
+
+import (
+	"OrderUserProject/internal/models"
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+type PromotionRepository struct {
+	PromotionCollection *mongo.Collection
+}
+
+func NewPromotionRepository(mongoCollection *mongo.Collection) IPromotionRepository {
+	promotionRepository := &PromotionRepository{PromotionCollection: mongoCollection}
+	return promotionRepository
+}
+
+// IPromotionRepository to use for test or
+type IPromotionRepository interface {
+	GetAll() ([]models.Promotion, error)
+	GetPromotionById(id string) (models.Promotion, error)
+	GetPromotionByCode(code string) (models.Promotion, error)
+	GetAutomatic(now time.Time) ([]models.Promotion, error)
+	Insert(promotion models.Promotion) (bool, error)
+	Update(promotion models.Promotion) (bool, error)
+	Redeem(id string, userId string, usageLimit int, perUserLimit int) (bool, error)
+	Release(id string, userId string) (bool, error)
+}
+
+// GetAll Method => every promotion, newest first
+func (b *PromotionRepository) GetAll() ([]models.Promotion, error) {
+	// to open connection
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	return b.find(ctx, bson.M{}, options.Find().SetSort(bson.M{"createdAt": -1}))
+}
+
+// GetPromotionById Method => to find a promotion by id
+func (b *PromotionRepository) GetPromotionById(id string) (models.Promotion, error) {
+	var promotion models.Promotion
+
+	// to open connection
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	err := b.PromotionCollection.FindOne(ctx, bson.M{"_id": id}).Decode(&promotion)
+	return promotion, err
+}
+
+// GetPromotionByCode Method => coupon with code, codes are kept in upper case
+func (b *PromotionRepository) GetPromotionByCode(code string) (models.Promotion, error) {
+	var promotion models.Promotion
+
+	// to open connection
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	err := b.PromotionCollection.FindOne(ctx, bson.M{"code": code}).Decode(&promotion)
+	return promotion, err
+}
+
+// GetAutomatic Method => active promotions without code which are valid at the time
+func (b *PromotionRepository) GetAutomatic(now time.Time) ([]models.Promotion, error) {
+	// to open connection
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"code":     bson.M{"$exists": false},
+		"active":   true,
+		"startsAt": bson.M{"$lte": now},
+		"$or":      bson.A{bson.M{"endsAt": time.Time{}}, bson.M{"endsAt": bson.M{"$gt": now}}},
+	}
+
+	return b.find(ctx, filter, options.Find().SetSort(bson.M{"priority": -1}))
+}
+
+// Insert method => to create promotion
+func (b *PromotionRepository) Insert(promotion models.Promotion) (bool, error) {
+	// to open connection
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := b.PromotionCollection.InsertOne(ctx, promotion)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Update method => change rules of promotion, usage counts are not changed
+func (b *PromotionRepository) Update(promotion models.Promotion) (bool, error) {
+	// to open connection
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	update := bson.M{"$set": bson.M{
+		"name":         promotion.Name,
+		"type":         promotion.Type,
+		"percent":      promotion.Percent,
+		"amount":       promotion.Amount,
+		"maxDiscount":  promotion.MaxDiscount,
+		"sku":          promotion.Sku,
+		"buyQuantity":  promotion.BuyQuantity,
+		"getQuantity":  promotion.GetQuantity,
+		"currency":     promotion.Currency,
+		"minSubtotal":  promotion.MinSubtotal,
+		"startsAt":     promotion.StartsAt,
+		"endsAt":       promotion.EndsAt,
+		"usageLimit":   promotion.UsageLimit,
+		"perUserLimit": promotion.PerUserLimit,
+		"stackable":    promotion.Stackable,
+		"priority":     promotion.Priority,
+		"active":       promotion.Active,
+		"updatedAt":    promotion.UpdatedAt,
+	}}
+
+	result, err := b.PromotionCollection.UpdateOne(ctx, bson.M{"_id": promotion.ID}, update)
+	if err != nil || result.ModifiedCount <= 0 {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Redeem Method => count one usage of user if usage limit and usage limit of user are not reached (zero is unlimited).
+// Limits are checked in the same update, so concurrent orders cannot use a promotion more than its limits.
+func (b *PromotionRepository) Redeem(id string, userId string, usageLimit int, perUserLimit int) (bool, error) {
+	// open connection
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userUsage := "userUsage." + userId
+	filter := bson.M{"_id": id}
+	if usageLimit > 0 {
+		filter["usedCount"] = bson.M{"$lt": usageLimit}
+	}
+	if perUserLimit > 0 {
+		filter[userUsage] = bson.M{"$not": bson.M{"$gte": perUserLimit}}
+	}
+	update := bson.M{"$inc": bson.M{"usedCount": 1, userUsage: 1}}
+
+	result, err := b.PromotionCollection.UpdateOne(ctx, filter, update)
+	if err != nil || result.ModifiedCount <= 0 {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Release Method => usage of user is given back, e.g. order cannot be saved after promotion is redeemed
+func (b *PromotionRepository) Release(id string, userId string) (bool, error) {
+	// open connection
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userUsage := "userUsage." + userId
+	filter := bson.M{"_id": id, "usedCount": bson.M{"$gt": 0}, userUsage: bson.M{"$gt": 0}}
+	update := bson.M{"$inc": bson.M{"usedCount": -1, userUsage: -1}}
+
+	result, err := b.PromotionCollection.UpdateOne(ctx, filter, update)
+	if err != nil || result.ModifiedCount <= 0 {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (b *PromotionRepository) find(ctx context.Context, filter interface{}, opt *options.FindOptions) ([]models.Promotion, error) {
+	var promotions []models.Promotion
+
+	result, err := b.PromotionCollection.Find(ctx, filter, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	for result.Next(ctx) {
+		var promotion models.Promotion
+		if err := result.Decode(&promotion); err != nil {
+			return nil, err
+		}
+		promotions = append(promotions, promotion)
+	}
+
+	return promotions, nil
+}
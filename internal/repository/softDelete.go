@@ -0,0 +1,17 @@
+package repository
+
+import "go.mongodb.org/mongo-driver/bson"
+
+// NotDeleted => add "not soft deleted" condition to optional filter, default queries use it
+func NotDeleted(filter ...bson.M) bson.M {
+	notDeleted := bson.M{"deletedAt": bson.M{"$exists": false}}
+	if len(filter) == 0 || len(filter[0]) == 0 {
+		return notDeleted
+	}
+	return bson.M{"$and": []bson.M{filter[0], notDeleted}}
+}
+
+// Deleted => filter of soft deleted document with id
+func Deleted(id string) bson.M {
+	return bson.M{"_id": id, "deletedAt": bson.M{"$exists": true}}
+}
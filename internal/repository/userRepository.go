@@ -22,9 +22,13 @@ func NewUserRepository(mongoCollection *mongo.Collection) IUserRepository {
 type IUserRepository interface {
 	GetAll() ([]models.User, error)
 	GetUserById(id string) (models.User, error)
+	GetUsersByIds(ids []string) ([]models.User, error)
 	Insert(user models.User) (bool, error)
 	Update(user models.User) (bool, error)
 	Delete(id string) (bool, error)
+	SoftDelete(id string, deletedAt time.Time) (bool, error)
+	Restore(id string) (bool, error)
+	PurgeDeleted(deletedBefore time.Time) (int64, error)
 }
 
 // GetAll Method => to list every user
@@ -37,7 +41,7 @@ func (b *UserRepository) GetAll() ([]models.User, error) {
 	defer cancel()
 
 	//We can think of "Cursor" like a request. We pull the data from the database with the "Next" command. (C# => IQueryable)
-	result, err := b.UserCollection.Find(ctx, bson.M{})
+	result, err := b.UserCollection.Find(ctx, NotDeleted())
 
 	if err != nil {
 		return nil, err
@@ -65,7 +69,7 @@ func (b *UserRepository) GetUserById(id string) (models.User, error) {
 	defer cancel()
 
 	// to find book by id
-	err := b.UserCollection.FindOne(ctx, bson.M{"_id": id}).Decode(&user)
+	err := b.UserCollection.FindOne(ctx, NotDeleted(bson.M{"_id": id})).Decode(&user)
 
 	if err != nil {
 		return user, err
@@ -74,6 +78,30 @@ func (b *UserRepository) GetUserById(id string) (models.User, error) {
 	return user, nil
 }
 
+// GetUsersByIds Method => users of ids, unknown or deleted ids are skipped
+func (b *UserRepository) GetUsersByIds(ids []string) ([]models.User, error) {
+	var users []models.User
+
+	// to open connection
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	result, err := b.UserCollection.Find(ctx, NotDeleted(bson.M{"_id": bson.M{"$in": ids}}))
+	if err != nil {
+		return nil, err
+	}
+
+	for result.Next(ctx) {
+		var user models.User
+		if err := result.Decode(&user); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	return users, nil
+}
+
 // Insert method => to create new user
 func (b *UserRepository) Insert(user models.User) (bool, error) {
 	// to open connection
@@ -98,7 +126,7 @@ func (b *UserRepository) Update(user models.User) (bool, error) {
 
 	// => Update => update + insert = upsert => default value false
 	// opt := options.Update().SetUpsert(true)
-	filter := bson.D{{"_id", user.ID}}
+	filter := NotDeleted(bson.M{"_id": user.ID})
 
 	// => if we use this CreatedDate and id value will be null, so we have to use "UpdateOne"
 	//replacement := models.Book{Title: book.Title, Quantity: book.Quantity, Author: book.Author, UpdatedDate: book.UpdatedDate}
@@ -138,3 +166,52 @@ func (b *UserRepository) Delete(id string) (bool, error) {
 
 	return true, nil
 }
+
+// SoftDelete Method => mark user as deleted, user document is kept
+func (b *UserRepository) SoftDelete(id string, deletedAt time.Time) (bool, error) {
+	// to open connection
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	filter := NotDeleted(bson.M{"_id": id})
+	update := bson.M{"$set": bson.M{"deletedAt": deletedAt, "updatedAt": deletedAt}}
+
+	result, err := b.UserCollection.UpdateOne(ctx, filter, update)
+
+	if err != nil || result.ModifiedCount <= 0 {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Restore Method => remove deletedAt of soft deleted user
+func (b *UserRepository) Restore(id string) (bool, error) {
+	// to open connection
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	update := bson.M{"$unset": bson.M{"deletedAt": ""}, "$set": bson.M{"updatedAt": time.Now()}}
+
+	result, err := b.UserCollection.UpdateOne(ctx, Deleted(id), update)
+
+	if err != nil || result.ModifiedCount <= 0 {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// PurgeDeleted Method => hard delete users which are soft deleted before the date
+func (b *UserRepository) PurgeDeleted(deletedBefore time.Time) (int64, error) {
+	// to open connection
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	result, err := b.UserCollection.DeleteMany(ctx, bson.M{"deletedAt": bson.M{"$lt": deletedBefore}})
+	if err != nil {
+		return 0, err
+	}
+
+	return result.DeletedCount, nil
+}
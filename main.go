@@ -13,8 +13,12 @@ func main() {
 		cmd.StartOrderAPI()
 	} else if project == "userAPI" {
 		cmd.StartUserAPI()
+	} else if project == "productAPI" {
+		cmd.StartProductAPI()
 	} else if project == "orderElastic" {
 		cmd.StartOrderElastic()
+	} else if project == "addressMigration" {
+		cmd.StartAddressMigration()
 	} else {
 		log.Fatal("Project cannot start!")
 	}
@@ -0,0 +1,73 @@
+package dataloader
+
+import (
+	"sync"
+)
+
+// BatchFunc => values of keys with one lookup, keys which are missing in result are resolved as nil
+type BatchFunc func(keys []string) (map[string]interface{}, error)
+
+// Loader => collects keys of Load calls and resolves all pending keys with one BatchFunc call when the first thunk is
+// called. Values are cached, so a loader must live for one request only (it is not a shared cache).
+type Loader struct {
+	batch   BatchFunc
+	mutex   sync.Mutex
+	pending []string
+	results map[string]*result
+}
+
+// result => value of a key, done is closed after batch of key is finished
+type result struct {
+	value interface{}
+	err   error
+	done  chan struct{}
+}
+
+func New(batch BatchFunc) *Loader {
+	return &Loader{batch: batch, results: map[string]*result{}}
+}
+
+// Load => thunk of value of key, batch runs when a thunk is called, so keys of thunks which are created before (e.g.
+// users of all orders in a list of GraphQL) are looked up together
+func (l *Loader) Load(key string) func() (interface{}, error) {
+	l.mutex.Lock()
+	r, ok := l.results[key]
+	if !ok {
+		r = &result{done: make(chan struct{})}
+		l.results[key] = r
+		l.pending = append(l.pending, key)
+	}
+	l.mutex.Unlock()
+
+	return func() (interface{}, error) {
+		l.dispatch()
+		<-r.done
+		return r.value, r.err
+	}
+}
+
+// dispatch => runs batch of pending keys, it does nothing if they are already dispatched
+func (l *Loader) dispatch() {
+	l.mutex.Lock()
+	keys := l.pending
+	l.pending = nil
+	batch := map[string]*result{}
+	for _, key := range keys {
+		batch[key] = l.results[key]
+	}
+	l.mutex.Unlock()
+
+	if len(keys) == 0 {
+		return
+	}
+
+	values, err := l.batch(keys)
+	for key, r := range batch {
+		if err != nil {
+			r.err = err
+		} else {
+			r.value = values[key]
+		}
+		close(r.done)
+	}
+}
@@ -0,0 +1,51 @@
+package dataloader
+
+import (
+	"errors"
+	"github.com/go-playground/assert/v2"
+	"testing"
+)
+
+func TestLoader_Load(t *testing.T) {
+	var batches [][]string
+	loader := New(func(keys []string) (map[string]interface{}, error) {
+		batches = append(batches, keys)
+		values := map[string]interface{}{}
+		for _, key := range keys {
+			if key != "unknown" {
+				values[key] = "value of " + key
+			}
+		}
+		return values, nil
+	})
+
+	// Keys of thunks are looked up together, duplicate keys once
+	thunks := []func() (interface{}, error){loader.Load("a"), loader.Load("b"), loader.Load("a"), loader.Load("unknown")}
+	var values []interface{}
+	for _, thunk := range thunks {
+		value, err := thunk()
+		assert.Equal(t, nil, err)
+		values = append(values, value)
+	}
+	assert.Equal(t, []interface{}{"value of a", "value of b", "value of a", nil}, values)
+	assert.Equal(t, [][]string{{"a", "b", "unknown"}}, batches)
+
+	// Cached keys are not looked up again
+	value, _ := loader.Load("b")()
+	assert.Equal(t, "value of b", value)
+	value, _ = loader.Load("c")()
+	assert.Equal(t, "value of c", value)
+	assert.Equal(t, [][]string{{"a", "b", "unknown"}, {"c"}}, batches)
+}
+
+func TestLoader_LoadError(t *testing.T) {
+	loader := New(func(keys []string) (map[string]interface{}, error) {
+		return nil, errors.New("user-api is down")
+	})
+
+	first, second := loader.Load("a"), loader.Load("b")
+	_, err := second()
+	assert.Equal(t, "user-api is down", err.Error())
+	_, err = first()
+	assert.Equal(t, "user-api is down", err.Error())
+}
@@ -0,0 +1,24 @@
+package pkg
+
+import (
+	"github.com/labstack/gommon/log"
+	"time"
+)
+
+// StartPeriodicJob => run job in every interval until the process stops (call it as goroutine). Errors are only logged,
+// job is tried again in next interval.
+func StartPeriodicJob(name string, interval time.Duration, job func() error) {
+	if interval <= 0 {
+		log.Infof("Periodic job (%v) is disabled.", name)
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := job(); err != nil {
+			log.Errorf("Periodic job (%v) failed: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package kafka
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+// AvroSerializer => value is encoded with avro binary encoding of the topic schema. Value is converted with json,
+// nullable (union) fields are written as plain json values instead of avro json ({"type": value}).
+type AvroSerializer struct {
+	// Encoder reads standard json, Decoder is the plain codec of the same schema.
+	// goavro's two-way standard json codec mislabels union branches while reading binary, so we don't use it.
+	Encoder *goavro.Codec
+	Decoder *goavro.Codec
+	schema  interface{}
+	names   map[string]interface{}
+}
+
+func NewAvroSerializer(schema string) (*AvroSerializer, error) {
+	encoder, err := goavro.NewCodecForStandardJSONOneWay(schema)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder, err := goavro.NewCodec(schema)
+	if err != nil {
+		return nil, err
+	}
+
+	var parsedSchema interface{}
+	if err := json.Unmarshal([]byte(schema), &parsedSchema); err != nil {
+		return nil, err
+	}
+
+	names := map[string]interface{}{}
+	collectAvroNames(parsedSchema, "", names)
+
+	return &AvroSerializer{Encoder: encoder, Decoder: decoder, schema: parsedSchema, names: names}, nil
+}
+
+func (a *AvroSerializer) ContentType() string {
+	return ContentTypeAvro
+}
+
+func (a *AvroSerializer) Marshal(v interface{}) ([]byte, error) {
+	textual, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	native, _, err := a.Encoder.NativeFromTextual(textual)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.Encoder.BinaryFromNative(nil, native)
+}
+
+func (a *AvroSerializer) Unmarshal(data []byte, v interface{}) error {
+	native, _, err := a.Decoder.NativeFromBinary(data)
+	if err != nil {
+		return err
+	}
+
+	return convertWithJSON(a.unwrapUnions(a.schema, native, ""), v)
+}
+
+// unwrapUnions => goavro gives union values as {"type name": value}, we need the value itself for standard json
+func (a *AvroSerializer) unwrapUnions(schema interface{}, value interface{}, namespace string) interface{} {
+	switch s := schema.(type) {
+	case []interface{}:
+		union, ok := value.(map[string]interface{})
+		if !ok || len(union) != 1 {
+			return value
+		}
+		for branchName, branchValue := range union {
+			for _, branch := range s {
+				if avroTypeName(branch, namespace) == branchName {
+					return a.unwrapUnions(branch, branchValue, namespace)
+				}
+			}
+		}
+		return value
+	case string:
+		if named, ok := a.names[avroFullName(s, namespace)]; ok {
+			return a.unwrapUnions(named, value, avroNamespace(avroFullName(s, namespace)))
+		}
+		return value
+	case map[string]interface{}:
+		switch s["type"] {
+		case "record", "error":
+			record, ok := value.(map[string]interface{})
+			if !ok {
+				return value
+			}
+			fullName := avroFullName(avroString(s, "name"), avroSchemaNamespace(s, namespace))
+			fields, _ := s["fields"].([]interface{})
+			for _, field := range fields {
+				fieldSchema, _ := field.(map[string]interface{})
+				fieldName := avroString(fieldSchema, "name")
+				if fieldValue, ok := record[fieldName]; ok {
+					record[fieldName] = a.unwrapUnions(fieldSchema["type"], fieldValue, avroNamespace(fullName))
+				}
+			}
+			return record
+		case "array":
+			items, ok := value.([]interface{})
+			if !ok {
+				return value
+			}
+			for i := range items {
+				items[i] = a.unwrapUnions(s["items"], items[i], namespace)
+			}
+			return items
+		case "map":
+			values, ok := value.(map[string]interface{})
+			if !ok {
+				return value
+			}
+			for key := range values {
+				values[key] = a.unwrapUnions(s["values"], values[key], namespace)
+			}
+			return values
+		case "enum", "fixed":
+			return value
+		default:
+			// Primitive type with attributes (e.g. logicalType) or nested type definition
+			return a.unwrapUnions(s["type"], value, namespace)
+		}
+	}
+	return value
+}
+
+// collectAvroNames => full names of named types (record, enum, fixed), so references can be resolved
+func collectAvroNames(schema interface{}, namespace string, names map[string]interface{}) {
+	switch s := schema.(type) {
+	case []interface{}:
+		for _, branch := range s {
+			collectAvroNames(branch, namespace, names)
+		}
+	case map[string]interface{}:
+		switch s["type"] {
+		case "record", "error", "enum", "fixed":
+			fullName := avroFullName(avroString(s, "name"), avroSchemaNamespace(s, namespace))
+			names[fullName] = s
+			fields, _ := s["fields"].([]interface{})
+			for _, field := range fields {
+				fieldSchema, _ := field.(map[string]interface{})
+				collectAvroNames(fieldSchema["type"], avroNamespace(fullName), names)
+			}
+		case "array":
+			collectAvroNames(s["items"], namespace, names)
+		case "map":
+			collectAvroNames(s["values"], namespace, names)
+		default:
+			collectAvroNames(s["type"], namespace, names)
+		}
+	}
+}
+
+// avroTypeName => name which goavro uses as key of union value
+func avroTypeName(schema interface{}, namespace string) string {
+	switch s := schema.(type) {
+	case string:
+		if isAvroPrimitive(s) {
+			return s
+		}
+		return avroFullName(s, namespace)
+	case map[string]interface{}:
+		switch s["type"] {
+		case "record", "error", "enum", "fixed":
+			return avroFullName(avroString(s, "name"), avroSchemaNamespace(s, namespace))
+		case "array", "map":
+			return avroString(s, "type")
+		default:
+			return avroTypeName(s["type"], namespace)
+		}
+	}
+	return ""
+}
+
+func isAvroPrimitive(name string) bool {
+	switch name {
+	case "null", "boolean", "int", "long", "float", "double", "bytes", "string":
+		return true
+	}
+	return false
+}
+
+func avroFullName(name string, namespace string) string {
+	if strings.Contains(name, ".") || namespace == "" {
+		return name
+	}
+	return namespace + "." + name
+}
+
+func avroNamespace(fullName string) string {
+	if index := strings.LastIndex(fullName, "."); index >= 0 {
+		return fullName[:index]
+	}
+	return ""
+}
+
+func avroSchemaNamespace(schema map[string]interface{}, enclosingNamespace string) string {
+	if namespace := avroString(schema, "namespace"); namespace != "" {
+		return namespace
+	}
+	return enclosingNamespace
+}
+
+func avroString(schema map[string]interface{}, key string) string {
+	value, _ := schema[key].(string)
+	return value
+}
@@ -1,7 +1,9 @@
 package kafka
 
 import (
+	"fmt"
 	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/google/uuid"
 	"github.com/labstack/gommon/log"
 	"time"
 )
@@ -9,12 +11,19 @@ import (
 type ConsumerKafka struct {
 	Consumer    *kafka.Consumer
 	LastMessage kafka.Message
+	// Serializers => deserializer of each content type, json is registered by default
+	Serializers map[string]Serializer
 }
 
 func NewConsumerKafka(kafkaURL string) *ConsumerKafka {
+	return NewConsumerKafkaWithGroup(kafkaURL, "myGroup")
+}
+
+// NewConsumerKafkaWithGroup => consumer with its own group, every service reads public topics with its own group
+func NewConsumerKafkaWithGroup(kafkaURL string, groupID string) *ConsumerKafka {
 	c, err := kafka.NewConsumer(&kafka.ConfigMap{
 		"bootstrap.servers": kafkaURL,
-		"group.id":          "myGroup",
+		"group.id":          groupID,
 		"auto.offset.reset": "earliest",
 	})
 	if err != nil {
@@ -23,9 +32,47 @@ func NewConsumerKafka(kafkaURL string) *ConsumerKafka {
 	return &ConsumerKafka{
 		Consumer:    c,
 		LastMessage: kafka.Message{},
+		Serializers: map[string]Serializer{
+			ContentTypeJSON: JSONSerializer{},
+		},
 	}
 }
 
+// NewBroadcastConsumerKafka => consumer with a new group of this process, so every instance of a service reads all
+// messages. It starts from the latest messages, older messages are not interesting for broadcasts.
+func NewBroadcastConsumerKafka(kafkaURL string, groupPrefix string) *ConsumerKafka {
+	c, err := kafka.NewConsumer(&kafka.ConfigMap{
+		"bootstrap.servers": kafkaURL,
+		"group.id":          groupPrefix + "-" + uuid.New().String(),
+		"auto.offset.reset": "latest",
+	})
+	if err != nil {
+		log.Errorf("Kafka consumer didn't work. Error:%v", err)
+	}
+	return &ConsumerKafka{
+		Consumer:    c,
+		LastMessage: kafka.Message{},
+		Serializers: map[string]Serializer{
+			ContentTypeJSON: JSONSerializer{},
+		},
+	}
+}
+
+// RegisterSerializer => add deserializer for a content type (avro serializer needs schema of topic)
+func (c *ConsumerKafka) RegisterSerializer(serializer Serializer) {
+	c.Serializers[serializer.ContentType()] = serializer
+}
+
+// Deserialize => decode message value with serializer of content-type header
+func (c *ConsumerKafka) Deserialize(message kafka.Message, v interface{}) error {
+	contentType := ContentTypeOf(message)
+	serializer, ok := c.Serializers[contentType]
+	if !ok {
+		return fmt.Errorf("there is no serializer for content type: %v", contentType)
+	}
+	return serializer.Unmarshal(message.Value, v)
+}
+
 func (c *ConsumerKafka) SubscribeToTopics(topics []string) error {
 	err := c.Consumer.SubscribeTopics(topics, nil)
 	return err
@@ -75,9 +122,42 @@ func (c *ConsumerKafka) ConsumeFromTopics(bulkConsumeIntervalInSeconds int64, bu
 
 func (c *ConsumerKafka) AckLastMessage() {
 	if &c.LastMessage != nil {
-		_, err := c.Consumer.CommitMessage(&c.LastMessage)
-		if err != nil {
-			log.Errorf("Ack Last message failed. | Error: %v\n", err)
+		c.AckMessage(c.LastMessage)
+	}
+}
+
+// AckMessage => message and messages before it in its partition are not read again
+func (c *ConsumerKafka) AckMessage(message kafka.Message) {
+	_, err := c.Consumer.CommitMessage(&message)
+	if err != nil {
+		log.Errorf("Ack message failed. | Error: %v\n", err)
+	}
+}
+
+// Rewind => messages are read again from the first of them in each partition. It is used instead of AckLastMessage
+// when a batch cannot handle, so messages after a failed message are not acked too.
+func (c *ConsumerKafka) Rewind(messages []kafka.Message) error {
+	type topicPartition struct {
+		topic     string
+		partition int32
+	}
+
+	first := map[topicPartition]kafka.TopicPartition{}
+	for _, message := range messages {
+		if message.TopicPartition.Topic == nil {
+			continue
+		}
+		key := topicPartition{topic: *message.TopicPartition.Topic, partition: message.TopicPartition.Partition}
+		if current, ok := first[key]; !ok || message.TopicPartition.Offset < current.Offset {
+			first[key] = message.TopicPartition
+		}
+	}
+
+	for _, partition := range first {
+		partition.Error = nil
+		if err := c.Consumer.Seek(partition, 0); err != nil {
+			return err
 		}
 	}
+	return nil
 }
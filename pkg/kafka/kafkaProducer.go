@@ -8,6 +8,8 @@ import (
 
 type ProducerKafka struct {
 	Producer *kafka.Producer
+	// Serializers => serializer of each topic for SendToKafkaWithValue, default is json
+	Serializers map[string]Serializer
 }
 
 func NewProducerKafka(kafkaHost string) *ProducerKafka {
@@ -18,11 +20,66 @@ func NewProducerKafka(kafkaHost string) *ProducerKafka {
 	}
 
 	return &ProducerKafka{
-		Producer: p,
+		Producer:    p,
+		Serializers: map[string]Serializer{},
 	}
 }
 
+// SetTopicSerializer => choose serializer (json or avro) of topic
+func (p *ProducerKafka) SetTopicSerializer(topic string, serializer Serializer) {
+	p.Serializers[topic] = serializer
+}
+
+// SendToKafkaWithValue => serialize value with serializer of topic and send it with content-type header
+func (p *ProducerKafka) SendToKafkaWithValue(value interface{}, topic string) error {
+	return p.SendToKafkaWithKey("", value, topic)
+}
+
+// SendToKafkaWithKey => same as SendToKafkaWithValue, messages with the same key go to the same partition (ordered)
+func (p *ProducerKafka) SendToKafkaWithKey(key string, value interface{}, topic string) error {
+	serializer, ok := p.Serializers[topic]
+	if !ok {
+		serializer = JSONSerializer{}
+	}
+
+	message, err := serializer.Marshal(value)
+	if err != nil {
+		log.Errorf("Message cannot serialize with %v: %v", serializer.ContentType(), err)
+		return err
+	}
+
+	headers := []kafka.Header{{Key: ContentTypeHeader, Value: []byte(serializer.ContentType())}}
+	return p.produce(message, key, topic, headers)
+}
+
 func (p *ProducerKafka) SendToKafkaWithMessage(message []byte, topic string) error {
+	return p.SendToKafkaWithHeaders(message, topic, nil)
+}
+
+func (p *ProducerKafka) SendToKafkaWithHeaders(message []byte, topic string, headers []kafka.Header) error {
+	return p.produce(message, "", topic, headers)
+}
+
+// Headers of dead letter messages, original headers of message are kept too
+const (
+	DeadLetterTopicHeader = "dead-letter-topic"
+	DeadLetterErrorHeader = "dead-letter-error"
+)
+
+// SendToDeadLetter => message which cannot handle is sent to dead letter topic with its key, value and headers. Topic
+// and error of message are added as headers, so it can be inspected and sent to its topic again.
+func (p *ProducerKafka) SendToDeadLetter(message kafka.Message, topic string, cause error) error {
+	headers := append([]kafka.Header{}, message.Headers...)
+	if message.TopicPartition.Topic != nil {
+		headers = append(headers, kafka.Header{Key: DeadLetterTopicHeader, Value: []byte(*message.TopicPartition.Topic)})
+	}
+	if cause != nil {
+		headers = append(headers, kafka.Header{Key: DeadLetterErrorHeader, Value: []byte(cause.Error())})
+	}
+	return p.produce(message.Value, string(message.Key), topic, headers)
+}
+
+func (p *ProducerKafka) produce(message []byte, key string, topic string, headers []kafka.Header) error {
 	// Delivery report handler for produced messages
 	go func() {
 		for e := range p.Producer.Events() {
@@ -49,11 +106,17 @@ func (p *ProducerKafka) SendToKafkaWithMessage(message []byte, topic string) err
 		Burada Key alanına "123" değeri atanmıştır. Bu, mesajın "123" değerine sahip partition key'e sahip olan bir partition'a yazılmasını sağlayacaktır. Partition key değeri, mesajın içeriğine göre değişebilir veya sabit bir değer olarak belirlenebilir.
 	*/
 
-	// Produce messages to topic
-	err := p.Producer.Produce(&kafka.Message{
+	// Produce messages to topic, without key partition is chosen randomly
+	kafkaMessage := &kafka.Message{
 		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
 		Value:          message,
-	}, nil)
+		Headers:        headers,
+	}
+	if key != "" {
+		kafkaMessage.Key = []byte(key)
+	}
+
+	err := p.Producer.Produce(kafkaMessage, nil)
 	if err != nil {
 		log.Errorf("Something went wrong: %v", err)
 		return err
@@ -0,0 +1,86 @@
+package kafka
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// ContentTypeHeader => every message has this header, so consumer knows how to deserialize value
+const ContentTypeHeader = "content-type"
+
+// Content types of serializers
+const (
+	ContentTypeJSON = "application/json"
+	ContentTypeAvro = "application/avro"
+)
+
+// Serialization formats in config (configs.Config.Kafka.Serialization)
+const (
+	FormatJSON = "json"
+	FormatAvro = "avro"
+)
+
+// Serializer => converts message value to bytes and back
+type Serializer interface {
+	ContentType() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// NewSerializer => create serializer for format in config, avro needs schema of topic. Empty format is json.
+func NewSerializer(format string, avroSchema string) (Serializer, error) {
+	switch format {
+	case "", FormatJSON:
+		return JSONSerializer{}, nil
+	case FormatAvro:
+		return NewAvroSerializer(avroSchema)
+	default:
+		return nil, fmt.Errorf("unknown serialization format: %v", format)
+	}
+}
+
+// ContentTypeOfFormat => content type of messages which are serialized with format in config, empty format is json
+func ContentTypeOfFormat(format string) (string, error) {
+	switch format {
+	case "", FormatJSON:
+		return ContentTypeJSON, nil
+	case FormatAvro:
+		return ContentTypeAvro, nil
+	default:
+		return "", fmt.Errorf("unknown serialization format: %v", format)
+	}
+}
+
+// JSONSerializer => default serializer, messages without content-type header are json too
+type JSONSerializer struct{}
+
+func (JSONSerializer) ContentType() string {
+	return ContentTypeJSON
+}
+
+func (JSONSerializer) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONSerializer) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// ContentTypeOf => content-type header of message, messages produced before headers are json
+func ContentTypeOf(message kafka.Message) string {
+	for _, header := range message.Headers {
+		if header.Key == ContentTypeHeader {
+			return string(header.Value)
+		}
+	}
+	return ContentTypeJSON
+}
+
+func convertWithJSON(from interface{}, to interface{}) error {
+	data, err := json.Marshal(from)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, to)
+}
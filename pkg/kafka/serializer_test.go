@@ -0,0 +1,94 @@
+package kafka
+
+import (
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/go-playground/assert/v2"
+	"testing"
+)
+
+type testMessage struct {
+	ID      string   `json:"id"`
+	Count   int      `json:"count"`
+	Tags    []string `json:"tags"`
+	Comment *string  `json:"comment"`
+}
+
+var testAvroSchema = `{
+	"type": "record",
+	"name": "TestMessage",
+	"namespace": "OrderUserProject.test",
+	"fields": [
+		{"name": "id", "type": "string"},
+		{"name": "count", "type": "long"},
+		{"name": "tags", "type": ["null", {"type": "array", "items": "string"}], "default": null},
+		{"name": "comment", "type": ["null", "string"], "default": null}
+	]
+}`
+
+func TestSerializer_MarshalUnmarshal(t *testing.T) {
+	comment := "fragile"
+	messages := map[string]testMessage{
+		"with-values": {ID: "2b45ac31-6906-4e1e-82db-d9bcdbdb2143", Count: 3, Tags: []string{"a", "b"}, Comment: &comment},
+		"with-nulls":  {ID: "41840818-6f62-4378-a82a-e6badf225bcc", Count: 1},
+	}
+
+	for _, format := range []string{FormatJSON, FormatAvro} {
+		serializer, err := NewSerializer(format, testAvroSchema)
+		if err != nil {
+			t.Fatalf("%v: %v", format, err)
+		}
+
+		for name, message := range messages {
+			data, err := serializer.Marshal(message)
+			if err != nil {
+				t.Fatalf("%v/%v: %v", format, name, err)
+			}
+
+			var result testMessage
+			if err := serializer.Unmarshal(data, &result); err != nil {
+				t.Fatalf("%v/%v: %v", format, name, err)
+			}
+			assert.Equal(t, message, result)
+		}
+	}
+
+	for _, format := range []string{"xml", "protobuf"} {
+		if _, err := NewSerializer(format, ""); err == nil {
+			t.Errorf("Expected error for unknown serialization format: %v", format)
+		}
+	}
+}
+
+func TestConsumerKafka_Deserialize_WithContentType(t *testing.T) {
+	avroSerializer, err := NewAvroSerializer(testAvroSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	consumer := &ConsumerKafka{Serializers: map[string]Serializer{
+		ContentTypeJSON: JSONSerializer{},
+	}}
+	consumer.RegisterSerializer(avroSerializer)
+
+	message := testMessage{ID: "2b45ac31-6906-4e1e-82db-d9bcdbdb2143", Count: 2}
+	for _, serializer := range []Serializer{JSONSerializer{}, avroSerializer} {
+		value, _ := serializer.Marshal(message)
+		kafkaMessage := kafka.Message{
+			Value:   value,
+			Headers: []kafka.Header{{Key: ContentTypeHeader, Value: []byte(serializer.ContentType())}},
+		}
+
+		var result testMessage
+		if err := consumer.Deserialize(kafkaMessage, &result); err != nil {
+			t.Fatalf("%v: %v", serializer.ContentType(), err)
+		}
+		assert.Equal(t, message, result)
+	}
+
+	// Messages without header are json (produced before content-type header)
+	var result testMessage
+	if err := consumer.Deserialize(kafka.Message{Value: []byte(`{"id":"1","count":5}`)}, &result); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 5, result.Count)
+}
@@ -2,6 +2,7 @@ package pkg
 
 import (
 	"OrderUserProject/internal/apps/order-api"
+	"crypto/subtle"
 	"fmt"
 	"net/http"
 	"reflect"
@@ -54,7 +55,35 @@ func Logger() echo.MiddlewareFunc {
 	}
 }
 
-// CheckOrderStatus => Middleware: Status Check using Reflection for Update and Post method (Learning Reflection!)
+// AdminTokenHeader => requests of admin endpoints (force delete, restore etc.) carry admin token with this header
+const AdminTokenHeader = "X-Admin-Token"
+
+// IsAdmin => request has the admin token of config. If there is no token in config nobody is admin.
+func IsAdmin(c echo.Context, adminToken string) bool {
+	if adminToken == "" {
+		return false
+	}
+	requestToken := c.Request().Header.Get(AdminTokenHeader)
+	return subtle.ConstantTimeCompare([]byte(requestToken), []byte(adminToken)) == 1
+}
+
+// AdminOnly => Middleware: only requests with admin token can pass
+func AdminOnly(adminToken string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !IsAdmin(c, adminToken) {
+				return CustomError{
+					Message:    "Forbidden. This endpoint is only for admin!",
+					StatusCode: http.StatusForbidden,
+				}
+			}
+			return next(c)
+		}
+	}
+}
+
+// CheckOrderStatus => Middleware: Status Check using Reflection for Update and Post method (Learning Reflection!). Status
+// is derived from shipments, so requests with status are rejected.
 func CheckOrderStatus(next echo.HandlerFunc) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		method := c.Request().Method
@@ -86,30 +115,19 @@ func CheckOrderStatus(next echo.HandlerFunc) echo.HandlerFunc {
 		// It is not necessary, it made to learn reflection
 		// Checking if it is assignable to the order model
 		if reflect.TypeOf(order).AssignableTo(orderType) {
-
-			statusList := []string{
-				"Shipped",
-				"Not Shipped",
-				"Delivered",
-				"Not Delivered",
-				"Canceled",
-				"Closed",
-			}
-
 			// If "order" represents the "pointer" then "Elem()" is used to reach the target value of "pointer"
 			orderValue := reflect.ValueOf(order).Elem()
 			orderStatusValue := orderValue.FieldByName("Status").String()
-			for _, status := range statusList {
-				if orderStatusValue == status {
-					// To reach value of order we can c.Set and c.Get.Otherwise we cannot bind context twice
-					c.Set("order", order)
-					return next(c)
-				}
+			// Status of order is derived from its shipments, it cannot be set by client
+			if orderStatusValue != "" {
+				return echo.NewHTTPError(http.StatusBadRequest, CustomError{
+					Message:    "Status of order is derived from its shipments, please don't send status!",
+					StatusCode: http.StatusBadRequest,
+				})
 			}
-			return echo.NewHTTPError(http.StatusBadRequest, CustomError{
-				Message:    "Please write a valid status value!",
-				StatusCode: http.StatusBadRequest,
-			})
+			// To reach value of order we can c.Set and c.Get.Otherwise we cannot bind context twice
+			c.Set("order", order)
+			return next(c)
 		}
 
 		return echo.NewHTTPError(http.StatusBadRequest, CustomError{
@@ -0,0 +1,278 @@
+package money
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Scale => fraction digits of amounts (kuruş, cent). Amounts are kept as integer minor units, so sums are exact.
+const Scale = 2
+
+const minorPerUnit = 100
+
+// ErrInvalidAmount => amount is not a decimal number, has more fraction digits than Scale or is out of range
+var ErrInvalidAmount = errors.New("invalid money amount")
+
+// Amount => decimal amount of money with Scale fraction digits. It is written to json as number (e.g. 199.90) and
+// to MongoDB as Decimal128. Currency is not part of amount, documents keep one currency code for their amounts.
+type Amount struct {
+	minor int64
+}
+
+// FromMinor => amount of minor units, FromMinor(19990) is 199.90
+func FromMinor(minor int64) Amount {
+	return Amount{minor: minor}
+}
+
+// FromUnits => amount of whole units, FromUnits(200) is 200.00
+func FromUnits(units int64) Amount {
+	return Amount{minor: units * minorPerUnit}
+}
+
+// FromFloat => amount of float value which is rounded half away from zero to Scale. It is only used for legacy
+// values (e.g. totals which were saved as double), new amounts are parsed from their decimal text.
+func FromFloat(value float64) Amount {
+	return Amount{minor: int64(math.Round(value * minorPerUnit))}
+}
+
+// Parse => amount of decimal text like "199.9", "199.90" or "1.999e2". Text with more fraction digits than Scale
+// is rejected instead of rounded, so no amount is changed silently.
+func Parse(value string) (Amount, error) {
+	minor, err := parseScaled(value, minorPerUnit)
+	if err != nil {
+		return Amount{}, fmt.Errorf("%w: %q", ErrInvalidAmount, value)
+	}
+	return Amount{minor: minor}, nil
+}
+
+// MustParse => like Parse but panics, it is used for constants and tests
+func MustParse(value string) Amount {
+	amount, err := Parse(value)
+	if err != nil {
+		panic(err)
+	}
+	return amount
+}
+
+// Minor => amount in minor units
+func (a Amount) Minor() int64 {
+	return a.minor
+}
+
+// Float64 => nearest float value, it is only used where a float is required (e.g. graphQL Float)
+func (a Amount) Float64() float64 {
+	return float64(a.minor) / minorPerUnit
+}
+
+// String => decimal text with Scale fraction digits, e.g. "199.90"
+func (a Amount) String() string {
+	sign := ""
+	minor := a.minor
+	if minor < 0 {
+		sign = "-"
+		minor = -minor
+	}
+	return fmt.Sprintf("%v%d.%02d", sign, minor/minorPerUnit, minor%minorPerUnit)
+}
+
+func (a Amount) Add(b Amount) Amount {
+	return Amount{minor: a.minor + b.minor}
+}
+
+func (a Amount) Sub(b Amount) Amount {
+	return Amount{minor: a.minor - b.minor}
+}
+
+// Mul => amount of quantity, e.g. line amount of unit price
+func (a Amount) Mul(quantity int) Amount {
+	return Amount{minor: a.minor * int64(quantity)}
+}
+
+// Neg => amount with opposite sign
+func (a Amount) Neg() Amount {
+	return Amount{minor: -a.minor}
+}
+
+// Cmp => -1 if a < b, 0 if a == b, +1 if a > b
+func (a Amount) Cmp(b Amount) int {
+	switch {
+	case a.minor < b.minor:
+		return -1
+	case a.minor > b.minor:
+		return 1
+	}
+	return 0
+}
+
+func (a Amount) IsZero() bool {
+	return a.minor == 0
+}
+
+func (a Amount) IsNegative() bool {
+	return a.minor < 0
+}
+
+func (a Amount) IsPositive() bool {
+	return a.minor > 0
+}
+
+// Min => smaller one of amounts
+func Min(a Amount, b Amount) Amount {
+	if a.minor < b.minor {
+		return a
+	}
+	return b
+}
+
+// Sum => sum of amounts
+func Sum(amounts ...Amount) Amount {
+	var sum Amount
+	for _, amount := range amounts {
+		sum.minor += amount.minor
+	}
+	return sum
+}
+
+// Allocate => split amount to parts which are proportional to weights (e.g. order discount to order lines). Parts
+// are rounded down and remaining minor units are given to parts with the largest remainder (first part on tie),
+// so sum of parts is always the amount. Every part is zero if sum of weights is zero.
+func (a Amount) Allocate(weights []Amount) []Amount {
+	parts := make([]Amount, len(weights))
+
+	total := big.NewInt(0)
+	for _, weight := range weights {
+		total.Add(total, big.NewInt(weight.minor))
+	}
+	if total.Sign() <= 0 {
+		return parts
+	}
+
+	sign := int64(1)
+	amount := a.minor
+	if amount < 0 {
+		sign, amount = -1, -amount
+	}
+
+	remainders := make([]*big.Int, len(weights))
+	allocated := int64(0)
+	for i, weight := range weights {
+		quotient, remainder := new(big.Int).QuoRem(
+			new(big.Int).Mul(big.NewInt(amount), big.NewInt(weight.minor)), total, new(big.Int))
+		parts[i].minor = quotient.Int64()
+		remainders[i] = remainder
+		allocated += parts[i].minor
+	}
+
+	for left := amount - allocated; left > 0; left-- {
+		largest := 0
+		for i := range remainders {
+			if remainders[i].Cmp(remainders[largest]) > 0 {
+				largest = i
+			}
+		}
+		parts[largest].minor++
+		remainders[largest].SetInt64(-1)
+	}
+
+	for i := range parts {
+		parts[i].minor *= sign
+	}
+	return parts
+}
+
+// MarshalJSON => amount as json number with Scale fraction digits
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalJSON => amount from json number or string, null is zero
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if string(data) == "null" {
+		*a = Amount{}
+		return nil
+	}
+
+	value := string(data)
+	if strings.HasPrefix(value, `"`) {
+		if err := json.Unmarshal(data, &value); err != nil {
+			return err
+		}
+	}
+
+	amount, err := Parse(value)
+	if err != nil {
+		return err
+	}
+	*a = amount
+	return nil
+}
+
+// MarshalBSONValue => amount as Decimal128, so MongoDB keeps exact value and compares it with other numbers
+func (a Amount) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	decimal, err := primitive.ParseDecimal128(a.String())
+	if err != nil {
+		return 0, nil, err
+	}
+	return bson.MarshalValue(decimal)
+}
+
+// UnmarshalBSONValue => amount from Decimal128, legacy documents have double or integer values
+func (a *Amount) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	value := bson.RawValue{Type: t, Value: data}
+
+	switch t {
+	case bsontype.Decimal128:
+		amount, err := Parse(value.Decimal128().String())
+		if err != nil {
+			return err
+		}
+		*a = amount
+	case bsontype.Double:
+		*a = FromFloat(value.Double())
+	case bsontype.Int32:
+		*a = FromUnits(int64(value.Int32()))
+	case bsontype.Int64:
+		*a = FromUnits(value.Int64())
+	case bsontype.Null, bsontype.Undefined:
+		*a = Amount{}
+	default:
+		return fmt.Errorf("%w: bson type %v", ErrInvalidAmount, t)
+	}
+	return nil
+}
+
+// parseScaled => value * scale as integer, value must be exact at scale
+func parseScaled(value string, scale int64) (int64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" || len(value) > 64 || strings.ContainsAny(value, "/") {
+		return 0, ErrInvalidAmount
+	}
+	// Big exponents (e.g. "1e999999999") would allocate huge numbers before the range check
+	if index := strings.IndexAny(value, "eE"); index >= 0 {
+		exponent, err := strconv.Atoi(value[index+1:])
+		if err != nil || exponent > 30 || exponent < -30 {
+			return 0, ErrInvalidAmount
+		}
+	}
+
+	number, ok := new(big.Rat).SetString(value)
+	if !ok {
+		return 0, ErrInvalidAmount
+	}
+
+	number.Mul(number, new(big.Rat).SetInt64(scale))
+	if !number.IsInt() || !number.Num().IsInt64() {
+		return 0, ErrInvalidAmount
+	}
+	return number.Num().Int64(), nil
+}
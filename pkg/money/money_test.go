@@ -0,0 +1,155 @@
+package money
+
+import (
+	"encoding/json"
+	"github.com/go-playground/assert/v2"
+	"github.com/go-playground/validator/v10"
+	"go.mongodb.org/mongo-driver/bson"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		minor int64
+		err   bool
+	}{
+		{name: "integer", value: "200", minor: 20000},
+		{name: "one fraction digit", value: "199.9", minor: 19990},
+		{name: "two fraction digits", value: "0.10", minor: 10},
+		{name: "negative", value: "-12.05", minor: -1205},
+		{name: "exponent", value: "1.999e2", minor: 19990},
+		{name: "trailing zeros", value: "1.2300", minor: 123},
+		{name: "more fraction digits", value: "0.105", err: true},
+		{name: "text", value: "ten", err: true},
+		{name: "empty", value: "", err: true},
+		{name: "huge exponent", value: "1e999999999", err: true},
+		{name: "out of range", value: "92233720368547758.08", err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			amount, err := Parse(test.value)
+			assert.Equal(t, test.err, err != nil)
+			assert.Equal(t, test.minor, amount.Minor())
+		})
+	}
+}
+
+func TestAmount_FloatSumIsExact(t *testing.T) {
+	// 0.1 + 0.2 is 0.30000000000000004 with float64
+	sum := Sum(MustParse("0.1"), MustParse("0.2"))
+
+	assert.Equal(t, "0.30", sum.String())
+	assert.Equal(t, MustParse("0.3"), sum)
+}
+
+func TestAmount_Allocate(t *testing.T) {
+	tests := []struct {
+		name    string
+		amount  Amount
+		weights []Amount
+		parts   []Amount
+	}{
+		{name: "even", amount: MustParse("10"), weights: []Amount{MustParse("50"), MustParse("50")},
+			parts: []Amount{MustParse("5"), MustParse("5")}},
+		{name: "remainder to largest remainder", amount: MustParse("10"),
+			weights: []Amount{MustParse("1"), MustParse("1"), MustParse("1")},
+			parts:   []Amount{MustParse("3.34"), MustParse("3.33"), MustParse("3.33")}},
+		{name: "proportional", amount: MustParse("30"), weights: []Amount{MustParse("200"), MustParse("100")},
+			parts: []Amount{MustParse("20"), MustParse("10")}},
+		{name: "negative", amount: MustParse("-0.05"), weights: []Amount{MustParse("1"), MustParse("1")},
+			parts: []Amount{MustParse("-0.03"), MustParse("-0.02")}},
+		{name: "zero weights", amount: MustParse("5"), weights: []Amount{{}, {}},
+			parts: []Amount{{}, {}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.parts, test.amount.Allocate(test.weights))
+		})
+	}
+}
+
+func TestRate_Of(t *testing.T) {
+	tests := []struct {
+		name   string
+		rate   Rate
+		amount Amount
+		result Amount
+	}{
+		{name: "standard", rate: MustParseRate("20"), amount: MustParse("199.90"), result: MustParse("39.98")},
+		{name: "half up", rate: MustParseRate("10"), amount: MustParse("0.05"), result: MustParse("0.01")},
+		{name: "round down", rate: MustParseRate("1"), amount: MustParse("0.49"), result: MustParse("0")},
+		{name: "fraction rate", rate: MustParseRate("0.5"), amount: MustParse("1000"), result: MustParse("5")},
+		{name: "negative half away from zero", rate: MustParseRate("10"), amount: MustParse("-0.05"), result: MustParse("-0.01")},
+		{name: "zero rate", rate: Rate{}, amount: MustParse("100"), result: Amount{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.result, test.rate.Of(test.amount))
+		})
+	}
+}
+
+func TestAmount_JSON(t *testing.T) {
+	var value struct {
+		Price Amount `json:"price"`
+		Total Amount `json:"total"`
+		Tax   Rate   `json:"tax"`
+	}
+
+	err := json.Unmarshal([]byte(`{"price": 199.9, "total": "20.05", "tax": 18}`), &value)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, MustParse("199.90"), value.Price)
+	assert.Equal(t, MustParse("20.05"), value.Total)
+	assert.Equal(t, MustParseRate("18"), value.Tax)
+
+	data, err := json.Marshal(value)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, `{"price":199.90,"total":20.05,"tax":18}`, string(data))
+
+	// Price with more fraction digits cannot be stored exactly
+	err = json.Unmarshal([]byte(`{"price": 0.001}`), &value)
+	assert.NotEqual(t, nil, err)
+}
+
+func TestAmount_BSON(t *testing.T) {
+	type document struct {
+		Total Amount `bson:"total"`
+		Rate  Rate   `bson:"rate"`
+	}
+
+	data, err := bson.Marshal(document{Total: MustParse("1800.50"), Rate: MustParseRate("20")})
+	assert.Equal(t, nil, err)
+
+	// Amount is stored as Decimal128
+	var raw bson.Raw = data
+	assert.Equal(t, "1800.50", raw.Lookup("total").Decimal128().String())
+
+	var decoded document
+	assert.Equal(t, nil, bson.Unmarshal(data, &decoded))
+	assert.Equal(t, MustParse("1800.50"), decoded.Total)
+	assert.Equal(t, MustParseRate("20"), decoded.Rate)
+
+	// Legacy documents have double totals
+	legacy, _ := bson.Marshal(bson.M{"total": 24000.0, "rate": int32(8)})
+	assert.Equal(t, nil, bson.Unmarshal(legacy, &decoded))
+	assert.Equal(t, MustParse("24000"), decoded.Total)
+	assert.Equal(t, MustParseRate("8"), decoded.Rate)
+}
+
+func TestRegisterValidation(t *testing.T) {
+	v := validator.New()
+	RegisterValidation(v)
+
+	type request struct {
+		Price Amount `validate:"required,gt=0"`
+	}
+
+	assert.Equal(t, nil, v.Struct(request{Price: MustParse("0.01")}))
+	assert.NotEqual(t, nil, v.Struct(request{}))
+	assert.NotEqual(t, nil, v.Struct(request{Price: MustParse("-1")}))
+}
@@ -0,0 +1,142 @@
+package money
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"math/big"
+	"strings"
+)
+
+const basisPointsPerPercent = 100
+
+// ErrInvalidRate => rate is not a decimal percent with at most 2 fraction digits or it is negative
+var ErrInvalidRate = errors.New("invalid rate")
+
+// Rate => percent rate with 2 fraction digits (e.g. VAT 20 or 0.5), it is kept as basis points. It is written to
+// json as number of percent and to MongoDB as Decimal128.
+type Rate struct {
+	basisPoints int64
+}
+
+// ParseRate => rate of percent text like "20", "8" or "0.5"
+func ParseRate(percent string) (Rate, error) {
+	basisPoints, err := parseScaled(percent, basisPointsPerPercent)
+	if err != nil || basisPoints < 0 {
+		return Rate{}, fmt.Errorf("%w: %q", ErrInvalidRate, percent)
+	}
+	return Rate{basisPoints: basisPoints}, nil
+}
+
+// MustParseRate => like ParseRate but panics, it is used for configs and tests
+func MustParseRate(percent string) Rate {
+	rate, err := ParseRate(percent)
+	if err != nil {
+		panic(err)
+	}
+	return rate
+}
+
+// BasisPoints => rate in hundredths of percent, 20% is 2000
+func (r Rate) BasisPoints() int64 {
+	return r.basisPoints
+}
+
+func (r Rate) IsZero() bool {
+	return r.basisPoints == 0
+}
+
+// String => percent without trailing zeros, e.g. "20" or "0.5"
+func (r Rate) String() string {
+	text := fmt.Sprintf("%d.%02d", r.basisPoints/basisPointsPerPercent, r.basisPoints%basisPointsPerPercent)
+	return strings.TrimSuffix(strings.TrimRight(text, "0"), ".")
+}
+
+// Of => rate of amount (e.g. tax of line), it is rounded half away from zero to Scale
+func (r Rate) Of(amount Amount) Amount {
+	const denominator = 100 * basisPointsPerPercent
+
+	product := new(big.Int).Mul(big.NewInt(amount.minor), big.NewInt(r.basisPoints))
+	quotient, remainder := new(big.Int).QuoRem(product, big.NewInt(denominator), new(big.Int))
+
+	// |remainder| >= denominator/2 => round away from zero
+	if new(big.Int).Abs(remainder).Int64()*2 >= denominator {
+		if product.Sign() < 0 {
+			quotient.Sub(quotient, big.NewInt(1))
+		} else {
+			quotient.Add(quotient, big.NewInt(1))
+		}
+	}
+
+	return Amount{minor: quotient.Int64()}
+}
+
+// MarshalJSON => rate as json number of percent
+func (r Rate) MarshalJSON() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// UnmarshalJSON => rate from json number or string of percent, null is zero
+func (r *Rate) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if string(data) == "null" {
+		*r = Rate{}
+		return nil
+	}
+
+	value := string(data)
+	if strings.HasPrefix(value, `"`) {
+		if err := json.Unmarshal(data, &value); err != nil {
+			return err
+		}
+	}
+
+	rate, err := ParseRate(value)
+	if err != nil {
+		return err
+	}
+	*r = rate
+	return nil
+}
+
+// MarshalBSONValue => rate as Decimal128 of percent
+func (r Rate) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	decimal, err := primitive.ParseDecimal128(r.String())
+	if err != nil {
+		return 0, nil, err
+	}
+	return bson.MarshalValue(decimal)
+}
+
+// UnmarshalBSONValue => rate from Decimal128, double or integer percent
+func (r *Rate) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	value := bson.RawValue{Type: t, Value: data}
+
+	var text string
+	switch t {
+	case bsontype.Decimal128:
+		text = value.Decimal128().String()
+	case bsontype.Double:
+		text = fmt.Sprintf("%.2f", value.Double())
+	case bsontype.Int32:
+		text = fmt.Sprint(value.Int32())
+	case bsontype.Int64:
+		text = fmt.Sprint(value.Int64())
+	case bsontype.Null, bsontype.Undefined:
+		*r = Rate{}
+		return nil
+	default:
+		return fmt.Errorf("%w: bson type %v", ErrInvalidRate, t)
+	}
+
+	rate, err := ParseRate(text)
+	if err != nil {
+		return err
+	}
+	*r = rate
+	return nil
+}
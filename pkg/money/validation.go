@@ -0,0 +1,24 @@
+package money
+
+import (
+	"github.com/go-playground/validator/v10"
+	"reflect"
+)
+
+// RegisterValidation => validator checks amounts and rates as their integer value (minor units, basis points),
+// so numeric tags like "required,gt=0" work on request fields of money types
+func RegisterValidation(v *validator.Validate) {
+	v.RegisterCustomTypeFunc(func(field reflect.Value) interface{} {
+		if amount, ok := field.Interface().(Amount); ok {
+			return amount.Minor()
+		}
+		return nil
+	}, Amount{})
+
+	v.RegisterCustomTypeFunc(func(field reflect.Value) interface{} {
+		if rate, ok := field.Interface().(Rate); ok {
+			return rate.BasisPoints()
+		}
+		return nil
+	}, Rate{})
+}
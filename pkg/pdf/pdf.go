@@ -0,0 +1,108 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Page layout of documents, A4 in points (1/72 inch) with monospaced Courier font, so columns of text line up
+const (
+	pageWidth    = 595
+	pageHeight   = 842
+	margin       = 40
+	fontSize     = 9
+	lineHeight   = 12
+	linesPerPage = (pageHeight - 2*margin) / lineHeight
+)
+
+// transliteration => letters which are not in WinAnsiEncoding of standard fonts (e.g. Turkish ğ, ş, ı) are written
+// with their closest latin letter, standard fonts don't need embedding
+var transliteration = map[rune]string{
+	'ğ': "g", 'Ğ': "G", 'ş': "s", 'Ş': "S", 'ı': "i", 'İ': "I",
+	'€': "\x80", '–': "-", '—': "-", '‘': "'", '’': "'", '“': "\"", '”': "\"", '•': "\x95",
+}
+
+// FromText => PDF document of text lines with monospaced font, lines which don't fit to a page continue on next page.
+// Lines starting with "#" are written with bold font (without "#"). Title is the title of document information.
+func FromText(title string, lines []string) []byte {
+	var pages [][]string
+	for start := 0; start < len(lines) || start == 0; start += linesPerPage {
+		end := start + linesPerPage
+		if end > len(lines) {
+			end = len(lines)
+		}
+		pages = append(pages, lines[start:end])
+	}
+
+	// Objects: 1 catalog, 2 pages, 3 regular font, 4 bold font, 5 info, then content and page object of every page
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Courier-Bold /Encoding /WinAnsiEncoding >>",
+		fmt.Sprintf("<< /Title (%v) /Producer (OrderUserProject) >>", escape(title)),
+	}
+
+	var kids []string
+	for _, page := range pages {
+		content := pageContent(page)
+		objects = append(objects, fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content))
+		contentRef := len(objects)
+		objects = append(objects, fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Contents %d 0 R "+
+			"/Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> >>", pageWidth, pageHeight, contentRef))
+		kids = append(kids, fmt.Sprintf("%d 0 R", len(objects)))
+	}
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%v] /Count %d >>", strings.Join(kids, " "), len(kids))
+
+	var buffer bytes.Buffer
+	buffer.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = buffer.Len()
+		fmt.Fprintf(&buffer, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+
+	xref := buffer.Len()
+	fmt.Fprintf(&buffer, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buffer, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buffer, "trailer\n<< /Size %d /Root 1 0 R /Info 5 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	return buffer.Bytes()
+}
+
+// pageContent => content stream which writes lines from top of page
+func pageContent(lines []string) string {
+	var content strings.Builder
+	fmt.Fprintf(&content, "BT\n%d TL\n%d %d Td\n", lineHeight, margin, pageHeight-margin)
+	for _, line := range lines {
+		font := "F1"
+		if strings.HasPrefix(line, "#") {
+			font, line = "F2", strings.TrimPrefix(line, "#")
+		}
+		fmt.Fprintf(&content, "/%v %d Tf\n(%v) Tj\nT*\n", font, fontSize, escape(line))
+	}
+	content.WriteString("ET")
+	return content.String()
+}
+
+// escape => text as WinAnsi bytes of PDF string, parentheses and backslash are escaped
+func escape(text string) string {
+	var escaped strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			escaped.WriteByte('\\')
+			escaped.WriteRune(r)
+		case transliteration[r] != "":
+			escaped.WriteString(transliteration[r])
+		case r < 0x80 || (r >= 0xA0 && r <= 0xFF):
+			escaped.WriteByte(byte(r))
+		default:
+			escaped.WriteByte('?')
+		}
+	}
+	return escaped.String()
+}
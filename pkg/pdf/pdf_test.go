@@ -0,0 +1,61 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/go-playground/assert/v2"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+func TestFromText(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines int
+		pages int
+	}{
+		{name: "empty", lines: 0, pages: 1},
+		{name: "one page", lines: linesPerPage, pages: 1},
+		{name: "two pages", lines: linesPerPage + 1, pages: 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var lines []string
+			for i := 0; i < test.lines; i++ {
+				lines = append(lines, fmt.Sprintf("line %d", i))
+			}
+
+			document := FromText("Invoice", lines)
+
+			assert.Equal(t, true, bytes.HasPrefix(document, []byte("%PDF-1.4\n")))
+			assert.Equal(t, true, bytes.HasSuffix(document, []byte("%%EOF\n")))
+			assert.Equal(t, fmt.Sprintf("/Count %d", test.pages), string(regexp.MustCompile(`/Count \d+`).Find(document)))
+
+			// Every offset of cross reference table points to its object
+			xref := regexp.MustCompile(`startxref\n(\d+)`).FindSubmatch(document)
+			start, _ := strconv.Atoi(string(xref[1]))
+			offsets := regexp.MustCompile(`(\d{10}) 00000 n`).FindAllSubmatch(document[start:], -1)
+			for i, offset := range offsets {
+				position, _ := strconv.Atoi(string(offset[1]))
+				assert.Equal(t, true, bytes.HasPrefix(document[position:], []byte(fmt.Sprintf("%d 0 obj", i+1))))
+			}
+		})
+	}
+}
+
+func TestEscape(t *testing.T) {
+	tests := map[string]string{
+		"Total (TRY)":     `Total \(TRY\)`,
+		`C:\invoices`:     `C:\\invoices`,
+		"Şişli, İstanbul": "Sisli, Istanbul",
+		"Kadıköy Ürün":    "Kadik\xf6y \xdcr\xfcn",
+		"€ 5":             "\x80 5",
+		"日本":              "??",
+	}
+
+	for text, expected := range tests {
+		assert.Equal(t, expected, escape(text))
+	}
+}